@@ -1,3 +1,7 @@
+# 构建信息（启动日志、/admin/version、健康检查和指标标签），VERSION 未设置时取 git describe
+VERSION_PKG=github.com/dify-router/dify-router/internal/static
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+VERSION_LDFLAGS="-X ${VERSION_PKG}.Version=${VERSION} -X ${VERSION_PKG}.GitCommit=$(git rev-parse --short HEAD 2>/dev/null) -X ${VERSION_PKG}.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
 rm -f internal/core/runner/python/python.so
 rm -f internal/core/runner/nodejs/nodejs.so
 rm -f /tmp/sandbox-python/python.so
@@ -7,6 +11,6 @@ CGO_ENABLED=1 GOOS=linux GOARCH=arm64 go build -o internal/core/runner/python/py
 echo "Building Nodejs lib" &&
 CGO_ENABLED=1 GOOS=linux GOARCH=arm64 go build -o internal/core/runner/nodejs/nodejs.so -buildmode=c-shared -ldflags="-s -w" cmd/lib/nodejs/main.go &&
 echo "Building main" &&
-GOOS=linux GOARCH=arm64 go build -o main -ldflags="-s -w" cmd/server/main.go
+GOOS=linux GOARCH=arm64 go build -o main -ldflags="-s -w ${VERSION_LDFLAGS}" cmd/server/main.go
 echo "Building env"
 GOOS=linux GOARCH=arm64 go build -o env -ldflags="-s -w" cmd/dependencies/init.go
@@ -3,12 +3,26 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/dify-router/dify-router/internal/server"
+	"github.com/dify-router/dify-router/internal/static"
 )
 
 func main() {
+    // 🔧 新增：main check [-config path] [-routes] [-skip-ports] [-strict] [-json]：部署前自检，有失败项时退出码非零
+    if len(os.Args) > 1 && os.Args[1] == "check" {
+        os.Exit(server.Check(os.Args[2:]))
+    }
+    // 🔧 新增：main agent -gateway url -id sandbox-id [-type python] [-sandbox url] [-connections 4]：沙箱侧反向隧道 agent
+    if len(os.Args) > 1 && os.Args[1] == "agent" {
+        os.Exit(server.Agent(os.Args[2:]))
+    }
+
     fmt.Println("🚀 Starting XAI Router Gateway...")
+    // 🔧 新增：启动时输出构建信息，便于确认实例运行的版本
+    build := static.GetBuildInfo()
+    fmt.Printf("📦 Version %s (commit %s, built %s, %s)\n", build.Version, build.GitCommit, build.BuildTime, build.GoVersion)
     
     // 启动服务器
     server.Run()
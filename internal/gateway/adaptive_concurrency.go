@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 统计窗口的样本数：上限已降到 min_limit 仍持续拥塞时，以窗口内的最小延迟作为新的无负载延迟（上游本身变慢）
+const concurrencyRTTWindow = 100
+
+var errConcurrencyLimited = fmt.Errorf("upstream concurrency limit reached")
+
+// 🔧 新增：按上游自适应并发限制（AIMD）：延迟保持在无负载延迟的 latency_tolerance 倍以内时缓慢增加上限，
+// 延迟超出或上游过载（连接失败、429、502-504）时按 backoff 成比例降低，从而在没有准入控制的沙箱前自动找到安全的并发数
+type adaptiveConcurrency struct {
+	limiters map[string]*concurrencyLimiter // 上游 -> 限制器
+	mutex    sync.Mutex
+}
+
+type concurrencyLimiter struct {
+	limit        float64
+	inflight     int
+	minRTT       time.Duration // 无负载延迟基线
+	lastRTT      time.Duration
+	windowMin    time.Duration
+	windowCount  int
+	samples      int64
+	rejected     int64
+	lastDecrease time.Time
+}
+
+func newAdaptiveConcurrency() *adaptiveConcurrency {
+	return &adaptiveConcurrency{limiters: make(map[string]*concurrencyLimiter)}
+}
+
+// 占用上游的一个并发名额；返回的 release 必须在请求结束后调用一次，传入响应延迟以及上游是否过载（延迟为 0 且未过载时不计入样本）
+func (a *adaptiveConcurrency) acquire(target string) (func(rtt time.Duration, overloaded bool), bool) {
+	settings := gatewaySettings().AdaptiveConcurrency
+	if a == nil || !settings.Enabled {
+		return func(time.Duration, bool) {}, true
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	limiter := a.limiters[target]
+	if limiter == nil {
+		limiter = &concurrencyLimiter{limit: float64(settings.InitialLimit)}
+		a.limiters[target] = limiter
+	}
+	if limiter.inflight >= int(limiter.limit) {
+		limiter.rejected++
+		return nil, false
+	}
+	limiter.inflight++
+	inflight := limiter.inflight
+
+	var once sync.Once
+	return func(rtt time.Duration, overloaded bool) {
+		once.Do(func() {
+			a.mutex.Lock()
+			defer a.mutex.Unlock()
+			limiter.inflight--
+			limiter.update(settings, inflight, rtt, overloaded, time.Now())
+		})
+	}, true
+}
+
+// 根据一次请求的结果调整上限；inflight 为该请求开始时的并发数
+func (l *concurrencyLimiter) update(settings static.AdaptiveConcurrencyConfig, inflight int, rtt time.Duration, overloaded bool, now time.Time) {
+	if rtt <= 0 && !overloaded {
+		return
+	}
+	l.samples++
+	if !overloaded {
+		l.lastRTT = rtt
+		if l.minRTT == 0 || rtt < l.minRTT {
+			l.minRTT = rtt
+		}
+		if l.windowMin == 0 || rtt < l.windowMin {
+			l.windowMin = rtt
+		}
+		if l.windowCount++; l.windowCount >= concurrencyRTTWindow {
+			if l.limit <= float64(settings.MinLimit) {
+				l.minRTT = l.windowMin
+			}
+			l.windowMin, l.windowCount = 0, 0
+		}
+	}
+
+	congested := overloaded || float64(rtt) > float64(l.minRTT)*settings.LatencyTolerance
+	switch {
+	case congested:
+		// 同一批并发请求只降低一次，避免一次抖动让上限骤降
+		if now.Sub(l.lastDecrease) < rtt {
+			return
+		}
+		l.limit *= settings.Backoff
+		l.lastDecrease = now
+	case float64(inflight) >= l.limit/2:
+		// 只有并发确实接近上限时才增加，空闲时上限不会无限增长
+		l.limit += 1 / l.limit
+	}
+
+	if l.limit < float64(settings.MinLimit) {
+		l.limit = float64(settings.MinLimit)
+	}
+	if l.limit > float64(settings.MaxLimit) {
+		l.limit = float64(settings.MaxLimit)
+	}
+}
+
+// 上游返回这些状态码时视为过载
+func upstreamOverloaded(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// 各上游的当前上限、并发数和拒绝次数
+func (a *adaptiveConcurrency) Stats() []map[string]interface{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	stats := make([]map[string]interface{}, 0, len(a.limiters))
+	for target, limiter := range a.limiters {
+		stats = append(stats, map[string]interface{}{
+			"target":         target,
+			"limit":          int(limiter.limit),
+			"inflight":       limiter.inflight,
+			"min_rtt_ms":     limiter.minRTT.Milliseconds(),
+			"last_rtt_ms":    limiter.lastRTT.Milliseconds(),
+			"samples":        limiter.samples,
+			"rejected_total": limiter.rejected,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i]["target"].(string) < stats[j]["target"].(string) })
+	return stats
+}
+
+// 选择健康且未达到并发上限的沙箱实例；所有候选实例都已满时返回 errConcurrencyLimited
+func (dr *DistributedRouter) selectAdmittedInstance(route *RouteConfig, tried map[string]bool) (*SandboxInstance, func(time.Duration, bool), error) {
+	excluded := make(map[string]bool, len(tried))
+	for id := range tried {
+		excluded[id] = true
+	}
+	saturated := false
+	for {
+		instance, err := dr.sandboxPool.SelectInstance(route.SandboxType, route.Locality, excluded)
+		if err != nil {
+			if saturated {
+				return nil, nil, errConcurrencyLimited
+			}
+			return nil, nil, err
+		}
+		if release, ok := dr.concurrency.acquire("sandbox:" + instance.ID); ok {
+			return instance, release, nil
+		}
+		dr.sandboxPool.ReleaseInstance(instance)
+		excluded[instance.ID] = true
+		saturated = true
+	}
+}
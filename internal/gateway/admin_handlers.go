@@ -2,11 +2,11 @@ package gateway
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/dify-router/dify-router/internal/static"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
@@ -38,7 +38,7 @@ func (dr *DistributedRouter) getConfigVersionHandler(c *gin.Context) {
 		"last_updated":      dr.routeManager.lastConfigUpdate,
 		"updating_routes":   updatingRoutes,
 		"total_routes":      totalRoutes,
-		"memory_routes":     len(dr.routeManager.routeCache),
+		"memory_routes":     dr.routeManager.snapshot().size(),
 		"instance_id":       dr.routeManager.instanceID,
 		"redis_enabled":     dr.routeManager.redisEnabled,
 	}
@@ -46,6 +46,26 @@ func (dr *DistributedRouter) getConfigVersionHandler(c *gin.Context) {
 	c.JSON(200, response)
 }
 
+// 🔧 新增：运行时统计信息
+func (dr *DistributedRouter) statsHandler(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"is_leader":   dr.leader.IsLeader(),
+		"route_cache": dr.routeManager.cacheStats(),
+		"log_forwarding": dr.logForwarder.Stats(),
+		"operational_logs": operationalLog.Stats(), // 🔧 新增：运行日志级别与省略的行数
+		"event_outbox": dr.routeManager.outbox.Stats(),
+		"adaptive_concurrency": dr.concurrency.Stats(),
+		"sandbox_wait": dr.sandboxWait.Stats(),
+		"execution_cancel": dr.executionCancel.Stats(),
+		"api_key_concurrency": dr.keyConcurrency.Stats(),
+		"sandbox_changes": dr.sandboxChanges.Stats(),
+		"dns": upstreamDNS.Load().Stats(),
+		"contract_violations": dr.metrics.ContractViolations(),
+		"panics": dr.panics.Stats(), // 🔧 新增：两个端口的 panic 次数（按端口和路由）
+	})
+}
+
 // 扩展的管理接口处理器
 func (dr *DistributedRouter) getStreamInfoHandler(c *gin.Context) {
 	if !dr.routeManager.redisEnabled {
@@ -177,7 +197,7 @@ func (dr *DistributedRouter) getEventStatsHandler(c *gin.Context) {
         "consumer_groups":     consumerStats,
         "instance_id":         dr.routeManager.instanceID,
         "last_config_update":  dr.routeManager.lastConfigUpdate,
-        "memory_route_count":  len(dr.routeManager.routeCache),
+        "memory_route_count":  dr.routeManager.snapshot().size(),
     }
 
     c.JSON(200, response)
@@ -211,10 +231,8 @@ func (dr *DistributedRouter) triggerSyncHandler(c *gin.Context) {
 func (dr *DistributedRouter) getRouteDetailsHandler(c *gin.Context) {
 	routeID := c.Param("routeId")
 	
-	dr.routeManager.mutex.RLock()
-	defer dr.routeManager.mutex.RUnlock()
-
-	route, exists := dr.routeManager.routeCache[routeID]
+	table := dr.routeManager.snapshot()
+	route, exists := table.get(routeID)
 	if !exists {
 		c.JSON(404, gin.H{"error": "route not found"})
 		return
@@ -226,7 +244,7 @@ func (dr *DistributedRouter) getRouteDetailsHandler(c *gin.Context) {
 		ctx := c.Request.Context()
 		routeJSON, err := dr.routeManager.redisClient.HGet(ctx, "gateway:routes", routeID).Result()
 		if err == nil {
-			json.Unmarshal([]byte(routeJSON), &redisRoute)
+			redisRoute, _ = decodeStoredRoute([]byte(routeJSON))
 		}
 	}
 
@@ -234,7 +252,19 @@ func (dr *DistributedRouter) getRouteDetailsHandler(c *gin.Context) {
 		"route": route,
 		"redis_data": redisRoute,
 		"in_memory": exists,
-		"version": dr.routeManager.routeVersions[routeID],
+		"version": table.versions[routeID],
+	}
+
+	// 🔧 新增：延迟加载的路由在内存中没有代码，从代码缓存或 Redis 加载后返回
+	if table.lazyCode[routeID] {
+		response["code_lazy"] = true
+		code, err := dr.routeManager.resolveCode(&route)
+		if err != nil {
+			response["code_error"] = err.Error()
+		} else {
+			route.Code = code
+			response["route"] = route
+		}
 	}
 
 	c.JSON(200, response)
@@ -300,6 +330,8 @@ func (dr *DistributedRouter) healthCheckHandler(c *gin.Context) {
 		"timestamp":    time.Now().Unix(),
 		"instance_id":  dr.routeManager.instanceID,
 		"redis_enabled": dr.routeManager.redisEnabled,
+		"version":      static.GetBuildInfo().Version, // 🔧 新增：构建版本
+		"git_commit":   static.GetBuildInfo().GitCommit,
 	}
 
 	if dr.routeManager.redisEnabled {
@@ -317,10 +349,8 @@ func (dr *DistributedRouter) healthCheckHandler(c *gin.Context) {
 	}
 
 	// 检查内存路由状态
-	dr.routeManager.mutex.RLock()
-	healthStatus["route_count"] = len(dr.routeManager.routeCache)
+	healthStatus["route_count"] = dr.routeManager.snapshot().size()
 	healthStatus["config_version"] = dr.routeManager.lastConfigUpdate
-	dr.routeManager.mutex.RUnlock()
 
 	c.JSON(200, healthStatus)
 }
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/middleware"
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	csrfCookieName = "gateway_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// 管理配置，未初始化时返回零值
+func adminSettings() static.AdminConfig {
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		return config.Admin
+	}
+	return static.AdminConfig{}
+}
+
+// 修改类管理请求的来源校验与 CSRF 防护。
+// 非浏览器客户端（不携带 Origin/Referer/Cookie）不受影响；浏览器请求需来自允许的来源，
+// 并以双重提交方式携带 CSRF 令牌（Cookie 与 X-CSRF-Token 头一致且签名有效）。
+func (dr *DistributedRouter) adminCSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		config := adminSettings()
+		origin := requestOrigin(c.Request)
+		if origin != "" && !originAllowed(origin, c.Request.Host, config.AllowedOrigins) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "origin not allowed"})
+			return
+		}
+
+		browser := origin != "" || c.Request.Header.Get("Cookie") != ""
+		if config.CSRFEnabled && browser {
+			cookie, err := c.Cookie(csrfCookieName)
+			token := c.GetHeader(csrfHeaderName)
+			if err != nil || token == "" || !hmac.Equal([]byte(cookie), []byte(token)) || !verifyCSRFToken(token, config.CSRFTokenTTL) {
+				c.AbortWithStatusJSON(403, gin.H{"error": "invalid csrf token"})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// 请求来源：优先 Origin，其次 Referer 的 scheme://host。Origin: null（如沙箱 iframe）不会匹配任何来源
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	if referer := r.Header.Get("Referer"); referer != "" {
+		if u, err := url.Parse(referer); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+	return ""
+}
+
+func originAllowed(origin, host string, allowed []string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, host) {
+		return true
+	}
+	for _, o := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRF 令牌：<签发时间>.<随机数>.<签名>，无需服务端会话，任意实例均可校验
+func issueCSRFToken(now time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	payload := strconv.FormatInt(now.Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + hex.EncodeToString(hmacSHA256(csrfSigningKey(), payload)), nil
+}
+
+func verifyCSRFToken(token string, ttl int) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload := parts[0] + "." + parts[1]
+	signature, err := hex.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, hmacSHA256(csrfSigningKey(), payload)) {
+		return false
+	}
+
+	issuedAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	if ttl <= 0 {
+		ttl = 43200
+	}
+	age := time.Since(time.Unix(issuedAt, 0))
+	return age >= -time.Minute && age <= time.Duration(ttl)*time.Second
+}
+
+// 令牌签名密钥由管理 Key 派生，所有实例一致，管理 Key 轮换后旧令牌自动失效
+func csrfSigningKey() []byte {
+	config := static.GetDifySandboxGlobalConfigurations()
+	adminKey := ""
+	if config != nil {
+		adminKey = middleware.AdminKey(config)
+	}
+	return hmacSHA256([]byte(adminKey), "admin-csrf")
+}
+
+// 🔧 新增：签发 CSRF 令牌（同时写入 SameSite=Strict Cookie）
+func (dr *DistributedRouter) csrfTokenHandler(c *gin.Context) {
+	token, err := issueCSRFToken(time.Now())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := adminSettings().CSRFTokenTTL
+	if ttl <= 0 {
+		ttl = 43200
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/admin",
+		MaxAge:   ttl,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	c.JSON(200, gin.H{"csrf_token": token, "header": csrfHeaderName, "expires_in": ttl})
+}
+
+// 来源是否命中 CORS 配置：返回是否命中通配符、是否显式列出
+func corsOriginAllowed(origin string, allowed []string) (wildcard, explicit bool) {
+	for _, o := range allowed {
+		if o == "*" {
+			wildcard = true
+		} else if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			explicit = true
+		}
+	}
+	return wildcard, explicit
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+
+	"github.com/dify-router/dify-router/internal/middleware"
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	generatedKeyPrefix = "drk_"
+	apiKeyPrefixLength = 12 // "drk_" + 8 位十六进制
+)
+
+// Key 哈希：配置 pepper 时为 HMAC-SHA256(pepper, key)，否则为 SHA256(key)
+func hashAPIKey(pepper, key string) string {
+	if pepper == "" {
+		return sha256Hex([]byte(key))
+	}
+	return hex.EncodeToString(hmacSHA256([]byte(pepper), key))
+}
+
+// Key 前缀（用于在配置中定位候选 Key，不足长度时为整个 Key）
+func apiKeyPrefix(key string) string {
+	if len(key) < apiKeyPrefixLength {
+		return key
+	}
+	return key[:apiKeyPrefixLength]
+}
+
+// 查找消费者 Key：哈希 Key 先按前缀筛选再比较哈希，明文 Key 使用常量时间比较
+func (dr *DistributedRouter) matchConsumerKey(ctx context.Context, config static.GatewayConfig, apiKey string) *static.APIKeyConfig {
+	prefix := apiKeyPrefix(apiKey)
+	var presentedHash string
+	hashed := false
+
+	for i := range config.APIKeys {
+		key := &config.APIKeys[i]
+		if key.KeyHash == "" {
+			if middleware.KeyMatches(key.Key, apiKey) {
+				return key
+			}
+			continue
+		}
+		if key.KeyPrefix != prefix {
+			continue
+		}
+
+		// 仅在需要时解析 pepper 并计算哈希
+		if !hashed {
+			pepper := ""
+			if config.APIKeyPepper != "" {
+				var err error
+				pepper, err = dr.secrets.Resolve(ctx, config.APIKeyPepper)
+				if err != nil {
+					log.Printf("❌ Failed to resolve api key pepper: %v", err)
+					return nil
+				}
+			}
+			presentedHash = hashAPIKey(pepper, apiKey)
+			hashed = true
+		}
+		if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(presentedHash)) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// 🔧 新增：生成新的消费者 Key，返回明文（仅此一次）及写入配置用的前缀和哈希
+func (dr *DistributedRouter) generateAPIKeyHandler(c *gin.Context) {
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	key := generatedKeyPrefix + hex.EncodeToString(random)
+
+	pepper := ""
+	if ref := gatewaySettings().APIKeyPepper; ref != "" {
+		var err error
+		pepper, err = dr.secrets.Resolve(c.Request.Context(), ref)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to resolve api key pepper: " + err.Error()})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"key":        key,
+		"key_prefix": apiKeyPrefix(key),
+		"key_hash":   hashAPIKey(pepper, key),
+		"message":    "store key_prefix and key_hash in gateway.api_keys; the key is not shown again",
+	})
+}
@@ -0,0 +1,279 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const backupFormatVersion = 1
+
+// 🔧 新增：启用路由字段加密时备份中的路由为存储格式（密文），旧版本无法读取，格式版本号随之提升
+const sealedBackupFormatVersion = 2
+
+// 配置快照
+type ConfigSnapshot struct {
+	FormatVersion int           `json:"format_version"`
+	CreatedAt     int64         `json:"created_at"`
+	InstanceID    string        `json:"instance_id"`
+	ConfigVersion int64         `json:"config_version"`
+	Routes        []RouteConfig `json:"routes"`
+}
+
+// 🔧 新增：备份文件格式：路由经 encodeStoredRoute 编码，启用加密时加密字段与 Redis 中一样为密文
+type storedConfigSnapshot struct {
+	ConfigSnapshot
+	Routes []json.RawMessage `json:"routes"`
+}
+
+// 备份管理器：主节点定时写入配置快照并按保留策略清理
+type BackupManager struct {
+	store        BackupStore
+	routeManager *RouteManager
+	leader       *LeaderElector
+	config       static.BackupConfig
+	mutex        sync.Mutex
+	lastBackup   *BackupInfo
+	lastError    string
+	stopChan     chan struct{}
+}
+
+func NewBackupManager(config static.BackupConfig, routeManager *RouteManager, leader *LeaderElector) (*BackupManager, error) {
+	store, err := NewBackupStore(config)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupManager{
+		store:        store,
+		routeManager: routeManager,
+		leader:       leader,
+		config:       config,
+		stopChan:     make(chan struct{}),
+	}, nil
+}
+
+// 启动定时备份
+func (bm *BackupManager) Start() {
+	if !bm.config.Enabled {
+		return
+	}
+
+	interval := time.Duration(bm.config.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !bm.leader.IsLeader() {
+					continue
+				}
+				if _, err := bm.CreateBackup(context.Background()); err != nil {
+					log.Printf("❌ Scheduled backup failed: %v", err)
+				}
+			case <-bm.stopChan:
+				return
+			}
+		}
+	}()
+	log.Printf("💾 Backup scheduler started (interval: %v, storage: %s)", interval, bm.config.Storage)
+}
+
+// 停止定时备份
+func (bm *BackupManager) Stop() {
+	close(bm.stopChan)
+}
+
+// 创建备份并执行保留策略
+func (bm *BackupManager) CreateBackup(ctx context.Context) (*BackupInfo, error) {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	routes, err := bm.routeManager.loadPersistedRoutes(ctx)
+	if err != nil {
+		bm.lastError = err.Error()
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshot := storedConfigSnapshot{
+		ConfigSnapshot: ConfigSnapshot{
+			FormatVersion: backupFormatVersion,
+			CreatedAt:     now.Unix(),
+			InstanceID:    bm.routeManager.instanceID,
+			ConfigVersion: bm.routeManager.snapshot().configVersion,
+		},
+		Routes: make([]json.RawMessage, 0, len(routes)),
+	}
+	if activeRouteCipher.Load() != nil {
+		snapshot.FormatVersion = sealedBackupFormatVersion
+	}
+	for _, route := range routes {
+		routeJSON, err := encodeStoredRoute(route)
+		if err != nil {
+			bm.lastError = err.Error()
+			return nil, fmt.Errorf("failed to encode route %s: %v", route.ID, err)
+		}
+		snapshot.Routes = append(snapshot.Routes, routeJSON)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("routes-%s.json", now.UTC().Format("20060102-150405"))
+	if err := bm.store.Save(name, data); err != nil {
+		bm.lastError = err.Error()
+		return nil, err
+	}
+
+	info := &BackupInfo{Name: name, Size: int64(len(data)), CreatedAt: now.Unix()}
+	bm.lastBackup = info
+	bm.lastError = ""
+	log.Printf("💾 Backup written: %s (%d routes)", name, len(routes))
+
+	if err := bm.applyRetention(); err != nil {
+		log.Printf("⚠️ Backup retention failed: %v", err)
+	}
+	return info, nil
+}
+
+// 保留策略：超出数量或超过最长保留时间的备份会被删除
+func (bm *BackupManager) applyRetention() error {
+	backups, err := bm.store.List()
+	if err != nil {
+		return err
+	}
+
+	cutoff := int64(0)
+	if bm.config.MaxAgeHours > 0 {
+		cutoff = time.Now().Add(-time.Duration(bm.config.MaxAgeHours) * time.Hour).Unix()
+	}
+
+	for i, backup := range backups {
+		expired := cutoff > 0 && backup.CreatedAt < cutoff
+		overflow := bm.config.Retention > 0 && i >= bm.config.Retention
+		if !expired && !overflow {
+			continue
+		}
+		if err := bm.store.Delete(backup.Name); err != nil {
+			return err
+		}
+		log.Printf("🗑️  Backup removed by retention policy: %s", backup.Name)
+	}
+	return nil
+}
+
+// 从备份恢复路由表：与声明式 apply 相同，计算差异后原子地新增、更新和删除路由，任何一条出错时全部不写入
+func (bm *BackupManager) Restore(name string, force, dryRun bool) (*RouteImportResult, error) {
+	data, err := bm.store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot storedConfigSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %v", name, err)
+	}
+	if snapshot.FormatVersion > sealedBackupFormatVersion {
+		return nil, fmt.Errorf("unsupported backup format version: %d", snapshot.FormatVersion)
+	}
+	routes := make([]RouteConfig, 0, len(snapshot.Routes))
+	for i, routeJSON := range snapshot.Routes {
+		route, err := decodeStoredRoute(routeJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup %s: route %d: %v", name, i, err)
+		}
+		routes = append(routes, route)
+	}
+
+	result, err := bm.routeManager.ApplyRoutes(routes, force, dryRun)
+	if err != nil || dryRun {
+		return result, err
+	}
+	log.Printf("♻️  Restored backup %s: %d created, %d updated, %d deleted, %d unchanged",
+		name, len(result.Created), len(result.Updated), len(result.Deleted), len(result.Unchanged))
+	return result, nil
+}
+
+// 备份状态
+func (bm *BackupManager) status() gin.H {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	return gin.H{
+		"enabled":     bm.config.Enabled,
+		"storage":     bm.config.Storage,
+		"interval":    bm.config.Interval,
+		"retention":   bm.config.Retention,
+		"is_leader":   bm.leader.IsLeader(),
+		"last_backup": bm.lastBackup,
+		"last_error":  bm.lastError,
+	}
+}
+
+// 🔧 新增：列出备份
+func (dr *DistributedRouter) listBackupsHandler(c *gin.Context) {
+	if dr.backupManager == nil {
+		c.JSON(503, gin.H{"error": "backup storage not configured"})
+		return
+	}
+
+	backups, err := dr.backupManager.store.List()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"backups": backups,
+		"status":  dr.backupManager.status(),
+	})
+}
+
+// 🔧 新增：立即创建备份
+func (dr *DistributedRouter) createBackupHandler(c *gin.Context) {
+	if dr.backupManager == nil {
+		c.JSON(503, gin.H{"error": "backup storage not configured"})
+		return
+	}
+
+	info, err := dr.backupManager.CreateBackup(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "backup created", "backup": info})
+}
+
+// 🔧 新增：从备份恢复
+func (dr *DistributedRouter) restoreBackupHandler(c *gin.Context) {
+	if dr.backupManager == nil {
+		c.JSON(503, gin.H{"error": "backup storage not configured"})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := dr.backupManager.Restore(c.Param("name"), c.Query("force") == "true", dryRun)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "summary": result})
+		return
+	}
+	if dryRun {
+		c.JSON(200, gin.H{"dry_run": true, "summary": result})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "backup restored", "summary": result})
+}
@@ -0,0 +1,273 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 备份文件信息
+type BackupInfo struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// 备份存储接口
+type BackupStore interface {
+	Save(name string, data []byte) error
+	Load(name string) ([]byte, error)
+	List() ([]BackupInfo, error)
+	Delete(name string) error
+}
+
+// 根据配置创建备份存储
+func NewBackupStore(config static.BackupConfig) (BackupStore, error) {
+	switch config.Storage {
+	case "", "local":
+		dir := config.LocalDir
+		if dir == "" {
+			dir = "backups"
+		}
+		return &localBackupStore{dir: dir}, nil
+	case "s3":
+		if config.S3.Bucket == "" {
+			return nil, fmt.Errorf("s3 backup bucket is required")
+		}
+		endpoint := config.S3.Endpoint
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.S3.Region)
+		}
+		return &s3BackupStore{
+			config:   config.S3,
+			endpoint: strings.TrimSuffix(endpoint, "/"),
+			client:   &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown backup storage: %s", config.Storage)
+	}
+}
+
+// 校验备份名称，防止路径穿越
+func validateBackupName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid backup name: %s", name)
+	}
+	return nil
+}
+
+// 本地磁盘备份存储
+type localBackupStore struct {
+	dir string
+}
+
+func (s *localBackupStore) Save(name string, data []byte) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免留下不完整的备份
+	tmpPath := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(s.dir, name))
+}
+
+func (s *localBackupStore) Load(name string) ([]byte, error) {
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.dir, name))
+}
+
+func (s *localBackupStore) List() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []BackupInfo{}, nil
+		}
+		return nil, err
+	}
+
+	backups := make([]BackupInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime().Unix(),
+		})
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (s *localBackupStore) Delete(name string) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// S3 兼容对象存储（路径风格访问，SigV4 签名）
+type s3BackupStore struct {
+	config   static.S3BackupConfig
+	endpoint string
+	client   *http.Client
+}
+
+func (s *s3BackupStore) objectURL(name string) string {
+	return fmt.Sprintf("%s/%s/%s", s.endpoint, s.config.Bucket, escapeObjectKey(s.config.Prefix+name))
+}
+
+func (s *s3BackupStore) do(method, rawURL string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	signRequestSigV4(req, body, s.config.AccessKey, s.config.SecretKey, s.config.Region, "s3", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s failed: %s %s", method, rawURL, resp.Status, string(detail))
+	}
+	return resp, nil
+}
+
+func (s *s3BackupStore) Save(name string, data []byte) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	resp, err := s.do("PUT", s.objectURL(name), data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3BackupStore) Load(name string) ([]byte, error) {
+	if err := validateBackupName(name); err != nil {
+		return nil, err
+	}
+	resp, err := s.do("GET", s.objectURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// ListObjectsV2 响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3BackupStore) List() ([]BackupInfo, error) {
+	backups := make([]BackupInfo, 0)
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.config.Prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do("GET", fmt.Sprintf("%s/%s?%s", s.endpoint, s.config.Bucket, query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode s3 list response: %v", err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.config.Prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			backups = append(backups, BackupInfo{
+				Name:      name,
+				Size:      object.Size,
+				CreatedAt: object.LastModified.Unix(),
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (s *s3BackupStore) Delete(name string) error {
+	if err := validateBackupName(name); err != nil {
+		return err
+	}
+	resp, err := s.do("DELETE", s.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// 对象键按路径段转义，保留分隔符
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// 按时间从新到旧排序
+func sortBackups(backups []BackupInfo) {
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].CreatedAt == backups[j].CreatedAt {
+			return backups[i].Name > backups[j].Name
+		}
+		return backups[i].CreatedAt > backups[j].CreatedAt
+	})
+}
@@ -3,14 +3,20 @@ package gateway
 import "math/rand"
 
 type LoadBalancer struct {
-	strategy string // "round-robin", "least-connections", "random"
-	counters map[string]int
+	strategy  string // "round-robin", "least-connections", "random", "cost-aware"
+	counters  map[string]int
+	spillLoad int    // 🔧 新增：cost-aware 策略下实例负载达到该值后溢出到更贵的实例
 }
 
 func NewLoadBalancer() *LoadBalancer {
+	spillLoad := gatewaySettings().CostSpillLoad
+	if spillLoad <= 0 {
+		spillLoad = 4
+	}
 	return &LoadBalancer{
-		strategy: "least-connections",
-		counters: make(map[string]int),
+		strategy:  "least-connections",
+		counters:  make(map[string]int),
+		spillLoad: spillLoad,
 	}
 }
 
@@ -30,6 +36,8 @@ func (lb *LoadBalancer) Select(instances []*SandboxInstance) *SandboxInstance {
 		return lb.roundRobin(instances)
 	case "random":
 		return lb.random(instances)
+	case "cost-aware":
+		return lb.costAware(instances)
 	default:
 		return lb.leastConnections(instances)
 	}
@@ -52,6 +60,34 @@ func (lb *LoadBalancer) leastConnections(instances []*SandboxInstance) *SandboxI
 	return selected
 }
 
+// 🔧 新增：成本优先：负载低时选择成本最低的实例，
+// 低成本实例负载都达到 spillLoad 后才使用更贵的实例，全部饱和时退化为最少连接
+func (lb *LoadBalancer) costAware(instances []*SandboxInstance) *SandboxInstance {
+	var selected *SandboxInstance
+	for _, instance := range instances {
+		if instance.Load >= lb.spillLoad {
+			continue
+		}
+		if selected == nil || instanceCost(instance) < instanceCost(selected) ||
+			(instanceCost(instance) == instanceCost(selected) && instance.Load < selected.Load) {
+			selected = instance
+		}
+	}
+
+	if selected == nil {
+		return lb.leastConnections(instances)
+	}
+	selected.Load++
+	return selected
+}
+
+func instanceCost(instance *SandboxInstance) float64 {
+	if instance.Cost <= 0 {
+		return 1
+	}
+	return instance.Cost
+}
+
 func (lb *LoadBalancer) roundRobin(instances []*SandboxInstance) *SandboxInstance {
 	if len(instances) == 0 {
 		return nil
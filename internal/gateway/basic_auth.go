@@ -0,0 +1,338 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	basicCredentialsRedisKey      = "gateway:auth:basic"
+	basicCredentialsRefreshPeriod = 5 * time.Second
+	generatedPasswordBytes        = 18
+	basicVerifiedCacheSize        = 1024
+)
+
+// 🔧 新增：Basic 密码使用 bcrypt 哈希（每个凭据随机盐）；配置了 api_key_pepper 时先计算
+// hex(HMAC-SHA256(pepper, password)) 再 bcrypt，pepper 作为额外一层保护
+func basicPasswordInput(pepper, password string) []byte {
+	if pepper == "" {
+		return []byte(password)
+	}
+	return []byte(hashAPIKey(pepper, password))
+}
+
+func hashBasicPassword(pepper, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(basicPasswordInput(pepper, password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// 校验密码；非 bcrypt 格式的哈希按旧算法（hashAPIKey）常量时间比较，兼容升级前保存的凭据
+func basicPasswordMatches(passwordHash, pepper, password string) bool {
+	if !strings.HasPrefix(passwordHash, "$2") {
+		return subtle.ConstantTimeCompare([]byte(passwordHash), []byte(hashAPIKey(pepper, password))) == 1
+	}
+	input := basicPasswordInput(pepper, password)
+	cacheKey := passwordHash + "\x00" + sha256Hex(input)
+	if basicVerified.contains(cacheKey) {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), input) != nil {
+		return false
+	}
+	basicVerified.add(cacheKey)
+	return true
+}
+
+// 校验通过的 (密码哈希, 密码摘要) 缓存：bcrypt 每次比较耗时数十毫秒，同一凭据的后续请求无需重复计算；
+// 键包含密码哈希，凭据修改后旧条目自然失效，缓存写满时整体清空
+type verifiedPasswordCache struct {
+	mutex   sync.Mutex
+	entries map[string]struct{}
+}
+
+var basicVerified = &verifiedPasswordCache{entries: make(map[string]struct{})}
+
+func (c *verifiedPasswordCache) contains(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+func (c *verifiedPasswordCache) add(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.entries) >= basicVerifiedCacheSize {
+		c.entries = make(map[string]struct{})
+	}
+	c.entries[key] = struct{}{}
+}
+
+// 存储在 Redis 中的 Basic 凭据（只保存密码的 bcrypt 哈希）
+type BasicCredential struct {
+	Name         string `json:"name"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// 🔧 新增：Basic 凭据存储：凭据存储在 Redis 中，各实例定时刷新本地副本；未启用 Redis 时只保存在本地内存
+type BasicCredentialStore struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	credentials  map[string]*BasicCredential // 名称 -> 凭据
+	mutex        sync.RWMutex
+}
+
+func NewBasicCredentialStore(redisClient *redis.Client, redisEnabled bool) *BasicCredentialStore {
+	store := &BasicCredentialStore{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		credentials:  make(map[string]*BasicCredential),
+	}
+	if redisEnabled {
+		store.refresh()
+		go store.refreshLoop()
+	}
+	return store
+}
+
+func (s *BasicCredentialStore) refreshLoop() {
+	ticker := time.NewTicker(basicCredentialsRefreshPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.refresh()
+	}
+}
+
+// 从 Redis 重新加载凭据
+func (s *BasicCredentialStore) refresh() {
+	stored, err := s.redisClient.HGetAll(context.Background(), basicCredentialsRedisKey).Result()
+	if err != nil {
+		log.Printf("Failed to load basic auth credentials: %v", err)
+		return
+	}
+
+	credentials := make(map[string]*BasicCredential, len(stored))
+	for name, credentialJSON := range stored {
+		var credential BasicCredential
+		if err := json.Unmarshal([]byte(credentialJSON), &credential); err != nil {
+			continue
+		}
+		credentials[name] = &credential
+	}
+
+	s.mutex.Lock()
+	s.credentials = credentials
+	s.mutex.Unlock()
+}
+
+// 按用户名查找凭据
+func (s *BasicCredentialStore) lookup(username string) *BasicCredential {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, credential := range s.credentials {
+		if credential.Username == username {
+			return credential
+		}
+	}
+	return nil
+}
+
+// 保存凭据（同名凭据被替换）
+func (s *BasicCredentialStore) Put(credential *BasicCredential) error {
+	s.mutex.RLock()
+	existing := s.credentials[credential.Name]
+	s.mutex.RUnlock()
+
+	now := time.Now().Unix()
+	credential.CreatedAt = now
+	if existing != nil {
+		credential.CreatedAt = existing.CreatedAt
+	}
+	credential.UpdatedAt = now
+
+	if s.redisEnabled {
+		credentialJSON, _ := json.Marshal(credential)
+		if err := s.redisClient.HSet(context.Background(), basicCredentialsRedisKey, credential.Name, credentialJSON).Err(); err != nil {
+			return fmt.Errorf("failed to save basic auth credential: %v", err)
+		}
+	}
+
+	s.mutex.Lock()
+	s.credentials[credential.Name] = credential
+	s.mutex.Unlock()
+
+	log.Printf("🔑 Basic auth credential %s saved (username %s)", credential.Name, credential.Username)
+	return nil
+}
+
+// 删除凭据，返回是否存在
+func (s *BasicCredentialStore) Delete(name string) (bool, error) {
+	s.mutex.RLock()
+	_, exists := s.credentials[name]
+	s.mutex.RUnlock()
+
+	if s.redisEnabled {
+		removed, err := s.redisClient.HDel(context.Background(), basicCredentialsRedisKey, name).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to delete basic auth credential: %v", err)
+		}
+		exists = exists || removed > 0
+	}
+
+	s.mutex.Lock()
+	delete(s.credentials, name)
+	s.mutex.Unlock()
+
+	if exists {
+		log.Printf("🔑 Basic auth credential %s deleted", name)
+	}
+	return exists, nil
+}
+
+// 列出凭据（不含密码哈希），按名称排序
+func (s *BasicCredentialStore) List() []gin.H {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make([]gin.H, 0, len(s.credentials))
+	for _, credential := range s.credentials {
+		list = append(list, gin.H{
+			"name":       credential.Name,
+			"username":   credential.Username,
+			"source":     "store",
+			"created_at": credential.CreatedAt,
+			"updated_at": credential.UpdatedAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i]["name"].(string) < list[j]["name"].(string) })
+	return list
+}
+
+// 按用户名查找凭据：配置文件中的凭据优先，其次是 Redis 中的凭据
+func (dr *DistributedRouter) basicCredential(settings static.GatewayConfig, username string) (name, passwordHash string, ok bool) {
+	for _, c := range settings.BasicAuth.Credentials {
+		if c.Username == username {
+			return c.Name, c.PasswordHash, true
+		}
+	}
+	if dr.basicAuth != nil {
+		if credential := dr.basicAuth.lookup(username); credential != nil {
+			return credential.Name, credential.PasswordHash, true
+		}
+	}
+	return "", "", false
+}
+
+// 🔧 新增：列出 Basic 凭据（配置文件和 Redis 中的，不返回密码哈希）
+func (dr *DistributedRouter) listBasicCredentialsHandler(c *gin.Context) {
+	credentials := []gin.H{}
+	for _, credential := range gatewaySettings().BasicAuth.Credentials {
+		credentials = append(credentials, gin.H{"name": credential.Name, "username": credential.Username, "source": "config"})
+	}
+	credentials = append(credentials, dr.basicAuth.List()...)
+	c.JSON(200, gin.H{"credentials": credentials, "count": len(credentials)})
+}
+
+// 🔧 新增：创建或替换 Basic 凭据；未提供密码时生成随机密码，明文只在响应中返回一次
+func (dr *DistributedRouter) putBasicCredentialHandler(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("name")
+	if req.Username == "" || strings.Contains(req.Username, ":") {
+		c.JSON(400, gin.H{"error": "username is required and must not contain ':'"})
+		return
+	}
+
+	settings := gatewaySettings()
+	for _, credential := range settings.BasicAuth.Credentials {
+		if credential.Name == name || credential.Username == req.Username {
+			c.JSON(409, gin.H{"error": "credential name or username is already defined in gateway.basic_auth"})
+			return
+		}
+	}
+	if existing := dr.basicAuth.lookup(req.Username); existing != nil && existing.Name != name {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("username %s is already used by credential %s", req.Username, existing.Name)})
+		return
+	}
+
+	password := req.Password
+	generated := password == ""
+	if generated {
+		random := make([]byte, generatedPasswordBytes)
+		if _, err := rand.Read(random); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		password = hex.EncodeToString(random)
+	}
+
+	pepper := ""
+	if settings.APIKeyPepper != "" {
+		var err error
+		pepper, err = dr.secrets.Resolve(c.Request.Context(), settings.APIKeyPepper)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to resolve api key pepper: " + err.Error()})
+			return
+		}
+	}
+
+	if len(basicPasswordInput(pepper, password)) > 72 {
+		c.JSON(400, gin.H{"error": "password must not exceed 72 bytes"})
+		return
+	}
+	passwordHash, err := hashBasicPassword(pepper, password)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential := &BasicCredential{Name: name, Username: req.Username, PasswordHash: passwordHash}
+	if err := dr.basicAuth.Put(credential); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"name": name, "username": req.Username, "message": "credential saved"}
+	if generated {
+		response["password"] = password
+		response["message"] = "credential saved; the generated password is not shown again"
+	}
+	c.JSON(200, response)
+}
+
+// 🔧 新增：删除 Basic 凭据
+func (dr *DistributedRouter) deleteBasicCredentialHandler(c *gin.Context) {
+	exists, err := dr.basicAuth.Delete(c.Param("name"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(404, gin.H{"error": "credential not found"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "credential deleted"})
+}
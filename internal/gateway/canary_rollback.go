@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	canaryBucketSeconds   = 10
+	canaryBuckets         = 360 // 1 小时的 10 秒桶
+	canaryDefaultWindow   = 300
+	canaryDefaultMinCount = 20
+	canaryEvaluatePeriod  = 10 * time.Second
+)
+
+// 🔧 新增：金丝雀自动回滚：观察窗口内金丝雀版本的错误率比稳定版本高出 max_error_rate 时，
+// 把 weight 置 0 切回稳定版本并发出 canary_rollback 告警。
+// 路由配置了 slo 时，错误按 SLO 口径统计（5xx 或超过延迟阈值），max_error_rate 默认为 SLO 的错误预算（1 - availability_target）
+type CanaryRollback struct {
+	MaxErrorRate  float64 `json:"max_error_rate,omitempty"` // 允许金丝雀错误率高出稳定版本的幅度，如 0.05
+	WindowSeconds int     `json:"window_seconds,omitempty"` // 观察窗口，默认 300 秒
+	MinRequests   int     `json:"min_requests,omitempty"`   // 窗口内金丝雀请求数达到后才判断，默认 20
+}
+
+func (rb *CanaryRollback) validate(route *RouteConfig) error {
+	if rb.MaxErrorRate < 0 || rb.MaxErrorRate >= 1 {
+		return fmt.Errorf("canary rollback max_error_rate must be between 0 and 1")
+	}
+	if rb.MaxErrorRate == 0 && route.SLO == nil {
+		return fmt.Errorf("canary rollback max_error_rate is required for routes without slo")
+	}
+	if rb.WindowSeconds < 0 || rb.WindowSeconds > canaryBuckets*canaryBucketSeconds {
+		return fmt.Errorf("canary rollback window_seconds must be between 1 and %d", canaryBuckets*canaryBucketSeconds)
+	}
+	if rb.MinRequests < 0 {
+		return fmt.Errorf("canary rollback min_requests must not be negative")
+	}
+	return nil
+}
+
+func (rb *CanaryRollback) window() int {
+	if rb.WindowSeconds <= 0 {
+		return canaryDefaultWindow
+	}
+	return rb.WindowSeconds
+}
+
+func (rb *CanaryRollback) minRequests() int64 {
+	if rb.MinRequests <= 0 {
+		return canaryDefaultMinCount
+	}
+	return int64(rb.MinRequests)
+}
+
+// 允许的错误率差值：未设置时使用 SLO 的错误预算
+func (rb *CanaryRollback) threshold(route *RouteConfig) float64 {
+	if rb.MaxErrorRate == 0 && route.SLO != nil {
+		return 1 - route.SLO.AvailabilityTarget
+	}
+	return rb.MaxErrorRate
+}
+
+type canaryRouteState struct {
+	version int64 // 路由版本变化（调整金丝雀或回滚）后重新统计
+	stable  [canaryBuckets]sloBucket
+	canary  [canaryBuckets]sloBucket
+}
+
+// 各版本在观察窗口内的统计
+type CanaryVariantStats struct {
+	Total     int64   `json:"total"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+func newCanaryVariantStats(buckets []sloBucket, now int64, n int) CanaryVariantStats {
+	good, total := sumBuckets(buckets, now, n)
+	stats := CanaryVariantStats{Total: total, Errors: total - good}
+	if total > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(total)
+	}
+	return stats
+}
+
+// 路由金丝雀状态报告
+type CanaryReport struct {
+	RouteID        string             `json:"route_id"`
+	Weight         int                `json:"weight"`
+	Rollback       *CanaryRollback    `json:"rollback,omitempty"`
+	MaxErrorRate   float64            `json:"max_error_rate,omitempty"` // 实际使用的阈值
+	Stable         CanaryVariantStats `json:"stable"`
+	Canary         CanaryVariantStats `json:"canary"`
+	RolledBackAt   int64              `json:"rolled_back_at,omitempty"`
+	RollbackReason string             `json:"rollback_reason,omitempty"`
+}
+
+// 金丝雀版本统计（当前实例），只统计配置了自动回滚的路由
+type CanaryMonitor struct {
+	routes map[string]*canaryRouteState
+	mutex  sync.Mutex
+}
+
+func NewCanaryMonitor() *CanaryMonitor {
+	return &CanaryMonitor{routes: make(map[string]*canaryRouteState)}
+}
+
+// 记录一次请求结果，错误口径与 SLO 一致
+func (m *CanaryMonitor) Record(route *RouteConfig, variant string, status int, duration time.Duration, now time.Time) {
+	if m == nil || route == nil || route.Canary == nil || route.Canary.Rollback == nil || variant == "" {
+		return
+	}
+	good := status > 0 && status < 500
+	if route.SLO != nil && route.SLO.LatencyThresholdMs > 0 && duration > time.Duration(route.SLO.LatencyThresholdMs)*time.Millisecond {
+		good = false
+	}
+	stamp := now.Unix() / canaryBucketSeconds
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	state := m.routes[route.ID]
+	if state != nil && route.Version < state.version {
+		return // 变更前开始处理的请求
+	}
+	if state == nil || state.version != route.Version {
+		state = &canaryRouteState{version: route.Version}
+		m.routes[route.ID] = state
+	}
+	buckets := state.stable[:]
+	if variant == routeVariantCanary {
+		buckets = state.canary[:]
+	}
+	addToBucket(&buckets[int(stamp%canaryBuckets)], stamp, good)
+}
+
+// 生成报告（调用方持有锁）
+func (m *CanaryMonitor) report(route *RouteConfig, now time.Time) CanaryReport {
+	canary := route.Canary
+	report := CanaryReport{
+		RouteID:        route.ID,
+		Weight:         canary.Weight,
+		Rollback:       canary.Rollback,
+		RolledBackAt:   canary.RolledBackAt,
+		RollbackReason: canary.RollbackReason,
+	}
+	if canary.Rollback == nil {
+		return report
+	}
+	report.MaxErrorRate = canary.Rollback.threshold(route)
+
+	state := m.routes[route.ID]
+	if state == nil || state.version != route.Version {
+		return report
+	}
+	stamp := now.Unix() / canaryBucketSeconds
+	buckets := (canary.Rollback.window() + canaryBucketSeconds - 1) / canaryBucketSeconds
+	report.Stable = newCanaryVariantStats(state.stable[:], stamp, buckets)
+	report.Canary = newCanaryVariantStats(state.canary[:], stamp, buckets)
+	return report
+}
+
+// 所有配置了金丝雀的路由报告
+func (m *CanaryMonitor) Reports(routes []RouteConfig, now time.Time) []CanaryReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reports := make([]CanaryReport, 0)
+	for i := range routes {
+		if routes[i].Canary != nil {
+			reports = append(reports, m.report(&routes[i], now))
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].RouteID < reports[j].RouteID })
+	return reports
+}
+
+// 需要回滚时返回原因
+func (m *CanaryMonitor) evaluate(route *RouteConfig, now time.Time) string {
+	if route.Canary == nil || route.Canary.Rollback == nil || route.Canary.Weight <= 0 {
+		return ""
+	}
+	m.mutex.Lock()
+	report := m.report(route, now)
+	m.mutex.Unlock()
+
+	rollback := route.Canary.Rollback
+	if report.Canary.Total < rollback.minRequests() || report.Canary.ErrorRate <= report.Stable.ErrorRate+report.MaxErrorRate {
+		return ""
+	}
+	return fmt.Sprintf("canary error rate %.4f (%d/%d) exceeds stable %.4f (%d/%d) by more than %.4f in the last %ds",
+		report.Canary.ErrorRate, report.Canary.Errors, report.Canary.Total,
+		report.Stable.ErrorRate, report.Stable.Errors, report.Stable.Total,
+		report.MaxErrorRate, rollback.window())
+}
+
+// 清理已删除或取消金丝雀的路由
+func (m *CanaryMonitor) prune(routes []RouteConfig) {
+	active := make(map[string]bool, len(routes))
+	for i := range routes {
+		if routes[i].Canary != nil && routes[i].Canary.Rollback != nil {
+			active[routes[i].ID] = true
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for routeID := range m.routes {
+		if !active[routeID] {
+			delete(m.routes, routeID)
+		}
+	}
+}
+
+// 定时比较金丝雀与稳定版本的错误率。
+// 每个实例按自己处理的请求判断，任一实例触发回滚后通过路由事件同步到所有实例
+func (dr *DistributedRouter) runCanaryEvaluator() {
+	ticker := time.NewTicker(canaryEvaluatePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		dr.evaluateCanaries(time.Now())
+	}
+}
+
+func (dr *DistributedRouter) evaluateCanaries(now time.Time) {
+	routes := dr.routeManager.snapshot().list()
+	for i := range routes {
+		reason := dr.canaries.evaluate(&routes[i], now)
+		if reason == "" {
+			continue
+		}
+		if err := dr.rollbackCanary(routes[i], reason, now); err != nil {
+			log.Printf("❌ Failed to roll back canary of route %s: %v", routes[i].ID, err)
+		}
+	}
+	dr.canaries.prune(routes)
+}
+
+// 把金丝雀权重置 0（保留金丝雀配置便于排查），记录回滚时间和原因并发出告警
+func (dr *DistributedRouter) rollbackCanary(route RouteConfig, reason string, now time.Time) error {
+	code, err := dr.routeManager.resolveCode(&route)
+	if err != nil {
+		return err
+	}
+	route.Code = code
+
+	canary := *route.Canary
+	canary.Weight = 0
+	canary.RolledBackAt = now.Unix()
+	canary.RollbackReason = reason
+	route.Canary = &canary
+	if _, err := dr.routeManager.UpdateRoute(route.ID, route); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Canary of route %s rolled back: %s", route.ID, reason)
+	log.Printf("🚨 %s", message)
+	dr.logForwarder.Emit(LogEvent{
+		Type:      logEventCanaryRollback,
+		Timestamp: now,
+		RouteID:   route.ID,
+		Variant:   routeVariantCanary,
+		Message:   message,
+	})
+	if dr.statsd != nil {
+		dr.statsd.Count("canary.rollback", 1, "route:"+route.ID)
+	}
+	return nil
+}
+
+// 🔧 新增：查看金丝雀路由各版本的错误率与回滚状态
+func (dr *DistributedRouter) canariesHandler(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"canaries":    dr.canaries.Reports(dr.routeManager.snapshot().list(), time.Now()),
+	})
+}
@@ -0,0 +1,260 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	chaosRedisKey        = "gateway:chaos"
+	chaosRefreshPeriod   = 5 * time.Second
+	chaosMaxRuleLifetime = 24 * time.Hour
+)
+
+// 故障注入规则（必须设置过期时间）
+type ChaosRule struct {
+	RouteID         string  `json:"route_id"`
+	LatencyMs       int     `json:"latency_ms,omitempty"`        // 固定注入延迟
+	LatencyJitterMs int     `json:"latency_jitter_ms,omitempty"` // 额外随机延迟上限
+	ErrorRate       float64 `json:"error_rate,omitempty"`        // 返回错误的概率 0-1
+	ErrorStatus     int     `json:"error_status,omitempty"`      // 注入错误的状态码，默认 503
+	ResetRate       float64 `json:"reset_rate,omitempty"`        // 直接重置连接的概率 0-1
+	ExpiresAt       int64   `json:"expires_at"`                  // 过期时间（Unix 秒）
+	CreatedAt       int64   `json:"created_at"`
+}
+
+// 校验规则
+func (r *ChaosRule) validate(now time.Time) error {
+	if r.ExpiresAt == 0 {
+		return fmt.Errorf("expires_at is required")
+	}
+	if r.ExpiresAt <= now.Unix() {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	if r.ExpiresAt > now.Add(chaosMaxRuleLifetime).Unix() {
+		return fmt.Errorf("expires_at must be within %v", chaosMaxRuleLifetime)
+	}
+	if r.LatencyMs < 0 || r.LatencyJitterMs < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	if r.ErrorRate < 0 || r.ErrorRate > 1 || r.ResetRate < 0 || r.ResetRate > 1 {
+		return fmt.Errorf("error_rate and reset_rate must be between 0 and 1")
+	}
+	if r.ErrorStatus != 0 && (r.ErrorStatus < 400 || r.ErrorStatus > 599) {
+		return fmt.Errorf("error_status must be a 4xx or 5xx code")
+	}
+	return nil
+}
+
+func (r *ChaosRule) expired(now time.Time) bool {
+	return now.Unix() >= r.ExpiresAt
+}
+
+// 故障注入管理器：规则存储在 Redis 中，各实例定时刷新本地副本
+type ChaosManager struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	rules        map[string]*ChaosRule
+	mutex        sync.RWMutex
+}
+
+func NewChaosManager(redisClient *redis.Client, redisEnabled bool) *ChaosManager {
+	cm := &ChaosManager{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		rules:        make(map[string]*ChaosRule),
+	}
+	if redisEnabled {
+		cm.refresh()
+		go cm.refreshLoop()
+	}
+	return cm
+}
+
+func (cm *ChaosManager) refreshLoop() {
+	ticker := time.NewTicker(chaosRefreshPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		cm.refresh()
+	}
+}
+
+// 从 Redis 重新加载规则并清理过期规则
+func (cm *ChaosManager) refresh() {
+	ctx := context.Background()
+	stored, err := cm.redisClient.HGetAll(ctx, chaosRedisKey).Result()
+	if err != nil {
+		log.Printf("Failed to load chaos rules: %v", err)
+		return
+	}
+
+	now := time.Now()
+	rules := make(map[string]*ChaosRule, len(stored))
+	for routeID, ruleJSON := range stored {
+		var rule ChaosRule
+		if err := json.Unmarshal([]byte(ruleJSON), &rule); err != nil {
+			continue
+		}
+		if rule.expired(now) {
+			cm.redisClient.HDel(ctx, chaosRedisKey, routeID)
+			continue
+		}
+		rules[routeID] = &rule
+	}
+
+	cm.mutex.Lock()
+	cm.rules = rules
+	cm.mutex.Unlock()
+}
+
+// 设置规则
+func (cm *ChaosManager) SetRule(rule *ChaosRule) error {
+	now := time.Now()
+	if err := rule.validate(now); err != nil {
+		return err
+	}
+	rule.CreatedAt = now.Unix()
+
+	if cm.redisEnabled {
+		ruleJSON, _ := json.Marshal(rule)
+		if err := cm.redisClient.HSet(context.Background(), chaosRedisKey, rule.RouteID, ruleJSON).Err(); err != nil {
+			return fmt.Errorf("failed to save chaos rule: %v", err)
+		}
+	}
+
+	cm.mutex.Lock()
+	cm.rules[rule.RouteID] = rule
+	cm.mutex.Unlock()
+
+	log.Printf("🐒 Chaos rule set for route %s (expires at %d)", rule.RouteID, rule.ExpiresAt)
+	return nil
+}
+
+// 删除规则
+func (cm *ChaosManager) DeleteRule(routeID string) error {
+	if cm.redisEnabled {
+		if err := cm.redisClient.HDel(context.Background(), chaosRedisKey, routeID).Err(); err != nil {
+			return fmt.Errorf("failed to delete chaos rule: %v", err)
+		}
+	}
+
+	cm.mutex.Lock()
+	delete(cm.rules, routeID)
+	cm.mutex.Unlock()
+
+	log.Printf("🐒 Chaos rule removed for route %s", routeID)
+	return nil
+}
+
+// 列出生效中的规则
+func (cm *ChaosManager) ListRules() []*ChaosRule {
+	now := time.Now()
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	rules := make([]*ChaosRule, 0, len(cm.rules))
+	for _, rule := range cm.rules {
+		if !rule.expired(now) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// 对匹配的路由注入故障，返回 true 表示请求已被处理
+func (cm *ChaosManager) inject(route *RouteConfig, w http.ResponseWriter, r *http.Request) bool {
+	cm.mutex.RLock()
+	rule := cm.rules[route.ID]
+	cm.mutex.RUnlock()
+
+	if rule == nil || rule.expired(time.Now()) {
+		return false
+	}
+
+	// 注入延迟
+	delay := time.Duration(rule.LatencyMs) * time.Millisecond
+	if rule.LatencyJitterMs > 0 {
+		delay += time.Duration(rand.Intn(rule.LatencyJitterMs+1)) * time.Millisecond
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+
+	// 注入连接重置
+	if rule.ResetRate > 0 && rand.Float64() < rule.ResetRate {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				if tcpConn, ok := conn.(*net.TCPConn); ok {
+					tcpConn.SetLinger(0)
+				}
+				conn.Close()
+				return true
+			}
+		}
+	}
+
+	// 注入错误响应
+	if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+		status := rule.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("X-Chaos-Injected", "error")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(gin.H{"error": "chaos: injected fault"})
+		return true
+	}
+
+	return false
+}
+
+// 🔧 新增：列出故障注入规则
+func (dr *DistributedRouter) listChaosRulesHandler(c *gin.Context) {
+	c.JSON(200, gin.H{"rules": dr.chaos.ListRules()})
+}
+
+// 🔧 新增：设置路由的故障注入规则
+func (dr *DistributedRouter) setChaosRuleHandler(c *gin.Context) {
+	var rule ChaosRule
+	if err := c.BindJSON(&rule); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	rule.RouteID = c.Param("routeId")
+
+	if _, exists := dr.routeManager.snapshot().get(rule.RouteID); !exists {
+		c.JSON(404, gin.H{"error": "route not found"})
+		return
+	}
+
+	if err := dr.chaos.SetRule(&rule); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "chaos rule set", "rule": rule})
+}
+
+// 🔧 新增：删除路由的故障注入规则
+func (dr *DistributedRouter) deleteChaosRuleHandler(c *gin.Context) {
+	if err := dr.chaos.DeleteRule(c.Param("routeId")); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "chaos rule deleted"})
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 🔧 新增：网关端口 TLS 配置，client_auth 为 request/require 时校验客户端证书（mTLS）
+func gatewayTLSConfig(settings static.GatewayTLSConfig) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	switch settings.ClientAuth {
+	case "", "none":
+		return config, nil
+	case "request":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid tls.client_auth: %s", settings.ClientAuth)
+	}
+
+	pem, err := os.ReadFile(settings.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", settings.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	return config, nil
+}
+
+// 配置的客户端证书身份请求头（未配置的项为空）
+func clientCertHeaderNames() []string {
+	headers := gatewaySettings().TLS.ClientCertHeaders
+	var names []string
+	for _, name := range []string{headers.Subject, headers.SAN, headers.Fingerprint} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// 用已校验的客户端证书设置身份请求头；客户端自带的同名请求头总是被移除，防止伪造
+func applyClientCertHeaders(r *http.Request) {
+	settings := gatewaySettings().TLS
+	if !settings.Enabled {
+		return
+	}
+	for _, name := range clientCertHeaderNames() {
+		r.Header.Del(name)
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	headers := settings.ClientCertHeaders
+	if headers.Subject != "" {
+		r.Header.Set(headers.Subject, cert.Subject.String())
+	}
+	if san := certificateSANs(cert); headers.SAN != "" && san != "" {
+		r.Header.Set(headers.SAN, san)
+	}
+	if headers.Fingerprint != "" {
+		sum := sha256.Sum256(cert.Raw)
+		r.Header.Set(headers.Fingerprint, hex.EncodeToString(sum[:]))
+	}
+}
+
+// 形如 "DNS:svc.example.com, URI:spiffe://example.com/billing, email:ops@example.com, IP:10.0.0.1"
+func certificateSANs(cert *x509.Certificate) string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, "URI:"+uri.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, "email:"+email)
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "IP:"+ip.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// 把客户端证书身份请求头复制到发往沙箱的请求
+func copyClientCertHeaders(dst, src http.Header) {
+	for _, name := range clientCertHeaderNames() {
+		if value := src.Get(name); value != "" {
+			dst.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"container/list"
+	"sync"
+)
+
+// 延迟加载代码的 LRU 缓存（按字节容量淘汰）
+type codeCache struct {
+	capacity int64
+	used     int64
+	items    map[string]*list.Element
+	order    *list.List
+	hits     int64
+	misses   int64
+	mutex    sync.Mutex
+}
+
+type codeCacheEntry struct {
+	routeID string
+	version int64
+	code    string
+}
+
+func newCodeCache(capacity int64) *codeCache {
+	return &codeCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// 获取代码，版本不一致视为未命中
+func (cc *codeCache) get(routeID string, version int64) (string, bool) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if elem, ok := cc.items[routeID]; ok {
+		entry := elem.Value.(*codeCacheEntry)
+		if entry.version == version {
+			cc.order.MoveToFront(elem)
+			cc.hits++
+			return entry.code, true
+		}
+	}
+	cc.misses++
+	return "", false
+}
+
+// 写入代码，超出容量时淘汰最久未使用的条目
+func (cc *codeCache) put(routeID string, version int64, code string) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if cc.capacity <= 0 || int64(len(code)) > cc.capacity {
+		return
+	}
+
+	if elem, ok := cc.items[routeID]; ok {
+		cc.removeElement(elem)
+	}
+
+	elem := cc.order.PushFront(&codeCacheEntry{routeID: routeID, version: version, code: code})
+	cc.items[routeID] = elem
+	cc.used += int64(len(code))
+
+	for cc.used > cc.capacity {
+		oldest := cc.order.Back()
+		if oldest == nil {
+			break
+		}
+		cc.removeElement(oldest)
+	}
+}
+
+// 移除路由的缓存代码（路由更新或删除时调用）
+func (cc *codeCache) invalidate(routeID string) {
+	if cc == nil {
+		return
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if elem, ok := cc.items[routeID]; ok {
+		cc.removeElement(elem)
+	}
+}
+
+func (cc *codeCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*codeCacheEntry)
+	cc.order.Remove(elem)
+	delete(cc.items, entry.routeID)
+	cc.used -= int64(len(entry.code))
+}
+
+// 缓存统计
+func (cc *codeCache) stats() map[string]interface{} {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	return map[string]interface{}{
+		"entries":        len(cc.items),
+		"used_bytes":     cc.used,
+		"capacity_bytes": cc.capacity,
+		"hits":           cc.hits,
+		"misses":         cc.misses,
+	}
+}
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"sort"
+)
+
+// 保留的配置版本对应记录上限，与删除记录上限相同
+const maxVersionMarks = maxRouteTombstones
+
+// 全局配置版本与本实例路由表快照版本的对应关系
+type versionMark struct {
+	global int64 // gateway:config:version（内存模式下等于快照版本）
+	local  int64 // 本实例路由表快照的版本
+}
+
+// 🔧 新增：全局配置版本（gateway:config:version）由所有实例共享，对外返回的 config_version 都使用它；
+// 路由表快照版本只在本实例内有意义，增量按本实例的快照版本计算，通过对应记录把全局版本换算为快照版本
+type configVersionTracker struct {
+	global  int64
+	pending int64 // 本实例写入后提升的版本，随写入后的快照一起生效
+	marks   []versionMark
+}
+
+// 提升全局配置版本：INCR 保证多实例之间单调递增，不受各实例时钟偏差影响。
+// 写操作随后发布新快照，新版本在发布时生效，避免同一版本对应写入前后两个快照
+func (rm *RouteManager) updateConfigVersion() {
+	if !rm.redisEnabled {
+		return
+	}
+
+	ctx := context.Background()
+	newVersion, err := rm.redisClient.Incr(ctx, "gateway:config:version").Result()
+	if err != nil {
+		log.Printf("Failed to update config version: %v", err)
+		return
+	}
+	rm.versionMutex.Lock()
+	if newVersion > rm.versions.pending {
+		rm.versions.pending = newVersion
+	}
+	rm.versionMutex.Unlock()
+}
+
+// 记录已知的全局配置版本（只增不减），并唤醒等待版本变化的 watch 请求
+func (rm *RouteManager) observeConfigVersion(version int64) {
+	rm.versionMutex.Lock()
+	if version <= rm.versions.global {
+		rm.versionMutex.Unlock()
+		return
+	}
+	rm.versions.global = version
+	rm.versions.mark(version, rm.snapshot().configVersion)
+	rm.versionMutex.Unlock()
+	rm.notifyTableChanged()
+}
+
+// 本实例最近写入的全局配置版本（包括尚未随快照生效的），随路由事件发布
+func (rm *RouteManager) latestConfigVersion() int64 {
+	rm.versionMutex.Lock()
+	defer rm.versionMutex.Unlock()
+	return max(rm.versions.global, rm.versions.pending)
+}
+
+// 同时读取全局配置版本和路由表快照，两者对应同一时刻
+func (rm *RouteManager) versionedSnapshot() (int64, *routeTable) {
+	rm.versionMutex.Lock()
+	defer rm.versionMutex.Unlock()
+	return rm.versions.global, rm.snapshot()
+}
+
+// 全局版本对应的本实例快照版本范围。增量从该版本下最早的快照算起（可能重复但不会遗漏），
+// watch 在路由表超过最晚的快照后才返回，避免同一版本反复返回重复的增量
+func (rm *RouteManager) localVersionFor(global int64) (first, last int64, ok bool) {
+	rm.versionMutex.Lock()
+	defer rm.versionMutex.Unlock()
+
+	marks := rm.versions.marks
+	i := sort.Search(len(marks), func(i int) bool { return marks[i].global >= global })
+	if i == len(marks) || marks[i].global != global {
+		return 0, 0, false
+	}
+	first = marks[i].local
+	for ; i < len(marks) && marks[i].global == global; i++ {
+		last = marks[i].local
+	}
+	return first, last, true
+}
+
+// 追加对应记录（调用方持有 versionMutex），超出上限时丢弃最早的记录
+func (t *configVersionTracker) mark(global, local int64) {
+	if n := len(t.marks); n > 0 && t.marks[n-1].global == global && t.marks[n-1].local == local {
+		return
+	}
+	t.marks = append(t.marks, versionMark{global: global, local: local})
+	if overflow := len(t.marks) - maxVersionMarks; overflow > 0 {
+		t.marks = append([]versionMark(nil), t.marks[overflow:]...)
+	}
+}
+
+// 🔧 新增：计算 since（全局配置版本）以来的路由增量。since 必须由本实例返回（instanceID 一致），
+// 其他实例或重启前返回的版本、以及对应记录已丢弃的版本都无法换算，返回全量（full_resync）
+func (rm *RouteManager) routeDelta(since int64, instanceID string) *RouteDelta {
+	global, table := rm.versionedSnapshot()
+
+	var delta *RouteDelta
+	if local, _, ok := rm.localVersionFor(since); ok && since > 0 && instanceID == rm.instanceID {
+		delta = table.deltaSince(local)
+	} else {
+		delta = table.fullDelta()
+	}
+	delta.Since = since
+	delta.ConfigVersion = global
+	delta.InstanceID = rm.instanceID
+	return delta
+}
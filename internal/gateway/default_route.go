@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 🔧 新增：没有路由匹配时使用的默认路由（按请求 Host 选择）。
+// 精确主机名优先，其次是最长的 *.example.com 通配，最后是 "*"；路由不存在或对当前租户不可见时返回 nil
+func (dr *DistributedRouter) defaultRoute(r *http.Request) *RouteConfig {
+	return dr.defaultRouteFor(requestHost(r), tenantFromRequest(r))
+}
+
+// 🔧 修改：gateway.default_routes 没有可用路由时，使用标记为 is_default 的路由
+func (dr *DistributedRouter) defaultRouteFor(host, tenant string) *RouteConfig {
+	table := dr.routeManager.snapshot()
+	if config := matchDefaultRouteHost(gatewaySettings().DefaultRoutes, host); config != nil {
+		route, exists := table.get(config.RouteID)
+		if exists && routeVisibleToTenant(&route, tenant) {
+			return &route
+		}
+	}
+	return table.defaultRoute(host, tenant, time.Now().Unix())
+}
+
+// 可见的 is_default 路由：限定租户的优先，其次是精确域名、通配域名、不限域名；不在生效时间窗口内的不使用
+func (t *routeTable) defaultRoute(host, tenant string, now int64) *RouteConfig {
+	var best *RouteConfig
+	bestScore := -1
+	for id := range t.defaults {
+		route := t.routes[id]
+		if !routeVisibleToTenant(&route, tenant) {
+			continue
+		}
+		if status := route.windowStatus(now); status == "pending" || status == "expired" {
+			continue
+		}
+		score, ok := routeHostBonus(strings.ToLower(route.Host), host)
+		if !ok {
+			continue
+		}
+		if route.Tenant != "" {
+			score += 2 * routeHostExactBonus
+		}
+		if score > bestScore || (score == bestScore && route.ID < best.ID) {
+			best, bestScore = &route, score
+		}
+	}
+	return best
+}
+
+// 同一租户、同一域名只能有一条默认路由（生效时间窗口不相交的除外）
+func defaultRouteConflict(table *routeTable, route RouteConfig) error {
+	if !route.IsDefault {
+		return nil
+	}
+	conflict := ""
+	for id := range table.defaults {
+		other := table.routes[id]
+		if id == route.ID || other.Tenant != route.Tenant || !strings.EqualFold(other.Host, route.Host) || !routeWindowsOverlap(route, other) {
+			continue
+		}
+		if conflict == "" || id < conflict {
+			conflict = id
+		}
+	}
+	if conflict != "" {
+		return fmt.Errorf("route %s is already the default route for this tenant and host", conflict)
+	}
+	return nil
+}
+
+func matchDefaultRouteHost(defaults []static.DefaultRouteConfig, host string) *static.DefaultRouteConfig {
+	var best *static.DefaultRouteConfig
+	bestScore := 0
+	for i := range defaults {
+		pattern := strings.ToLower(defaults[i].Host)
+		score := 0
+		switch {
+		case pattern == host:
+			score = 1 << 16
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			score = len(pattern)
+		case pattern == "*" || pattern == "":
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = &defaults[i], score
+		}
+	}
+	return best
+}
+
+// 请求的主机名（小写，不含端口）
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
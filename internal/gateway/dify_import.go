@@ -0,0 +1,204 @@
+package gateway
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	difyImportMaxBody        = 10 << 20
+	difyImportDefaultTimeout = 30
+)
+
+// Dify 应用/工作流导出（DSL）中导入需要的部分，JSON 导出同样可以解析
+type difyAppExport struct {
+	App struct {
+		Name string `yaml:"name"`
+		Mode string `yaml:"mode"`
+	} `yaml:"app"`
+	Workflow struct {
+		Graph struct {
+			Nodes []difyNode `yaml:"nodes"`
+		} `yaml:"graph"`
+	} `yaml:"workflow"`
+}
+
+type difyNode struct {
+	ID   string `yaml:"id"`
+	Data struct {
+		Type         string `yaml:"type"`
+		Title        string `yaml:"title"`
+		Desc         string `yaml:"desc"`
+		CodeLanguage string `yaml:"code_language"`
+		Code         string `yaml:"code"`
+		Timeout      int    `yaml:"timeout"`
+		Variables    []struct {
+			Variable string `yaml:"variable"`
+		} `yaml:"variables"`
+	} `yaml:"data"`
+}
+
+// Dify 代码语言 -> 沙箱类型
+var difyLanguages = map[string]string{
+	"python3":    "python",
+	"javascript": "nodejs",
+}
+
+// 无输入参数的代码节点追加入口调用，输出与 Dify 一致的 main() 返回值
+var difyEntrypoints = map[string]string{
+	"python": "\n\nif __name__ == \"__main__\":\n    import json\n    print(json.dumps(main()))\n",
+	"nodejs": "\n\nconsole.log(JSON.stringify(main()))\n",
+}
+
+var difySlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func difySlug(value string) string {
+	return strings.Trim(difySlugPattern.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
+
+// 导入结果
+type DifyImportResult struct {
+	App      string            `json:"app"`
+	Routes   []RouteConfig     `json:"routes"`
+	Skipped  map[string]string `json:"skipped,omitempty"` // 节点ID -> 原因
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// 把 Dify 导出中的代码节点转换为沙箱路由
+func convertDifyExport(data []byte, pathPrefix string, timeout int) (*DifyImportResult, error) {
+	var export difyAppExport
+	if err := yaml.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Dify export: %v", err)
+	}
+	if len(export.Workflow.Graph.Nodes) == 0 {
+		return nil, fmt.Errorf("Dify export contains no workflow nodes")
+	}
+
+	appSlug := difySlug(export.App.Name)
+	if appSlug == "" {
+		appSlug = "app"
+	}
+	if pathPrefix == "" {
+		pathPrefix = "/dify/" + appSlug
+	}
+	pathPrefix = "/" + strings.Trim(pathPrefix, "/")
+
+	result := &DifyImportResult{
+		App:     export.App.Name,
+		Routes:  make([]RouteConfig, 0),
+		Skipped: make(map[string]string),
+	}
+	usedPaths := make(map[string]bool)
+
+	for _, node := range export.Workflow.Graph.Nodes {
+		if node.Data.Type != "code" {
+			if node.Data.Type != "start" && node.Data.Type != "end" {
+				result.Skipped[node.ID] = fmt.Sprintf("node type %q has no sandbox equivalent", node.Data.Type)
+			}
+			continue
+		}
+
+		sandboxType, ok := difyLanguages[node.Data.CodeLanguage]
+		if !ok {
+			result.Skipped[node.ID] = fmt.Sprintf("unsupported code language: %s", node.Data.CodeLanguage)
+			continue
+		}
+
+		// 路径按节点标题生成，重名时追加节点ID
+		slug := difySlug(node.Data.Title)
+		if slug == "" || usedPaths[slug] {
+			slug = strings.Trim(slug+"-"+difySlug(node.ID), "-")
+		}
+		usedPaths[slug] = true
+
+		code := node.Data.Code
+		if len(node.Data.Variables) == 0 {
+			code = strings.TrimRight(code, "\n") + difyEntrypoints[sandboxType]
+		} else {
+			result.Warnings = append(result.Warnings, fmt.Sprintf(
+				"node %s (%s) takes input variables; sandbox routes run the code as-is, add an entrypoint before use",
+				node.ID, node.Data.Title))
+		}
+
+		routeTimeout := timeout
+		if node.Data.Timeout > 0 {
+			routeTimeout = node.Data.Timeout
+		}
+
+		result.Routes = append(result.Routes, RouteConfig{
+			ID:          "dify-" + appSlug + "-" + difySlug(node.ID),
+			Path:        pathPrefix + "/" + slug,
+			Method:      "POST",
+			Handler:     "sandbox",
+			SandboxType: sandboxType,
+			Code:        code,
+			Timeout:     routeTimeout,
+			Metadata: map[string]string{
+				"source":          "dify",
+				"dify_app":        export.App.Name,
+				"dify_node_id":    node.ID,
+				"dify_node_title": node.Data.Title,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// 🔧 新增：从 Dify 应用导出（YAML/JSON）导入沙箱路由
+func (dr *DistributedRouter) importDifyHandler(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, difyImportMaxBody))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	timeout := difyImportDefaultTimeout
+	if value := c.Query("timeout"); value != "" {
+		if timeout, err = strconv.Atoi(value); err != nil || timeout <= 0 {
+			c.JSON(400, gin.H{"error": "invalid timeout"})
+			return
+		}
+	}
+
+	result, err := convertDifyExport(data, c.Query("path_prefix"), timeout)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		c.JSON(200, gin.H{"dry_run": true, "result": result})
+		return
+	}
+
+	summary := &RouteApplySummary{
+		Created: make([]string, 0),
+		Updated: make([]string, 0),
+		Deleted: make([]string, 0),
+		Errors:  make(map[string]string),
+	}
+	for _, route := range result.Routes {
+		if _, exists := dr.routeManager.snapshot().get(route.ID); exists {
+			if _, err := dr.routeManager.UpdateRoute(route.ID, route); err != nil {
+				summary.Errors[route.ID] = err.Error()
+				continue
+			}
+			summary.Updated = append(summary.Updated, route.ID)
+		} else {
+			if err := dr.routeManager.AddRoute(route); err != nil {
+				summary.Errors[route.ID] = err.Error()
+				continue
+			}
+			summary.Created = append(summary.Created, route.ID)
+		}
+	}
+
+	c.JSON(200, gin.H{"message": "Dify export imported", "result": result, "summary": summary})
+}
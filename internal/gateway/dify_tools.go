@@ -0,0 +1,222 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+const difyExtensionMaxBody = 1 << 20
+
+// 作为 Dify 工具暴露的路由：metadata.dify_tool 为 "true"
+func isDifyTool(route *RouteConfig) bool {
+	return route.Metadata["dify_tool"] == "true"
+}
+
+// 注册 Dify 集成接口（网关端口）
+//
+//	GET  {prefix}/openapi.json      自定义工具导入用的 OpenAPI 描述
+//	POST {prefix}/tools/{routeId}   工具调用，请求体原样交给路由处理
+//	POST {prefix}/extension         API 扩展（ping、app.external_data_tool.query）
+func (dr *DistributedRouter) setupDifyRoutes(prefix string) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	dr.muxRouter.HandleFunc(prefix+"/openapi.json", dr.difyAuthenticated(dr.difyOpenAPIHandler)).Methods(http.MethodGet)
+	dr.muxRouter.HandleFunc(prefix+"/tools/{routeId}", dr.difyAuthenticated(dr.difyToolHandler)).Methods(http.MethodPost)
+	dr.muxRouter.HandleFunc(prefix+"/extension", dr.difyAuthenticated(dr.difyExtensionHandler)).Methods(http.MethodPost)
+}
+
+// Dify 通过 Authorization: Bearer {api_key} 传递密钥，非 JWT 的 Bearer 值按 X-Api-Key 校验
+func (dr *DistributedRouter) difyAuthenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := bearerToken(r); ok && r.Header.Get("X-Api-Key") == "" && strings.Count(token, ".") != 2 {
+			r = r.Clone(r.Context())
+			r.Header.Set("X-Api-Key", token)
+			r.Header.Del("Authorization")
+		}
+
+		principal, err := dr.verifyGatewayRequest(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
+			return
+		}
+		if info := logInfoFromRequest(r); info != nil {
+			info.Principal = principal.Name
+		}
+		if err := resolveTenant(r, principal); err != nil {
+			writeTenantError(w, err)
+			return
+		}
+		next(w, withPrincipal(r, principal))
+	}
+}
+
+// 🔧 新增：生成 Dify 自定义工具使用的 OpenAPI 描述（只包含调用方有权访问的工具路由）
+func (dr *DistributedRouter) difyOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	prefix := strings.TrimSuffix(r.URL.Path, "/openapi.json")
+
+	principal := principalFromRequest(r)
+	host := requestHost(r)
+	paths := gin.H{}
+	for _, route := range dr.routeManager.snapshot().list() {
+		route := route
+		if !isDifyTool(&route) || !principal.allows(&route) || !routeVisibleToTenant(&route, principal.Tenant) || !routeVisibleToHost(&route, host) {
+			continue
+		}
+
+		// metadata.dify_parameters 声明工具参数（逗号分隔）
+		properties := gin.H{}
+		for _, name := range strings.Split(route.Metadata["dify_parameters"], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				properties[name] = gin.H{"type": "string"}
+			}
+		}
+
+		// 🔧 新增：优先使用路由的 description 字段，兼容 metadata.description
+		summary := route.Description
+		if summary == "" {
+			summary = route.Metadata["description"]
+		}
+		if summary == "" {
+			summary = route.ID
+		}
+		operation := gin.H{
+			"operationId": route.ID,
+			"summary":     summary,
+			"requestBody": gin.H{
+				"content": gin.H{
+					"application/json": gin.H{
+						"schema": gin.H{"type": "object", "properties": properties},
+					},
+				},
+			},
+			"responses": gin.H{"200": gin.H{"description": "route response"}},
+		}
+		if route.Description != "" {
+			operation["description"] = route.Description
+		}
+		if route.DocsURL != "" {
+			operation["externalDocs"] = gin.H{"url": route.DocsURL}
+		}
+		if route.ContactOwner != "" {
+			operation["x-contact-owner"] = route.ContactOwner
+		}
+		paths["/tools/"+route.ID] = gin.H{"post": operation}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gin.H{
+		"openapi": "3.1.0",
+		"info":    gin.H{"title": "dify-router tools", "version": Version},
+		"servers": []gin.H{{"url": scheme + "://" + r.Host + prefix}},
+		"paths":   paths,
+	})
+}
+
+// 🔧 新增：Dify 工具调用，按路由ID执行路由
+func (dr *DistributedRouter) difyToolHandler(w http.ResponseWriter, r *http.Request) {
+	route, exists := dr.routeManager.snapshot().get(mux.Vars(r)["routeId"])
+	if !exists || !isDifyTool(&route) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(gin.H{"error": "tool not found"})
+		return
+	}
+	dr.serveRoute(&route, w, r)
+}
+
+// Dify API 扩展请求
+type difyExtensionRequest struct {
+	Point  string `json:"point"`
+	Params struct {
+		AppID        string                 `json:"app_id"`
+		ToolVariable string                 `json:"tool_variable"`
+		Inputs       map[string]interface{} `json:"inputs"`
+		Query        string                 `json:"query"`
+	} `json:"params"`
+}
+
+// 缓存路由响应，转换为 API 扩展的 result 字段
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// 🔧 新增：Dify API 扩展（外部数据工具），tool_variable 对应路由ID
+func (dr *DistributedRouter) difyExtensionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req difyExtensionRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, difyExtensionMaxBody)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(gin.H{"error": "invalid request body"})
+		return
+	}
+
+	switch req.Point {
+	case "ping":
+		json.NewEncoder(w).Encode(gin.H{"result": "pong"})
+		return
+	case "app.external_data_tool.query":
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(gin.H{"error": "unsupported point: " + req.Point})
+		return
+	}
+
+	route, exists := dr.routeManager.snapshot().get(req.Params.ToolVariable)
+	if !exists || !isDifyTool(&route) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(gin.H{"error": "tool not found"})
+		return
+	}
+
+	// 以 inputs 和 query 作为请求体调用路由
+	body, _ := json.Marshal(gin.H{"app_id": req.Params.AppID, "inputs": req.Params.Inputs, "query": req.Params.Query})
+	toolReq := r.Clone(r.Context())
+	toolReq.Body = io.NopCloser(bytes.NewReader(body))
+	toolReq.ContentLength = int64(len(body))
+	toolReq.Header.Set("Content-Type", "application/json")
+
+	recorder := &bufferedResponse{header: make(http.Header)}
+	dr.serveRoute(&route, recorder, toolReq)
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+
+	if recorder.status >= 400 {
+		w.WriteHeader(recorder.status)
+		json.NewEncoder(w).Encode(gin.H{"error": strings.TrimSpace(recorder.body.String())})
+		return
+	}
+	json.NewEncoder(w).Encode(gin.H{"result": recorder.body.String()})
+}
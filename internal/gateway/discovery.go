@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const gatewayRegistryKey = "gateway:instances"
+
+// 网关版本，构建时通过 -ldflags "-X github.com/dify-router/dify-router/internal/gateway.Version=..." 注入
+var Version = "dev"
+
+// 网关实例注册信息
+type GatewayRegistration struct {
+	InstanceID    string `json:"instance_id"`
+	Hostname      string `json:"hostname"`
+	GatewayURL    string `json:"gateway_url"`
+	ManagementURL string `json:"management_url"`
+	Version       string `json:"version"`
+	ConfigVersion int64  `json:"config_version"`
+	IsLeader      bool   `json:"is_leader"`
+	StartedAt     int64  `json:"started_at"`
+	LastHeartbeat int64  `json:"last_heartbeat"`
+	ExpiresAt     int64  `json:"expires_at"`
+}
+
+// 🔧 新增：启动自注册，定时把本实例信息写入 Redis
+func (dr *DistributedRouter) startSelfRegistration() {
+	settings := gatewaySettings().Discovery
+	if !settings.Enabled || !dr.routeManager.redisEnabled {
+		return
+	}
+
+	ttl := time.Duration(settings.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	hostname, _ := os.Hostname()
+	address := strings.Trim(settings.AdvertiseAddress, "[]")
+	if address == "" {
+		address = hostname
+	}
+
+	registration := GatewayRegistration{
+		InstanceID:    dr.routeManager.instanceID,
+		Hostname:      hostname,
+		GatewayURL:    "http://" + net.JoinHostPort(address, strconv.Itoa(dr.gatewayPort)),
+		ManagementURL: "http://" + net.JoinHostPort(address, strconv.Itoa(dr.managementPort)),
+		Version:       Version,
+		StartedAt:     time.Now().Unix(),
+	}
+
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			// 排空期间不再续约，避免重新注册已摘除的实例
+			if !dr.draining.Load() {
+				dr.heartbeatRegistration(&registration, ttl)
+			}
+			<-ticker.C
+		}
+	}()
+	log.Printf("📡 Gateway self-registration enabled: %s (management: %s)", registration.InstanceID, registration.ManagementURL)
+}
+
+func (dr *DistributedRouter) heartbeatRegistration(registration *GatewayRegistration, ttl time.Duration) {
+	now := time.Now()
+	registration.ConfigVersion = dr.routeManager.snapshot().configVersion
+	registration.IsLeader = dr.leader.IsLeader()
+	registration.LastHeartbeat = now.Unix()
+	registration.ExpiresAt = now.Add(ttl).Unix()
+
+	data, _ := json.Marshal(registration)
+	if err := dr.redisClient.HSet(context.Background(), gatewayRegistryKey, registration.InstanceID, data).Err(); err != nil {
+		log.Printf("Failed to register gateway instance: %v", err)
+	}
+}
+
+// 列出存活的网关实例，并清理已过期的注册
+func (dr *DistributedRouter) listGatewayRegistrations(ctx context.Context) ([]GatewayRegistration, error) {
+	stored, err := dr.redisClient.HGetAll(ctx, gatewayRegistryKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	registrations := make([]GatewayRegistration, 0, len(stored))
+	for instanceID, data := range stored {
+		var registration GatewayRegistration
+		if err := json.Unmarshal([]byte(data), &registration); err != nil || registration.ExpiresAt < now {
+			dr.redisClient.HDel(ctx, gatewayRegistryKey, instanceID)
+			continue
+		}
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].InstanceID < registrations[j].InstanceID
+	})
+	return registrations, nil
+}
+
+// 🔧 新增：列出已注册的网关实例（服务发现）
+func (dr *DistributedRouter) listGatewaysHandler(c *gin.Context) {
+	if !dr.routeManager.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	registrations, err := dr.listGatewayRegistrations(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"gateways": registrations,
+		"self":     dr.routeManager.instanceID,
+	})
+}
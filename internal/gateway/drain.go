@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 🔧 新增：探针路径在路由匹配之前处理，同路径的路由永远无法被访问，创建和更新时直接拒绝
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// 🔧 新增：负载均衡探针（网关端口，无需认证，不计入访问日志）：
+// /healthz 只要进程存活就返回 200；/readyz 在排空期间或健康策略判定为 unhealthy 时返回 503，负载均衡据此停止转发新请求
+func (dr *DistributedRouter) serveProbe(w http.ResponseWriter, r *http.Request) bool {
+	switch r.URL.Path {
+	case "/healthz":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(gin.H{"status": "ok"})
+		return true
+	case "/readyz":
+		// 🔧 修改：与 /admin/health 使用同一份健康报告，默认只有排空时失败（依赖降级时仍可接收流量）
+		dr.readinessHandler(w, r)
+		return true
+	}
+	return false
+}
+
+// 等待 SIGTERM/SIGINT 后排空：先让 /readyz 失败，等待 drain_period 让负载均衡摘除本实例，再优雅关闭监听
+func (dr *DistributedRouter) shutdownOnSignal(servers ...*http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	signal.Stop(signals)
+
+	settings := gatewaySettings().Shutdown
+	drainPeriod := time.Duration(settings.DrainPeriod) * time.Second
+	dr.draining.Store(true)
+	log.Printf("🛑 Received %v, draining for %v before closing listeners", sig, drainPeriod)
+	dr.deregisterInstance()
+	time.Sleep(drainPeriod)
+
+	timeout := time.Duration(settings.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("⚠️  Server on %s did not shut down cleanly: %v", server.Addr, err)
+		}
+	}
+	dr.leader.Stop()
+	log.Printf("👋 Gateway shut down")
+}
+
+// 排空开始时从服务发现中移除本实例
+func (dr *DistributedRouter) deregisterInstance() {
+	if !gatewaySettings().Discovery.Enabled || !dr.routeManager.redisEnabled {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := dr.redisClient.HDel(ctx, gatewayRegistryKey, dr.routeManager.instanceID).Err(); err != nil {
+		log.Printf("Failed to deregister gateway instance: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// echo 响应中最多返回的请求体大小
+const maxEchoBodyBytes = 64 << 10
+
+// 调试输出中需要隐藏值的请求头（🔧 另按 gateway.redaction 隐藏配置的请求头并按正则脱敏）
+func redactedHeaderValue(name, value string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Gateway-Signature":
+		return redactedValue
+	}
+	return currentRedactor().header(name, value)
+}
+
+// 🔧 新增：echo 处理器：按网关看到的样子返回请求（规范化后的方法和路径、匹配的路由、经过转换的请求头、客户端 IP 和认证调用方），
+// 用于调试中间件链配置；敏感请求头的值被隐藏
+func (dr *DistributedRouter) handleEchoRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = redactedHeaderValue(name, strings.Join(values, ", "))
+	}
+
+	var body string
+	truncated := false
+	if r.Body != nil {
+		data, _ := io.ReadAll(io.LimitReader(r.Body, maxEchoBodyBytes+1))
+		if len(data) > maxEchoBodyBytes {
+			data, truncated = data[:maxEchoBodyBytes], true
+		}
+		body = string(data)
+	}
+
+	echo := map[string]interface{}{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"raw_path":    r.URL.EscapedPath(),
+		"match_path":  matchPath(r),
+		"query":       r.URL.Query(),
+		"host":        r.Host,
+		"proto":       r.Proto,
+		"tls":         r.TLS != nil,
+		"client_ip":   clientIP(r),
+		"remote_addr": r.RemoteAddr,
+		"headers":     headers,
+		"body":        body,
+		"route": map[string]interface{}{
+			"id":      route.ID,
+			"path":    route.Path,
+			"method":  route.Method,
+			"methods": route.methodList(),
+			"handler": route.Handler,
+			"tenant":  route.Tenant,
+			"host":    route.Host,
+			"auth":    route.authMode(),
+		},
+	}
+	if truncated {
+		echo["body_truncated"] = true
+	}
+	if principal := principalFromRequest(r); principal != nil {
+		echo["principal"] = map[string]interface{}{
+			"name":   principal.Name,
+			"method": principal.Method,
+			"tenant": principal.Tenant,
+			"scope":  principal.Scope,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(echo)
+}
+
+// 按名称排序的请求头（值已隐藏敏感信息），用于调试日志
+func redactedHeaderLines(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+": "+redactedHeaderValue(name, strings.Join(header[name], ", ")))
+	}
+	return lines
+}
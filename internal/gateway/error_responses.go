@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 可以自定义响应的网关错误：404（路由对调用方不可见）、502（上游失败或响应无效）、503（没有可用沙箱、上游或依赖）
+var routeErrorStatuses = map[string]bool{"404": true, "502": true, "503": true}
+
+// 🔧 新增：路由自定义的错误响应，替代网关生成的 {"error": ...}（上游自己返回的错误响应不受影响）。
+// body 可以引用 ${status}、${error}、${metadata.<key>}（路由 metadata），以及请求头改写规则可用的变量
+type RouteErrorResponse struct {
+	Status      int    `json:"status,omitempty"`       // 返回的状态码，默认为原状态码
+	ContentType string `json:"content_type,omitempty"` // 默认 application/json
+	Body        string `json:"body"`
+}
+
+var errorBodyVariablePattern = regexp.MustCompile(`\$\{([a-z_]+)(?:\.([A-Za-z0-9_.-]+))?\}`)
+
+// 校验路由的 error_responses（键为状态码）
+func validateRouteErrorResponses(responses map[string]*RouteErrorResponse) error {
+	for key, response := range responses {
+		if !routeErrorStatuses[key] {
+			return fmt.Errorf("invalid error_responses key %q (use 404, 502 or 503)", key)
+		}
+		if response == nil {
+			return fmt.Errorf("error_responses.%s must not be empty", key)
+		}
+		if response.Status != 0 && (response.Status < 400 || response.Status > 599) {
+			return fmt.Errorf("error_responses.%s: status must be between 400 and 599", key)
+		}
+		if strings.ContainsAny(response.ContentType, "\r\n") {
+			return fmt.Errorf("error_responses.%s: content_type must not contain line breaks", key)
+		}
+		for _, match := range errorBodyVariablePattern.FindAllStringSubmatch(response.Body, -1) {
+			name, field := match[1], match[2]
+			switch {
+			case name == "metadata" && field != "":
+			case (name == "status" || name == "error") && field == "":
+			case headerVariables[name] != nil && field == "":
+			default:
+				return fmt.Errorf("error_responses.%s: unknown variable %s", key, match[0])
+			}
+		}
+	}
+	return nil
+}
+
+// 🔧 新增：返回网关生成的错误，路由为该状态码配置了 error_responses 时按模板返回
+func writeRouteError(w http.ResponseWriter, r *http.Request, route *RouteConfig, status int, body map[string]interface{}) {
+	if route != nil {
+		if response := route.ErrorResponses[strconv.Itoa(status)]; response != nil {
+			response.write(w, r, route, status, body)
+			return
+		}
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (response *RouteErrorResponse) write(w http.ResponseWriter, r *http.Request, route *RouteConfig, status int, body map[string]interface{}) {
+	contentType := response.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	message, _ := body["error"].(string)
+	escape := func(value string) string { return value }
+	switch {
+	case strings.Contains(contentType, "json"):
+		escape = func(value string) string {
+			quoted, _ := json.Marshal(value)
+			return string(quoted[1 : len(quoted)-1])
+		}
+	case strings.Contains(contentType, "html") || strings.Contains(contentType, "xml"):
+		escape = html.EscapeString
+	}
+
+	// 变量值按内容类型转义，模板本身原样输出
+	rendered := errorBodyVariablePattern.ReplaceAllStringFunc(response.Body, func(match string) string {
+		parts := errorBodyVariablePattern.FindStringSubmatch(match)
+		switch name := parts[1]; {
+		case name == "metadata":
+			return escape(route.Metadata[parts[2]])
+		case name == "status":
+			return strconv.Itoa(status)
+		case name == "error":
+			return escape(message)
+		case headerVariables[name] != nil:
+			return escape(headerVariables[name](route, r))
+		}
+		return match
+	})
+
+	if response.Status != 0 {
+		status = response.Status
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	w.Write([]byte(rendered))
+}
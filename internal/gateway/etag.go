@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// 🔧 修改：根据路由表内容生成 ETag：路由ID及其版本（写入时生成，随路由保存在 Redis 中）、延迟加载标记和分组前缀。
+// 内容相同的实例返回相同的 ETag，经负载均衡轮询不同实例时不会重复下载；每个快照只计算一次
+func (t *routeTable) contentETag() string {
+	t.etagOnce.Do(func() {
+		ids := make([]string, 0, len(t.routes))
+		for id := range t.routes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		hash := fnv.New64a()
+		for _, id := range ids {
+			fmt.Fprintf(hash, "%s\x00%d\x00%t\n", id, t.versions[id], t.lazyCode[id])
+		}
+		groups := make([]string, 0, len(t.groupPrefixes))
+		for id, prefix := range t.groupPrefixes {
+			groups = append(groups, id+"\x00"+prefix)
+		}
+		sort.Strings(groups)
+		for _, group := range groups {
+			fmt.Fprintf(hash, "group\x00%s\n", group)
+		}
+		t.etag = fmt.Sprintf("\"%016x\"", hash.Sum64())
+	})
+	return t.etag
+}
+
+// 🔧 新增：路由列表的 ETag：列表中的 window_status 随时间变化，有生效时间窗口的路由表把当前窗口纪元并入 ETag，
+// 路由进入或离开窗口后轮询方不会收到带有过期状态的 304；纪元只取决于路由表和时间，各实例仍然一致
+func (t *routeTable) listETag(now int64) string {
+	etag := t.contentETag()
+	if len(t.windows) == 0 {
+		return etag
+	}
+	return fmt.Sprintf("%s-w%d\"", strings.TrimSuffix(etag, "\""), t.windowEpoch(now))
+}
+
+// 检查 If-None-Match 是否命中当前 ETag（支持多个值、弱校验和 *）
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
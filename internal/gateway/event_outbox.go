@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 事件发布可靠性模式
+const (
+	eventPublishFireAndForget = "fire-and-forget" // 发布失败只记录日志（默认）
+	eventPublishOutbox        = "outbox"          // 发布失败进入本地发件箱，后台按顺序重试
+)
+
+// 🔧 新增：启动时校验发布模式，拼写错误（如 sync）不再静默退化为 fire-and-forget；未配置时为 fire-and-forget
+func validateEventPublishConfig(config static.EventPublishConfig) error {
+	switch config.Mode {
+	case "", eventPublishFireAndForget, eventPublishOutbox:
+		return nil
+	default:
+		return fmt.Errorf("invalid gateway.event_publish.mode: %s (expected fire-and-forget or outbox)", config.Mode)
+	}
+}
+
+// 🔧 新增：路由事件本地发件箱，保证发布失败的事件最终送达事件流
+type eventOutbox struct {
+	events   []*RouteEvent
+	queuedAt []time.Time
+	dropped  int64
+	mutex    sync.Mutex
+	wake     chan struct{}
+}
+
+func newEventOutbox() *eventOutbox {
+	return &eventOutbox{wake: make(chan struct{}, 1)}
+}
+
+// 发布路由事件；outbox 模式下失败的事件（以及排在其后的事件，保证顺序）进入发件箱
+func (rm *RouteManager) publishRouteEvent(event *RouteEvent) {
+	if event.ConfigVersion == 0 {
+		event.ConfigVersion = rm.latestConfigVersion()
+	}
+	settings := gatewaySettings().EventPublish
+	if settings.Mode != eventPublishOutbox {
+		if err := rm.eventStream.PublishRouteEvent(context.Background(), event); err != nil {
+			opLogf(logCategoryEvent, logLevelWarn, "Failed to publish %s event: %v", event.EventType, err)
+		}
+		return
+	}
+
+	if rm.outbox.depth() == 0 {
+		err := rm.eventStream.PublishRouteEvent(context.Background(), event)
+		if err == nil {
+			return
+		}
+		opLogf(logCategoryEvent, logLevelWarn, "Failed to publish %s event, queued to outbox: %v", event.EventType, err)
+	}
+	rm.outbox.push(event, settings.MaxEvents)
+}
+
+func (o *eventOutbox) push(event *RouteEvent, limit int) {
+	o.mutex.Lock()
+	o.events = append(o.events, event)
+	o.queuedAt = append(o.queuedAt, time.Now())
+	// 超过上限时丢弃最旧的事件，由定时增量同步兜底
+	if limit > 0 && len(o.events) > limit {
+		overflow := len(o.events) - limit
+		o.events = o.events[overflow:]
+		o.queuedAt = o.queuedAt[overflow:]
+		o.dropped += int64(overflow)
+		opLogf(logCategoryEvent, logLevelWarn, "⚠️  Event outbox full, dropped %d oldest events", overflow)
+	}
+	o.mutex.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *eventOutbox) depth() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.events)
+}
+
+func (o *eventOutbox) peek() *RouteEvent {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.events) == 0 {
+		return nil
+	}
+	return o.events[0]
+}
+
+// 移除已发布的队首事件（队首可能已因溢出被丢弃）
+func (o *eventOutbox) remove(event *RouteEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.events) > 0 && o.events[0] == event {
+		o.events = o.events[1:]
+		o.queuedAt = o.queuedAt[1:]
+	}
+}
+
+// 后台按顺序重试发件箱中的事件，遇到失败则等待下一个间隔
+func (rm *RouteManager) runEventOutbox() {
+	interval := time.Duration(gatewaySettings().EventPublish.RetryInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rm.outbox.wake:
+		case <-ticker.C:
+		}
+		for event := rm.outbox.peek(); event != nil; event = rm.outbox.peek() {
+			if err := rm.eventStream.PublishRouteEvent(context.Background(), event); err != nil {
+				opLogf(logCategoryEvent, logLevelWarn, "Event outbox retry failed (%d pending): %v", rm.outbox.depth(), err)
+				break
+			}
+			rm.outbox.remove(event)
+			opLogf(logCategoryEvent, logLevelInfo, "📤 Published queued %s event for route %s", event.EventType, event.RouteID)
+		}
+	}
+}
+
+// 发件箱状态
+func (o *eventOutbox) Stats() map[string]interface{} {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	oldestAge := 0.0
+	if len(o.queuedAt) > 0 {
+		oldestAge = time.Since(o.queuedAt[0]).Seconds()
+	}
+	return map[string]interface{}{
+		"mode":               gatewaySettings().EventPublish.Mode,
+		"depth":              len(o.events),
+		"dropped":            o.dropped,
+		"oldest_age_seconds": oldestAge,
+	}
+}
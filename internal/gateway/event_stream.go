@@ -34,6 +34,11 @@ type EventHandler interface {
 	HandleEvent(event *RouteEvent) error
 }
 
+// 🔧 新增：批量事件处理器：一次读取到的多条事件一起处理，返回与事件一一对应的结果
+type BatchEventHandler interface {
+	HandleEvents(events []*RouteEvent) []error
+}
+
 // 创建新的事件流管理器
 func NewEventStreamManager(redisClient *redis.Client) *EventStreamManager {
 	return &EventStreamManager{
@@ -72,7 +77,7 @@ func (esm *EventStreamManager) PublishRouteEvent(ctx context.Context, event *Rou
 		return fmt.Errorf("failed to publish event: %v", err)
 	}
 
-	log.Printf("📨 Published event: %s - %s - %s", event.EventType, event.RouteID, messageID)
+	opLogf(logCategoryEvent, logLevelDebug, "📨 Published event: %s - %s - %s", event.EventType, event.RouteID, messageID)
 	return nil
 }
 
@@ -141,7 +146,7 @@ func (ec *EventConsumer) consumeEvents() {
 			}).Result()
 
 			if err != nil && err != redis.Nil {
-				log.Printf("Error reading from stream: %v", err)
+				opLogf(logCategoryEvent, logLevelError, "Error reading from stream: %v", err)
 				time.Sleep(1 * time.Second)
 				continue
 			}
@@ -150,10 +155,16 @@ func (ec *EventConsumer) consumeEvents() {
 				continue
 			}
 
+			// 🔧 新增：处理器支持批量时，一次读取到的消息一起处理
+			if batch, ok := ec.handler.(BatchEventHandler); ok {
+				ec.processMessages(ctx, batch, streams[0].Messages)
+				continue
+			}
+
 			// 处理消息
 			for _, message := range streams[0].Messages {
 				if err := ec.processMessage(ctx, message); err != nil {
-					log.Printf("Error processing message %s: %v", message.ID, err)
+					opLogf(logCategoryEvent, logLevelError, "Error processing message %s: %v", message.ID, err)
 				}
 			}
 		}
@@ -162,28 +173,70 @@ func (ec *EventConsumer) consumeEvents() {
 
 // 处理单个消息
 func (ec *EventConsumer) processMessage(ctx context.Context, message redis.XMessage) error {
+	event, err := decodeEventMessage(message)
+	if err != nil {
+		return err
+	}
+
+	// 调用事件处理器
+	if err := ec.handler.HandleEvent(event); err != nil {
+		return fmt.Errorf("event handler failed: %v", err)
+	}
+
+	return ec.ackMessage(ctx, message)
+}
+
+// 🔧 新增：批量处理消息，只确认处理成功的消息（无法解析或处理失败的消息留在待处理列表中）
+func (ec *EventConsumer) processMessages(ctx context.Context, handler BatchEventHandler, messages []redis.XMessage) {
+	events := make([]*RouteEvent, 0, len(messages))
+	decoded := make([]redis.XMessage, 0, len(messages))
+	for _, message := range messages {
+		event, err := decodeEventMessage(message)
+		if err != nil {
+			opLogf(logCategoryEvent, logLevelError, "Error processing message %s: %v", message.ID, err)
+			continue
+		}
+		events = append(events, event)
+		decoded = append(decoded, message)
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	for i, err := range handler.HandleEvents(events) {
+		if err == nil {
+			err = ec.ackMessage(ctx, decoded[i])
+		} else {
+			err = fmt.Errorf("event handler failed: %v", err)
+		}
+		if err != nil {
+			opLogf(logCategoryEvent, logLevelError, "Error processing message %s: %v", decoded[i].ID, err)
+		}
+	}
+}
+
+// 解析消息中的事件
+func decodeEventMessage(message redis.XMessage) (*RouteEvent, error) {
 	eventData, exists := message.Values["event_data"].(string)
 	if !exists {
-		return fmt.Errorf("missing event_data in message")
+		return nil, fmt.Errorf("missing event_data in message")
 	}
 
 	var event RouteEvent
 	if err := json.Unmarshal([]byte(eventData), &event); err != nil {
-		return fmt.Errorf("failed to unmarshal event: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal event: %v", err)
 	}
+	return &event, nil
+}
 
-	// 调用事件处理器
-	if err := ec.handler.HandleEvent(&event); err != nil {
-		return fmt.Errorf("event handler failed: %v", err)
+// 确认消息
+func (ec *EventConsumer) ackMessage(ctx context.Context, message redis.XMessage) error {
+	if !ec.config.AutoAck {
+		return nil
 	}
-
-	// 确认消息
-	if ec.config.AutoAck {
-		if err := ec.redisClient.XAck(ctx, ec.streamKey, ec.config.ConsumerGroup, message.ID).Err(); err != nil {
-			return fmt.Errorf("failed to ack message: %v", err)
-		}
+	if err := ec.redisClient.XAck(ctx, ec.streamKey, ec.config.ConsumerGroup, message.ID).Err(); err != nil {
+		return fmt.Errorf("failed to ack message: %v", err)
 	}
-
 	return nil
 }
 
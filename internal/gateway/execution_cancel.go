@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 执行请求的 ID，沙箱取消执行时按它找到对应的执行
+const executionIDHeader = "X-Execution-Id"
+
+// 🔧 新增：超时取消的统计
+type executionCancelStats struct {
+	timeouts atomic.Int64 // 网关放弃等待的执行数
+	sent     atomic.Int64 // 沙箱确认（2xx）的取消请求数
+	failed   atomic.Int64 // 发送失败或沙箱返回非 2xx 的取消请求数
+	skipped  atomic.Int64 // 沙箱没有取消接口而未发送的次数
+}
+
+func (s *executionCancelStats) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"timeouts_total": s.timeouts.Load(),
+		"sent_total":     s.sent.Load(),
+		"failed_total":   s.failed.Load(),
+		"skipped_total":  s.skipped.Load(),
+	}
+}
+
+// 沙箱实例的取消接口路径，没有时返回空
+func (instance *SandboxInstance) cancelPath() string {
+	path := instance.CancelPath
+	if path == "" {
+		path = gatewaySettings().ExecutionCancel.DefaultPath
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// 是否为等待超时（http.Client 的 Timeout），客户端取消等其他错误不触发取消
+func isExecutionTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// 网关放弃等待执行结果后，异步通知沙箱取消执行，避免被放弃的执行继续占用沙箱 worker
+func (dr *DistributedRouter) cancelExecution(instance *SandboxInstance, client *http.Client, executionID, apiKey string) {
+	dr.executionCancel.timeouts.Add(1)
+	path := instance.cancelPath()
+	if path == "" {
+		dr.executionCancel.skipped.Add(1)
+		return
+	}
+
+	go func() {
+		timeout := time.Duration(gatewaySettings().ExecutionCancel.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = 2 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		body, _ := json.Marshal(map[string]string{"execution_id": executionID})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, instance.URL+path, bytes.NewReader(body))
+		if err != nil {
+			dr.executionCancel.failed.Add(1)
+			log.Printf("❌ Failed to cancel execution %s on sandbox %s: %v", executionID, instance.ID, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Api-Key", apiKey)
+		req.Header.Set(executionIDHeader, executionID)
+
+		resp, err := (&http.Client{Transport: client.Transport}).Do(req)
+		if err != nil {
+			dr.executionCancel.failed.Add(1)
+			log.Printf("❌ Failed to cancel execution %s on sandbox %s: %v", executionID, instance.ID, err)
+			return
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			dr.executionCancel.failed.Add(1)
+			log.Printf("⚠️ Sandbox %s rejected cancellation of execution %s: %s", instance.ID, executionID, resp.Status)
+			return
+		}
+		dr.executionCancel.sent.Add(1)
+		log.Printf("🛑 Execution %s timed out; cancelled on sandbox %s", executionID, instance.ID)
+	}()
+}
+
+// 响应头已返回、读取响应体时超时的执行同样需要取消
+type cancelOnTimeoutBody struct {
+	io.ReadCloser
+	once   sync.Once
+	cancel func()
+}
+
+func (b *cancelOnTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && isExecutionTimeout(err) {
+		b.once.Do(b.cancel)
+	}
+	return n, err
+}
@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 🔧 新增：路由的请求头、响应头改写规则。先 remove（支持 X-Internal-* 形式的前缀通配），再 set（覆盖），最后 add（追加）。
+// 值可以引用 ${client_ip}、${route_id}、${principal}、${tenant}、${host}、${method}、${path}
+type HeaderRules struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// 🔧 新增：转发给上游（沙箱、proxy 目标、LLM 上游）前改写的请求头
+type RouteRequestHeaders struct {
+	HeaderRules
+}
+
+var headerVariablePattern = regexp.MustCompile(`\$\{([a-z_]+)\}`)
+
+// 规则值可以引用的变量
+var headerVariables = map[string]func(route *RouteConfig, r *http.Request) string{
+	"client_ip": func(_ *RouteConfig, r *http.Request) string { return clientIP(r) },
+	"route_id":  func(route *RouteConfig, _ *http.Request) string { return route.ID },
+	"principal": func(_ *RouteConfig, r *http.Request) string {
+		if principal := principalFromRequest(r); principal != nil {
+			return principal.Name
+		}
+		return ""
+	},
+	"tenant": func(_ *RouteConfig, r *http.Request) string { return tenantFromRequest(r) },
+	"host":   func(_ *RouteConfig, r *http.Request) string { return requestHost(r) },
+	"method": func(_ *RouteConfig, r *http.Request) string { return r.Method },
+	"path":   func(_ *RouteConfig, r *http.Request) string { return r.URL.Path },
+}
+
+// 不允许改写的请求头和响应头：由 net/http 管理或只对单个连接有效
+var protectedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Upgrade":           true,
+}
+
+func (rules *HeaderRules) validate(kind string) error {
+	for _, values := range []map[string]string{rules.Set, rules.Add} {
+		for name, value := range values {
+			if err := validateHeaderRuleName(name, false); err != nil {
+				return fmt.Errorf("invalid %s header %q: %v", kind, name, err)
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("invalid %s header %q: value must not contain line breaks", kind, name)
+			}
+			for _, match := range headerVariablePattern.FindAllStringSubmatch(value, -1) {
+				if _, ok := headerVariables[match[1]]; !ok {
+					return fmt.Errorf("invalid %s header %q: unknown variable ${%s}", kind, name, match[1])
+				}
+			}
+		}
+	}
+	for _, name := range rules.Remove {
+		if err := validateHeaderRuleName(name, true); err != nil {
+			return fmt.Errorf("invalid %s header pattern %q: %v", kind, name, err)
+		}
+	}
+	return nil
+}
+
+func validateHeaderRuleName(name string, wildcard bool) error {
+	if wildcard {
+		name = strings.TrimSuffix(name, "*")
+	}
+	if name == "" || strings.ContainsAny(name, " :*\t\r\n") {
+		return fmt.Errorf("not a valid header name")
+	}
+	if protectedHeaders[http.CanonicalHeaderKey(name)] {
+		return fmt.Errorf("header is managed by the gateway")
+	}
+	return nil
+}
+
+// 按规则改写头部
+func (rules *HeaderRules) apply(header http.Header, route *RouteConfig, r *http.Request) {
+	for _, pattern := range rules.Remove {
+		for name := range header {
+			if headerPatternMatch(pattern, name) {
+				header.Del(name)
+			}
+		}
+	}
+	for name, value := range rules.Set {
+		header.Set(name, expandHeaderValue(value, route, r))
+	}
+	for name, value := range rules.Add {
+		header.Add(name, expandHeaderValue(value, route, r))
+	}
+}
+
+func expandHeaderValue(value string, route *RouteConfig, r *http.Request) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return headerVariablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		return headerVariables[match[2:len(match)-1]](route, r)
+	})
+}
+
+// 改写入站请求头（返回请求的副本），proxy 直接转发改写后的请求头
+func (h *RouteRequestHeaders) applyTo(route *RouteConfig, r *http.Request) *http.Request {
+	if h == nil {
+		return r
+	}
+	r = r.Clone(r.Context())
+	h.apply(r.Header, route, r)
+	return r
+}
+
+// 沙箱和 LLM 上游请求只复制固定的请求头，set 和 add 的请求头另行从改写后的入站请求复制
+func (h *RouteRequestHeaders) copyTo(dst, src http.Header) {
+	if h == nil {
+		return
+	}
+	for _, names := range []map[string]string{h.Set, h.Add} {
+		for name := range names {
+			dst.Del(name)
+			for _, value := range src.Values(name) {
+				dst.Add(name, value)
+			}
+		}
+	}
+}
+
+// 在写出响应头之前按规则改写（包括网关自己生成的响应）
+type headerRewriter struct {
+	http.ResponseWriter
+	rules   *HeaderRules
+	route   *RouteConfig
+	request *http.Request
+	applied bool
+}
+
+func (rw *headerRewriter) rewrite() {
+	if !rw.applied {
+		rw.applied = true
+		rw.rules.apply(rw.ResponseWriter.Header(), rw.route, rw.request)
+	}
+}
+
+func (rw *headerRewriter) WriteHeader(status int) {
+	rw.rewrite()
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *headerRewriter) Write(data []byte) (int, error) {
+	rw.rewrite()
+	return rw.ResponseWriter.Write(data)
+}
+
+func (rw *headerRewriter) Flush() {
+	rw.rewrite()
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *headerRewriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,313 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 组件与整体健康状态
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+)
+
+// 健康报告中的组件
+const (
+	healthComponentRedis           = "redis"
+	healthComponentEventConsumers  = "event_consumers"
+	healthComponentSandboxPool     = "sandbox_pool"
+	healthComponentConfigStaleness = "config_staleness"
+)
+
+var healthComponents = []string{healthComponentRedis, healthComponentEventConsumers, healthComponentSandboxPool, healthComponentConfigStaleness}
+
+var healthSeverity = map[string]int{healthStatusHealthy: 0, healthStatusDegraded: 1, healthStatusUnhealthy: 2}
+
+// Redis 探测超时（/readyz 也会探测，应小于负载均衡的探测超时）
+const healthRedisTimeout = time.Second
+
+// 广播事件读取每 5 秒阻塞返回一次，超过该时间没有成功读取视为异常
+const healthEventReadMaxAge = 15 * time.Second
+
+// 🔧 新增：组件健康状态，sandbox_pool 按沙箱类型细分
+type healthComponent struct {
+	Status     string                      `json:"status"`
+	Message    string                      `json:"message,omitempty"`
+	Details    map[string]interface{}      `json:"details,omitempty"`
+	Components map[string]*healthComponent `json:"components,omitempty"`
+}
+
+// 🔧 新增：/admin/health 与 /readyz 共用的健康报告
+type healthReport struct {
+	Status     string                      `json:"status"`
+	Draining   bool                        `json:"draining"`
+	Critical   []string                    `json:"critical"`
+	Components map[string]*healthComponent `json:"components"`
+}
+
+// 校验 gateway.health.critical 中的组件名
+func validateHealthPolicy(config static.HealthConfig) error {
+	for _, name := range config.Critical {
+		known := false
+		for _, component := range healthComponents {
+			known = known || component == name
+		}
+		if !known {
+			return fmt.Errorf("invalid gateway.health.critical component: %q (use redis, event_consumers, sandbox_pool or config_staleness)", name)
+		}
+	}
+	return nil
+}
+
+// 检查所有组件并按策略得出整体状态：关键组件 unhealthy 时为 unhealthy，其他组件异常时为 degraded
+func (dr *DistributedRouter) checkHealth(ctx context.Context) *healthReport {
+	settings := gatewaySettings().Health
+	redisHealth := dr.redisHealth(ctx)
+	report := &healthReport{
+		Status:   healthStatusHealthy,
+		Draining: dr.draining.Load(),
+		Critical: settings.Critical,
+		Components: map[string]*healthComponent{
+			healthComponentRedis:           redisHealth,
+			healthComponentEventConsumers:  dr.eventConsumersHealth(ctx, redisHealth.Status == healthStatusHealthy),
+			healthComponentSandboxPool:     dr.sandboxPoolHealth(),
+			healthComponentConfigStaleness: dr.configStalenessHealth(settings),
+		},
+	}
+	if report.Critical == nil {
+		report.Critical = []string{}
+	}
+
+	critical := make(map[string]bool, len(settings.Critical))
+	for _, name := range settings.Critical {
+		critical[name] = true
+	}
+	for name, component := range report.Components {
+		switch {
+		case component.Status == healthStatusUnhealthy && critical[name]:
+			report.Status = healthStatusUnhealthy
+		case component.Status != healthStatusHealthy && report.Status == healthStatusHealthy:
+			report.Status = healthStatusDegraded
+		}
+	}
+	return report
+}
+
+// 内存模式（启动时 Redis 不可用）为 degraded，运行中 Ping 失败为 unhealthy
+func (dr *DistributedRouter) redisHealth(ctx context.Context) *healthComponent {
+	if !dr.routeManager.redisEnabled {
+		return &healthComponent{Status: healthStatusDegraded, Message: "running in memory mode, routes are not persisted"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthRedisTimeout)
+	defer cancel()
+	startTime := time.Now()
+	if err := dr.redisClient.Ping(ctx).Err(); err != nil {
+		return &healthComponent{Status: healthStatusUnhealthy, Message: "Redis connection failed: " + err.Error()}
+	}
+	return &healthComponent{
+		Status:  healthStatusHealthy,
+		Details: map[string]interface{}{"latency_ms": float64(time.Since(startTime).Microseconds()) / 1000},
+	}
+}
+
+// 路由事件消费：消费者组模式需要有运行中的消费者，广播读取（RESYNC 与 fallback 模式的路由事件）需要最近成功读取过；
+// 发件箱中有待发布的事件时为 degraded
+func (dr *DistributedRouter) eventConsumersHealth(ctx context.Context, redisHealthy bool) *healthComponent {
+	rm := dr.routeManager
+	if !rm.redisEnabled {
+		return &healthComponent{Status: healthStatusDegraded, Message: "route events require Redis"}
+	}
+	component := &healthComponent{Status: healthStatusHealthy, Details: map[string]interface{}{}}
+	problems := make([]string, 0)
+
+	mode := "consumer_group"
+	if gatewaySettings().Sync.Poll == syncPollFallback {
+		mode = "broadcast"
+	}
+	component.Details["mode"] = mode
+	if mode == "consumer_group" {
+		component.Details["consumers"] = len(rm.eventConsumers)
+		if len(rm.eventConsumers) == 0 {
+			component.Status = healthStatusUnhealthy
+			problems = append(problems, "route event consumer is not running")
+		}
+		// Redis 异常时不再查询，避免探测时间翻倍
+		if redisHealthy {
+			pendingCtx, cancel := context.WithTimeout(ctx, healthRedisTimeout)
+			if pending, err := rm.redisClient.XPending(pendingCtx, rm.eventStream.streamKey, "route-managers").Result(); err == nil {
+				component.Details["pending"] = pending.Count
+			}
+			cancel()
+		}
+	}
+
+	if readAt := rm.broadcastReadAt.Load(); readAt > 0 {
+		age := time.Since(time.Unix(0, readAt))
+		component.Details["last_read_age_seconds"] = age.Seconds()
+		if age > healthEventReadMaxAge {
+			component.Status = healthStatusUnhealthy
+			problems = append(problems, fmt.Sprintf("no successful event stream read for %s", age.Round(time.Second)))
+		}
+	} else {
+		component.Status = healthStatusUnhealthy
+		problems = append(problems, "event stream has not been read yet")
+	}
+
+	depth := rm.outbox.depth()
+	component.Details["outbox_depth"] = depth
+	if depth > 0 {
+		if component.Status == healthStatusHealthy {
+			component.Status = healthStatusDegraded
+		}
+		problems = append(problems, fmt.Sprintf("%d route events waiting in the outbox", depth))
+	}
+	component.Message = strings.Join(problems, "; ")
+	return component
+}
+
+// 按沙箱类型统计健康实例：有路由使用但没有健康实例时为 unhealthy，部分实例异常或没有路由使用时为 degraded
+func (dr *DistributedRouter) sandboxPoolHealth() *healthComponent {
+	type typeCounts struct{ healthy, total, routes int }
+	counts := make(map[string]*typeCounts)
+	countsFor := func(sandboxType string) *typeCounts {
+		if counts[sandboxType] == nil {
+			counts[sandboxType] = &typeCounts{}
+		}
+		return counts[sandboxType]
+	}
+	for _, instance := range dr.sandboxPool.GetAllInstances() {
+		typeCount := countsFor(instance.Type)
+		typeCount.total++
+		if instance.Status == "healthy" {
+			typeCount.healthy++
+		}
+	}
+	for _, route := range dr.routeManager.GetAllRoutes() {
+		if route.Handler == "sandbox" {
+			countsFor(route.SandboxType).routes++
+		}
+	}
+
+	component := &healthComponent{Status: healthStatusHealthy, Components: make(map[string]*healthComponent, len(counts))}
+	if len(counts) == 0 {
+		component.Message = "no sandbox instances or sandbox routes"
+	}
+	types := make([]string, 0, len(counts))
+	for sandboxType := range counts {
+		types = append(types, sandboxType)
+	}
+	sort.Strings(types)
+	for _, sandboxType := range types {
+		typeCount := counts[sandboxType]
+		typeHealth := &healthComponent{
+			Status: healthStatusHealthy,
+			Details: map[string]interface{}{
+				"healthy": typeCount.healthy,
+				"total":   typeCount.total,
+				"routes":  typeCount.routes,
+			},
+		}
+		switch {
+		case typeCount.healthy == 0 && typeCount.routes > 0:
+			typeHealth.Status = healthStatusUnhealthy
+			typeHealth.Message = fmt.Sprintf("no healthy %s sandbox for %d routes", sandboxType, typeCount.routes)
+		case typeCount.healthy < typeCount.total:
+			typeHealth.Status = healthStatusDegraded
+			typeHealth.Message = fmt.Sprintf("%d of %d %s sandboxes are not healthy", typeCount.total-typeCount.healthy, typeCount.total, sandboxType)
+		}
+		component.Components[sandboxType] = typeHealth
+
+		if healthSeverity[typeHealth.Status] > healthSeverity[component.Status] {
+			component.Status = typeHealth.Status
+			component.Message = typeHealth.Message
+		}
+	}
+	return component
+}
+
+// 距最近一次成功检查配置版本的时间超过上限时为 unhealthy（本实例的路由可能已过期）
+func (dr *DistributedRouter) configStalenessHealth(settings static.HealthConfig) *healthComponent {
+	rm := dr.routeManager
+	if !rm.redisEnabled {
+		return &healthComponent{Status: healthStatusDegraded, Message: "configuration sync requires Redis"}
+	}
+	maxAge := time.Duration(settings.MaxConfigStaleness) * time.Second
+	if maxAge <= 0 {
+		maxAge = 3*rm.SyncInterval() + time.Duration(gatewaySettings().Sync.Jitter)*time.Second
+	}
+	component := &healthComponent{
+		Status:  healthStatusHealthy,
+		Details: map[string]interface{}{"config_version": rm.lastConfigUpdate, "max_age_seconds": maxAge.Seconds()},
+	}
+	lastCheck := rm.syncStats.lastCheck()
+	if lastCheck.IsZero() {
+		component.Status = healthStatusDegraded
+		component.Message = "configuration has not been checked yet"
+		return component
+	}
+	age := time.Since(lastCheck)
+	component.Details["age_seconds"] = age.Seconds()
+	if age > maxAge {
+		component.Status = healthStatusUnhealthy
+		component.Message = fmt.Sprintf("configuration not checked for %s", age.Round(time.Second))
+	}
+	return component
+}
+
+// GET /admin/health：完整的健康报告，整体为 unhealthy 时返回 503
+func (dr *DistributedRouter) healthHandler(c *gin.Context) {
+	report := dr.checkHealth(c.Request.Context())
+	code := http.StatusOK
+	if report.Status == healthStatusUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":          report.Status,
+		"draining":        report.Draining,
+		"critical":        report.Critical,
+		"components":      report.Components,
+		"timestamp":       time.Now().Unix(),
+		"instance_id":     dr.routeManager.instanceID,
+		"routes":          len(dr.routeManager.GetAllRoutes()),
+		"sandboxes":       len(dr.sandboxPool.GetAllInstances()),
+		"sandbox_probing": dr.sandboxPool.probing(),
+		"version":         static.GetBuildInfo().Version,
+		"git_commit":      static.GetBuildInfo().GitCommit,
+	})
+}
+
+// 网关端口 /readyz：排空中或整体为 unhealthy 时返回 503；只返回各组件状态，不暴露详情
+func (dr *DistributedRouter) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := dr.checkHealth(r.Context())
+	components := make(map[string]string, len(report.Components))
+	for name, component := range report.Components {
+		components[name] = component.Status
+	}
+
+	status := "ready"
+	code := http.StatusOK
+	switch {
+	case report.Draining:
+		status, code = "draining", http.StatusServiceUnavailable
+	case report.Status == healthStatusUnhealthy:
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(gin.H{
+		"status":     status,
+		"health":     report.Status,
+		"degraded":   report.Status == healthStatusDegraded,
+		"components": components,
+	})
+}
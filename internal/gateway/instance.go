@@ -0,0 +1,36 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// 🔧 新增：解析实例标识（gateway.instance_id）：
+// hostname 使用主机名，env:NAME 读取环境变量（如 Kubernetes 的 env:POD_NAME），其他非空值按字面使用；
+// 为空或解析失败时回退为 instance-<纳秒时间戳>
+func resolveInstanceID(setting string) string {
+	fallback := fmt.Sprintf("instance-%d", time.Now().UnixNano())
+	switch {
+	case setting == "":
+		return fallback
+	case setting == "hostname":
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			log.Printf("⚠️  Failed to read hostname for instance_id, using %s: %v", fallback, err)
+			return fallback
+		}
+		return hostname
+	case strings.HasPrefix(setting, "env:"):
+		name := strings.TrimPrefix(setting, "env:")
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		log.Printf("⚠️  Environment variable %s for instance_id is empty, using %s", name, fallback)
+		return fallback
+	default:
+		return setting
+	}
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"sync"
+)
+
+// 🔧 新增：消费者 Key 的并发上限（gateway.api_keys[].max_concurrency），防止单个调用方占满沙箱池。
+// 每个网关实例分别计数进行中的请求，达到上限时返回 429
+type keyConcurrencyLimiter struct {
+	mutex    sync.Mutex
+	inFlight map[string]int64
+	rejected map[string]int64
+}
+
+// 占用调用方的一个并发名额，达到上限时 ok 为 false；不限制的调用方返回空操作的 release
+func (l *keyConcurrencyLimiter) acquire(principal *gatewayPrincipal) (release func(), ok bool) {
+	if principal == nil || principal.MaxConcurrency <= 0 {
+		return func() {}, true
+	}
+	name := principal.Name
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight == nil {
+		l.inFlight = make(map[string]int64)
+		l.rejected = make(map[string]int64)
+	}
+	if l.inFlight[name] >= int64(principal.MaxConcurrency) {
+		l.rejected[name]++
+		return nil, false
+	}
+	l.inFlight[name]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			if l.inFlight[name]--; l.inFlight[name] <= 0 {
+				delete(l.inFlight, name)
+			}
+		})
+	}, true
+}
+
+// 配置了并发上限的 Key 的当前并发数、上限和累计拒绝次数
+func (l *keyConcurrencyLimiter) Stats() map[string]interface{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stats := make(map[string]interface{})
+	for _, key := range gatewaySettings().APIKeys {
+		if key.MaxConcurrency <= 0 {
+			continue
+		}
+		stats[key.Name] = map[string]int64{
+			"limit":          int64(key.MaxConcurrency),
+			"in_flight":      l.inFlight[key.Name],
+			"rejected_total": l.rejected[key.Name],
+		}
+	}
+	return stats
+}
+
+// 各 Key 因并发上限被拒绝的次数
+func (l *keyConcurrencyLimiter) Rejections() map[string]int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	rejections := make(map[string]int64, len(l.rejected))
+	for name, count := range l.rejected {
+		rejections[name] = count
+	}
+	return rejections
+}
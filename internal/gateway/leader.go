@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	leaderKey      = "gateway:leader"
+	leaderLeaseTTL = 15 * time.Second
+)
+
+// 续约脚本：只有当前持有者才能延长租约
+var renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// 基于 Redis 租约的主节点选举，定时任务只在主节点上运行
+type LeaderElector struct {
+	redisClient *redis.Client
+	instanceID  string
+	standalone  bool // 没有 Redis 时单实例运行，始终视为主节点
+	isLeader    atomic.Bool
+	stopChan    chan struct{}
+}
+
+func NewLeaderElector(redisClient *redis.Client, instanceID string, redisEnabled bool) *LeaderElector {
+	le := &LeaderElector{
+		redisClient: redisClient,
+		instanceID:  instanceID,
+		standalone:  !redisEnabled,
+		stopChan:    make(chan struct{}),
+	}
+	if le.standalone {
+		le.isLeader.Store(true)
+	}
+	return le
+}
+
+// 启动选举循环
+func (le *LeaderElector) Start() {
+	if le.standalone {
+		return
+	}
+
+	le.campaign()
+	go func() {
+		ticker := time.NewTicker(leaderLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				le.campaign()
+			case <-le.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// 停止选举并主动释放租约
+func (le *LeaderElector) Stop() {
+	if le.standalone {
+		return
+	}
+	close(le.stopChan)
+
+	if le.isLeader.Load() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if holder, err := le.redisClient.Get(ctx, leaderKey).Result(); err == nil && holder == le.instanceID {
+			le.redisClient.Del(ctx, leaderKey)
+		}
+		le.isLeader.Store(false)
+	}
+}
+
+// 尝试获取或续约租约
+func (le *LeaderElector) campaign() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wasLeader := le.isLeader.Load()
+	leader := false
+
+	if wasLeader {
+		renewed, err := renewLeaderScript.Run(ctx, le.redisClient, []string{leaderKey},
+			le.instanceID, leaderLeaseTTL.Milliseconds()).Int()
+		leader = err == nil && renewed == 1
+	}
+	if !leader {
+		acquired, err := le.redisClient.SetNX(ctx, leaderKey, le.instanceID, leaderLeaseTTL).Result()
+		if err != nil {
+			log.Printf("Leader election failed: %v", err)
+		}
+		leader = err == nil && acquired
+	}
+
+	le.isLeader.Store(leader)
+	if leader != wasLeader {
+		if leader {
+			log.Printf("👑 Instance %s became leader", le.instanceID)
+		} else {
+			log.Printf("👋 Instance %s lost leadership", le.instanceID)
+		}
+	}
+}
+
+// 当前实例是否为主节点
+func (le *LeaderElector) IsLeader() bool {
+	return le.isLeader.Load()
+}
+
+// 当前主节点的实例ID
+func (le *LeaderElector) CurrentLeader(ctx context.Context) string {
+	if le.standalone {
+		return le.instanceID
+	}
+	holder, err := le.redisClient.Get(ctx, leaderKey).Result()
+	if err != nil {
+		return ""
+	}
+	return holder
+}
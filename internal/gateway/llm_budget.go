@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const llmBudgetRedisPrefix = "gateway:llm:budget:"
+
+// 🔧 新增：LLM 路由 token 预算，超出后在窗口结束前返回 429
+type LLMBudget struct {
+	Tokens int64  `json:"tokens"`            // 每个窗口允许的 total_tokens
+	Period string `json:"period,omitempty"`  // minute、hour、day（默认）、month，按 UTC 对齐
+	PerKey bool   `json:"per_key,omitempty"` // 按调用方（API Key / JWT subject）分别计算，否则整条路由共享（启用多租户时按租户分别计算）
+}
+
+func (b *LLMBudget) validate() error {
+	if b.Tokens <= 0 {
+		return fmt.Errorf("llm budget tokens must be positive")
+	}
+	switch b.Period {
+	case "", "minute", "hour", "day", "month":
+		return nil
+	}
+	return fmt.Errorf("invalid llm budget period: %s", b.Period)
+}
+
+// 当前预算窗口的起止时间
+func (b *LLMBudget) window(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	switch b.Period {
+	case "minute":
+		start := now.Truncate(time.Minute)
+		return start, start.Add(time.Minute)
+	case "hour":
+		start := now.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case "month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// 本地预算计数（Redis 不可用时使用）
+type llmBudgetCounter struct {
+	used      int64
+	windowEnd time.Time
+}
+
+func llmBudgetKey(routeID, tenant, caller string, budget *LLMBudget, windowStart time.Time) string {
+	subject := "*"
+	if budget.PerKey {
+		subject = caller
+	}
+	return llmBudgetRedisPrefix + routeID + ":" + tenant + ":" + subject + ":" + strconv.FormatInt(windowStart.Unix(), 10)
+}
+
+// 检查预算：已用量达到上限时返回距窗口结束的时间。
+// 用量在响应完成后才累加，并发请求可能使窗口用量略超出预算
+func (kp *LLMKeyPools) budgetExceeded(ctx context.Context, routeID, tenant, caller string, budget *LLMBudget, now time.Time) (time.Duration, bool) {
+	start, end := budget.window(now)
+	key := llmBudgetKey(routeID, tenant, caller, budget, start)
+
+	var used int64
+	if kp.redisEnabled {
+		value, err := kp.redisClient.Get(ctx, key).Int64()
+		if err != nil && err != redis.Nil {
+			// Redis 故障时不阻断请求
+			log.Printf("Failed to read LLM budget %s: %v", key, err)
+			return 0, false
+		}
+		used = value
+	} else {
+		kp.mutex.Lock()
+		if counter := kp.budgets[key]; counter != nil {
+			used = counter.used
+		}
+		kp.mutex.Unlock()
+	}
+
+	if used < budget.Tokens {
+		return 0, false
+	}
+	return end.Sub(now), true
+}
+
+// 累加预算窗口用量
+func (kp *LLMKeyPools) consumeBudget(routeID, tenant, caller string, budget *LLMBudget, tokens int64, now time.Time) {
+	if tokens <= 0 {
+		return
+	}
+	start, end := budget.window(now)
+	key := llmBudgetKey(routeID, tenant, caller, budget, start)
+
+	if kp.redisEnabled {
+		ctx := context.Background()
+		pipe := kp.redisClient.Pipeline()
+		pipe.IncrBy(ctx, key, tokens)
+		pipe.ExpireAt(ctx, key, end.Add(time.Minute))
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to record LLM budget %s: %v", key, err)
+		}
+		return
+	}
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+	counter := kp.budgets[key]
+	if counter == nil {
+		// 新窗口开始时清理已过期的计数
+		for k, c := range kp.budgets {
+			if !now.Before(c.windowEnd) {
+				delete(kp.budgets, k)
+			}
+		}
+		counter = &llmBudgetCounter{windowEnd: end}
+		kp.budgets[key] = counter
+	}
+	counter.used += tokens
+}
@@ -0,0 +1,468 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	llmCacheRedisPrefix       = "gateway:llm:cache:"
+	llmCacheVectorRedisPrefix = "gateway:llm:cache:vectors:"
+	llmCacheDefaultTTL        = 3600
+	llmCacheDefaultThreshold  = 0.95
+	llmCacheDefaultMaxEntries = 10000
+)
+
+// 🔧 新增：LLM 路由响应缓存配置
+type RouteLLMCache struct {
+	TTL       int     `json:"ttl,omitempty"`       // 缓存有效期（秒），默认 3600
+	Mode      string  `json:"mode,omitempty"`      // exact（默认，规范化后的请求体完全一致）或 semantic（prompt 向量相似）
+	Threshold float64 `json:"threshold,omitempty"` // semantic 模式的余弦相似度阈值，默认 0.95
+}
+
+func (c *RouteLLMCache) validate() error {
+	if c.TTL < 0 {
+		return fmt.Errorf("llm cache ttl must not be negative")
+	}
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return fmt.Errorf("llm cache threshold must be between 0 and 1")
+	}
+	switch c.Mode {
+	case "", "exact":
+		return nil
+	case "semantic":
+		embedding := gatewaySettings().LLMCache.Embedding
+		if embedding.Target == "" || findLLMKeyPool(embedding.KeyPool) == nil {
+			return fmt.Errorf("semantic llm cache requires gateway.llm_cache.embedding with a valid key_pool")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid llm cache mode: %s", c.Mode)
+}
+
+func (c *RouteLLMCache) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return llmCacheDefaultTTL * time.Second
+	}
+	return time.Duration(c.TTL) * time.Second
+}
+
+func (c *RouteLLMCache) threshold() float64 {
+	if c.Threshold <= 0 {
+		return llmCacheDefaultThreshold
+	}
+	return c.Threshold
+}
+
+// 缓存的上游响应（只缓存非流式 200 响应）
+type llmCachedResponse struct {
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// 向量存储接口：semantic 模式按 prompt 向量查找相似请求的缓存键
+type LLMVectorStore interface {
+	Add(ctx context.Context, namespace, key string, vector []float64, expiresAt time.Time) error
+	Search(ctx context.Context, namespace string, vector []float64, threshold float64) (string, bool, error)
+}
+
+// 根据配置创建向量存储
+func NewLLMVectorStore(config static.LLMCacheConfig, redisClient *redis.Client, redisEnabled bool) (LLMVectorStore, error) {
+	maxEntries := config.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = llmCacheDefaultMaxEntries
+	}
+	switch config.VectorStore {
+	case "", "memory":
+		return &memoryVectorStore{entries: make(map[string][]vectorEntry), maxEntries: maxEntries}, nil
+	case "redis":
+		if !redisEnabled {
+			return nil, fmt.Errorf("redis vector store requires redis")
+		}
+		return &redisVectorStore{client: redisClient, maxEntries: maxEntries}, nil
+	default:
+		return nil, fmt.Errorf("unknown llm vector store: %s", config.VectorStore)
+	}
+}
+
+type vectorEntry struct {
+	Key       string    `json:"key"`
+	Vector    []float64 `json:"vector"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 在条目中查找相似度最高且达到阈值的未过期条目
+func nearestVector(entries []vectorEntry, vector []float64, threshold float64, now time.Time) (string, bool) {
+	best, bestScore := "", threshold
+	for _, entry := range entries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		if score := cosineSimilarity(entry.Vector, vector); score >= bestScore {
+			best, bestScore = entry.Key, score
+		}
+	}
+	return best, best != ""
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// 进程内向量存储（暴力检索，仅当前实例可见）
+type memoryVectorStore struct {
+	entries    map[string][]vectorEntry
+	maxEntries int
+	mutex      sync.RWMutex
+}
+
+func (s *memoryVectorStore) Add(ctx context.Context, namespace, key string, vector []float64, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	entries := s.entries[namespace][:0:0]
+	for _, entry := range s.entries[namespace] {
+		if now.Before(entry.ExpiresAt) && entry.Key != key {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, vectorEntry{Key: key, Vector: vector, ExpiresAt: expiresAt})
+	if len(entries) > s.maxEntries {
+		entries = entries[len(entries)-s.maxEntries:]
+	}
+	s.entries[namespace] = entries
+	return nil
+}
+
+func (s *memoryVectorStore) Search(ctx context.Context, namespace string, vector []float64, threshold float64) (string, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, ok := nearestVector(s.entries[namespace], vector, threshold, time.Now())
+	return key, ok, nil
+}
+
+// Redis 向量存储：每个命名空间（路由/租户）一个 Hash（缓存键 -> 向量），检索时全量读取后计算相似度
+type redisVectorStore struct {
+	client     *redis.Client
+	maxEntries int
+}
+
+func (s *redisVectorStore) load(ctx context.Context, namespace string) ([]vectorEntry, error) {
+	stored, err := s.client.HGetAll(ctx, llmCacheVectorRedisPrefix+namespace).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]vectorEntry, 0, len(stored))
+	for _, value := range stored {
+		var entry vectorEntry
+		if json.Unmarshal([]byte(value), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *redisVectorStore) Add(ctx context.Context, namespace, key string, vector []float64, expiresAt time.Time) error {
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	// 清理过期条目，超出上限时删除最早过期的条目
+	hash := llmCacheVectorRedisPrefix + namespace
+	now := time.Now()
+	var stale []string
+	live := entries[:0]
+	for _, entry := range entries {
+		if now.After(entry.ExpiresAt) {
+			stale = append(stale, entry.Key)
+		} else if entry.Key != key {
+			live = append(live, entry)
+		}
+	}
+	if excess := len(live) + 1 - s.maxEntries; excess > 0 {
+		sort.Slice(live, func(i, j int) bool { return live[i].ExpiresAt.Before(live[j].ExpiresAt) })
+		for _, entry := range live[:excess] {
+			stale = append(stale, entry.Key)
+		}
+	}
+
+	data, err := json.Marshal(vectorEntry{Key: key, Vector: vector, ExpiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	pipe := s.client.Pipeline()
+	if len(stale) > 0 {
+		pipe.HDel(ctx, hash, stale...)
+	}
+	pipe.HSet(ctx, hash, key, data)
+	pipe.ExpireAt(ctx, hash, expiresAt)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisVectorStore) Search(ctx context.Context, namespace string, vector []float64, threshold float64) (string, bool, error) {
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		return "", false, err
+	}
+	key, ok := nearestVector(entries, vector, threshold, time.Now())
+	return key, ok, nil
+}
+
+// LLM 响应缓存：响应存储在 Redis（不可用时为当前实例内存），semantic 模式另用向量存储查找相似请求
+type LLMCache struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	vectors      LLMVectorStore
+	responses    map[string]*llmCachedResponse
+	mutex        sync.Mutex
+}
+
+func NewLLMCache(redisClient *redis.Client, redisEnabled bool) *LLMCache {
+	cache := &LLMCache{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		responses:    make(map[string]*llmCachedResponse),
+	}
+	vectors, err := NewLLMVectorStore(gatewaySettings().LLMCache, redisClient, redisEnabled)
+	if err != nil {
+		log.Printf("⚠️  LLM semantic cache disabled: %v", err)
+	} else {
+		cache.vectors = vectors
+	}
+	return cache
+}
+
+func (c *LLMCache) get(ctx context.Context, key string) (*llmCachedResponse, bool) {
+	if c.redisEnabled {
+		data, err := c.redisClient.Get(ctx, llmCacheRedisPrefix+key).Bytes()
+		if err != nil {
+			if err != redis.Nil {
+				log.Printf("Failed to read LLM cache: %v", err)
+			}
+			return nil, false
+		}
+		var cached llmCachedResponse
+		if json.Unmarshal(data, &cached) != nil {
+			return nil, false
+		}
+		return &cached, true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cached := c.responses[key]
+	if cached == nil || time.Now().After(cached.ExpiresAt) {
+		return nil, false
+	}
+	return cached, true
+}
+
+func (c *LLMCache) set(ctx context.Context, key string, cached *llmCachedResponse) {
+	if c.redisEnabled {
+		data, err := json.Marshal(cached)
+		if err == nil {
+			err = c.redisClient.Set(ctx, llmCacheRedisPrefix+key, data, time.Until(cached.ExpiresAt)).Err()
+		}
+		if err != nil {
+			log.Printf("Failed to write LLM cache: %v", err)
+		}
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	for k, v := range c.responses {
+		if now.After(v.ExpiresAt) {
+			delete(c.responses, k)
+		}
+	}
+	c.responses[key] = cached
+}
+
+// 可缓存请求的查找结果，未命中时用于写入缓存
+type llmCacheLookup struct {
+	namespace string // 路由ID，启用多租户时附加租户，不同租户之间不共享缓存
+	key       string
+	vector    []float64
+}
+
+// 查找缓存：先按规范化请求体精确匹配，semantic 模式再按 prompt 向量查找相似请求
+func (dr *DistributedRouter) lookupLLMCache(ctx context.Context, route *RouteConfig, tenant string, body []byte) (*llmCachedResponse, *llmCacheLookup) {
+	normalized, ok := normalizeLLMRequest(body)
+	if !ok {
+		return nil, nil
+	}
+	lookup := &llmCacheLookup{namespace: route.ID}
+	if tenant != "" {
+		lookup.namespace += "@" + tenant
+	}
+	sum := sha256.Sum256(append([]byte(lookup.namespace+"\n"), normalized...))
+	lookup.key = lookup.namespace + ":" + hex.EncodeToString(sum[:])
+	if cached, hit := dr.llmCache.get(ctx, lookup.key); hit {
+		return cached, lookup
+	}
+
+	settings := route.LLM.Cache
+	if settings.Mode != "semantic" || dr.llmCache.vectors == nil {
+		return nil, lookup
+	}
+	vector, err := dr.llmEmbedding(ctx, llmPromptText(body))
+	if err != nil {
+		log.Printf("⚠️  LLM cache embedding for route %s failed: %v", route.ID, err)
+		return nil, lookup
+	}
+	lookup.vector = vector
+	key, found, err := dr.llmCache.vectors.Search(ctx, lookup.namespace, vector, settings.threshold())
+	if err != nil {
+		log.Printf("⚠️  LLM vector search for route %s failed: %v", route.ID, err)
+		return nil, lookup
+	}
+	if found {
+		if cached, hit := dr.llmCache.get(ctx, key); hit {
+			return cached, lookup
+		}
+	}
+	return nil, lookup
+}
+
+func (dr *DistributedRouter) storeLLMCache(ctx context.Context, route *RouteConfig, lookup *llmCacheLookup, contentType string, body []byte) {
+	expiresAt := time.Now().Add(route.LLM.Cache.ttl())
+	dr.llmCache.set(ctx, lookup.key, &llmCachedResponse{ContentType: contentType, Body: body, ExpiresAt: expiresAt})
+	if lookup.vector != nil {
+		if err := dr.llmCache.vectors.Add(ctx, lookup.namespace, lookup.key, lookup.vector, expiresAt); err != nil {
+			log.Printf("⚠️  Failed to store LLM cache vector for route %s: %v", route.ID, err)
+		}
+	}
+}
+
+// 规范化请求体：字段排序，去掉不影响生成结果的字段；流式请求不缓存
+func normalizeLLMRequest(body []byte) ([]byte, bool) {
+	var request map[string]interface{}
+	if json.Unmarshal(body, &request) != nil {
+		return nil, false
+	}
+	if stream, _ := request["stream"].(bool); stream {
+		return nil, false
+	}
+	delete(request, "user")
+	delete(request, "stream")
+	delete(request, "stream_options")
+	normalized, err := json.Marshal(request)
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
+
+// 提取用于计算向量的 prompt 文本（model + messages，兼容 prompt/input 字段）
+func llmPromptText(body []byte) string {
+	var request struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		Prompt json.RawMessage `json:"prompt"`
+		Input  json.RawMessage `json:"input"`
+	}
+	json.Unmarshal(body, &request)
+
+	var text strings.Builder
+	text.WriteString(request.Model)
+	for _, message := range request.Messages {
+		text.WriteString("\n" + message.Role + ": ")
+		var content string
+		if json.Unmarshal(message.Content, &content) == nil {
+			text.WriteString(content)
+			continue
+		}
+		// 多模态消息只取文本部分
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		json.Unmarshal(message.Content, &parts)
+		for _, part := range parts {
+			if part.Type == "text" {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	for _, raw := range []json.RawMessage{request.Prompt, request.Input} {
+		if len(raw) > 0 {
+			text.WriteString("\n" + string(raw))
+		}
+	}
+	return text.String()
+}
+
+// 调用 gateway.llm_cache.embedding 配置的 embeddings 接口
+func (dr *DistributedRouter) llmEmbedding(ctx context.Context, text string) ([]float64, error) {
+	config := gatewaySettings().LLMCache.Embedding
+	pool := findLLMKeyPool(config.KeyPool)
+	if pool == nil || len(pool.Keys) == 0 {
+		return nil, fmt.Errorf("unknown embedding key pool: %s", config.KeyPool)
+	}
+	index, ok := dr.llmKeys.acquire(ctx, pool, map[int]bool{})
+	if !ok {
+		return nil, fmt.Errorf("no embedding key available")
+	}
+
+	body, _ := json.Marshal(map[string]string{"model": config.Model, "input": text})
+	request, _ := http.NewRequest(http.MethodPost, "", nil)
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := dr.sendLLMRequest(ctx, pool, index, request, strings.TrimRight(config.Target, "/")+"/v1/embeddings", body, nil)
+	if err != nil {
+		dr.llmKeys.report(pool, index, http.StatusBadGateway, "")
+		return nil, err
+	}
+	defer resp.Body.Close()
+	dr.llmKeys.report(pool, index, resp.StatusCode, resp.Header.Get("Retry-After"))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, llmMaxCapturedBody)).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embeddings response has no data")
+	}
+	return result.Data[0].Embedding, nil
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 按模型路由：model 为精确名称，或以 * 结尾的前缀（如 claude-*）
+type LLMModelRoute struct {
+	Model     string        `json:"model"`
+	Upstreams []LLMUpstream `json:"upstreams"` // 第一个为主上游，其余按顺序作为备用
+}
+
+// LLM 上游
+type LLMUpstream struct {
+	Target  string `json:"target"`          // OpenAI 兼容接口的基础地址
+	KeyPool string `json:"key_pool"`        // gateway.llm_key_pools 中的池名称
+	Model   string `json:"model,omitempty"` // 转发时替换请求体中的 model（如 Azure 部署名）
+}
+
+func (m *LLMModelRoute) validate() error {
+	if m.Model == "" {
+		return fmt.Errorf("llm model route requires model")
+	}
+	if len(m.Upstreams) == 0 {
+		return fmt.Errorf("llm model %s requires at least one upstream", m.Model)
+	}
+	for _, upstream := range m.Upstreams {
+		target, err := url.Parse(upstream.Target)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("invalid upstream target for model %s: %s", m.Model, upstream.Target)
+		}
+		if findLLMKeyPool(upstream.KeyPool) == nil {
+			return fmt.Errorf("unknown llm key pool for model %s: %s", m.Model, upstream.KeyPool)
+		}
+	}
+	return nil
+}
+
+func (m *LLMModelRoute) matches(model string) bool {
+	if prefix, ok := strings.CutSuffix(m.Model, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return m.Model == model
+}
+
+// 请求使用的上游列表：精确匹配优先于前缀匹配，未匹配时使用路由默认上游
+func (l *RouteLLM) upstreams(target, model string) []LLMUpstream {
+	if model != "" {
+		var prefixMatch *LLMModelRoute
+		for i := range l.Models {
+			if l.Models[i].Model == model {
+				return l.Models[i].Upstreams
+			}
+			if prefixMatch == nil && l.Models[i].matches(model) {
+				prefixMatch = &l.Models[i]
+			}
+		}
+		if prefixMatch != nil {
+			return prefixMatch.Upstreams
+		}
+	}
+	if target == "" || l.KeyPool == "" {
+		return nil
+	}
+	return []LLMUpstream{{Target: target, KeyPool: l.KeyPool}}
+}
+
+// 读取请求体中的 model 字段
+func requestModel(body []byte) string {
+	var request struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &request) != nil {
+		return ""
+	}
+	return request.Model
+}
+
+// 替换请求体中的 model 字段，其余字段保持不变
+func rewriteRequestModel(body []byte, model string) []byte {
+	var request map[string]json.RawMessage
+	if json.Unmarshal(body, &request) != nil {
+		return body
+	}
+	request["model"], _ = json.Marshal(model)
+	rewritten, err := json.Marshal(request)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// 调用方标识，用于按 API Key 统计用量和预算
+func llmCaller(r *http.Request) string {
+	if principal := principalFromRequest(r); principal != nil && principal.Name != "" {
+		return principal.Name
+	}
+	return "anonymous"
+}
+
+// 流式请求未设置 stream_options 时要求上游在最后返回 usage，保证预算可以计量
+func requestStreamUsage(body []byte) []byte {
+	var request map[string]json.RawMessage
+	if json.Unmarshal(body, &request) != nil || string(request["stream"]) != "true" {
+		return body
+	}
+	if _, ok := request["stream_options"]; ok {
+		return body
+	}
+	request["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	rewritten, err := json.Marshal(request)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
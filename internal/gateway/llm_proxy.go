@@ -0,0 +1,640 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	llmUsageRedisKey     = "gateway:llm:usage"
+	llmKeyUsageRedisKey  = "gateway:llm:usage:keys"
+	llmTenantUsageKey    = "gateway:llm:usage:tenants"
+	llmRateKeyPrefix     = "gateway:llm:rpm:"
+	llmMaxRequestBody    = 10 << 20
+	llmMaxCapturedBody   = 1 << 20
+	llmDefaultCooldown   = 60 * time.Second
+	llmDefaultAuthHeader = "Authorization"
+)
+
+// LLM 代理路由配置（handler: llm，Target 为 OpenAI 兼容接口的基础地址）
+type RouteLLM struct {
+	KeyPool string          `json:"key_pool,omitempty"` // gateway.llm_key_pools 中的池名称
+	Models  []LLMModelRoute `json:"models,omitempty"`   // 🔧 新增：按请求体 model 字段选择上游
+	Budget  *LLMBudget      `json:"budget,omitempty"`   // 🔧 新增：token 预算
+	Cache   *RouteLLMCache  `json:"cache,omitempty"`    // 🔧 新增：响应缓存
+}
+
+// 校验配置：未配置 models 时 target 与 key_pool 必填；配置了 models 时二者作为未匹配模型的默认上游（可省略）
+func (l *RouteLLM) validate(target string) error {
+	if len(l.Models) == 0 && (l.KeyPool == "" || target == "") {
+		return fmt.Errorf("llm routes require target and key_pool")
+	}
+	if (l.KeyPool == "") != (target == "") {
+		return fmt.Errorf("llm target and key_pool must be set together")
+	}
+	if l.KeyPool != "" && findLLMKeyPool(l.KeyPool) == nil {
+		return fmt.Errorf("unknown llm key pool: %s", l.KeyPool)
+	}
+	for _, model := range l.Models {
+		if err := model.validate(); err != nil {
+			return err
+		}
+	}
+	if l.Budget != nil {
+		if err := l.Budget.validate(); err != nil {
+			return err
+		}
+	}
+	if l.Cache != nil {
+		return l.Cache.validate()
+	}
+	return nil
+}
+
+func findLLMKeyPool(name string) *static.LLMKeyPoolConfig {
+	pools := gatewaySettings().LLMKeyPools
+	for i := range pools {
+		if pools[i].Name == name {
+			return &pools[i]
+		}
+	}
+	return nil
+}
+
+// 上游 Key 运行状态（当前实例）
+type llmKeyState struct {
+	CooldownUntil time.Time `json:"cooldown_until"`
+	Requests      int64     `json:"requests"`
+	Failures      int64     `json:"failures"`
+	LastStatus    int       `json:"last_status"`
+	window        int64     // 本地限流窗口（分钟），Redis 不可用时使用
+	windowCount   int
+}
+
+// LLM 用量统计
+type LLMUsage struct {
+	Requests         int64 `json:"requests"`
+	Errors           int64 `json:"errors"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// 上游响应中的 usage 字段
+type llmResponseUsage struct {
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// LLM Key 池管理：轮换选择、限流、冷却和用量统计
+type LLMKeyPools struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	states       map[string][]*llmKeyState
+	next         map[string]int
+	usage        map[string]*LLMUsage // 路由ID -> 用量（当前实例）
+	keyUsage     map[string]*LLMUsage // 调用方 -> 用量（当前实例）
+	tenantUsage  map[string]*LLMUsage // 租户 -> 用量（当前实例）
+	budgets      map[string]*llmBudgetCounter
+	mutex        sync.Mutex
+}
+
+func NewLLMKeyPools(redisClient *redis.Client, redisEnabled bool) *LLMKeyPools {
+	return &LLMKeyPools{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		states:       make(map[string][]*llmKeyState),
+		next:         make(map[string]int),
+		usage:        make(map[string]*LLMUsage),
+		keyUsage:     make(map[string]*LLMUsage),
+		tenantUsage:  make(map[string]*LLMUsage),
+		budgets:      make(map[string]*llmBudgetCounter),
+	}
+}
+
+// 池的运行状态，Key 数量变化（配置更新）时重建
+func (kp *LLMKeyPools) poolStates(pool *static.LLMKeyPoolConfig) []*llmKeyState {
+	states := kp.states[pool.Name]
+	if len(states) != len(pool.Keys) {
+		states = make([]*llmKeyState, len(pool.Keys))
+		for i := range states {
+			states[i] = &llmKeyState{}
+		}
+		kp.states[pool.Name] = states
+	}
+	return states
+}
+
+// 从上次位置开始轮换选择可用的 Key，跳过已尝试、冷却中和达到限流的 Key
+func (kp *LLMKeyPools) acquire(ctx context.Context, pool *static.LLMKeyPoolConfig, tried map[int]bool) (int, bool) {
+	now := time.Now()
+
+	kp.mutex.Lock()
+	states := kp.poolStates(pool)
+	start := kp.next[pool.Name]
+	candidates := make([]int, 0, len(states))
+	for i := range states {
+		index := (start + i) % len(states)
+		if !tried[index] && now.After(states[index].CooldownUntil) {
+			candidates = append(candidates, index)
+		}
+	}
+	kp.mutex.Unlock()
+
+	for _, index := range candidates {
+		if !kp.allowRate(ctx, pool, index, now) {
+			continue
+		}
+		kp.mutex.Lock()
+		kp.next[pool.Name] = index + 1
+		states[index].Requests++
+		kp.mutex.Unlock()
+		return index, true
+	}
+	return 0, false
+}
+
+// 按 Key 的每分钟请求上限限流：Redis 可用时所有实例共享计数，否则按实例计数
+func (kp *LLMKeyPools) allowRate(ctx context.Context, pool *static.LLMKeyPoolConfig, index int, now time.Time) bool {
+	rpm := pool.Keys[index].RPM
+	if rpm <= 0 {
+		return true
+	}
+	minute := now.Unix() / 60
+
+	if kp.redisEnabled {
+		key := fmt.Sprintf("%s%s:%d:%d", llmRateKeyPrefix, pool.Name, index, minute)
+		pipe := kp.redisClient.TxPipeline()
+		count := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, 2*time.Minute)
+		if _, err := pipe.Exec(ctx); err == nil {
+			return count.Val() <= int64(rpm)
+		}
+	}
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+	state := kp.states[pool.Name][index]
+	if state.window != minute {
+		state.window = minute
+		state.windowCount = 0
+	}
+	if state.windowCount >= rpm {
+		return false
+	}
+	state.windowCount++
+	return true
+}
+
+// 记录上游响应，限流或认证失败的 Key 进入冷却
+func (kp *LLMKeyPools) report(pool *static.LLMKeyPoolConfig, index, status int, retryAfter string) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	state := kp.poolStates(pool)[index]
+	state.LastStatus = status
+	if status < 400 {
+		return
+	}
+	state.Failures++
+
+	if status == http.StatusTooManyRequests || status == http.StatusUnauthorized || status == http.StatusForbidden {
+		cooldown := llmDefaultCooldown
+		if pool.Cooldown > 0 {
+			cooldown = time.Duration(pool.Cooldown) * time.Second
+		}
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 && status == http.StatusTooManyRequests {
+			cooldown = time.Duration(seconds) * time.Second
+		}
+		state.CooldownUntil = time.Now().Add(cooldown)
+		log.Printf("🧊 LLM key %s#%d cooling down for %v (status %d)", pool.Name, index, cooldown, status)
+	}
+}
+
+// 累加路由、调用方和租户用量（当前实例 + Redis 全局计数）
+func (kp *LLMKeyPools) recordUsage(routeID, tenant, caller string, failed bool, usage *llmResponseUsage) {
+	delta := LLMUsage{Requests: 1}
+	if failed {
+		delta.Errors = 1
+	}
+	if usage != nil && usage.Usage != nil {
+		delta.PromptTokens = usage.Usage.PromptTokens
+		delta.CompletionTokens = usage.Usage.CompletionTokens
+		delta.TotalTokens = usage.Usage.TotalTokens
+	}
+
+	kp.mutex.Lock()
+	addLLMUsage(kp.usage, routeID, delta)
+	addLLMUsage(kp.keyUsage, caller, delta)
+	if tenant != "" {
+		addLLMUsage(kp.tenantUsage, tenant, delta)
+	}
+	kp.mutex.Unlock()
+
+	counters := map[string]string{llmUsageRedisKey: routeID, llmKeyUsageRedisKey: caller}
+	if tenant != "" {
+		counters[llmTenantUsageKey] = tenant
+	}
+	if kp.redisEnabled {
+		ctx := context.Background()
+		pipe := kp.redisClient.Pipeline()
+		for hash, name := range counters {
+			pipe.HIncrBy(ctx, hash, name+":requests", delta.Requests)
+			pipe.HIncrBy(ctx, hash, name+":errors", delta.Errors)
+			pipe.HIncrBy(ctx, hash, name+":prompt_tokens", delta.PromptTokens)
+			pipe.HIncrBy(ctx, hash, name+":completion_tokens", delta.CompletionTokens)
+			pipe.HIncrBy(ctx, hash, name+":total_tokens", delta.TotalTokens)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to record LLM usage: %v", err)
+		}
+	}
+}
+
+func addLLMUsage(usage map[string]*LLMUsage, name string, delta LLMUsage) {
+	current := usage[name]
+	if current == nil {
+		current = &LLMUsage{}
+		usage[name] = current
+	}
+	current.Requests += delta.Requests
+	current.Errors += delta.Errors
+	current.PromptTokens += delta.PromptTokens
+	current.CompletionTokens += delta.CompletionTokens
+	current.TotalTokens += delta.TotalTokens
+}
+
+// 用量报告
+type LLMUsageReport struct {
+	Routes  map[string]*LLMUsage `json:"usage"`
+	Callers map[string]*LLMUsage `json:"callers"`
+	Tenants map[string]*LLMUsage `json:"tenants"`
+}
+
+// 按路由、调用方和租户的用量统计：Redis 可用时返回所有实例合计
+func (kp *LLMKeyPools) Usage(ctx context.Context) (*LLMUsageReport, error) {
+	report := &LLMUsageReport{}
+	for _, source := range []struct {
+		target *map[string]*LLMUsage
+		hash   string
+		local  map[string]*LLMUsage
+	}{
+		{&report.Routes, llmUsageRedisKey, kp.usage},
+		{&report.Callers, llmKeyUsageRedisKey, kp.keyUsage},
+		{&report.Tenants, llmTenantUsageKey, kp.tenantUsage},
+	} {
+		usage, err := kp.usageFrom(ctx, source.hash, source.local)
+		if err != nil {
+			return nil, err
+		}
+		*source.target = usage
+	}
+	return report, nil
+}
+
+func (kp *LLMKeyPools) usageFrom(ctx context.Context, hash string, local map[string]*LLMUsage) (map[string]*LLMUsage, error) {
+	if !kp.redisEnabled {
+		kp.mutex.Lock()
+		defer kp.mutex.Unlock()
+		usage := make(map[string]*LLMUsage, len(local))
+		for name, current := range local {
+			copied := *current
+			usage[name] = &copied
+		}
+		return usage, nil
+	}
+
+	stored, err := kp.redisClient.HGetAll(ctx, hash).Result()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*LLMUsage)
+	for field, value := range stored {
+		separator := strings.LastIndex(field, ":")
+		if separator < 0 {
+			continue
+		}
+		name, counter := field[:separator], field[separator+1:]
+		count, _ := strconv.ParseInt(value, 10, 64)
+		current := usage[name]
+		if current == nil {
+			current = &LLMUsage{}
+			usage[name] = current
+		}
+		switch counter {
+		case "requests":
+			current.Requests = count
+		case "errors":
+			current.Errors = count
+		case "prompt_tokens":
+			current.PromptTokens = count
+		case "completion_tokens":
+			current.CompletionTokens = count
+		case "total_tokens":
+			current.TotalTokens = count
+		}
+	}
+	return usage, nil
+}
+
+// Key 池状态（不返回 Key 值）
+func (kp *LLMKeyPools) Status() []gin.H {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	pools := gatewaySettings().LLMKeyPools
+	status := make([]gin.H, 0, len(pools))
+	for i := range pools {
+		states := kp.poolStates(&pools[i])
+		keys := make([]gin.H, 0, len(states))
+		for index, state := range states {
+			cooldownUntil := int64(0)
+			if !state.CooldownUntil.IsZero() {
+				cooldownUntil = state.CooldownUntil.Unix()
+			}
+			keys = append(keys, gin.H{
+				"index":          index,
+				"rpm":            pools[i].Keys[index].RPM,
+				"cooling":        time.Now().Before(state.CooldownUntil),
+				"cooldown_until": cooldownUntil,
+				"requests":       state.Requests,
+				"failures":       state.Failures,
+				"last_status":    state.LastStatus,
+			})
+		}
+		status = append(status, gin.H{"name": pools[i].Name, "keys": keys})
+	}
+	return status
+}
+
+// 上游失败时是否换下一个 Key 重试
+func llmRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusUnauthorized ||
+		status == http.StatusForbidden || status >= 500
+}
+
+// 🔧 新增：OpenAI 兼容接口代理，使用 Key 池中的上游 Key 替换客户端凭证
+func (dr *DistributedRouter) handleLLMRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if route.LLM == nil {
+		writeRouteError(w, r, route, http.StatusBadGateway, gin.H{"error": "llm route misconfigured"})
+		return
+	}
+
+	// 请求体需要在换 Key 重试时重复发送
+	body, err := io.ReadAll(io.LimitReader(r.Body, llmMaxRequestBody+1))
+	if err != nil || len(body) > llmMaxRequestBody {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(gin.H{"error": "request body too large"})
+		return
+	}
+
+	ctx := r.Context()
+	if route.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(route.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	// 🔧 新增：响应缓存，命中时不访问上游也不计入 token 预算
+	var cacheLookup *llmCacheLookup
+	if route.LLM.Cache != nil {
+		var cached *llmCachedResponse
+		cached, cacheLookup = dr.lookupLLMCache(ctx, route, tenantFromRequest(r), body)
+		if cached != nil {
+			w.Header().Set("Content-Type", cached.ContentType)
+			w.Header().Set("X-Cache", "HIT")
+			w.Write(cached.Body)
+			dr.llmKeys.recordUsage(route.ID, tenantFromRequest(r), llmCaller(r), false, nil)
+			return
+		}
+		if cacheLookup != nil {
+			w.Header().Set("X-Cache", "MISS")
+		}
+	}
+
+	// 🔧 新增：token 预算检查，调用方为认证后的 API Key 名称或 JWT subject
+	caller, tenant := llmCaller(r), tenantFromRequest(r)
+	if budget := route.LLM.Budget; budget != nil {
+		if wait, exceeded := dr.llmKeys.budgetExceeded(ctx, route.ID, tenant, caller, budget, time.Now()); exceeded {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(gin.H{"error": "token budget exceeded", "retry_after": int(wait.Seconds()) + 1})
+			return
+		}
+		body = requestStreamUsage(body)
+	}
+
+	// 🔧 新增：按 model 字段选择上游（主上游 + 备用上游）
+	model := requestModel(body)
+	upstreams := route.LLM.upstreams(route.Target, model)
+	if len(upstreams) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(gin.H{"error": fmt.Sprintf("model %q is not served by this route", model)})
+		return
+	}
+
+	var lastErr error
+	for i, upstream := range upstreams {
+		resp, err := dr.tryLLMUpstream(ctx, route, upstream, r, body)
+		if err != nil {
+			lastErr = err
+		} else if llmRetryableStatus(resp.StatusCode) && i < len(upstreams)-1 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("upstream returned %d", resp.StatusCode)
+		} else {
+			usage, captured := writeLLMResponse(w, resp, route.ResponseHeaders)
+			if cacheLookup != nil && resp.StatusCode == http.StatusOK && captured != nil {
+				dr.storeLLMCache(ctx, route, cacheLookup, resp.Header.Get("Content-Type"), captured)
+			}
+			dr.llmKeys.recordUsage(route.ID, tenant, caller, resp.StatusCode >= 400, usage)
+			if route.LLM.Budget != nil && usage != nil && usage.Usage != nil {
+				dr.llmKeys.consumeBudget(route.ID, tenant, caller, route.LLM.Budget, usage.Usage.TotalTokens, time.Now())
+			}
+			return
+		}
+		if i < len(upstreams)-1 {
+			log.Printf("↪️  LLM route %s: upstream %s failed (%v), falling back", route.ID, upstream.Target, lastErr)
+		}
+	}
+
+	dr.llmKeys.recordUsage(route.ID, tenant, caller, true, nil)
+	writeRouteError(w, r, route, http.StatusServiceUnavailable, gin.H{"error": "upstream unavailable: " + lastErr.Error()})
+}
+
+// 使用上游的 Key 池依次尝试，返回最终响应（可能是可重试的错误状态），所有 Key 都不可用时返回错误
+func (dr *DistributedRouter) tryLLMUpstream(ctx context.Context, route *RouteConfig, upstream LLMUpstream, r *http.Request, body []byte) (*http.Response, error) {
+	pool := findLLMKeyPool(upstream.KeyPool)
+	target, err := url.Parse(upstream.Target)
+	if pool == nil || len(pool.Keys) == 0 || err != nil || target.Host == "" {
+		return nil, fmt.Errorf("upstream %s misconfigured", upstream.Target)
+	}
+
+	if upstream.Model != "" {
+		body = rewriteRequestModel(body, upstream.Model)
+	}
+
+	upstreamURL := *target
+	upstreamURL.Path = strings.TrimRight(target.Path, "/") + r.URL.Path
+	upstreamURL.RawQuery = r.URL.RawQuery
+
+	tried := make(map[int]bool)
+	lastErr := fmt.Errorf("no upstream key available")
+	for attempt := 0; attempt < len(pool.Keys); attempt++ {
+		index, ok := dr.llmKeys.acquire(ctx, pool, tried)
+		if !ok {
+			break
+		}
+		tried[index] = true
+
+		resp, err := dr.sendLLMRequest(ctx, pool, index, r, upstreamURL.String(), body, route.RequestHeaders)
+		if err != nil {
+			lastErr = err
+			dr.llmKeys.report(pool, index, http.StatusBadGateway, "")
+			log.Printf("🔁 LLM route %s: key %s#%d failed: %v", route.ID, pool.Name, index, err)
+			continue
+		}
+
+		dr.llmKeys.report(pool, index, resp.StatusCode, resp.Header.Get("Retry-After"))
+		if llmRetryableStatus(resp.StatusCode) && attempt < len(pool.Keys)-1 && len(tried) < len(pool.Keys) {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("upstream returned %d", resp.StatusCode)
+			log.Printf("🔁 LLM route %s: key %s#%d returned %d, trying next key", route.ID, pool.Name, index, resp.StatusCode)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+func (dr *DistributedRouter) sendLLMRequest(ctx context.Context, pool *static.LLMKeyPoolConfig, index int, r *http.Request, upstreamURL string, body []byte, headers *RouteRequestHeaders) (*http.Response, error) {
+	key, err := dr.secrets.Resolve(ctx, pool.Keys[index].Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve key: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range []string{"Content-Type", "Accept", "OpenAI-Organization", "OpenAI-Beta", "User-Agent"} {
+		if value := r.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	// 🔧 新增：路由改写的请求头
+	headers.copyTo(req.Header, r.Header)
+
+	// 客户端凭证不转发，使用池中的上游 Key
+	authHeader := pool.AuthHeader
+	if authHeader == "" {
+		authHeader = llmDefaultAuthHeader
+	}
+	if strings.EqualFold(authHeader, "Authorization") {
+		req.Header.Set("Authorization", "Bearer "+key)
+	} else {
+		req.Header.Set(authHeader, key)
+	}
+
+	return upstreamClient.Do(req)
+}
+
+// 将上游响应写回客户端（流式响应逐行转发并刷新），返回解析到的用量；非流式响应完整读取时同时返回响应体（用于缓存）
+func writeLLMResponse(w http.ResponseWriter, resp *http.Response, filter *RouteResponseHeaders) (*llmResponseUsage, []byte) {
+	defer resp.Body.Close()
+
+	copyResponseHeaders(w.Header(), resp.Header, filter)
+	w.WriteHeader(resp.StatusCode)
+
+	// SSE：usage 出现在最后的数据块中（需客户端设置 stream_options.include_usage）
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		flusher, _ := w.(http.Flusher)
+		reader := bufio.NewReader(resp.Body)
+		var usage *llmResponseUsage
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				w.Write(line)
+				if flusher != nil && len(bytes.TrimSpace(line)) == 0 {
+					flusher.Flush()
+				}
+				if data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok && bytes.Contains(data, []byte(`"usage"`)) {
+					var chunk llmResponseUsage
+					if json.Unmarshal(bytes.TrimSpace(data), &chunk) == nil && chunk.Usage != nil {
+						usage = &chunk
+					}
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return usage, nil
+	}
+
+	// 非流式：保留前 llmMaxCapturedBody 字节用于解析 usage
+	var captured bytes.Buffer
+	copied, err := io.Copy(w, io.TeeReader(resp.Body, &limitedBuffer{buffer: &captured, limit: llmMaxCapturedBody}))
+	var usage llmResponseUsage
+	if json.Unmarshal(captured.Bytes(), &usage) != nil {
+		return nil, nil
+	}
+	if err != nil || copied != int64(captured.Len()) {
+		return &usage, nil
+	}
+	return &usage, captured.Bytes()
+}
+
+// 超出上限后丢弃写入的缓冲区
+type limitedBuffer struct {
+	buffer *bytes.Buffer
+	limit  int
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	if remaining := b.limit - b.buffer.Len(); remaining > 0 {
+		if len(data) > remaining {
+			b.buffer.Write(data[:remaining])
+		} else {
+			b.buffer.Write(data)
+		}
+	}
+	return len(data), nil
+}
+
+// 🔧 新增：LLM 路由用量统计
+func (dr *DistributedRouter) llmUsageHandler(c *gin.Context) {
+	report, err := dr.llmKeys.Usage(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
+
+// 🔧 新增：LLM Key 池状态
+func (dr *DistributedRouter) llmKeyPoolsHandler(c *gin.Context) {
+	c.JSON(200, gin.H{"pools": dr.llmKeys.Status()})
+}
@@ -0,0 +1,185 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loadGenMaxRequests    = 1000000
+	loadGenMaxConcurrency = 256
+)
+
+// 压测请求参数
+type loadGenRequest struct {
+	Path        string `json:"path"`
+	Method      string `json:"method"`
+	Requests    int    `json:"requests"`
+	Concurrency int    `json:"concurrency"`
+	Mode        string `json:"mode"` // "match" 只执行路由匹配；"gateway" 走完整网关处理链
+	// 🔧 新增：gateway 模式命中非 echo 路由时请求会真实转发到上游，需要显式确认
+	AllowUpstream bool `json:"allow_upstream"`
+}
+
+// 🔧 新增：进程内微型压测，用于验证路由热路径的性能改动
+func (dr *DistributedRouter) loadGenHandler(c *gin.Context) {
+	var req loadGenRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Path == "" {
+		c.JSON(400, gin.H{"error": "path is required"})
+		return
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	// 路径和方法必须能构成合法的请求行
+	if !strings.HasPrefix(req.Path, "/") {
+		c.JSON(400, gin.H{"error": "path must start with /"})
+		return
+	}
+	if _, err := url.ParseRequestURI(req.Path); err != nil || strings.ContainsAny(req.Path, " \t\r\n") {
+		c.JSON(400, gin.H{"error": "invalid path: " + req.Path})
+		return
+	}
+	if _, err := http.NewRequest(req.Method, req.Path, nil); err != nil {
+		c.JSON(400, gin.H{"error": "invalid method: " + req.Method})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = "match"
+	}
+	if req.Mode != "match" && req.Mode != "gateway" {
+		c.JSON(400, gin.H{"error": "invalid mode: " + req.Mode})
+		return
+	}
+	if req.Requests <= 0 {
+		req.Requests = 10000
+	}
+	if req.Requests > loadGenMaxRequests {
+		req.Requests = loadGenMaxRequests
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = 1
+	}
+	if req.Concurrency > loadGenMaxConcurrency {
+		req.Concurrency = loadGenMaxConcurrency
+	}
+
+	// gateway 模式只有 echo 路由在网关内应答，其他处理器会把压测流量发到真实的上游或沙箱
+	if req.Mode == "gateway" && !req.AllowUpstream {
+		if route := dr.routeManager.matchRoute(req.Path, req.Method); route != nil && route.Handler != "echo" {
+			c.JSON(400, gin.H{"error": "route " + route.ID + " uses the " + route.Handler +
+				" handler and gateway mode would send real traffic to it; use an echo route or set allow_upstream: true"})
+			return
+		}
+	}
+
+	apiKey := ""
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		apiKey = config.App.GatewayKey
+		if apiKey == "" {
+			apiKey = config.App.Key
+		}
+	}
+
+	latencies := make([]time.Duration, req.Requests)
+	statusCounts := make(map[int]int)
+	matched := 0
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan int, req.Concurrency)
+	// 🔧 修改：处理链只构建一次，延迟中不包含构建中间件链的开销
+	handler := dr.gatewayHandler()
+	startTime := time.Now()
+
+	for w := 0; w < req.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				begin := time.Now()
+				if req.Mode == "match" {
+					route := dr.routeManager.matchRoute(req.Path, req.Method)
+					latencies[i] = time.Since(begin)
+					if route != nil {
+						mutex.Lock()
+						matched++
+						mutex.Unlock()
+					}
+					continue
+				}
+
+				// 请求已在上面校验，这里不会失败
+				httpReq, _ := http.NewRequest(req.Method, req.Path, nil)
+				httpReq.Body = http.NoBody // 与服务端收到的请求一致，Body 不为 nil
+				httpReq.RequestURI = req.Path
+				httpReq.RemoteAddr = "127.0.0.1:0"
+				httpReq.Header.Set("X-Api-Key", apiKey)
+				// 处理链在路由确定后记录路由ID，只统计真正命中路由的请求（认证失败等提前返回的不算）
+				info := &requestLogInfo{}
+				httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), requestLogInfoKey{}, info))
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httpReq)
+				latencies[i] = time.Since(begin)
+
+				mutex.Lock()
+				statusCounts[recorder.Code]++
+				if info.RouteID != "" {
+					matched++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < req.Requests; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	elapsed := time.Since(startTime)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	response := gin.H{
+		"mode":           req.Mode,
+		"requests":       req.Requests,
+		"concurrency":    req.Concurrency,
+		"matched":        matched,
+		"route_count":    len(dr.routeManager.GetAllRoutes()),
+		"duration_ms":    elapsed.Milliseconds(),
+		"rps":            float64(req.Requests) / elapsed.Seconds(),
+		"latency_p50_ns": percentile(latencies, 0.50).Nanoseconds(),
+		"latency_p90_ns": percentile(latencies, 0.90).Nanoseconds(),
+		"latency_p99_ns": percentile(latencies, 0.99).Nanoseconds(),
+		"latency_max_ns": latencies[len(latencies)-1].Nanoseconds(),
+	}
+	if req.Mode == "gateway" {
+		response["status_codes"] = statusCounts
+	}
+
+	c.JSON(200, response)
+}
+
+// 计算已排序延迟数据的分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
@@ -0,0 +1,414 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	logEventAccess   = "access"
+	logEventAudit    = "audit"
+	logEventSLOAlert = "slo_alert"
+
+	logEventCanaryRollback = "canary_rollback"
+	logEventSandboxChange  = "sandbox_change"
+)
+
+// 转发给外部日志系统的结构化事件
+type LogEvent struct {
+	Type           string    `json:"type"` // access、audit、slo_alert、canary_rollback 或 sandbox_change
+	Timestamp      time.Time `json:"timestamp"`
+	InstanceID     string    `json:"instance_id"`
+	Server         string    `json:"server,omitempty"` // 🔧 新增：访问日志的来源端口 admin 或 gateway
+	Method         string    `json:"method"`
+	OriginalMethod string    `json:"original_method,omitempty"` // 方法覆盖前的请求方法
+	Path           string    `json:"path"`
+	RouteID        string    `json:"route_id,omitempty"`
+	Principal      string    `json:"principal,omitempty"`
+	Tenant         string    `json:"tenant,omitempty"`
+	Variant        string    `json:"variant,omitempty"`  // 🔧 新增：金丝雀路由的版本 stable 或 canary
+	TraceID        string    `json:"trace_id,omitempty"` // 🔧 新增：采样请求的追踪ID
+	ClientIP       string    `json:"client_ip"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Status         int       `json:"status"`
+	RequestBytes   int64     `json:"request_bytes"`
+	Bytes          int64     `json:"bytes"`
+	DurationMs     float64   `json:"duration_ms"`
+	Message        string    `json:"message,omitempty"`
+}
+
+// 日志输出端
+type LogSink interface {
+	Name() string
+	Write(ctx context.Context, events []LogEvent) error
+	Close() error
+}
+
+// 日志转发器：事件进入有界队列，后台批量写入各输出端，队列满时丢弃而不阻塞请求
+type LogForwarder struct {
+	config     static.LogForwardingConfig
+	instanceID string
+	sinks      []LogSink
+	events     chan LogEvent
+	dropped    atomic.Int64
+	sent       atomic.Int64
+	failed     atomic.Int64
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewLogForwarder(config static.LogForwardingConfig, instanceID string, sinks []LogSink) *LogForwarder {
+	bufferSize := config.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	return &LogForwarder{
+		config:     config,
+		instanceID: instanceID,
+		sinks:      sinks,
+		events:     make(chan LogEvent, bufferSize),
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// 根据配置创建日志转发器（extraSinks 为其他模块提供的输出端，如 OTLP），没有输出端时返回 nil
+func newLogForwarderFromConfig(config static.LogForwardingConfig, instanceID string, secrets *SecretResolver, extraSinks ...LogSink) *LogForwarder {
+	if !config.Enabled && len(extraSinks) == 0 {
+		return nil
+	}
+
+	sinks := append([]LogSink(nil), extraSinks...)
+	if config.Enabled && config.Syslog.Enabled {
+		sink, err := newSyslogSink(config.Syslog)
+		if err != nil {
+			log.Printf("❌ Failed to create syslog sink: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if config.Enabled && config.HTTP.Enabled {
+		sinks = append(sinks, newHTTPLogSink(config.HTTP, secrets))
+	}
+	if len(sinks) == 0 {
+		log.Printf("⚠️ Log forwarding enabled but no sink configured")
+		return nil
+	}
+
+	forwarder := NewLogForwarder(config, instanceID, sinks)
+	forwarder.Start()
+	return forwarder
+}
+
+// 启动后台发送
+func (f *LogForwarder) Start() {
+	batchSize := f.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := time.Duration(f.config.FlushInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		batch := make([]LogEvent, 0, batchSize)
+		for {
+			select {
+			case event := <-f.events:
+				batch = append(batch, event)
+				if len(batch) >= batchSize {
+					f.flush(batch)
+					batch = batch[:0]
+				}
+			case <-ticker.C:
+				if len(batch) > 0 {
+					f.flush(batch)
+					batch = batch[:0]
+				}
+			case <-f.stopChan:
+				// 发送剩余事件
+				for {
+					select {
+					case event := <-f.events:
+						batch = append(batch, event)
+					default:
+						if len(batch) > 0 {
+							f.flush(batch)
+						}
+						return
+					}
+				}
+			}
+		}
+	}()
+	log.Printf("📤 Log forwarding started (%d sinks)", len(f.sinks))
+}
+
+// 停止并关闭输出端
+func (f *LogForwarder) Stop() {
+	if f == nil {
+		return
+	}
+	close(f.stopChan)
+	f.wg.Wait()
+	for _, sink := range f.sinks {
+		sink.Close()
+	}
+}
+
+func (f *LogForwarder) flush(batch []LogEvent) {
+	for _, sink := range f.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := sink.Write(ctx, batch)
+		cancel()
+		if err != nil {
+			f.failed.Add(int64(len(batch)))
+			log.Printf("❌ Log sink %s failed: %v", sink.Name(), err)
+			continue
+		}
+		f.sent.Add(int64(len(batch)))
+	}
+}
+
+// 提交事件（不阻塞）
+func (f *LogForwarder) Emit(event LogEvent) {
+	if f == nil {
+		return
+	}
+	event.InstanceID = f.instanceID
+	// 🔧 新增：按 gateway.redaction 脱敏后再进入队列
+	event = currentRedactor().logEvent(event)
+	select {
+	case f.events <- event:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// 转发统计
+func (f *LogForwarder) Stats() gin.H {
+	if f == nil {
+		return gin.H{"enabled": false}
+	}
+	sinks := make([]string, 0, len(f.sinks))
+	for _, sink := range f.sinks {
+		sinks = append(sinks, sink.Name())
+	}
+	return gin.H{
+		"enabled": true,
+		"sinks":   sinks,
+		"queued":  len(f.events),
+		"sent":    f.sent.Load(),
+		"failed":  f.failed.Load(),
+		"dropped": f.dropped.Load(),
+	}
+}
+
+// 请求处理过程中补充的日志信息（认证和路由匹配后写入）
+type requestLogInfo struct {
+	RouteID   string
+	Principal string
+	Tenant    string
+	Variant   string     // 🔧 新增：金丝雀路由选择的版本
+	Trace     *traceSpan // 🔧 新增：采样的追踪 span
+	Route     *RouteConfig
+}
+
+type requestLogInfoKey struct{}
+
+func logInfoFromRequest(r *http.Request) *requestLogInfo {
+	info, _ := r.Context().Value(requestLogInfoKey{}).(*requestLogInfo)
+	return info
+}
+
+// 统计已读取的请求体字节数
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.bytes += int64(n)
+	return n, err
+}
+
+// 记录状态码和响应大小，同时保留 Flusher/Hijacker 能力
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(data []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(data)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// 🔧 新增：访问日志与请求指标（两个端口共用）。请求指标、SLO、金丝雀统计和追踪只针对网关端口的路由请求，
+// 管理端口的请求同时输出到控制台
+func (dr *DistributedRouter) accessLogMiddleware(server string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logging := dr.logForwarder != nil && dr.logForwarder.config.AccessLog
+			// 🔧 新增：方法覆盖的使用记入审计日志
+			originalMethod := methodOverrideFrom(r)
+			auditing := originalMethod != "" && dr.logForwarder != nil && dr.logForwarder.config.AuditLog
+			gateway := server == serverGateway
+			measuring := gateway && (dr.metrics != nil || dr.statsd != nil || dr.slo != nil || dr.tracer != nil)
+			console := server == serverAdmin
+			if !logging && !auditing && !measuring && !console {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			// 调用方（如进程内压测）已提供时沿用，处理完成后可读取命中的路由
+			info := logInfoFromRequest(r)
+			if info == nil {
+				info = &requestLogInfo{}
+			}
+			recorder := &statusRecorder{ResponseWriter: w}
+			body := &countingReader{ReadCloser: r.Body}
+			request := r.WithContext(context.WithValue(r.Context(), requestLogInfoKey{}, info))
+			request.Body = body
+			next.ServeHTTP(recorder, request)
+			duration := time.Since(start)
+
+			// 请求体字节数：处理器未读取请求体时使用 Content-Length
+			requestBytes := body.bytes
+			if requestBytes == 0 && r.ContentLength > 0 {
+				requestBytes = r.ContentLength
+			}
+			if gateway {
+				dr.metrics.RecordRequest(info.RouteID, r.Method, recorder.status, duration, requestBytes, recorder.bytes)
+				dr.statsd.RecordRequest(info.RouteID, r.Method, recorder.status, duration, requestBytes, recorder.bytes)
+				dr.slo.Record(info.Route, recorder.status, duration, start)
+				dr.canaries.Record(info.Route, info.Variant, recorder.status, duration, start)
+				dr.tracer.finish(info, request, recorder.status, start, duration)
+			}
+			if console {
+				log.Printf("📝 [%s] %3d | %10v | %15s | %-7s %q", server, recorder.status, duration, clientIP(r), r.Method, r.URL.Path)
+			}
+			event := LogEvent{
+				Type:           logEventAccess,
+				Server:         server,
+				Timestamp:      start,
+				Method:         r.Method,
+				OriginalMethod: originalMethod,
+				Path:           r.URL.Path,
+				RouteID:        info.RouteID,
+				Principal:      info.Principal,
+				Tenant:         info.Tenant,
+				Variant:        info.Variant,
+				TraceID:        info.traceID(),
+				ClientIP:       clientIP(r),
+				UserAgent:      r.UserAgent(),
+				Status:         recorder.status,
+				RequestBytes:   requestBytes,
+				Bytes:          recorder.bytes,
+				DurationMs:     float64(duration.Microseconds()) / 1000,
+			}
+			if logging {
+				dr.logForwarder.Emit(event)
+			}
+			if auditing {
+				event.Type = logEventAudit
+				event.Message = fmt.Sprintf("method override %s -> %s", originalMethod, r.Method)
+				dr.logForwarder.Emit(event)
+			}
+		})
+	}
+}
+
+// 🔧 新增：管理端口修改操作审计日志
+func (dr *DistributedRouter) auditLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dr.logForwarder == nil || !dr.logForwarder.config.AuditLog {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		// 认证失败的修改尝试同样记录，但不标记调用方
+		principal := "admin"
+		if c.Writer.Status() == http.StatusUnauthorized {
+			principal = ""
+		}
+		dr.logForwarder.Emit(LogEvent{
+			Type:       logEventAudit,
+			Timestamp:  start,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			RouteID:    c.Param("id") + c.Param("routeId"),
+			Principal:  principal,
+			ClientIP:   c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			Status:     c.Writer.Status(),
+			Bytes:      int64(c.Writer.Size()),
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Message:    c.GetString(auditMessageKey),
+		})
+	}
+}
+
+// 客户端地址（不信任转发头，取连接地址）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
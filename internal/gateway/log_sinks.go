@@ -0,0 +1,209 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// Syslog 输出（RFC 5424，消息体为 JSON；TCP 使用 octet-counting 分帧）
+type syslogSink struct {
+	config   static.SyslogSinkConfig
+	hostname string
+	tls      *tls.Config
+	conn     net.Conn
+	mutex    sync.Mutex
+}
+
+func newSyslogSink(config static.SyslogSinkConfig) (*syslogSink, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+	switch config.Network {
+	case "", "udp", "tcp", "tcp+tls":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", config.Network)
+	}
+
+	sink := &syslogSink{config: config}
+	sink.hostname, _ = os.Hostname()
+	if sink.hostname == "" {
+		sink.hostname = "-"
+	}
+	if sink.config.AppName == "" {
+		sink.config.AppName = "dify-router"
+	}
+
+	if config.Network == "tcp+tls" {
+		host, _, _ := net.SplitHostPort(config.Address)
+		sink.tls = &tls.Config{ServerName: host, InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CAFile != "" {
+			pem, err := os.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read syslog CA: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("invalid syslog CA file: %s", config.CAFile)
+			}
+			sink.tls.RootCAs = pool
+		}
+	}
+	return sink, nil
+}
+
+func (s *syslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *syslogSink) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	switch s.config.Network {
+	case "tcp+tls":
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tls}).DialContext(ctx, "tcp", s.config.Address)
+	case "tcp":
+		conn, err = dialer.DialContext(ctx, "tcp", s.config.Address)
+	default:
+		conn, err = dialer.DialContext(ctx, "udp", s.config.Address)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *syslogSink) Write(ctx context.Context, events []LogEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	stream := s.config.Network != "" && s.config.Network != "udp"
+	for _, event := range events {
+		message := s.format(event)
+		if stream {
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+		if _, err := io.WriteString(s.conn, message); err != nil {
+			// 连接失效，下次重连
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+func (s *syslogSink) format(event LogEvent) string {
+	severity := 6 // informational
+	if event.Type == logEventAudit {
+		severity = 5 // notice
+	}
+	if event.Status >= 500 {
+		severity = 3 // error
+	}
+	if event.Type == logEventSLOAlert || event.Type == logEventCanaryRollback {
+		severity = 4 // warning
+	}
+	body, _ := json.Marshal(event)
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.config.Facility*8+severity,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		s.config.AppName,
+		os.Getpid(),
+		event.Type,
+		body)
+}
+
+func (s *syslogSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		err := s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// HTTP 投递：每批事件以 JSON 数组 POST 到指定地址
+type httpLogSink struct {
+	config  static.HTTPSinkConfig
+	secrets *SecretResolver
+	client  *http.Client
+}
+
+func newHTTPLogSink(config static.HTTPSinkConfig, secrets *SecretResolver) *httpLogSink {
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &httpLogSink{
+		config:  config,
+		secrets: secrets,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *httpLogSink) Name() string {
+	return "http"
+}
+
+func (s *httpLogSink) Write(ctx context.Context, events []LogEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, ref := range s.config.Headers {
+		value, err := s.secrets.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve header %s: %v", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("log endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpLogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// 匹配缓存的分片数上限，分片之间互不加锁
+const matchCacheShards = 16
+
+// 🔧 新增：路由匹配结果缓存：(方法, 租户, 路径) -> 路由ID，
+// 条目记录生成时的路由表版本，路由表发布新快照后旧条目视为未命中，热点接口无需遍历匹配器；
+// 🔧 有生效时间窗口的路由时同时记录窗口纪元，任何路由进入或离开窗口后旧条目同样失效。
+// 🔧 修改：按键哈希分片，命中只持有分片读锁并标记访问位，淘汰采用 CLOCK（近似 LRU），
+// 读路径不再被一把全局互斥锁串行化
+type matchCache struct {
+	capacity int
+	seed     maphash.Seed
+	shards   []matchCacheShard
+}
+
+type matchCacheShard struct {
+	mutex    sync.RWMutex
+	capacity int
+	items    map[string]*matchCacheEntry
+	ring     []*matchCacheEntry // CLOCK 环，hand 指向下一个淘汰候选
+	hand     int
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+type matchCacheEntry struct {
+	key        string
+	version    int64
+	epoch      int
+	routeID    string
+	referenced atomic.Bool // 最近被访问过，淘汰时跳过一轮
+}
+
+func newMatchCache(capacity int) *matchCache {
+	if capacity <= 0 {
+		return nil
+	}
+	shards := min(matchCacheShards, capacity)
+	mc := &matchCache{
+		capacity: capacity,
+		seed:     maphash.MakeSeed(),
+		shards:   make([]matchCacheShard, shards),
+	}
+	for i := range mc.shards {
+		// 容量按分片均分，余数分给前面的分片，总容量不变
+		shardCapacity := capacity / shards
+		if i < capacity%shards {
+			shardCapacity++
+		}
+		mc.shards[i].capacity = shardCapacity
+		mc.shards[i].items = make(map[string]*matchCacheEntry)
+	}
+	return mc
+}
+
+// 🔧 修改：缓存键追加到调用方提供的缓冲区，命中时查找不分配内存
+func appendMatchCacheKey(dst []byte, method, tenant, host, path string) []byte {
+	dst = append(dst, method...)
+	dst = append(dst, 0)
+	dst = append(dst, tenant...)
+	dst = append(dst, 0)
+	dst = append(dst, host...)
+	dst = append(dst, 0)
+	return append(dst, path...)
+}
+
+func (mc *matchCache) shard(key []byte) *matchCacheShard {
+	return &mc.shards[maphash.Bytes(mc.seed, key)%uint64(len(mc.shards))]
+}
+
+// 获取匹配结果，路由表版本或窗口纪元不一致视为未命中（过期条目由下一次写入覆盖）
+func (mc *matchCache) get(key []byte, version int64, epoch int) (string, bool) {
+	if mc == nil {
+		return "", false
+	}
+	shard := mc.shard(key)
+	shard.mutex.RLock()
+	entry, ok := shard.items[string(key)]
+	if ok && entry.version == version && entry.epoch == epoch {
+		routeID := entry.routeID
+		if !entry.referenced.Load() {
+			entry.referenced.Store(true)
+		}
+		shard.mutex.RUnlock()
+		shard.hits.Add(1)
+		return routeID, true
+	}
+	shard.mutex.RUnlock()
+	shard.misses.Add(1)
+	return "", false
+}
+
+// 写入匹配结果，超出容量时按 CLOCK 淘汰最近未被访问的条目
+func (mc *matchCache) put(key []byte, version int64, epoch int, routeID string) {
+	if mc == nil {
+		return
+	}
+	shard := mc.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if entry, ok := shard.items[string(key)]; ok {
+		entry.version, entry.epoch, entry.routeID = version, epoch, routeID
+		entry.referenced.Store(true)
+		return
+	}
+	entry := &matchCacheEntry{key: string(key), version: version, epoch: epoch, routeID: routeID}
+	shard.items[entry.key] = entry
+	if len(shard.ring) < shard.capacity {
+		shard.ring = append(shard.ring, entry)
+		return
+	}
+	for {
+		victim := shard.ring[shard.hand]
+		if victim.referenced.Load() {
+			victim.referenced.Store(false)
+			shard.hand = (shard.hand + 1) % len(shard.ring)
+			continue
+		}
+		delete(shard.items, victim.key)
+		shard.ring[shard.hand] = entry
+		shard.hand = (shard.hand + 1) % len(shard.ring)
+		return
+	}
+}
+
+// 各分片的条目数与命中次数之和
+func (mc *matchCache) totals() (entries int, hits, misses int64) {
+	for i := range mc.shards {
+		shard := &mc.shards[i]
+		shard.mutex.RLock()
+		entries += len(shard.items)
+		shard.mutex.RUnlock()
+		hits += shard.hits.Load()
+		misses += shard.misses.Load()
+	}
+	return entries, hits, misses
+}
+
+// 命中率，没有查询时为 0
+func (mc *matchCache) hitRatio() float64 {
+	if mc == nil {
+		return 0
+	}
+	_, hits, misses := mc.totals()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// 缓存统计
+func (mc *matchCache) stats() map[string]interface{} {
+	if mc == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	entries, hits, misses := mc.totals()
+
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"entries":   entries,
+		"capacity":  mc.capacity,
+		"shards":    len(mc.shards),
+		"hits":      hits,
+		"misses":    misses,
+		"hit_ratio": ratio,
+	}
+}
@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// 预编译的路由匹配器
+// 在路由写入缓存时编译一次，请求热路径上只做正则匹配，不再构建 mux.Router
+type routeMatcher struct {
+	pathRegexp      *regexp.Regexp // 🔧 新增：正则路由 path_regex（匹配整个路径）
+	paramRegexp     *regexp.Regexp // 参数路由 /users/{id}
+	wildcardRegexp  *regexp.Regexp // 通配符路由 /api/*
+	prefix          string         // 前缀匹配 /api/
+	segments        []string       // 🔧 新增：参数都是整段 {name} 的路由按段比较，不使用正则（参数段为空字符串）
+	wildcardPrefix  string         // 🔧 新增：只在末尾有一个 * 且不含正则元字符的通配符路由按前缀比较
+	caseInsensitive bool           // 🔧 新增：忽略大小写（gateway.path_normalization.case_insensitive）
+}
+
+// 编译路由匹配器
+func compileRouteMatcher(route RouteConfig) *routeMatcher {
+	path := route.fullPath() // 🔧 新增：分组路由按分组前缀加路径匹配
+	m := &routeMatcher{
+		prefix:          path + "/",
+		caseInsensitive: gatewaySettings().PathNormalization.CaseInsensitive,
+	}
+	flags := ""
+	if m.caseInsensitive {
+		flags = "(?i)"
+	}
+
+	// 🔧 新增：正则路由只编译正则（路径中的 {n} 是量词而不是参数）
+	if route.PathRegex != "" {
+		m.pathRegexp, _ = compileRoutePathRegex(route, m.caseInsensitive)
+		return m
+	}
+
+	if strings.Contains(path, "{") {
+		// 复用 mux 的模板解析，保证参数语义（包括 {id:[0-9]+}）与之前一致
+		tpl := mux.NewRouter().Path(path)
+		if pattern, err := tpl.GetPathRegexp(); err == nil {
+			m.paramRegexp, _ = regexp.Compile(flags + pattern)
+		}
+		m.segments = simpleParamSegments(path)
+	}
+
+	if strings.Contains(path, "*") {
+		pattern := strings.ReplaceAll(path, "*", ".*")
+		m.wildcardRegexp, _ = regexp.Compile(flags + "^" + pattern + "$")
+		if prefix := strings.TrimSuffix(path, "*"); !strings.Contains(prefix, "*") && regexp.QuoteMeta(prefix) == prefix {
+			m.wildcardPrefix = prefix
+		}
+	}
+
+	return m
+}
+
+// 🔧 新增：正则匹配
+func (m *routeMatcher) matchRegex(path string) bool {
+	return m.pathRegexp.MatchString(path)
+}
+
+// 参数匹配
+func (m *routeMatcher) matchParams(path string) bool {
+	if m.segments != nil && m.paramRegexp != nil {
+		return m.matchSegments(path)
+	}
+	return m.paramRegexp != nil && m.paramRegexp.MatchString(path)
+}
+
+// 与 mux 的 {name}（[^/]+）语义相同：段数一致，静态段相同，参数段非空
+func (m *routeMatcher) matchSegments(path string) bool {
+	if !strings.HasPrefix(path, "/") {
+		return false
+	}
+	rest := path[1:]
+	for i, expected := range m.segments {
+		segment, next, more := strings.Cut(rest, "/")
+		if more != (i < len(m.segments)-1) {
+			return false
+		}
+		switch {
+		case expected == "":
+			if segment == "" {
+				return false
+			}
+		case segment != expected && !(m.caseInsensitive && strings.EqualFold(segment, expected)):
+			return false
+		}
+		rest = next
+	}
+	return true
+}
+
+// 路由路径的各段，参数段为空字符串；含正则参数、段内参数或空段时返回 nil（使用正则匹配）
+func simpleParamSegments(path string) []string {
+	if !strings.HasPrefix(path, "/") || strings.Contains(path, "*") {
+		return nil
+	}
+	segments := strings.Split(path[1:], "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, "{}") {
+			if segment == "" {
+				return nil
+			}
+			continue
+		}
+		name, isParam := strings.CutPrefix(segment, "{")
+		name, closed := strings.CutSuffix(name, "}")
+		if !isParam || !closed || name == "" || strings.ContainsAny(name, "{}:") {
+			return nil
+		}
+		segments[i] = ""
+	}
+	return segments
+}
+
+// 精确匹配
+func (m *routeMatcher) matchExact(routePath, path string) bool {
+	return routePath == path || (m.caseInsensitive && strings.EqualFold(routePath, path))
+}
+
+// 前缀匹配
+func (m *routeMatcher) matchPrefix(path string) bool {
+	if m.caseInsensitive {
+		return len(path) >= len(m.prefix) && strings.EqualFold(path[:len(m.prefix)], m.prefix)
+	}
+	return strings.HasPrefix(path, m.prefix)
+}
+
+// 通配符匹配
+func (m *routeMatcher) matchWildcard(path string) bool {
+	if m.wildcardPrefix != "" && m.wildcardRegexp != nil {
+		// 与正则 .* 一致：不匹配换行符
+		if len(path) < len(m.wildcardPrefix) || strings.IndexByte(path, '\n') >= 0 {
+			return false
+		}
+		if m.caseInsensitive {
+			return strings.EqualFold(path[:len(m.wildcardPrefix)], m.wildcardPrefix)
+		}
+		return path[:len(m.wildcardPrefix)] == m.wildcardPrefix
+	}
+	return m.wildcardRegexp != nil && m.wildcardRegexp.MatchString(path)
+}
+
+// 去掉正则首尾的 ^ 和 $（正则总是匹配整个路径）
+func pathRegexExpr(expr string) string {
+	expr = strings.TrimPrefix(expr, "^")
+	if strings.HasSuffix(expr, "$") && !strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, "$")
+	}
+	return expr
+}
+
+// 校验 path_regex：以 / 开头（可带 ^），可以编译
+func validatePathRegex(expr string) error {
+	if !strings.HasPrefix(pathRegexExpr(expr), "/") {
+		return fmt.Errorf("path_regex must start with / or ^/")
+	}
+	if _, err := regexp.Compile(pathRegexExpr(expr)); err != nil {
+		return fmt.Errorf("invalid path_regex: %v", err)
+	}
+	return nil
+}
+
+// 编译正则路由：分组前缀按字面匹配，正则匹配其余的整个路径
+func compileRoutePathRegex(route RouteConfig, caseInsensitive bool) (*regexp.Regexp, error) {
+	flags := ""
+	if caseInsensitive {
+		flags = "(?i)"
+	}
+	return regexp.Compile(flags + "^" + regexp.QuoteMeta(route.groupPrefix) + "(?:" + pathRegexExpr(route.PathRegex) + ")$")
+}
+
+// 路径索引使用的路径：正则路由取正则的字面前缀中完整的路径段，挂在对应的索引节点上
+func (route *RouteConfig) indexPath() string {
+	if route.PathRegex == "" {
+		return route.fullPath()
+	}
+	re, err := compileRoutePathRegex(*route, false)
+	if err != nil {
+		return ""
+	}
+	prefix, _ := re.LiteralPrefix()
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		return prefix[:i]
+	}
+	return ""
+}
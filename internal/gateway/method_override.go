@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type methodOverrideKey struct{}
+
+// 🔧 新增：方法覆盖（只对 POST 请求生效），在路由匹配前把请求方法替换为覆盖头指定的方法。
+// 返回的请求在上下文中记录原始方法，用于访问日志和审计；覆盖为不允许的方法时返回错误（400）
+func applyMethodOverride(r *http.Request) (*http.Request, error) {
+	config := gatewaySettings().MethodOverride
+	if !config.Enabled || config.Header == "" || r.Method != http.MethodPost {
+		return r, nil
+	}
+	method := strings.ToUpper(strings.TrimSpace(r.Header.Get(config.Header)))
+	if method == "" || method == r.Method {
+		return r, nil
+	}
+
+	allowed := false
+	for _, m := range config.Methods {
+		if strings.EqualFold(m, method) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("method override to %s is not allowed", method)
+	}
+
+	overridden := r.WithContext(context.WithValue(r.Context(), methodOverrideKey{}, r.Method))
+	overridden.Method = method
+	overridden.Header = r.Header.Clone()
+	overridden.Header.Del(config.Header)
+	return overridden, nil
+}
+
+// 被覆盖前的原始方法，未覆盖时为空
+func methodOverrideFrom(r *http.Request) string {
+	original, _ := r.Context().Value(methodOverrideKey{}).(string)
+	return original
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 请求耗时直方图桶上界（秒）
+var requestDurationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestMetricKey struct {
+	RouteID string
+	Method  string
+	Status  int
+}
+
+type requestMetricValue struct {
+	Count        int64
+	Bytes        int64   // 响应字节数
+	RequestBytes int64   // 请求体字节数
+	DurationSum  float64 // 秒
+	BucketCounts []int64 // 长度为 len(bounds)+1，最后一个桶为 +Inf
+}
+
+// 请求指标快照
+type RequestMetric struct {
+	RouteID      string
+	Method       string
+	Status       int
+	Count        int64
+	Bytes        int64
+	RequestBytes int64
+	DurationSum  float64
+	BucketCounts []int64
+}
+
+// 网关进程内指标（累计值），由各导出器定期读取
+type GatewayMetrics struct {
+	startTime      time.Time
+	requests       map[requestMetricKey]*requestMetricValue
+	responseLimits map[string]int64 // 路由ID -> 响应超过大小限制被中止的次数
+	panics         map[string]int64 // 路由ID -> 处理器 panic 次数（未匹配路由时为空）
+	contracts      map[string]int64 // 路由ID -> 上游响应违反契约的次数
+	mutex          sync.Mutex
+}
+
+func NewGatewayMetrics() *GatewayMetrics {
+	return &GatewayMetrics{
+		startTime:      time.Now(),
+		requests:       make(map[requestMetricKey]*requestMetricValue),
+		responseLimits: make(map[string]int64),
+		panics:         make(map[string]int64),
+		contracts:      make(map[string]int64),
+	}
+}
+
+// 记录一次网关请求（未匹配路由时 routeID 为空）
+func (m *GatewayMetrics) RecordRequest(routeID, method string, status int, duration time.Duration, requestBytes, bytes int64) {
+	if m == nil {
+		return
+	}
+	key := requestMetricKey{RouteID: routeID, Method: method, Status: status}
+	seconds := duration.Seconds()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	value := m.requests[key]
+	if value == nil {
+		value = &requestMetricValue{BucketCounts: make([]int64, len(requestDurationBounds)+1)}
+		m.requests[key] = value
+	}
+	value.Count++
+	value.Bytes += bytes
+	value.RequestBytes += requestBytes
+	value.DurationSum += seconds
+
+	bucket := sort.SearchFloat64s(requestDurationBounds, seconds)
+	value.BucketCounts[bucket]++
+}
+
+// 读取所有请求指标
+func (m *GatewayMetrics) Snapshot() []RequestMetric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	metrics := make([]RequestMetric, 0, len(m.requests))
+	for key, value := range m.requests {
+		metrics = append(metrics, RequestMetric{
+			RouteID:      key.RouteID,
+			Method:       key.Method,
+			Status:       key.Status,
+			Count:        value.Count,
+			Bytes:        value.Bytes,
+			RequestBytes: value.RequestBytes,
+			DurationSum:  value.DurationSum,
+			BucketCounts: append([]int64(nil), value.BucketCounts...),
+		})
+	}
+	return metrics
+}
+
+// 记录一次响应超过路由大小限制
+func (m *GatewayMetrics) RecordResponseLimit(routeID string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.responseLimits[routeID]++
+	m.mutex.Unlock()
+}
+
+// 各路由响应超过大小限制的次数
+func (m *GatewayMetrics) ResponseLimits() map[string]int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	limits := make(map[string]int64, len(m.responseLimits))
+	for routeID, count := range m.responseLimits {
+		limits[routeID] = count
+	}
+	return limits
+}
+
+// 记录一次网关处理器 panic
+func (m *GatewayMetrics) RecordPanic(routeID string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.panics[routeID]++
+	m.mutex.Unlock()
+}
+
+// 各路由处理器 panic 的次数
+func (m *GatewayMetrics) Panics() map[string]int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	panics := make(map[string]int64, len(m.panics))
+	for routeID, count := range m.panics {
+		panics[routeID] = count
+	}
+	return panics
+}
+
+// 记录一次上游响应违反路由契约
+func (m *GatewayMetrics) RecordContractViolation(routeID string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.contracts[routeID]++
+	m.mutex.Unlock()
+}
+
+// 各路由上游响应违反契约的次数
+func (m *GatewayMetrics) ContractViolations() map[string]int64 {
+	if m == nil {
+		return map[string]int64{}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	violations := make(map[string]int64, len(m.contracts))
+	for routeID, count := range m.contracts {
+		violations[routeID] = count
+	}
+	return violations
+}
+
+// 状态码分类，如 2xx
+func statusClass(status int) string {
+	if status < 100 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
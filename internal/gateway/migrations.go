@@ -0,0 +1,284 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	schemaVersionKey    = "gateway:schema:version"
+	schemaHistoryKey    = "gateway:schema:history"
+	schemaLockKey       = "gateway:schema:lock"
+	schemaLockTTL       = 5 * time.Minute
+	schemaCheckInterval = time.Minute
+)
+
+// Redis 存储结构迁移，按版本号顺序执行，每个迁移只执行一次
+// 迁移需要兼容旧版本网关读取（滚动升级期间新旧实例共存）
+type redisMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, rdb *redis.Client) error
+}
+
+// 已注册的迁移，追加新迁移时版本号递增，已发布的迁移不能修改
+var redisMigrations = []redisMigration{
+	{Version: 1, Name: "backfill route version metadata", Up: migrateBackfillRouteVersions},
+}
+
+// 迁移执行记录
+type MigrationRecord struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	AppliedAt  int64  `json:"applied_at"`
+	DurationMs int64  `json:"duration_ms"`
+	InstanceID string `json:"instance_id"`
+}
+
+// 迁移执行器：主节点在启动时及定时检查并执行待执行的迁移
+type MigrationRunner struct {
+	redisClient *redis.Client
+	leader      *LeaderElector
+	instanceID  string
+	migrations  []redisMigration
+	mutex       sync.Mutex
+	lastError   string
+	lastRun     int64
+}
+
+func NewMigrationRunner(redisClient *redis.Client, leader *LeaderElector, instanceID string) *MigrationRunner {
+	return &MigrationRunner{
+		redisClient: redisClient,
+		leader:      leader,
+		instanceID:  instanceID,
+		migrations:  redisMigrations,
+	}
+}
+
+// 启动时执行一次，之后定时检查（新主节点可能运行更新的版本）
+func (mr *MigrationRunner) Start() {
+	mr.runIfLeader()
+	go func() {
+		ticker := time.NewTicker(schemaCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			mr.runIfLeader()
+		}
+	}()
+}
+
+func (mr *MigrationRunner) runIfLeader() {
+	if !mr.leader.IsLeader() {
+		return
+	}
+	if _, err := mr.Apply(context.Background()); err != nil {
+		log.Printf("❌ Schema migration failed: %v", err)
+	}
+}
+
+// 当前存储结构版本
+func (mr *MigrationRunner) currentVersion(ctx context.Context) (int, error) {
+	value, err := mr.redisClient.Get(ctx, schemaVersionKey).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func (mr *MigrationRunner) latestVersion() int {
+	if len(mr.migrations) == 0 {
+		return 0
+	}
+	return mr.migrations[len(mr.migrations)-1].Version
+}
+
+// 执行所有待执行的迁移，返回本次执行的记录
+func (mr *MigrationRunner) Apply(ctx context.Context) ([]MigrationRecord, error) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	applied, err := mr.apply(ctx)
+	mr.lastRun = time.Now().Unix()
+	mr.lastError = ""
+	if err != nil {
+		mr.lastError = err.Error()
+	}
+	return applied, err
+}
+
+func (mr *MigrationRunner) apply(ctx context.Context) ([]MigrationRecord, error) {
+	current, err := mr.currentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current > mr.latestVersion() {
+		log.Printf("⚠️  Storage schema version %d is newer than this gateway (%d)", current, mr.latestVersion())
+		return nil, nil
+	}
+	if current == mr.latestVersion() {
+		return nil, nil
+	}
+
+	// 多实例同时成为主节点的窗口内，用锁保证只有一个实例执行
+	locked, err := mr.redisClient.SetNX(ctx, schemaLockKey, mr.instanceID, schemaLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("migration lock held by another instance")
+	}
+	defer mr.redisClient.Del(ctx, schemaLockKey)
+
+	applied := make([]MigrationRecord, 0)
+	for _, migration := range mr.migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		log.Printf("🧱 Applying schema migration %d: %s", migration.Version, migration.Name)
+		start := time.Now()
+		if err := migration.Up(ctx, mr.redisClient); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %v", migration.Version, migration.Name, err)
+		}
+
+		record := MigrationRecord{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			AppliedAt:  time.Now().Unix(),
+			DurationMs: time.Since(start).Milliseconds(),
+			InstanceID: mr.instanceID,
+		}
+		recordJSON, _ := json.Marshal(record)
+		pipe := mr.redisClient.TxPipeline()
+		pipe.Set(ctx, schemaVersionKey, migration.Version, 0)
+		pipe.RPush(ctx, schemaHistoryKey, recordJSON)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return applied, fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+		}
+		applied = append(applied, record)
+		log.Printf("✅ Schema migration %d applied in %dms", migration.Version, record.DurationMs)
+	}
+	return applied, nil
+}
+
+// 迁移状态
+func (mr *MigrationRunner) Status(ctx context.Context) (gin.H, error) {
+	current, err := mr.currentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]gin.H, 0)
+	for _, migration := range mr.migrations {
+		if migration.Version > current {
+			pending = append(pending, gin.H{"version": migration.Version, "name": migration.Name})
+		}
+	}
+
+	history := make([]MigrationRecord, 0)
+	stored, err := mr.redisClient.LRange(ctx, schemaHistoryKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, recordJSON := range stored {
+		var record MigrationRecord
+		if json.Unmarshal([]byte(recordJSON), &record) == nil {
+			history = append(history, record)
+		}
+	}
+
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	return gin.H{
+		"current_version": current,
+		"latest_version":  mr.latestVersion(),
+		"pending":         pending,
+		"history":         history,
+		"is_leader":       mr.leader.IsLeader(),
+		"last_run":        mr.lastRun,
+		"last_error":      mr.lastError,
+	}, nil
+}
+
+// 迁移 1：为早期写入、缺少版本号和时间戳的路由补齐字段（保留未知字段）
+func migrateBackfillRouteVersions(ctx context.Context, rdb *redis.Client) error {
+	routes, err := rdb.HGetAll(ctx, "gateway:routes").Result()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for routeID, routeJSON := range routes {
+		var route map[string]interface{}
+		if err := json.Unmarshal([]byte(routeJSON), &route); err != nil {
+			log.Printf("⚠️  Skipping unreadable route %s: %v", routeID, err)
+			continue
+		}
+
+		changed := false
+		for field, value := range map[string]interface{}{
+			"version":    now.UnixNano(),
+			"created_at": now.Unix(),
+			"updated_at": now.Unix(),
+		} {
+			if current, ok := route[field].(float64); !ok || current == 0 {
+				route[field] = value
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		updated, _ := json.Marshal(route)
+		if err := rdb.HSet(ctx, "gateway:routes", routeID, updated).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 🔧 新增：查看存储结构迁移状态
+func (dr *DistributedRouter) migrationStatusHandler(c *gin.Context) {
+	if dr.migrations == nil {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	status, err := dr.migrations.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, status)
+}
+
+// 🔧 新增：立即执行待执行的迁移（仅主节点）
+func (dr *DistributedRouter) applyMigrationsHandler(c *gin.Context) {
+	if dr.migrations == nil {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+	if !dr.leader.IsLeader() {
+		c.JSON(409, gin.H{"error": "migrations are applied by the leader"})
+		return
+	}
+
+	applied, err := dr.migrations.Apply(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error(), "applied": applied})
+		return
+	}
+	c.JSON(200, gin.H{"message": "migrations applied", "applied": applied})
+}
@@ -0,0 +1,231 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 上游连接优先使用的地址族
+const (
+	addressFamilyAuto = "auto"
+	addressFamilyIPv4 = "ipv4"
+	addressFamilyIPv6 = "ipv6"
+)
+
+// 未配置 fallback_delay 时的等待时间（与 net.Dialer 默认值相同）
+const defaultFallbackDelay = 300 * time.Millisecond
+
+func validateNetworkConfig(config static.NetworkConfig) error {
+	switch config.PreferredFamily {
+	case "", addressFamilyAuto, addressFamilyIPv4, addressFamilyIPv6:
+	default:
+		return fmt.Errorf("invalid gateway.network.preferred_family: %s (use auto, ipv4 or ipv6)", config.PreferredFamily)
+	}
+	if config.FallbackDelay < 0 {
+		return fmt.Errorf("gateway.network.fallback_delay must not be negative")
+	}
+	for _, address := range config.ListenAddresses {
+		if _, err := netip.ParseAddr(strings.Trim(address, "[]")); err != nil {
+			return fmt.Errorf("invalid gateway.network.listen_addresses entry %q: must be an IP address", address)
+		}
+	}
+	return nil
+}
+
+// 🔧 新增：按 listen_addresses 监听端口。IPv4 地址使用 tcp4，IPv6 地址使用 tcp6（"::" 只接受 IPv6 连接）；
+// 未配置时监听所有地址（双栈）
+func listenAddresses(port int, addresses []string) ([]net.Listener, error) {
+	if len(addresses) == 0 {
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	}
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		ip, err := netip.ParseAddr(strings.Trim(address, "[]"))
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("invalid listen address %q", address)
+		}
+		network := "tcp6"
+		if ip.Is4() {
+			network = "tcp4"
+		}
+		listener, err := net.Listen(network, net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+func listenerAddrs(listeners []net.Listener) string {
+	addrs := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		addrs = append(addrs, listener.Addr().String())
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// 在所有监听上提供服务，任一监听出错时返回该错误；服务器关闭后返回 http.ErrServerClosed
+func serveListeners(listeners []net.Listener, serve func(net.Listener) error) error {
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func() {
+			errs <- serve(listener)
+		}()
+	}
+	for range listeners {
+		if err := <-errs; err != http.ErrServerClosed {
+			return err
+		}
+	}
+	return http.ErrServerClosed
+}
+
+// 🔧 新增：校验沙箱地址，IPv6 地址必须加方括号，如 http://[fd00::1]:8194
+func validateSandboxURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		raw = "http://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid sandbox URL: %s", raw)
+	}
+	if strings.HasPrefix(parsed.Host, "[") {
+		if _, err := netip.ParseAddr(parsed.Hostname()); err != nil {
+			return fmt.Errorf("invalid IPv6 address in sandbox URL: %s", raw)
+		}
+	} else if strings.Count(parsed.Host, ":") > 1 {
+		return fmt.Errorf("IPv6 address in sandbox URL must be enclosed in brackets, e.g. http://[fd00::1]:8194")
+	}
+	return nil
+}
+
+// 按首选地址族把解析出的地址分为首选和备选两组（各自保持解析顺序），并按 network 过滤
+func splitAddressFamilies(ips []net.IP, network, preferred string) (primaries, fallbacks []net.IP) {
+	var ipv4, ipv6 []net.IP
+	for _, ip := range ips {
+		switch {
+		case ip.To4() != nil && network != "tcp6":
+			ipv4 = append(ipv4, ip)
+		case ip.To4() == nil && network != "tcp4":
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	preferIPv6 := false
+	switch preferred {
+	case addressFamilyIPv4:
+	case addressFamilyIPv6:
+		preferIPv6 = true
+	default:
+		preferIPv6 = len(ips) > 0 && ips[0].To4() == nil
+	}
+	if (preferIPv6 && len(ipv6) > 0) || len(ipv4) == 0 {
+		return ipv6, ipv4
+	}
+	return ipv4, ipv6
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// 🔧 新增：Happy Eyeballs（RFC 8305）：先依次连接首选地址族，fallback 延迟后或首选地址族全部失败时并行连接另一地址族，先建立的连接胜出
+func dialHappyEyeballs(ctx context.Context, network, port string, primaries, fallbacks []net.IP, delay time.Duration) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, network, port, primaries)
+	}
+	if len(primaries) == 0 {
+		return dialSerial(ctx, network, port, fallbacks)
+	}
+	if delay <= 0 {
+		delay = defaultFallbackDelay
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	dial := func(ips []net.IP) {
+		go func() {
+			conn, err := dialSerial(ctx, network, port, ips)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+	dial(primaries)
+	pending, fallbackStarted := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				dial(fallbacks)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// 另一组随后建立的连接直接关闭
+				go func(remaining int) {
+					for ; remaining > 0; remaining-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				dial(fallbacks)
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func dialSerial(ctx context.Context, network, port string, ips []net.IP) (net.Conn, error) {
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := upstreamDialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
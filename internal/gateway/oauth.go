@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// JWT 头部（仅支持 HS256）
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	errInvalidAccessToken = fmt.Errorf("invalid access token")
+	errExpiredAccessToken = fmt.Errorf("access token expired")
+)
+
+// 访问令牌声明
+type accessTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // client_id
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Scope     string `json:"scope,omitempty"`
+	ID        string `json:"jti"`
+
+	Extra map[string]interface{} `json:"-"` // 全部声明（含租户等自定义声明）
+}
+
+// 签发访问令牌（HS256 JWT，无状态，所有实例共享签名密钥即可校验）
+func (dr *DistributedRouter) issueAccessToken(ctx context.Context, config static.OAuthConfig, clientID, scope, tenant string) (string, int64, error) {
+	secret, err := dr.secrets.Resolve(ctx, config.SigningSecret)
+	if err != nil || secret == "" {
+		return "", 0, fmt.Errorf("token signing secret unavailable: %v", err)
+	}
+
+	ttl := config.TokenTTL
+	if ttl <= 0 {
+		ttl = 3600
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+	payload, _ := json.Marshal(accessTokenClaims{
+		Issuer:    config.Issuer,
+		Subject:   clientID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Duration(ttl) * time.Second).Unix(),
+		Scope:     scope,
+		ID:        hex.EncodeToString(jti),
+	})
+	// 🔧 新增：租户声明（声明名称由 gateway.tenancy.jwt_claim 配置）
+	if claim := gatewaySettings().Tenancy.JWTClaim; tenant != "" && claim != "" {
+		var claims map[string]interface{}
+		json.Unmarshal(payload, &claims)
+		claims[claim] = tenant
+		payload, _ = json.Marshal(claims)
+	}
+
+	signingInput := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := base64.RawURLEncoding.EncodeToString(hmacSHA256([]byte(secret), signingInput))
+	return signingInput + "." + signature, int64(ttl), nil
+}
+
+// 校验访问令牌
+func (dr *DistributedRouter) verifyAccessToken(ctx context.Context, config static.OAuthConfig, token string) (*accessTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != accessTokenHeader {
+		return nil, errInvalidAccessToken
+	}
+
+	secret, err := dr.secrets.Resolve(ctx, config.SigningSecret)
+	if err != nil || secret == "" {
+		return nil, errInvalidAccessToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, hmacSHA256([]byte(secret), parts[0]+"."+parts[1])) {
+		return nil, errInvalidAccessToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidAccessToken
+	}
+	var claims accessTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidAccessToken
+	}
+	json.Unmarshal(payload, &claims.Extra)
+	if claims.Issuer != config.Issuer {
+		return nil, errInvalidAccessToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errExpiredAccessToken
+	}
+	return &claims, nil
+}
+
+// 从 Authorization 头提取 Bearer 令牌
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:]), true
+	}
+	return "", false
+}
+
+// 🔧 新增：OAuth2 client_credentials 令牌端点（RFC 6749 4.4）
+func (dr *DistributedRouter) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	config := gatewaySettings().OAuth
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// 客户端凭证：优先 HTTP Basic，其次表单参数
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	var client *static.OAuthClientConfig
+	for i := range config.Clients {
+		if config.Clients[i].ClientID == clientID {
+			client = &config.Clients[i]
+			break
+		}
+	}
+	if client == nil || clientID == "" {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	expected, err := dr.secrets.Resolve(r.Context(), client.ClientSecret)
+	if err != nil || expected == "" || !hmac.Equal([]byte(expected), []byte(clientSecret)) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	// 请求的 scope 必须是客户端允许范围的子集，未指定时授予全部
+	scope := strings.Join(client.Scopes, " ")
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		allowed := make(map[string]bool, len(client.Scopes))
+		for _, s := range client.Scopes {
+			allowed[s] = true
+		}
+		for _, s := range requested {
+			if !allowed[s] {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope")
+				return
+			}
+		}
+		scope = strings.Join(requested, " ")
+	}
+
+	token, expiresIn, err := dr.issueAccessToken(r.Context(), config, clientID, scope, client.Tenant)
+	if err != nil {
+		log.Printf("❌ Failed to issue access token for %s: %v", clientID, err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   expiresIn,
+		"scope":        scope,
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
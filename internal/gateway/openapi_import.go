@@ -0,0 +1,320 @@
+package gateway
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const openAPIImportMaxBody = 10 << 20
+
+// OpenAPI 3 文档中导入需要的部分（YAML 或 JSON）
+type openAPIDocument struct {
+	OpenAPI string `yaml:"openapi"`
+	Swagger string `yaml:"swagger"`
+	Info    struct {
+		Title string `yaml:"title"`
+	} `yaml:"info"`
+	Servers      []openAPIServer            `yaml:"servers"`
+	ExternalDocs *openAPIExternalDocs       `yaml:"externalDocs"`
+	Paths        map[string]openAPIPathItem `yaml:"paths"`
+	Gateway      *openAPIGatewayMapping     `yaml:"x-gateway"`
+}
+
+type openAPIServer struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+type openAPIExternalDocs struct {
+	URL string `yaml:"url"`
+}
+
+type openAPIPathItem struct {
+	Ref     string                 `yaml:"$ref"`
+	Servers []openAPIServer        `yaml:"servers"`
+	Gateway *openAPIGatewayMapping `yaml:"x-gateway"`
+	Get     *openAPIOperation      `yaml:"get"`
+	Put     *openAPIOperation      `yaml:"put"`
+	Post    *openAPIOperation      `yaml:"post"`
+	Delete  *openAPIOperation      `yaml:"delete"`
+	Options *openAPIOperation      `yaml:"options"`
+	Head    *openAPIOperation      `yaml:"head"`
+	Patch   *openAPIOperation      `yaml:"patch"`
+	Trace   *openAPIOperation      `yaml:"trace"`
+}
+
+type openAPIOperation struct {
+	OperationID  string                 `yaml:"operationId"`
+	Summary      string                 `yaml:"summary"`
+	Description  string                 `yaml:"description"`
+	Tags         []string               `yaml:"tags"`
+	Deprecated   bool                   `yaml:"deprecated"`
+	ExternalDocs *openAPIExternalDocs   `yaml:"externalDocs"`
+	Servers      []openAPIServer        `yaml:"servers"`
+	Gateway      *openAPIGatewayMapping `yaml:"x-gateway"`
+}
+
+// 🔧 新增：处理器和上游映射，可以在文档、路径和操作上用 x-gateway 扩展覆盖导入参数（越具体越优先）
+type openAPIGatewayMapping struct {
+	Handler     string `yaml:"handler" json:"handler,omitempty"`
+	Target      string `yaml:"target" json:"target,omitempty"`
+	SandboxType string `yaml:"sandbox_type" json:"sandbox_type,omitempty"`
+	Timeout     int    `yaml:"timeout" json:"timeout,omitempty"`
+	Skip        bool   `yaml:"skip" json:"skip,omitempty"` // 不导入该操作
+}
+
+// 合并映射，非空字段覆盖
+func (m openAPIGatewayMapping) with(override *openAPIGatewayMapping) openAPIGatewayMapping {
+	if override == nil {
+		return m
+	}
+	if override.Handler != "" {
+		m.Handler = override.Handler
+	}
+	if override.Target != "" {
+		m.Target = override.Target
+	}
+	if override.SandboxType != "" {
+		m.SandboxType = override.SandboxType
+	}
+	if override.Timeout > 0 {
+		m.Timeout = override.Timeout
+	}
+	m.Skip = m.Skip || override.Skip
+	return m
+}
+
+// 导入参数
+type openAPIImportOptions struct {
+	Mapping    openAPIGatewayMapping // 默认处理器、上游、沙箱类型和超时
+	PathPrefix string                // 网关路径前缀，proxy 路由转发前去掉
+	IDPrefix   string                // 路由ID前缀，默认为文档标题
+}
+
+// 转换结果
+type OpenAPIImportResult struct {
+	Title    string            `json:"title"`
+	Routes   []RouteConfig     `json:"routes"`
+	Skipped  map[string]string `json:"skipped,omitempty"` // "METHOD 路径" -> 原因
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+type openAPIMethodOperation struct {
+	method    string
+	operation *openAPIOperation
+}
+
+// 按 OpenAPI 方法顺序列出路径下的操作
+func (item openAPIPathItem) operations() []openAPIMethodOperation {
+	all := []openAPIMethodOperation{
+		{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+		{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch}, {"TRACE", item.Trace},
+	}
+	operations := all[:0]
+	for _, entry := range all {
+		if entry.operation != nil {
+			operations = append(operations, entry)
+		}
+	}
+	return operations
+}
+
+// 第一个绝对地址的 server（按默认值替换 {变量}），没有时返回空
+func openAPIServerURL(servers []openAPIServer) string {
+	for _, server := range servers {
+		address := server.URL
+		for name, variable := range server.Variables {
+			address = strings.ReplaceAll(address, "{"+name+"}", variable.Default)
+		}
+		if parsed, err := url.Parse(address); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" {
+			return strings.TrimSuffix(address, "/")
+		}
+	}
+	return ""
+}
+
+// 把 OpenAPI 3 文档中的每个路径 + 方法转换为一条路由
+func convertOpenAPISpec(data []byte, options openAPIImportOptions) (*OpenAPIImportResult, error) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	if doc.Swagger != "" || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("only OpenAPI 3.x documents are supported")
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("OpenAPI document contains no paths")
+	}
+
+	idPrefix := difySlug(options.IDPrefix)
+	if idPrefix == "" {
+		idPrefix = difySlug(doc.Info.Title)
+	}
+	if idPrefix == "" {
+		idPrefix = "openapi"
+	}
+	pathPrefix := ""
+	if trimmed := strings.Trim(options.PathPrefix, "/"); trimmed != "" {
+		pathPrefix = "/" + trimmed
+	}
+
+	result := &OpenAPIImportResult{
+		Title:   doc.Info.Title,
+		Routes:  make([]RouteConfig, 0),
+		Skipped: make(map[string]string),
+	}
+	usedIDs := make(map[string]bool)
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		if item.Ref != "" {
+			result.Skipped[path] = "path item $ref is not supported"
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			result.Skipped[path] = "path must start with /"
+			continue
+		}
+
+		for _, entry := range item.operations() {
+			key := entry.method + " " + path
+			op := entry.operation
+			mapping := options.Mapping.with(doc.Gateway).with(item.Gateway).with(op.Gateway)
+			if mapping.Skip {
+				result.Skipped[key] = "x-gateway.skip is set"
+				continue
+			}
+
+			// 上游：导入参数或 x-gateway.target 优先，否则按操作、路径、文档的 servers 查找
+			if mapping.Handler == "proxy" && mapping.Target == "" {
+				for _, servers := range [][]openAPIServer{op.Servers, item.Servers, doc.Servers} {
+					if mapping.Target = openAPIServerURL(servers); mapping.Target != "" {
+						break
+					}
+				}
+				if mapping.Target == "" {
+					result.Skipped[key] = "no absolute server URL; set the target parameter or x-gateway.target"
+					continue
+				}
+			}
+
+			// 路由ID按 operationId 生成，没有时按方法和路径生成，重复时追加序号
+			slug := difySlug(op.OperationID)
+			if slug == "" {
+				slug = difySlug(entry.method + "-" + path)
+			}
+			id := idPrefix + "-" + slug
+			for n := 2; usedIDs[id]; n++ {
+				id = idPrefix + "-" + slug + "-" + strconv.Itoa(n)
+			}
+			usedIDs[id] = true
+
+			route := RouteConfig{
+				ID:          id,
+				Path:        pathPrefix + path,
+				Method:      entry.method,
+				Handler:     mapping.Handler,
+				Target:      mapping.Target,
+				SandboxType: mapping.SandboxType,
+				Timeout:     mapping.Timeout,
+				Description: op.Summary,
+				Metadata: map[string]string{
+					"source":        "openapi",
+					"openapi_title": doc.Info.Title,
+				},
+			}
+			if route.Description == "" {
+				route.Description = op.Description
+			}
+			if op.ExternalDocs != nil {
+				route.DocsURL = op.ExternalDocs.URL
+			} else if doc.ExternalDocs != nil {
+				route.DocsURL = doc.ExternalDocs.URL
+			}
+			if op.OperationID != "" {
+				route.Metadata["openapi_operation_id"] = op.OperationID
+			}
+			if len(op.Tags) > 0 {
+				route.Metadata["openapi_tags"] = strings.Join(op.Tags, ",")
+			}
+			if op.Deprecated {
+				route.Metadata["openapi_deprecated"] = "true"
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s is deprecated", key))
+			}
+			// 上游不认识网关的路径前缀，转发前去掉
+			if pathPrefix != "" && route.Handler == "proxy" {
+				route.RewritePath = &RoutePathRewrite{StripPrefix: pathPrefix}
+			}
+			if route.Handler == "sandbox" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s (%s) is a sandbox route without code; update it before use", key, id))
+			}
+			result.Routes = append(result.Routes, route)
+		}
+	}
+
+	return result, nil
+}
+
+// 🔧 新增：从 OpenAPI 3 文档（YAML/JSON）批量导入路由，整批校验通过后一次写入
+func (dr *DistributedRouter) importOpenAPIHandler(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, openAPIImportMaxBody))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := openAPIImportOptions{
+		Mapping: openAPIGatewayMapping{
+			Handler:     c.DefaultQuery("handler", "proxy"),
+			Target:      c.Query("target"),
+			SandboxType: c.Query("sandbox_type"),
+		},
+		PathPrefix: c.Query("path_prefix"),
+		IDPrefix:   c.Query("id_prefix"),
+	}
+	if value := c.Query("timeout"); value != "" {
+		if options.Mapping.Timeout, err = strconv.Atoi(value); err != nil || options.Mapping.Timeout <= 0 {
+			c.JSON(400, gin.H{"error": "invalid timeout"})
+			return
+		}
+	}
+
+	converted, err := convertOpenAPISpec(data, options)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(converted.Routes) == 0 {
+		c.JSON(400, gin.H{"error": "no routes to import", "openapi": converted})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := dr.routeManager.ImportRoutes(converted.Routes, c.Query("force") == "true", dryRun)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "openapi": converted, "result": result})
+		return
+	}
+	if dryRun {
+		c.JSON(200, gin.H{"dry_run": true, "openapi": converted, "result": result})
+		return
+	}
+
+	c.Set(auditMessageKey, fmt.Sprintf("imported %d routes from OpenAPI spec %q", len(converted.Routes), converted.Title))
+	c.JSON(200, gin.H{"message": "OpenAPI spec imported", "openapi": converted, "result": result})
+}
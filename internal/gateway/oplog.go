@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 运行日志分类
+const (
+	logCategoryEvent  = "event"  // 路由事件的发布与处理
+	logCategoryHealth = "health" // 沙箱健康检查与健康事件
+	logCategorySync   = "sync"   // 配置同步与全量重新同步
+)
+
+// 运行日志级别
+const (
+	logLevelDebug = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var opLogLevels = map[string]int{
+	"debug": logLevelDebug,
+	"info":  logLevelInfo,
+	"warn":  logLevelWarn,
+	"error": logLevelError,
+}
+
+// 去重记录超过该数量时清理过期条目
+const opLogMaxEntries = 10000
+
+// 🔧 新增：运行日志：按分类过滤级别，相同消息在去重窗口内只输出一次，每个分类每秒限流，省略的行计数
+type opLogger struct {
+	mutex      sync.Mutex
+	seen       map[string]*opLogEntry // 分类 + 消息 -> 首次输出时间与省略次数
+	second     map[string]int64       // 分类 -> 当前限流窗口（Unix 秒）
+	lines      map[string]int         // 分类 -> 当前窗口已输出的行数
+	limited    map[string]int         // 分类 -> 当前窗口因限流省略的行数
+	duplicates map[string]int64       // 分类 -> 累计因重复省略的行数
+	rateDrops  map[string]int64       // 分类 -> 累计因限流省略的行数
+}
+
+type opLogEntry struct {
+	first      time.Time
+	suppressed int64
+}
+
+var operationalLog = &opLogger{
+	seen:       make(map[string]*opLogEntry),
+	second:     make(map[string]int64),
+	lines:      make(map[string]int),
+	limited:    make(map[string]int),
+	duplicates: make(map[string]int64),
+	rateDrops:  make(map[string]int64),
+}
+
+// 输出一行运行日志
+func opLogf(category string, level int, format string, args ...interface{}) {
+	operationalLog.logf(time.Now(), category, level, format, args...)
+}
+
+// 分类的最低输出级别
+func opLogLevel(category string) int {
+	if level, ok := opLogLevels[strings.ToLower(gatewaySettings().OperationalLogs.Levels[category])]; ok {
+		return level
+	}
+	return logLevelInfo
+}
+
+func (l *opLogger) logf(now time.Time, category string, level int, format string, args ...interface{}) {
+	if level < opLogLevel(category) {
+		return
+	}
+	config := gatewaySettings().OperationalLogs
+	message := fmt.Sprintf(format, args...)
+
+	l.mutex.Lock()
+	var lines []string
+
+	// 相同消息在窗口内只输出一次
+	if config.DedupWindow > 0 {
+		window := time.Duration(config.DedupWindow) * time.Second
+		key := category + "\x00" + message
+		if entry := l.seen[key]; entry != nil && now.Sub(entry.first) < window {
+			entry.suppressed++
+			l.duplicates[category]++
+			l.mutex.Unlock()
+			return
+		} else if entry != nil && entry.suppressed > 0 {
+			message = fmt.Sprintf("%s (🔁 %d duplicates suppressed in the last %v)", message, entry.suppressed, now.Sub(entry.first).Round(time.Second))
+		}
+		if len(l.seen) >= opLogMaxEntries {
+			l.pruneSeen(now, window)
+		}
+		l.seen[key] = &opLogEntry{first: now}
+	}
+
+	// 每个分类每秒限流，进入新的一秒时报告上一秒省略的行数
+	if config.RateLimit > 0 {
+		if l.second[category] != now.Unix() {
+			if dropped := l.limited[category]; dropped > 0 {
+				lines = append(lines, fmt.Sprintf("🔇 [%s] %d log lines suppressed by rate limit", category, dropped))
+			}
+			l.second[category], l.lines[category], l.limited[category] = now.Unix(), 0, 0
+		}
+		if l.lines[category] >= config.RateLimit {
+			l.limited[category]++
+			l.rateDrops[category]++
+			l.mutex.Unlock()
+			return
+		}
+		l.lines[category]++
+	}
+	l.mutex.Unlock()
+
+	for _, line := range append(lines, message) {
+		log.Print(line)
+	}
+}
+
+// 清理超出去重窗口的条目（调用方持有锁），省略计数在清理时丢弃
+func (l *opLogger) pruneSeen(now time.Time, window time.Duration) {
+	for key, entry := range l.seen {
+		if now.Sub(entry.first) >= window {
+			delete(l.seen, key)
+		}
+	}
+	if len(l.seen) >= opLogMaxEntries {
+		l.seen = make(map[string]*opLogEntry)
+	}
+}
+
+// 各分类累计省略的行数
+func (l *opLogger) Stats() gin.H {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	config := gatewaySettings().OperationalLogs
+	categories := gin.H{}
+	for _, category := range []string{logCategoryEvent, logCategoryHealth, logCategorySync} {
+		level := "info"
+		for name, value := range opLogLevels {
+			if value == opLogLevel(category) {
+				level = name
+			}
+		}
+		categories[category] = gin.H{
+			"level":                  level,
+			"suppressed_duplicates":  l.duplicates[category],
+			"suppressed_rate_limits": l.rateDrops[category],
+		}
+	}
+	return gin.H{
+		"dedup_window": config.DedupWindow,
+		"rate_limit":   config.RateLimit,
+		"categories":   categories,
+	}
+}
@@ -0,0 +1,445 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+const otlpScopeName = "github.com/dify-router/dify-router/internal/gateway"
+
+// OTLP/HTTP JSON 客户端
+type otlpClient struct {
+	config      static.OTLPConfig
+	serviceName string
+	instanceID  string
+	secrets     *SecretResolver
+	client      *http.Client
+}
+
+func newOTLPClient(config static.OTLPConfig, serviceName, instanceID string, secrets *SecretResolver) *otlpClient {
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if serviceName == "" {
+		serviceName = "dify-router"
+	}
+	return &otlpClient{
+		config:      config,
+		serviceName: serviceName,
+		instanceID:  instanceID,
+		secrets:     secrets,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// OTLP 资源描述
+func (c *otlpClient) resource() map[string]interface{} {
+	return map[string]interface{}{
+		"attributes": []map[string]interface{}{
+			otlpAttribute("service.name", c.serviceName),
+			otlpAttribute("service.instance.id", c.instanceID),
+			otlpAttribute("service.version", static.GetBuildInfo().Version), // 🔧 新增：构建版本
+			otlpAttribute("vcs.revision", static.GetBuildInfo().GitCommit),
+		},
+	}
+}
+
+func (c *otlpClient) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(c.config.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, ref := range c.config.Headers {
+		value, err := c.secrets.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve header %s: %v", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp endpoint %s returned %s", path, resp.Status)
+	}
+	return nil
+}
+
+func otlpAttribute(key string, value interface{}) map[string]interface{} {
+	var v map[string]interface{}
+	switch typed := value.(type) {
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(typed)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(typed)}
+	}
+	return map[string]interface{}{"key": key, "value": v}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// OTLP 日志输出端（接入 LogForwarder）
+type otlpLogSink struct {
+	client *otlpClient
+}
+
+func (s *otlpLogSink) Name() string {
+	return "otlp"
+}
+
+func (s *otlpLogSink) Write(ctx context.Context, events []LogEvent) error {
+	records := make([]map[string]interface{}, 0, len(events))
+	for _, event := range events {
+		severityNumber, severityText := 9, "INFO"
+		if event.Status >= 500 {
+			severityNumber, severityText = 17, "ERROR"
+		} else if event.Status >= 400 || event.Type == logEventSLOAlert || event.Type == logEventCanaryRollback {
+			severityNumber, severityText = 13, "WARN"
+		}
+		body := fmt.Sprintf("%s %s %d", event.Method, event.Path, event.Status)
+		if event.Message != "" {
+			body = event.Message
+		}
+
+		attributes := []map[string]interface{}{
+			otlpAttribute("log.type", event.Type),
+			otlpAttribute("http.request.method", event.Method),
+			otlpAttribute("url.path", event.Path),
+			otlpAttribute("http.response.status_code", event.Status),
+			otlpAttribute("http.request.body.size", event.RequestBytes),
+			otlpAttribute("http.response.body.size", event.Bytes),
+			otlpAttribute("client.address", event.ClientIP),
+			otlpAttribute("duration_ms", strconv.FormatFloat(event.DurationMs, 'f', 3, 64)),
+		}
+		if event.RouteID != "" {
+			attributes = append(attributes, otlpAttribute("gateway.route_id", event.RouteID))
+		}
+		if event.Principal != "" {
+			attributes = append(attributes, otlpAttribute("gateway.principal", event.Principal))
+		}
+		if event.Tenant != "" {
+			attributes = append(attributes, otlpAttribute("gateway.tenant", event.Tenant))
+		}
+		if event.Variant != "" {
+			attributes = append(attributes, otlpAttribute("gateway.route.variant", event.Variant))
+		}
+		if event.UserAgent != "" {
+			attributes = append(attributes, otlpAttribute("user_agent.original", event.UserAgent))
+		}
+
+		record := map[string]interface{}{
+			"timeUnixNano":   unixNano(event.Timestamp),
+			"severityNumber": severityNumber,
+			"severityText":   severityText,
+			"body":           map[string]interface{}{"stringValue": body},
+			"attributes":     attributes,
+		}
+		// 🔧 新增：采样请求的日志关联到追踪
+		if event.TraceID != "" {
+			record["traceId"] = event.TraceID
+		}
+		records = append(records, record)
+	}
+
+	return s.client.post(ctx, "/v1/logs", map[string]interface{}{
+		"resourceLogs": []map[string]interface{}{{
+			"resource": s.client.resource(),
+			"scopeLogs": []map[string]interface{}{{
+				"scope":      map[string]interface{}{"name": otlpScopeName},
+				"logRecords": records,
+			}},
+		}},
+	})
+}
+
+func (s *otlpLogSink) Close() error {
+	return nil
+}
+
+// OTLP 指标导出器：按间隔推送累计指标
+type otlpMetricsExporter struct {
+	client   *otlpClient
+	router   *DistributedRouter
+	interval time.Duration
+	stopChan chan struct{}
+}
+
+func newOTLPMetricsExporter(client *otlpClient, router *DistributedRouter) *otlpMetricsExporter {
+	interval := time.Duration(client.config.Interval) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &otlpMetricsExporter{
+		client:   client,
+		router:   router,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (e *otlpMetricsExporter) Start() {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), e.client.client.Timeout)
+				if err := e.export(ctx); err != nil {
+					log.Printf("❌ OTLP metrics export failed: %v", err)
+				}
+				cancel()
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+	log.Printf("📈 OTLP metrics export started (endpoint: %s, interval: %v)", e.client.config.Endpoint, e.interval)
+}
+
+func (e *otlpMetricsExporter) Stop() {
+	close(e.stopChan)
+}
+
+func (e *otlpMetricsExporter) export(ctx context.Context) error {
+	now := unixNano(time.Now())
+	start := unixNano(e.router.metrics.startTime)
+
+	var requestPoints, durationPoints, requestSizePoints, responseSizePoints []map[string]interface{}
+	for _, metric := range e.router.metrics.Snapshot() {
+		attributes := []map[string]interface{}{
+			otlpAttribute("gateway.route_id", metric.RouteID),
+			otlpAttribute("http.request.method", metric.Method),
+			otlpAttribute("http.response.status_code", metric.Status),
+			otlpAttribute("http.response.status_class", statusClass(metric.Status)),
+		}
+		requestPoints = append(requestPoints, map[string]interface{}{
+			"attributes":        attributes,
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(metric.Count, 10),
+		})
+		requestSizePoints = append(requestSizePoints, map[string]interface{}{
+			"attributes":        attributes,
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(metric.RequestBytes, 10),
+		})
+		responseSizePoints = append(responseSizePoints, map[string]interface{}{
+			"attributes":        attributes,
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(metric.Bytes, 10),
+		})
+
+		bucketCounts := make([]string, len(metric.BucketCounts))
+		for i, count := range metric.BucketCounts {
+			bucketCounts[i] = strconv.FormatInt(count, 10)
+		}
+		durationPoints = append(durationPoints, map[string]interface{}{
+			"attributes":        attributes,
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"count":             strconv.FormatInt(metric.Count, 10),
+			"sum":               metric.DurationSum,
+			"bucketCounts":      bucketCounts,
+			"explicitBounds":    requestDurationBounds,
+		})
+	}
+
+	healthy := 0
+	for _, instance := range e.router.sandboxPool.GetAllInstances() {
+		if instance.Status == "healthy" {
+			healthy++
+		}
+	}
+
+	metrics := []map[string]interface{}{
+		{
+			"name": "gateway.routes",
+			"unit": "{route}",
+			"gauge": map[string]interface{}{"dataPoints": []map[string]interface{}{{
+				"timeUnixNano": now,
+				"asInt":        strconv.Itoa(e.router.routeManager.snapshot().size()),
+			}}},
+		},
+		{
+			"name": "gateway.match_cache.hit_ratio",
+			"unit": "1",
+			"gauge": map[string]interface{}{"dataPoints": []map[string]interface{}{{
+				"timeUnixNano": now,
+				"asDouble":     e.router.routeManager.matchCache.hitRatio(),
+			}}},
+		},
+		{
+			"name": "gateway.sandboxes.healthy",
+			"unit": "{instance}",
+			"gauge": map[string]interface{}{"dataPoints": []map[string]interface{}{{
+				"timeUnixNano": now,
+				"asInt":        strconv.Itoa(healthy),
+			}}},
+		},
+	}
+	if len(requestPoints) > 0 {
+		metrics = append(metrics,
+			map[string]interface{}{
+				"name": "gateway.requests",
+				"unit": "{request}",
+				"sum": map[string]interface{}{
+					"aggregationTemporality": 2, // 累计
+					"isMonotonic":            true,
+					"dataPoints":             requestPoints,
+				},
+			},
+			map[string]interface{}{
+				"name": "gateway.request.duration",
+				"unit": "s",
+				"histogram": map[string]interface{}{
+					"aggregationTemporality": 2,
+					"dataPoints":             durationPoints,
+				},
+			},
+			map[string]interface{}{
+				"name": "gateway.request.body.size",
+				"unit": "By",
+				"sum": map[string]interface{}{
+					"aggregationTemporality": 2,
+					"isMonotonic":            true,
+					"dataPoints":             requestSizePoints,
+				},
+			},
+			map[string]interface{}{
+				"name": "gateway.response.body.size",
+				"unit": "By",
+				"sum": map[string]interface{}{
+					"aggregationTemporality": 2,
+					"isMonotonic":            true,
+					"dataPoints":             responseSizePoints,
+				},
+			},
+		)
+	}
+
+	// 🔧 新增：响应超过路由大小限制的次数
+	var limitPoints []map[string]interface{}
+	for routeID, count := range e.router.metrics.ResponseLimits() {
+		limitPoints = append(limitPoints, map[string]interface{}{
+			"attributes":        []map[string]interface{}{otlpAttribute("gateway.route_id", routeID)},
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(count, 10),
+		})
+	}
+	if len(limitPoints) > 0 {
+		metrics = append(metrics, map[string]interface{}{
+			"name": "gateway.response.limit_exceeded",
+			"unit": "{response}",
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+				"dataPoints":             limitPoints,
+			},
+		})
+	}
+
+	// 🔧 新增：处理器 panic 次数
+	var panicPoints []map[string]interface{}
+	for routeID, count := range e.router.metrics.Panics() {
+		panicPoints = append(panicPoints, map[string]interface{}{
+			"attributes":        []map[string]interface{}{otlpAttribute("gateway.route_id", routeID)},
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(count, 10),
+		})
+	}
+	if len(panicPoints) > 0 {
+		metrics = append(metrics, map[string]interface{}{
+			"name": "gateway.panics",
+			"unit": "{panic}",
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+				"dataPoints":             panicPoints,
+			},
+		})
+	}
+
+	// 🔧 新增：上游响应违反契约的次数
+	var contractPoints []map[string]interface{}
+	for routeID, count := range e.router.metrics.ContractViolations() {
+		contractPoints = append(contractPoints, map[string]interface{}{
+			"attributes":        []map[string]interface{}{otlpAttribute("gateway.route_id", routeID)},
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(count, 10),
+		})
+	}
+	if len(contractPoints) > 0 {
+		metrics = append(metrics, map[string]interface{}{
+			"name": "gateway.response.contract_violations",
+			"unit": "{response}",
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+				"dataPoints":             contractPoints,
+			},
+		})
+	}
+
+	// 🔧 新增：消费者 Key 因并发上限被拒绝的次数
+	var concurrencyPoints []map[string]interface{}
+	for keyName, count := range e.router.keyConcurrency.Rejections() {
+		concurrencyPoints = append(concurrencyPoints, map[string]interface{}{
+			"attributes":        []map[string]interface{}{otlpAttribute("gateway.api_key", keyName)},
+			"startTimeUnixNano": start,
+			"timeUnixNano":      now,
+			"asInt":             strconv.FormatInt(count, 10),
+		})
+	}
+	if len(concurrencyPoints) > 0 {
+		metrics = append(metrics, map[string]interface{}{
+			"name": "gateway.api_key.concurrency_rejected",
+			"unit": "{request}",
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+				"dataPoints":             concurrencyPoints,
+			},
+		})
+	}
+
+	return e.client.post(ctx, "/v1/metrics", map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource": e.client.resource(),
+			"scopeMetrics": []map[string]interface{}{{
+				"scope":   map[string]interface{}{"name": otlpScopeName},
+				"metrics": metrics,
+			}},
+		}},
+	})
+}
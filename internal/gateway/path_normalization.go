@@ -0,0 +1,141 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 路径规范化策略
+const (
+	trailingSlashStrict   = "strict"   // 按字面匹配（默认）
+	trailingSlashIgnore   = "ignore"   // 字面未匹配时忽略末尾斜杠再匹配
+	trailingSlashRedirect = "redirect" // 去掉/补上末尾斜杠能匹配时 308 重定向到该路径
+
+	percentDecodingDecode        = "decode"         // 完全解码后匹配（默认）
+	percentDecodingPreserveSlash = "preserve-slash" // 解码但保留 %2F，编码的斜杠不作为路径分隔符
+	percentDecodingRaw           = "raw"            // 不解码，按原始编码路径匹配
+)
+
+// 🔧 新增：网关入口处理器，在路由匹配前规范化请求路径（去除 . 和 .. 段，按配置合并连续斜杠）并应用方法覆盖
+func (dr *DistributedRouter) gatewayHandler() http.Handler {
+	// 🔧 修改：panic 恢复包住整个处理链，探针、方法覆盖、客户端证书头、路径规范化和调试捕获中的 panic 同样被恢复；
+	// mux 中间件链里的恢复仍然保留，处理器 panic 时访问日志记录 500
+	return dr.recoveryMiddleware(serverGateway)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dr.serveProbe(w, r) {
+			return
+		}
+		r, err := applyMethodOverride(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
+			return
+		}
+		applyClientCertHeaders(r)
+
+		collapse := gatewaySettings().PathNormalization.CollapseSlashes
+		if cleaned := cleanRequestPath(r.URL.Path, collapse); cleaned != r.URL.Path {
+			u := *r.URL
+			u.Path = cleaned
+			if u.RawPath != "" {
+				u.RawPath = cleanRequestPath(u.RawPath, collapse)
+			}
+			r = r.WithContext(r.Context())
+			r.URL = &u
+		}
+		if dr.runtime.debugCapture.Load() {
+			dr.captureRequest(w, r, dr.muxRouter)
+			return
+		}
+		dr.muxRouter.ServeHTTP(w, r)
+	}))
+}
+
+// 去除 . 和 .. 段（不能越过根路径），collapse 为 true 时合并连续斜杠；保留末尾斜杠
+func cleanRequestPath(p string, collapse bool) string {
+	if p == "" || p[0] != '/' {
+		return p
+	}
+	if !strings.Contains(p, "/.") && (!collapse || !strings.Contains(p, "//")) {
+		return p
+	}
+
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	cleaned := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch {
+		case segment == ".":
+			if last {
+				cleaned = append(cleaned, "")
+			}
+		case segment == "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+			if last {
+				cleaned = append(cleaned, "")
+			}
+		case segment == "" && collapse && !last:
+		default:
+			cleaned = append(cleaned, segment)
+		}
+	}
+	return "/" + strings.Join(cleaned, "/")
+}
+
+// 用于路由匹配的路径（按 percent_decoding 策略）
+func matchPath(r *http.Request) string {
+	switch gatewaySettings().PathNormalization.PercentDecoding {
+	case percentDecodingRaw:
+		return r.URL.EscapedPath()
+	case percentDecodingPreserveSlash:
+		escaped := r.URL.EscapedPath()
+		if !strings.Contains(escaped, "%2F") && !strings.Contains(escaped, "%2f") {
+			return r.URL.Path
+		}
+		parts := strings.Split(strings.ReplaceAll(escaped, "%2f", "%2F"), "%2F")
+		for i, part := range parts {
+			if decoded, err := url.PathUnescape(part); err == nil {
+				parts[i] = decoded
+			}
+		}
+		return strings.Join(parts, "%2F")
+	default:
+		return r.URL.Path
+	}
+}
+
+// 去掉或补上末尾斜杠后的路径，根路径没有替代形式
+func toggleTrailingSlash(p string) (string, bool) {
+	if p == "/" || p == "" {
+		return "", false
+	}
+	if strings.HasSuffix(p, "/") {
+		return strings.TrimSuffix(p, "/"), true
+	}
+	return p + "/", true
+}
+
+// 按末尾斜杠策略匹配路由；redirect 策略下需要重定向时返回目标路径
+func (dr *DistributedRouter) matchNormalizedRoute(path, method, tenant, host string) (*RouteConfig, string) {
+	route := dr.routeManager.matchTenantRoute(path, method, tenant, host)
+	policy := gatewaySettings().PathNormalization.TrailingSlash
+	if route != nil || (policy != trailingSlashIgnore && policy != trailingSlashRedirect) {
+		return route, ""
+	}
+
+	alternate, ok := toggleTrailingSlash(path)
+	if !ok {
+		return nil, ""
+	}
+	route = dr.routeManager.matchTenantRoute(alternate, method, tenant, host)
+	if route != nil && policy == trailingSlashRedirect {
+		return nil, alternate
+	}
+	return route, ""
+}
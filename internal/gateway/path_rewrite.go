@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+const pathRewriteCacheSize = 1024
+
+var pathTemplateParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// 🔧 新增：转发前改写请求路径（三种方式选一种），用于公开路径与上游路径不一致的 proxy 路由；
+// sandbox 路由在执行请求的 metadata 中收到原路径、改写后的路径和路径参数
+type RoutePathRewrite struct {
+	StripPrefix string `json:"strip_prefix,omitempty"` // 去掉路径前缀，如 /public
+	Regex       string `json:"regex,omitempty"`        // 正则替换，不匹配时路径不变
+	Replacement string `json:"replacement,omitempty"`  // 正则替换结果，可引用 $1、${name}
+	Template    string `json:"template,omitempty"`     // 路径模板，{name} 为路由路径中的参数（正则路由为命名分组），如 /api/users/{id}
+}
+
+func (rw *RoutePathRewrite) validate(route *RouteConfig) error {
+	modes := 0
+	for _, value := range []string{rw.StripPrefix, rw.Regex, rw.Template} {
+		if value != "" {
+			modes++
+		}
+	}
+	if modes != 1 {
+		return fmt.Errorf("rewrite_path requires exactly one of strip_prefix, regex or template")
+	}
+	if rw.Replacement != "" && rw.Regex == "" {
+		return fmt.Errorf("rewrite_path.replacement requires regex")
+	}
+	switch {
+	case rw.StripPrefix != "":
+		if !strings.HasPrefix(rw.StripPrefix, "/") {
+			return fmt.Errorf("rewrite_path.strip_prefix must start with /")
+		}
+	case rw.Regex != "":
+		if _, err := regexp.Compile(rw.Regex); err != nil {
+			return fmt.Errorf("invalid rewrite_path.regex: %v", err)
+		}
+	default:
+		if !strings.HasPrefix(rw.Template, "/") {
+			return fmt.Errorf("rewrite_path.template must start with /")
+		}
+		names := routePathParamNames(route)
+		for _, match := range pathTemplateParam.FindAllStringSubmatch(rw.Template, -1) {
+			if !slices.Contains(names, match[1]) {
+				return fmt.Errorf("rewrite_path.template references unknown path parameter: %s", match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// 路由路径中的参数名（正则路由为命名分组）
+func routePathParamNames(route *RouteConfig) []string {
+	if route.PathRegex != "" {
+		re, err := regexp.Compile(pathRegexExpr(route.PathRegex))
+		if err != nil {
+			return nil
+		}
+		return re.SubexpNames()
+	}
+	if !strings.Contains(route.Path, "{") {
+		return nil
+	}
+	names, _ := mux.NewRouter().Path(route.Path).GetVarNames()
+	return names
+}
+
+// 编译后的改写规则：正则和路径参数提取在首次使用时编译并缓存
+type pathRewriter struct {
+	rewrite *RoutePathRewrite
+	regex   *regexp.Regexp
+	params  *regexp.Regexp // 路由路径的参数正则
+	names   []string       // 参数正则各分组对应的参数名（与 SubexpNames 对齐）
+}
+
+var (
+	pathRewriters      = make(map[string]*pathRewriter)
+	pathRewritersMutex sync.Mutex
+)
+
+func compilePathRewriter(route *RouteConfig, rw *RoutePathRewrite) *pathRewriter {
+	routePath := route.fullPath()
+	key := strings.Join([]string{route.PathRegex, routePath, rw.StripPrefix, rw.Regex, rw.Replacement, rw.Template}, "\x00")
+
+	pathRewritersMutex.Lock()
+	defer pathRewritersMutex.Unlock()
+	if compiled, ok := pathRewriters[key]; ok {
+		return compiled
+	}
+
+	compiled := &pathRewriter{rewrite: rw}
+	if rw.Regex != "" {
+		compiled.regex, _ = regexp.Compile(rw.Regex)
+	}
+	caseInsensitive := gatewaySettings().PathNormalization.CaseInsensitive
+	if route.PathRegex != "" {
+		compiled.params, _ = compileRoutePathRegex(*route, caseInsensitive)
+		if compiled.params != nil {
+			compiled.names = compiled.params.SubexpNames()
+		}
+	} else if strings.Contains(routePath, "{") {
+		tpl := mux.NewRouter().Path(routePath)
+		pattern, err := tpl.GetPathRegexp()
+		if err == nil {
+			if caseInsensitive {
+				pattern = "(?i)" + pattern
+			}
+			compiled.params, _ = regexp.Compile(pattern)
+			names, _ := tpl.GetVarNames()
+			compiled.names = append([]string{""}, names...)
+		}
+	}
+
+	// 路由更新后旧规则不再使用，超过上限时整体清空
+	if len(pathRewriters) >= pathRewriteCacheSize {
+		pathRewriters = make(map[string]*pathRewriter)
+	}
+	pathRewriters[key] = compiled
+	return compiled
+}
+
+// 路径参数
+func (p *pathRewriter) pathParams(path string) map[string]string {
+	if p.params == nil {
+		return nil
+	}
+	match := p.params.FindStringSubmatch(path)
+	if match == nil {
+		return nil
+	}
+	params := make(map[string]string, len(p.names))
+	for i, name := range p.names {
+		if name != "" && i < len(match) {
+			params[name] = match[i]
+		}
+	}
+	return params
+}
+
+func (p *pathRewriter) apply(path string, params map[string]string) string {
+	rw := p.rewrite
+	switch {
+	case rw.StripPrefix != "":
+		if !strings.HasPrefix(path, rw.StripPrefix) {
+			return path
+		}
+		path = strings.TrimPrefix(path, rw.StripPrefix)
+	case p.regex != nil:
+		path = p.regex.ReplaceAllString(path, rw.Replacement)
+	case rw.Template != "":
+		path = pathTemplateParam.ReplaceAllStringFunc(rw.Template, func(placeholder string) string {
+			return params[placeholder[1:len(placeholder)-1]]
+		})
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// 🔧 新增：按路由的改写规则计算转发路径，未配置时返回原路径
+func (route *RouteConfig) rewriteRequestPath(r *http.Request) (string, map[string]string) {
+	if route.RewritePath == nil {
+		return r.URL.Path, nil
+	}
+	rewriter := compilePathRewriter(route, route.RewritePath)
+	params := rewriter.pathParams(r.URL.Path)
+	return rewriter.apply(r.URL.Path, params), params
+}
@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 🔧 新增：统一中间件：管理端口（gin）和网关端口（mux）共用 net/http 中间件，
+// 横切功能（访问日志与指标、panic 恢复、CORS）只实现一次，各端口的差异只在配置上；gin 和 mux 只负责路由，
+// 以及只属于某个端口的中间件。认证不在共用链中：管理接口按管理 Key 认证（gin 中间件，认证失败记入审计），
+// 网关端口按路由的认证方式认证（公开路由、API Key、签名或访问令牌），两者共用 Key 比较和未配置 Key 的处理
+type Middleware func(http.Handler) http.Handler
+
+// 中间件所在的端口，日志和 panic 记录中区分来源
+const (
+	serverAdmin   = "admin"
+	serverGateway = "gateway"
+)
+
+// 按顺序组合中间件，第一个在最外层
+func chainMiddleware(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// 两个端口共用的中间件：访问日志在外层，panic 的请求以 500 记入访问日志；
+// 🔧 修改：CORS 同样在共用链中（预检请求也记入访问日志）
+func (dr *DistributedRouter) sharedMiddleware(server string) []Middleware {
+	return []Middleware{
+		dr.accessLogMiddleware(server),
+		dr.recoveryMiddleware(server),
+		corsMiddleware(server),
+	}
+}
+
+// 管理端口的处理链
+func (dr *DistributedRouter) adminHandler() http.Handler {
+	return chainMiddleware(dr.ginRouter, dr.sharedMiddleware(serverAdmin)...)
+}
+
+// 端口的 CORS 配置：管理端口使用 admin.cors，网关端口在 gateway.cors_enabled 时使用 gateway.cors
+func corsSettings(server string) (static.CORSConfig, bool) {
+	if server == serverAdmin {
+		return adminSettings().CORS, true
+	}
+	settings := gatewaySettings()
+	return settings.CORS, settings.CorsEnabled
+}
+
+// CORS（配置按请求读取，运行时修改立即生效）。管理端口的 OPTIONS 请求直接返回 204；
+// 网关端口的路由可以处理 OPTIONS，只有预检请求（带 Origin 和 Access-Control-Request-Method）直接返回 204
+func corsMiddleware(server string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cors, enabled := corsSettings(server)
+			if !enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if origin := r.Header.Get("Origin"); origin != "" {
+				header := w.Header()
+				header.Add("Vary", "Origin")
+
+				wildcard, explicit := corsOriginAllowed(origin, cors.AllowedOrigins)
+				if explicit {
+					header.Set("Access-Control-Allow-Origin", origin)
+					if cors.AllowCredentials {
+						header.Set("Access-Control-Allow-Credentials", "true")
+					}
+				} else if wildcard {
+					header.Set("Access-Control-Allow-Origin", "*")
+				}
+				if explicit || wildcard {
+					header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+					header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+					if cors.MaxAge > 0 {
+						header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+					}
+				}
+			}
+
+			preflight := r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+			if r.Method == http.MethodOptions && (server == serverAdmin || preflight) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
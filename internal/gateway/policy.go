@@ -0,0 +1,342 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+)
+
+var (
+	policyOPAPath = regexp.MustCompile(`^[A-Za-z0-9_]+(/[A-Za-z0-9_]+)*$`)
+	policyDays    = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+	// 🔧 新增：已加载的时区（时区名 -> *time.Location），策略按请求判断时间段时不再每次读取时区数据库
+	policyLocations sync.Map
+)
+
+// 🔧 新增：路由授权策略：认证和访问范围校验通过后再按策略放行或拒绝（403）。
+// rules 在网关内按顺序判断，第一条条件全部满足的规则决定结果；opa 把请求上下文交给 OPA 边车判断
+type RoutePolicy struct {
+	Rules   []PolicyRule `json:"rules,omitempty"`
+	Default string       `json:"default,omitempty"` // 没有规则匹配时的结果：allow 或 deny（默认）
+	OPA     string       `json:"opa,omitempty"`     // OPA 策略路径，如 gateway/authz（与 rules 二选一）
+}
+
+// 策略规则：未设置的条件不限制，设置的条件全部满足时规则生效
+type PolicyRule struct {
+	Effect     string            `json:"effect"`               // allow 或 deny
+	Methods    []string          `json:"methods,omitempty"`    // 请求方法
+	Tenants    []string          `json:"tenants,omitempty"`    // 请求租户
+	Principals []string          `json:"principals,omitempty"` // 调用方名称（消费者 Key 名称、令牌 sub、签名 key_id 等）
+	Headers    map[string]string `json:"headers,omitempty"`    // 请求头等于该值，"*" 表示存在即可
+	Claims     map[string]string `json:"claims,omitempty"`     // 访问令牌声明等于该值（数组声明包含即可），"*" 表示存在即可
+	Time       *PolicyTimeWindow `json:"time,omitempty"`       // 生效时间段
+	Reason     string            `json:"reason,omitempty"`     // 拒绝时返回给调用方的原因
+}
+
+// 时间段：end 早于 start 时跨零点（如 22:00-06:00）
+type PolicyTimeWindow struct {
+	Days     []string `json:"days,omitempty"`     // mon、tue ... sun，为空表示每天
+	Start    string   `json:"start,omitempty"`    // HH:MM（含），为空表示 00:00
+	End      string   `json:"end,omitempty"`      // HH:MM（不含），为空表示 24:00
+	Timezone string   `json:"timezone,omitempty"` // IANA 时区，默认 UTC
+}
+
+func (p *RoutePolicy) validate() error {
+	if p.OPA != "" {
+		if len(p.Rules) > 0 {
+			return fmt.Errorf("policy.opa and policy.rules cannot be used together")
+		}
+		if !policyOPAPath.MatchString(p.OPA) {
+			return fmt.Errorf("invalid policy.opa path: %s", p.OPA)
+		}
+		return nil
+	}
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("policy requires rules or opa")
+	}
+	if p.Default != "" && p.Default != policyAllow && p.Default != policyDeny {
+		return fmt.Errorf("invalid policy default: %s", p.Default)
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].validate(); err != nil {
+			return fmt.Errorf("policy rule %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (rule *PolicyRule) validate() error {
+	if rule.Effect != policyAllow && rule.Effect != policyDeny {
+		return fmt.Errorf("invalid effect: %s", rule.Effect)
+	}
+	if rule.Time != nil {
+		return rule.Time.validate()
+	}
+	return nil
+}
+
+func (t *PolicyTimeWindow) validate() error {
+	for _, day := range t.Days {
+		if _, ok := policyDays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day: %s", day)
+		}
+	}
+	if _, err := parsePolicyClock(t.Start, 0); err != nil {
+		return err
+	}
+	if _, err := parsePolicyClock(t.End, 24*60); err != nil {
+		return err
+	}
+	if _, err := policyLocation(t.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", t.Timezone)
+	}
+	return nil
+}
+
+// HH:MM 转为当天的分钟数
+func parsePolicyClock(value string, empty int) (int, error) {
+	if value == "" {
+		return empty, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s (expected HH:MM)", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// 按时区名加载时区，成功加载的结果缓存（只缓存有效时区，缓存大小受路由配置中的时区数限制）
+func policyLocation(name string) (*time.Location, error) {
+	if cached, ok := policyLocations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	policyLocations.Store(name, location)
+	return location, nil
+}
+
+func (t *PolicyTimeWindow) contains(now time.Time) bool {
+	location, err := policyLocation(t.Timezone)
+	if err != nil {
+		return false
+	}
+	now = now.In(location)
+	start, _ := parsePolicyClock(t.Start, 0)
+	end, _ := parsePolicyClock(t.End, 24*60)
+	minute := now.Hour()*60 + now.Minute()
+
+	day := now.Weekday()
+	inWindow := minute >= start && minute < end
+	if end <= start {
+		// 跨零点：零点之后的部分属于前一天的时间段
+		inWindow = minute >= start || minute < end
+		if minute < end {
+			day = (day + 6) % 7
+		}
+	}
+	if !inWindow {
+		return false
+	}
+	if len(t.Days) == 0 {
+		return true
+	}
+	for _, d := range t.Days {
+		if policyDays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// 声明值匹配：数组声明包含期望值即可
+func policyClaimMatches(value interface{}, expected string) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if policyClaimMatches(item, expected) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return expected == "*" || v == expected
+	}
+	return expected == "*" || fmt.Sprint(value) == expected
+}
+
+func (rule *PolicyRule) matches(r *http.Request, principal *gatewayPrincipal, tenant string, now time.Time) bool {
+	if len(rule.Methods) > 0 && !slices.ContainsFunc(rule.Methods, func(m string) bool { return strings.EqualFold(m, r.Method) }) {
+		return false
+	}
+	if len(rule.Tenants) > 0 && !slices.Contains(rule.Tenants, tenant) {
+		return false
+	}
+	if len(rule.Principals) > 0 && (principal == nil || !slices.Contains(rule.Principals, principal.Name)) {
+		return false
+	}
+	for name, expected := range rule.Headers {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok || (expected != "*" && !slices.Contains(values, expected)) {
+			return false
+		}
+	}
+	for name, expected := range rule.Claims {
+		if principal == nil || !policyClaimMatches(principal.Claims[name], expected) {
+			return false
+		}
+	}
+	if rule.Time != nil && !rule.Time.contains(now) {
+		return false
+	}
+	return true
+}
+
+// 按规则判断，返回是否放行和拒绝原因
+func (p *RoutePolicy) evaluateRules(r *http.Request, now time.Time) (bool, string) {
+	principal := principalFromRequest(r)
+	tenant := tenantFromRequest(r)
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matches(r, principal, tenant, now) {
+			continue
+		}
+		if rule.Effect == policyAllow {
+			return true, ""
+		}
+		reason := rule.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("denied by policy rule %d", i)
+		}
+		return false, reason
+	}
+	if p.Default == policyAllow {
+		return true, ""
+	}
+	return false, "no policy rule allows this request"
+}
+
+// 🔧 新增：执行路由的授权策略；查询 OPA 失败时按 gateway.policy.fail_open 放行或返回错误（503）
+func (dr *DistributedRouter) authorizePolicy(route *RouteConfig, r *http.Request) (bool, string, error) {
+	policy := route.Policy
+	if policy == nil {
+		return true, "", nil
+	}
+	now := time.Now()
+	if policy.OPA == "" {
+		allowed, reason := policy.evaluateRules(r, now)
+		return allowed, reason, nil
+	}
+
+	config := gatewaySettings().Policy
+	allowed, reason, err := queryOPA(r.Context(), config.OPAURL, config.TimeoutMs, policy.OPA, policyInput(route, r, now))
+	if err != nil {
+		if config.FailOpen {
+			return true, "", nil
+		}
+		return false, "", err
+	}
+	return allowed, reason, nil
+}
+
+// OPA 输入：路由、请求（敏感请求头的值被隐藏）、调用方、租户和当前时间
+func policyInput(route *RouteConfig, r *http.Request, now time.Time) gin.H {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = redactedHeaderValue(name, strings.Join(values, ", "))
+	}
+	input := gin.H{
+		"route": gin.H{
+			"id":       route.ID,
+			"path":     route.Path,
+			"method":   route.Method,
+			"methods":  route.methodList(),
+			"handler":  route.Handler,
+			"metadata": route.Metadata,
+		},
+		"request": gin.H{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"host":      requestHost(r),
+			"query":     r.URL.Query(),
+			"headers":   headers,
+			"client_ip": clientIP(r),
+		},
+		"tenant": tenantFromRequest(r),
+		"time":   now.UTC().Format(time.RFC3339),
+	}
+	if principal := principalFromRequest(r); principal != nil {
+		input["principal"] = gin.H{
+			"name":        principal.Name,
+			"auth_method": principal.Method,
+			"claims":      principal.Claims,
+		}
+	}
+	return input
+}
+
+// 查询 OPA Data API：结果为布尔值，或带有 allow（和可选的 reason）的对象；策略未定义时拒绝
+func queryOPA(ctx context.Context, opaURL string, timeoutMs int, path string, input gin.H) (bool, string, error) {
+	if opaURL == "" {
+		return false, "", fmt.Errorf("gateway.policy.opa_url is not configured")
+	}
+	if timeoutMs <= 0 {
+		timeoutMs = 200
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	body, _ := json.Marshal(gin.H{"input": input})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(opaURL, "/")+"/v1/data/"+path, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("opa query failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("opa query failed: status %d", resp.StatusCode)
+	}
+
+	var decoded struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return false, "", fmt.Errorf("invalid opa response: %v", err)
+	}
+	switch result := decoded.Result.(type) {
+	case nil:
+		return false, "policy " + path + " is undefined", nil
+	case bool:
+		return result, "", nil
+	case map[string]interface{}:
+		allowed, _ := result["allow"].(bool)
+		reason, _ := result["reason"].(string)
+		return allowed, reason, nil
+	}
+	return false, "", fmt.Errorf("invalid opa result for %s", path)
+}
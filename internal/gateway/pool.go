@@ -7,31 +7,52 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// 🔧 新增：路由的沙箱就近策略
+const (
+	LocalityAny          = "any"
+	LocalityPreferLocal  = "prefer-local"
+	LocalityRequireLocal = "require-local"
+)
+
 // 沙箱池管理
 type SandboxPool struct {
 	redisClient  *redis.Client
 	instances    map[string]*SandboxInstance
 	loadBalancer *LoadBalancer
+	mutex        sync.RWMutex
+	events       *EventStreamManager // 🔧 新增：健康状态变化事件发布
+	source       string              // 当前网关实例ID，用于忽略自己发布的事件
+	leader       *LeaderElector      // 🔧 新增：leader 模式下只有主节点探测
+	region       string              // 🔧 新增：网关所在区域/可用区，用于就近选择沙箱
+	zone         string
+	interval     atomic.Int64        // 🔧 新增：当前健康检查间隔（纳秒），可通过 /admin/runtime 调整
+	intervalChanges chan time.Duration
+	changed      chan struct{}      // 🔧 新增：实例注册或健康状态变化时关闭并替换，唤醒等待可用沙箱的请求
+	changedMutex sync.Mutex
 }
 
 func NewSandboxPool(rdb *redis.Client) *SandboxPool {
+	settings := gatewaySettings()
 	pool := &SandboxPool{
 		redisClient:  rdb,
 		instances:    make(map[string]*SandboxInstance),
 		loadBalancer: NewLoadBalancer(),
+		region:       settings.Region,
+		zone:         settings.Zone,
+		intervalChanges: make(chan time.Duration, 1),
+		changed:      make(chan struct{}),
 	}
 
 	// 从Redis加载现有实例
 	pool.loadInstancesFromRedis()
 
-	// 启动健康检查
-	go pool.healthCheckLoop()
-
 	return pool
 }
 
@@ -50,69 +71,263 @@ func (sp *SandboxPool) loadInstancesFromRedis() {
 	}
 }
 
-func (sp *SandboxPool) healthCheckLoop() {
-	ticker := time.NewTicker(15 * time.Second)
-	for range ticker.C {
+// 🔧 新增：启动健康检查
+// leader 模式下只有主节点探测沙箱，结果写入 Redis 并通过 HEALTH_UPDATE 事件广播，
+// 从节点不探测，只从 Redis 同步健康状态；local 模式下每个实例独立探测
+func (sp *SandboxPool) StartHealthChecks(leader *LeaderElector) {
+	settings := gatewaySettings()
+	if settings.HealthCheckMode != "local" {
+		sp.leader = leader
+	}
+
+	interval := time.Duration(settings.HealthCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	sp.interval.Store(int64(interval))
+	go sp.healthCheckLoop(interval)
+}
+
+// 🔧 新增：运行时调整健康检查间隔
+func (sp *SandboxPool) SetHealthCheckInterval(interval time.Duration) {
+	sp.interval.Store(int64(interval))
+	sendLatestInterval(sp.intervalChanges, interval)
+}
+
+func (sp *SandboxPool) HealthCheckInterval() time.Duration {
+	return time.Duration(sp.interval.Load())
+}
+
+func (sp *SandboxPool) healthCheckLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case interval := <-sp.intervalChanges:
+			ticker.Reset(interval)
+			log.Printf("⏰ Sandbox health check interval changed to %v", interval)
+			continue
+		case <-ticker.C:
+		}
+		if !sp.probing() {
+			sp.syncHealthFromRedis()
+			continue
+		}
 		sp.checkInstancesHealth()
 	}
 }
 
-func (sp *SandboxPool) checkInstancesHealth() {
+// 当前实例是否执行健康探测
+func (sp *SandboxPool) probing() bool {
+	return sp.leader == nil || sp.leader.IsLeader()
+}
+
+// 🔧 新增：从 Redis 同步主节点写入的健康状态（兜底遗漏的事件，并同步注册/删除）
+func (sp *SandboxPool) syncHealthFromRedis() {
+	stored, err := sp.redisClient.HGetAll(context.Background(), "sandbox:instances").Result()
+	if err != nil {
+		opLogf(logCategoryHealth, logLevelWarn, "Failed to sync sandbox health from Redis: %v", err)
+		return
+	}
+
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	changed := false
 	for id, instance := range sp.instances {
+		// 隧道实例只存在于本实例
+		if _, exists := stored[id]; !exists && !instance.Tunnel {
+			delete(sp.instances, id)
+		}
+	}
+	for _, instanceJSON := range stored {
+		var update SandboxInstance
+		if err := json.Unmarshal([]byte(instanceJSON), &update); err != nil {
+			continue
+		}
+		instance, exists := sp.instances[update.ID]
+		if !exists {
+			sp.instances[update.ID] = &update
+			changed = true
+			continue
+		}
+		if instance.Tunnel {
+			continue
+		}
+		if instance.Status != update.Status {
+			opLogf(logCategoryHealth, logLevelInfo, "🩺 Sandbox %s health synced from leader: %s -> %s", update.ID, instance.Status, update.Status)
+			changed = true
+		}
+		instance.Status = update.Status
+		instance.LastPing = update.LastPing
+	}
+	if changed {
+		sp.notifyChange()
+	}
+}
+
+func (sp *SandboxPool) checkInstancesHealth() {
+	for id, instance := range sp.GetAllInstances() {
+		// 🔧 新增：隧道实例由隧道自己探测
+		if instance.Tunnel {
+			continue
+		}
 		// 构建完整的健康检查URL - 关键修复
 		healthURL := sp.buildHealthCheckURL(instance)
 		if healthURL == "" {
-			instance.Status = "unhealthy"
-			log.Printf("❌ Sandbox %s has invalid URL: %s", id, instance.URL)
-			sp.updateInstanceInRedis(instance)
+			opLogf(logCategoryHealth, logLevelWarn, "❌ Sandbox %s has invalid URL: %s", id, instance.URL)
+			sp.setInstanceHealth(instance, "unhealthy", 0)
 			continue
 		}
 
-		log.Printf("🔍 Health checking sandbox %s at %s", id, healthURL)
+		opLogf(logCategoryHealth, logLevelDebug, "🔍 Health checking sandbox %s at %s", id, healthURL)
 
-		// 检查沙箱健康状态
-		client := &http.Client{Timeout: 5 * time.Second}
+		// 检查沙箱健康状态（探测期间不持有锁）
+		status, lastPing := "unhealthy", int64(0)
+		client := &http.Client{Timeout: 5 * time.Second, Transport: upstreamTransport}
 		resp, err := client.Get(healthURL)
 		if err != nil {
-			instance.Status = "unhealthy"
-			log.Printf("❌ Sandbox %s is unhealthy: %v", id, err)
+			opLogf(logCategoryHealth, logLevelWarn, "❌ Sandbox %s is unhealthy: %v", id, err)
 		} else {
 			if resp.StatusCode == 200 {
-				instance.Status = "healthy"
-				instance.LastPing = time.Now().Unix()
-				log.Printf("✅ Sandbox %s is healthy (status: %d)", id, resp.StatusCode)
+				status, lastPing = "healthy", time.Now().Unix()
+				opLogf(logCategoryHealth, logLevelDebug, "✅ Sandbox %s is healthy (status: %d)", id, resp.StatusCode)
 			} else {
-				instance.Status = "unhealthy"
-				log.Printf("❌ Sandbox %s returned non-200 status: %d", id, resp.StatusCode)
+				opLogf(logCategoryHealth, logLevelWarn, "❌ Sandbox %s returned non-200 status: %d", id, resp.StatusCode)
 			}
 			resp.Body.Close() // 记得关闭响应体
 		}
 
-		// 更新到 Redis
-		sp.updateInstanceInRedis(instance)
+		sp.setInstanceHealth(instance, status, lastPing)
+	}
+}
+
+// 🔧 新增：更新实例健康状态，状态变化时发布 HEALTH_UPDATE 事件
+func (sp *SandboxPool) setInstanceHealth(instance *SandboxInstance, status string, lastPing int64) {
+	sp.mutex.Lock()
+	previous := instance.Status
+	instance.Status = status
+	if lastPing > 0 {
+		instance.LastPing = lastPing
+	}
+	snapshot := *instance
+	sp.mutex.Unlock()
+
+	// 更新到 Redis
+	sp.updateInstanceInRedis(&snapshot)
+
+	if previous != status {
+		opLogf(logCategoryHealth, logLevelInfo, "🩺 Sandbox %s health changed: %s -> %s", snapshot.ID, previous, status)
+		sp.publishHealthUpdate(&snapshot)
+		sp.notifyChange()
 	}
 }
 
 // 新增：构建健康检查URL - 这是关键的修复
 func (sp *SandboxPool) buildHealthCheckURL(instance *SandboxInstance) string {
 	if instance.URL == "" {
-		log.Printf("⚠️ Sandbox %s has empty URL", instance.ID)
+		opLogf(logCategoryHealth, logLevelWarn, "⚠️ Sandbox %s has empty URL", instance.ID)
 		return ""
 	}
 	
 	// 如果URL已经包含协议，直接使用
 	if strings.HasPrefix(instance.URL, "http://") || strings.HasPrefix(instance.URL, "https://") {
 		healthURL := instance.URL + "/health"
-		log.Printf("🔗 Using existing protocol URL: %s", healthURL)
+		opLogf(logCategoryHealth, logLevelDebug, "🔗 Using existing protocol URL: %s", healthURL)
 		return healthURL
 	}
 	
 	// 否则添加默认的http协议
 	healthURL := "http://" + instance.URL + "/health"
-	log.Printf("🔗 Adding HTTP protocol to URL: %s", healthURL)
+	opLogf(logCategoryHealth, logLevelDebug, "🔗 Adding HTTP protocol to URL: %s", healthURL)
 	return healthURL
 }
 
+// 🔧 新增：启用健康状态事件（发布本实例探测到的变化，并应用其他实例发布的变化）
+func (sp *SandboxPool) EnableHealthEvents(events *EventStreamManager, source string) {
+	sp.events = events
+	sp.source = source
+	go sp.consumeHealthEvents()
+	log.Printf("🩺 Sandbox health events enabled (source: %s)", source)
+}
+
+func (sp *SandboxPool) publishHealthUpdate(instance *SandboxInstance) {
+	if sp.events == nil {
+		return
+	}
+	event := &RouteEvent{
+		EventID:   fmt.Sprintf("health-%s-%d", instance.ID, time.Now().UnixNano()),
+		EventType: "HEALTH_UPDATE",
+		Sandbox:   instance,
+		Source:    sp.source,
+	}
+	if err := sp.events.PublishRouteEvent(context.Background(), event); err != nil {
+		opLogf(logCategoryHealth, logLevelWarn, "Failed to publish HEALTH_UPDATE event: %v", err)
+	}
+}
+
+// 健康事件需要广播给所有网关实例，因此直接 XREAD 读取事件流而不使用消费者组
+func (sp *SandboxPool) consumeHealthEvents() {
+	ctx := context.Background()
+	lastID := "$"
+	for {
+		streams, err := sp.redisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{sp.events.streamKey, lastID},
+			Count:   100,
+			Block:   5 * time.Second,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				opLogf(logCategoryHealth, logLevelError, "Error reading health events: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastID = message.ID
+				if message.Values["event_type"] != "HEALTH_UPDATE" {
+					continue
+				}
+				eventData, _ := message.Values["event_data"].(string)
+				var event RouteEvent
+				if err := json.Unmarshal([]byte(eventData), &event); err != nil || event.Sandbox == nil {
+					continue
+				}
+				if event.Source != sp.source {
+					sp.applyHealthUpdate(event.Sandbox)
+				}
+			}
+		}
+	}
+}
+
+// 应用其他实例发布的健康状态
+func (sp *SandboxPool) applyHealthUpdate(update *SandboxInstance) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	instance, exists := sp.instances[update.ID]
+	if exists && instance.Tunnel {
+		return
+	}
+	if !exists {
+		// 其他实例注册的沙箱
+		sp.instances[update.ID] = update
+		opLogf(logCategoryHealth, logLevelInfo, "🩺 Sandbox %s added from health event: %s", update.ID, update.Status)
+		sp.notifyChange()
+		return
+	}
+	if instance.Status != update.Status {
+		opLogf(logCategoryHealth, logLevelInfo, "🩺 Sandbox %s health updated by event: %s -> %s", update.ID, instance.Status, update.Status)
+		sp.notifyChange()
+	}
+	instance.Status = update.Status
+	if update.LastPing > instance.LastPing {
+		instance.LastPing = update.LastPing
+	}
+}
+
 func (sp *SandboxPool) updateInstanceInRedis(instance *SandboxInstance) {
 	instanceJSON, _ := json.Marshal(instance)
 	err := sp.redisClient.HSet(context.Background(), 
@@ -129,32 +344,66 @@ func (sp *SandboxPool) RegisterInstance(instance *SandboxInstance) error {
 		log.Printf("🔗 Added protocol to new instance URL: %s", instance.URL)
 	}
 	
+	sp.mutex.Lock()
 	sp.instances[instance.ID] = instance
+	sp.mutex.Unlock()
+	sp.notifyChange()
 
 	// 注册到 Redis
 	sp.updateInstanceInRedis(instance)
 	return nil
 }
 
+// 🔧 新增：实例变化通知（返回的通道在下一次注册或健康状态变化时关闭）
+func (sp *SandboxPool) changes() <-chan struct{} {
+	sp.changedMutex.Lock()
+	defer sp.changedMutex.Unlock()
+	return sp.changed
+}
+
+func (sp *SandboxPool) notifyChange() {
+	sp.changedMutex.Lock()
+	defer sp.changedMutex.Unlock()
+	close(sp.changed)
+	sp.changed = make(chan struct{})
+}
+
 // 删除沙箱实例
 func (sp *SandboxPool) RemoveInstance(instanceID string) error {
+	sp.mutex.Lock()
 	delete(sp.instances, instanceID)
+	sp.mutex.Unlock()
 
 	// 从 Redis 中删除
 	ctx := context.Background()
 	err := sp.redisClient.HDel(ctx, "sandbox:instances", instanceID).Err()
 	if err != nil {
-		log.Printf("Failed to remove instance from Redis: %v")
+		log.Printf("Failed to remove instance from Redis: %v", err)
 		return err
 	}
 	return nil
 }
 
 func (sp *SandboxPool) GetHealthyInstance(sandboxType string) (*SandboxInstance, error) {
+	return sp.GetHealthyInstanceExcluding(sandboxType, nil)
+}
+
+// 🔧 新增：选择健康实例，跳过已尝试失败的实例（用于重试）
+func (sp *SandboxPool) GetHealthyInstanceExcluding(sandboxType string, excluded map[string]bool) (*SandboxInstance, error) {
+	return sp.SelectInstance(sandboxType, LocalityAny, excluded)
+}
+
+// 🔧 新增：按就近策略选择健康实例
+// prefer-local 依次尝试同可用区、同区域、任意实例；require-local 只选择本地实例
+// （网关配置了 zone 时要求同可用区，否则要求同区域）
+func (sp *SandboxPool) SelectInstance(sandboxType, locality string, excluded map[string]bool) (*SandboxInstance, error) {
 	var candidates []*SandboxInstance
 
+	// 负载均衡会修改实例负载计数，需要写锁
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
 	for _, instance := range sp.instances {
-		if instance.Type == sandboxType && instance.Status == "healthy" {
+		if instance.Type == sandboxType && instance.Status == "healthy" && !excluded[instance.ID] {
 			candidates = append(candidates, instance)
 		}
 	}
@@ -163,10 +412,63 @@ func (sp *SandboxPool) GetHealthyInstance(sandboxType string) (*SandboxInstance,
 		return nil, fmt.Errorf("no healthy %s sandbox available", sandboxType)
 	}
 
+	switch locality {
+	case LocalityPreferLocal:
+		if local := sp.filterLocal(candidates, true); len(local) > 0 {
+			candidates = local
+		} else if local := sp.filterLocal(candidates, false); len(local) > 0 {
+			candidates = local
+		}
+	case LocalityRequireLocal:
+		candidates = sp.filterLocal(candidates, sp.zone != "")
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no healthy %s sandbox available in local %s", sandboxType, sp.localityName())
+		}
+	}
+
 	// 使用负载均衡选择实例
 	return sp.loadBalancer.Select(candidates), nil
 }
 
+// 🔧 新增：请求结束后释放实例负载计数
+func (sp *SandboxPool) ReleaseInstance(instance *SandboxInstance) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if instance.Load > 0 {
+		instance.Load--
+	}
+}
+
+// 筛选同可用区（sameZone）或同区域的实例，网关未配置对应位置时不筛选
+func (sp *SandboxPool) filterLocal(instances []*SandboxInstance, sameZone bool) []*SandboxInstance {
+	if (sameZone && sp.zone == "") || (!sameZone && sp.region == "") {
+		return instances
+	}
+	local := make([]*SandboxInstance, 0, len(instances))
+	for _, instance := range instances {
+		if sameZone && instance.Zone == sp.zone && (sp.region == "" || instance.Region == sp.region) {
+			local = append(local, instance)
+		} else if !sameZone && instance.Region == sp.region {
+			local = append(local, instance)
+		}
+	}
+	return local
+}
+
+func (sp *SandboxPool) localityName() string {
+	if sp.zone != "" {
+		return "zone " + sp.zone
+	}
+	return "region " + sp.region
+}
+
 func (sp *SandboxPool) GetAllInstances() map[string]*SandboxInstance {
-	return sp.instances
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	instances := make(map[string]*SandboxInstance, len(sp.instances))
+	for id, instance := range sp.instances {
+		instances[id] = instance
+	}
+	return instances
 }
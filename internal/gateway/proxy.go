@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 代理请求到路由配置的上游地址（Target），请求路径（🔧 按 rewrite_path 改写后）追加在 Target 路径之后
+func (dr *DistributedRouter) handleProxyRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
+	target, err := url.Parse(route.Target)
+	if err != nil || target.Host == "" {
+		writeRouteError(w, r, route, http.StatusBadGateway, gin.H{"error": "invalid proxy target"})
+		return
+	}
+
+	// 签名需要完整请求体
+	var body []byte
+	if route.Signing != nil && r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(gin.H{"error": "failed to read request body"})
+			return
+		}
+		if len(body) > maxSignedBodySize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(gin.H{"error": "request body too large for signed route"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// 签名密钥在转发前解析
+	var secret string
+	if route.Signing != nil {
+		secret, err = dr.secrets.Resolve(r.Context(), route.Signing.Secret)
+		if err != nil {
+			log.Printf("❌ Failed to resolve signing secret for route %s: %v", route.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(gin.H{"error": "failed to sign request"})
+			return
+		}
+	}
+
+	if route.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(route.Timeout)*time.Second)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	// 🔧 新增：按上游自适应并发限制
+	release, ok := dr.concurrency.acquire("proxy:" + target.Host)
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		writeRouteError(w, r, route, http.StatusServiceUnavailable, gin.H{"error": errConcurrencyLimited.Error()})
+		return
+	}
+	// 延迟按收到响应头计算，并发名额在响应传输结束后释放；未收到响应时按过载处理（客户端取消除外）
+	start := time.Now()
+	var rtt time.Duration
+	overloaded := true
+	defer func() {
+		if rtt == 0 && r.Context().Err() != nil {
+			overloaded = false
+		}
+		release(rtt, overloaded)
+	}()
+
+	path, _ := route.rewriteRequestPath(r)
+
+	proxy := &httputil.ReverseProxy{
+		Transport: routeTransport(route),
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// 🔧 新增：转发改写后的路径
+			if path != pr.Out.URL.Path {
+				pr.Out.URL.Path, pr.Out.URL.RawPath = path, ""
+			}
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			// 网关认证密钥不转发给上游
+			pr.Out.Header.Del("X-Api-Key")
+
+			if route.Signing != nil {
+				signOutbound(route.Signing, secret, pr.Out, body, time.Now())
+			}
+		},
+		// 🔧 新增：按路由过滤响应头（协议升级响应不过滤）
+		ModifyResponse: func(resp *http.Response) error {
+			rtt, overloaded = time.Since(start), upstreamOverloaded(resp.StatusCode)
+			// 🔧 新增：校验上游响应契约，reject 时由 ErrorHandler 返回 502
+			if violation := dr.checkResponseContract(route, resp); violation != nil {
+				return violation
+			}
+			if resp.StatusCode != http.StatusSwitchingProtocols {
+				filterResponseHeaders(resp.Header, route.ResponseHeaders)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if violation, ok := err.(*contractViolationError); ok {
+				writeContractViolation(w, r, route, violation)
+				return
+			}
+			log.Printf("❌ Proxy request for route %s failed: %v", route.ID, err)
+			writeRouteError(w, r, route, http.StatusBadGateway, gin.H{"error": "upstream unavailable: " + err.Error()})
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
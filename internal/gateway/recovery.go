@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 🔧 新增：panic 统计，不依赖 OTLP/StatsD 是否启用，始终在 GET /admin/stats 中返回
+type panicStats struct {
+	mutex   sync.Mutex
+	servers map[string]int64 // 端口 -> 次数
+	routes  map[string]int64 // 路由ID -> 次数（未匹配路由时为空）
+}
+
+func (s *panicStats) record(server, routeID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.servers == nil {
+		s.servers = make(map[string]int64)
+		s.routes = make(map[string]int64)
+	}
+	s.servers[server]++
+	s.routes[routeID]++
+}
+
+func (s *panicStats) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var total int64
+	servers := make(map[string]int64, len(s.servers))
+	for server, count := range s.servers {
+		servers[server] = count
+		total += count
+	}
+	routes := make(map[string]int64, len(s.routes))
+	for routeID, count := range s.routes {
+		routes[routeID] = count
+	}
+	return map[string]interface{}{
+		"total":   total,
+		"servers": servers,
+		"routes":  routes,
+	}
+}
+
+// 🔧 新增：panic 恢复（两个端口共用）：记录堆栈与请求上下文并计数，响应未开始时返回 500，已开始传输则中止连接
+func (dr *DistributedRouter) recoveryMiddleware(server string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// 主动中止连接（如响应超限）不是错误
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				routeID, principal, tenant := "", "", ""
+				if info := logInfoFromRequest(r); info != nil {
+					routeID, principal, tenant = info.RouteID, info.Principal, info.Tenant
+				}
+				log.Printf("💥 Panic in %s handler: %v (method=%s path=%s route=%s principal=%s tenant=%s client=%s)\n%s",
+					server, recovered, r.Method, r.URL.Path, routeID, principal, tenant, clientIP(r), debug.Stack())
+				dr.panics.record(server, routeID)
+				dr.metrics.RecordPanic(routeID)
+				dr.statsd.RecordPanic(routeID)
+
+				if recorder.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(gin.H{"error": "internal server error"})
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+const redactedValue = "[REDACTED]"
+
+// JSON 路径的一步："*" 匹配任意字段或数组元素，recursive 表示在任意层级查找（$..name）
+type redactionStep struct {
+	name      string
+	recursive bool
+}
+
+// 🔧 新增：按 gateway.redaction 编译的脱敏规则
+type logRedactor struct {
+	headers  map[string]bool
+	paths    [][]redactionStep
+	patterns []*regexp.Regexp
+}
+
+var (
+	redactorMutex sync.Mutex
+	redactorKey   string
+	redactor      *logRedactor
+)
+
+// 当前配置对应的脱敏规则，配置变化时重新编译（无效的规则记录日志后忽略）
+func currentRedactor() *logRedactor {
+	config := gatewaySettings().Redaction
+	key := strings.Join(config.Headers, "\x00") + "\x01" + strings.Join(config.JSONPaths, "\x00") + "\x01" + strings.Join(config.Patterns, "\x00")
+
+	redactorMutex.Lock()
+	defer redactorMutex.Unlock()
+	if redactor == nil || key != redactorKey {
+		redactor, redactorKey = compileRedactor(config), key
+	}
+	return redactor
+}
+
+func compileRedactor(config static.RedactionConfig) *logRedactor {
+	rd := &logRedactor{headers: make(map[string]bool)}
+	for _, name := range config.Headers {
+		rd.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, path := range config.JSONPaths {
+		steps, err := parseRedactionPath(path)
+		if err != nil {
+			log.Printf("⚠️ Ignoring redaction json path %q: %v", path, err)
+			continue
+		}
+		rd.paths = append(rd.paths, steps)
+	}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("⚠️ Ignoring redaction pattern %q: %v", pattern, err)
+			continue
+		}
+		rd.patterns = append(rd.patterns, re)
+	}
+	return rd
+}
+
+// 解析 $.a.b、$.a.*.b、$..b 形式的路径
+func parseRedactionPath(path string) ([]redactionStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("path must start with $.")
+	}
+	var steps []redactionStep
+	for rest != "" {
+		if !strings.HasPrefix(rest, ".") {
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+		step := redactionStep{}
+		if strings.HasPrefix(rest, "..") {
+			step.recursive = true
+			rest = rest[2:]
+		} else {
+			rest = rest[1:]
+		}
+		end := strings.Index(rest, ".")
+		if end < 0 {
+			end = len(rest)
+		}
+		step.name, rest = rest[:end], rest[end:]
+		if step.name == "" {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// 按正则脱敏文本
+func (rd *logRedactor) text(value string) string {
+	for _, re := range rd.patterns {
+		value = re.ReplaceAllString(value, redactedValue)
+	}
+	return value
+}
+
+// 请求头值：凭据和配置的请求头隐藏整个值，其他请求头按正则脱敏
+func (rd *logRedactor) header(name, value string) string {
+	if rd.headers[http.CanonicalHeaderKey(name)] {
+		return redactedValue
+	}
+	return rd.text(value)
+}
+
+// 按 JSON 路径脱敏（原地修改）
+func redactJSONPath(node interface{}, steps []redactionStep) interface{} {
+	if len(steps) == 0 {
+		return redactedValue
+	}
+	step := steps[0]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if step.name == "*" || step.name == key {
+				v[key] = redactJSONPath(child, steps[1:])
+			}
+			if step.recursive {
+				v[key] = redactJSONPath(v[key], steps)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if step.name == "*" {
+				v[i] = redactJSONPath(child, steps[1:])
+			}
+			if step.recursive {
+				v[i] = redactJSONPath(v[i], steps)
+			}
+		}
+	}
+	return node
+}
+
+// 按 JSON 路径脱敏一个文档，返回副本
+func (rd *logRedactor) document(doc map[string]interface{}) map[string]interface{} {
+	if len(rd.paths) == 0 {
+		return doc
+	}
+	data, _ := json.Marshal(doc)
+	var copied map[string]interface{}
+	if json.Unmarshal(data, &copied) != nil {
+		return doc
+	}
+	for _, steps := range rd.paths {
+		redactJSONPath(copied, steps)
+	}
+	return copied
+}
+
+// 🔧 新增：字段变更按 JSON 路径脱敏（路径以被修改的对象为根，如 $.metadata.api_token），用于审计记录
+func (rd *logRedactor) fieldChanges(changes map[string]FieldChange) map[string]FieldChange {
+	if len(rd.paths) == 0 || len(changes) == 0 {
+		return changes
+	}
+	from := make(map[string]interface{}, len(changes))
+	to := make(map[string]interface{}, len(changes))
+	for field, change := range changes {
+		from[field], to[field] = change.From, change.To
+	}
+	from, to = rd.document(from), rd.document(to)
+
+	redacted := make(map[string]FieldChange, len(changes))
+	for field := range changes {
+		redacted[field] = FieldChange{From: from[field], To: to[field]}
+	}
+	return redacted
+}
+
+// 🔧 新增：转发前脱敏日志事件
+func (rd *logRedactor) logEvent(event LogEvent) LogEvent {
+	if len(rd.patterns) == 0 {
+		return event
+	}
+	event.Path = rd.text(event.Path)
+	event.UserAgent = rd.text(event.UserAgent)
+	event.Message = rd.text(event.Message)
+	return event
+}
@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	requestSigningOff      = "off"
+	requestSigningRequired = "required"
+
+	nonceKeyPrefix = "gateway:auth:nonce:"
+	maxNonceLength = 128
+)
+
+var (
+	errInvalidGatewayKey     = fmt.Errorf("invalid gateway api key")
+	errSignatureRequired     = fmt.Errorf("request signature required")
+	errInvalidSignature      = fmt.Errorf("invalid request signature")
+	errUnknownSigningKey     = fmt.Errorf("unknown signing key")
+	errStaleRequest          = fmt.Errorf("request timestamp outside allowed window")
+	errReplayedRequest       = fmt.Errorf("request nonce already used")
+	errMissingSignatureParts = fmt.Errorf("X-Gateway-Key-Id, X-Gateway-Timestamp and X-Gateway-Nonce are required")
+)
+
+// 网关请求认证：携带签名时校验签名（不传输密钥本身），携带 Bearer 令牌时校验访问令牌，否则校验 X-Api-Key。
+// 认证失败返回错误（401）；返回的调用方带有访问范围，路由匹配后再做授权（403）。
+func (dr *DistributedRouter) verifyGatewayRequest(r *http.Request) (*gatewayPrincipal, error) {
+	settings := gatewaySettings()
+	signing := settings.RequestSigning
+	enabled := signing.Mode != "" && signing.Mode != requestSigningOff
+
+	if enabled && r.Header.Get("X-Gateway-Signature") != "" {
+		return dr.verifyRequestSignature(r, signing.MaxSkew, signing.Keys)
+	}
+	if signing.Mode == requestSigningRequired {
+		return nil, errSignatureRequired
+	}
+	if token, ok := bearerToken(r); ok && settings.OAuth.Enabled {
+		return dr.verifyBearerToken(r, settings, token)
+	}
+	principal, ok := dr.authenticateGatewayRequest(r)
+	if !ok {
+		return nil, errInvalidGatewayKey
+	}
+	return principal, nil
+}
+
+// 校验 Bearer 访问令牌，令牌的 scope 作为访问范围
+func (dr *DistributedRouter) verifyBearerToken(r *http.Request, settings static.GatewayConfig, token string) (*gatewayPrincipal, error) {
+	claims, err := dr.verifyAccessToken(r.Context(), settings.OAuth, token)
+	if err != nil {
+		return nil, err
+	}
+	principal := &gatewayPrincipal{Name: claims.Subject, Scope: routeScopeFromOAuth(claims.Scope), Method: routeAuthJWT, Claims: claims.Extra}
+	if claim := settings.Tenancy.JWTClaim; claim != "" {
+		principal.tenantClaim, _ = claims.Extra[claim].(string)
+	}
+	return principal, nil
+}
+
+// 校验客户端签名：
+//
+//	X-Gateway-Signature = hex(HMAC-SHA256(secret, METHOD\nPATH\nQUERY\nTIMESTAMP\nNONCE\nSHA256(body)))
+//
+// PATH 为转义后的路径；QUERY 为规范化查询字符串：参数按键排序、同名参数按值排序，键和值使用 RFC 3986 编码，
+// 以 & 连接（与 SigV4 相同，没有查询参数时为空字符串），篡改查询参数同样会使签名失效。
+// 时间戳超出 maxSkew 或 nonce 在窗口内重复使用的请求会被拒绝。
+func (dr *DistributedRouter) verifyRequestSignature(r *http.Request, maxSkew int, keys []static.SigningKeyConfig) (*gatewayPrincipal, error) {
+	keyID := r.Header.Get("X-Gateway-Key-Id")
+	timestamp := r.Header.Get("X-Gateway-Timestamp")
+	nonce := r.Header.Get("X-Gateway-Nonce")
+	if keyID == "" || timestamp == "" || nonce == "" || len(nonce) > maxNonceLength {
+		return nil, errMissingSignatureParts
+	}
+
+	var signingKey *static.SigningKeyConfig
+	for i := range keys {
+		if keys[i].KeyID == keyID {
+			signingKey = &keys[i]
+			break
+		}
+	}
+	if signingKey == nil || signingKey.Secret == "" {
+		return nil, errUnknownSigningKey
+	}
+
+	// 时间窗口校验
+	if maxSkew <= 0 {
+		maxSkew = 300
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errStaleRequest
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > time.Duration(maxSkew)*time.Second {
+		return nil, errStaleRequest
+	}
+
+	// 读取请求体用于计算摘要，之后恢复供后续处理使用
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		if err != nil || len(body) > maxSignedBodySize {
+			return nil, errInvalidSignature
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	secret, err := dr.secrets.Resolve(r.Context(), signingKey.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("signing key unavailable: %v", err)
+	}
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+	expected := hmacSignature(secret, r.Method, r.URL.EscapedPath(), canonicalQueryString(query), timestamp, nonce, sha256Hex(body))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Gateway-Signature"))) {
+		return nil, errInvalidSignature
+	}
+
+	// 签名有效后再记录 nonce，nonce 保留整个时间窗口（前后各 maxSkew）
+	if !dr.nonces.claim(r.Context(), keyID+":"+nonce, 2*time.Duration(maxSkew)*time.Second) {
+		return nil, errReplayedRequest
+	}
+	return &gatewayPrincipal{Name: keyID, Scope: signingKey.RouteScope, Method: routeAuthHMAC}, nil
+}
+
+// nonce 记录：有 Redis 时跨实例共享，否则保存在本地内存
+type nonceStore struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	local        map[string]time.Time
+	mutex        sync.Mutex
+}
+
+func newNonceStore(redisClient *redis.Client, redisEnabled bool) *nonceStore {
+	return &nonceStore{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		local:        make(map[string]time.Time),
+	}
+}
+
+// 首次使用返回 true，重复使用返回 false
+func (ns *nonceStore) claim(ctx context.Context, nonce string, ttl time.Duration) bool {
+	if ns.redisEnabled {
+		claimed, err := ns.redisClient.SetNX(ctx, nonceKeyPrefix+nonce, 1, ttl).Result()
+		if err == nil {
+			return claimed
+		}
+		// Redis 异常时退化为本地校验
+	}
+
+	now := time.Now()
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	if expiresAt, exists := ns.local[nonce]; exists && now.Before(expiresAt) {
+		return false
+	}
+	// 顺带清理过期记录
+	if len(ns.local) > 10000 {
+		for key, expiresAt := range ns.local {
+			if now.After(expiresAt) {
+				delete(ns.local, key)
+			}
+		}
+	}
+	ns.local[nonce] = now.Add(ttl)
+	return true
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+const testSigningSecret = "test-signing-secret"
+
+// 按 verifyRequestSignature 的规范化规则计算签名
+func signedRequestFields(method, target, body, nonce string, ts time.Time) (timestamp, signature string) {
+	req := httptest.NewRequest(method, target, nil)
+	timestamp = strconv.FormatInt(ts.Unix(), 10)
+	signature = hmacSignature(testSigningSecret, method, req.URL.EscapedPath(), canonicalQueryString(req.URL.Query()),
+		timestamp, nonce, sha256Hex([]byte(body)))
+	return timestamp, signature
+}
+
+func TestVerifyRequestSignature(t *testing.T) {
+	keys := []static.SigningKeyConfig{{KeyID: "billing", Secret: testSigningSecret}}
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		// 签名时使用的请求
+		signTarget, signBody string
+		signedAt             time.Time
+		// 实际发送的请求
+		target, body string
+		keyID        string
+		replay       bool // 先发送一次相同的请求
+		wantErr      error
+	}{
+		{name: "valid", signTarget: "/api/orders?b=2&a=1", signBody: `{"id":1}`, signedAt: now,
+			target: "/api/orders?a=1&b=2", body: `{"id":1}`, keyID: "billing"},
+		{name: "tampered body", signTarget: "/api/orders", signBody: `{"amount":1}`, signedAt: now,
+			target: "/api/orders", body: `{"amount":1000}`, keyID: "billing", wantErr: errInvalidSignature},
+		{name: "tampered query", signTarget: "/api/orders?amount=1", signedAt: now,
+			target: "/api/orders?amount=1000", keyID: "billing", wantErr: errInvalidSignature},
+		{name: "added query", signTarget: "/api/orders", signedAt: now,
+			target: "/api/orders?admin=true", keyID: "billing", wantErr: errInvalidSignature},
+		{name: "stale timestamp", signTarget: "/api/orders", signedAt: now.Add(-10 * time.Minute),
+			target: "/api/orders", keyID: "billing", wantErr: errStaleRequest},
+		{name: "future timestamp", signTarget: "/api/orders", signedAt: now.Add(10 * time.Minute),
+			target: "/api/orders", keyID: "billing", wantErr: errStaleRequest},
+		{name: "replayed nonce", signTarget: "/api/orders", signedAt: now,
+			target: "/api/orders", keyID: "billing", replay: true, wantErr: errReplayedRequest},
+		{name: "unknown key", signTarget: "/api/orders", signedAt: now,
+			target: "/api/orders", keyID: "unknown", wantErr: errUnknownSigningKey},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dr := &DistributedRouter{secrets: NewSecretResolver(nil, false), nonces: newNonceStore(nil, false)}
+			nonce := "nonce-" + strconv.Itoa(i)
+			timestamp, signature := signedRequestFields("POST", tt.signTarget, tt.signBody, nonce, tt.signedAt)
+
+			verify := func() error {
+				req := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body))
+				req.Header.Set("X-Gateway-Key-Id", tt.keyID)
+				req.Header.Set("X-Gateway-Timestamp", timestamp)
+				req.Header.Set("X-Gateway-Nonce", nonce)
+				req.Header.Set("X-Gateway-Signature", signature)
+				_, err := dr.verifyRequestSignature(req, 300, keys)
+				return err
+			}
+			if tt.replay {
+				if err := verify(); err != nil {
+					t.Fatalf("first request: %v", err)
+				}
+			}
+			if err := verify(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyRequestSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNonceStoreClaim(t *testing.T) {
+	ns := newNonceStore(nil, false)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		nonce string
+		ttl   time.Duration
+		sleep time.Duration
+		want  bool
+	}{
+		{name: "first use", nonce: "billing:a", ttl: time.Minute, want: true},
+		{name: "replayed", nonce: "billing:a", ttl: time.Minute, want: false},
+		{name: "other nonce", nonce: "billing:b", ttl: time.Minute, want: true},
+		{name: "same nonce other key", nonce: "partner:a", ttl: time.Minute, want: true},
+		{name: "short ttl", nonce: "billing:c", ttl: 10 * time.Millisecond, want: true},
+		{name: "reused after expiry", nonce: "billing:c", ttl: time.Minute, sleep: 20 * time.Millisecond, want: true},
+	}
+	for _, tt := range tests {
+		time.Sleep(tt.sleep)
+		if got := ns.claim(ctx, tt.nonce, tt.ttl); got != tt.want {
+			t.Errorf("%s: claim(%q) = %v, want %v", tt.name, tt.nonce, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 缓存的域名数上限，超出时先清理过期条目
+const maxDNSCacheEntries = 4096
+
+// 使用过期地址后，隔多久再重新查询（避免 DNS 不可用时每个请求都等待查询超时）
+const dnsStaleRetry = 5 * time.Second
+
+// 🔧 新增：上游拨号共用的 DNS 缓存：IP 字面量直接使用，其次是静态解析（overrides），再次是未过期的缓存（包括域名不存在的否定缓存）；
+// 同一域名同时只发起一次查询，查询失败（域名不存在除外）时继续使用过期的缓存
+type dnsCache struct {
+	config    static.DNSConfig
+	servers   []string            // 规范化为 host:port，为空时使用系统解析
+	overrides map[string][]net.IP // 小写域名 -> 地址
+	lookup    func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+	mutex    sync.Mutex
+	entries  map[string]*dnsEntry
+	inflight map[string]*dnsCall
+
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	overrideHits atomic.Int64
+	misses       atomic.Int64
+	stale        atomic.Int64
+	errors       atomic.Int64
+}
+
+type dnsEntry struct {
+	ips       []net.IP
+	notFound  bool
+	expires   time.Time
+	updatedAt time.Time
+}
+
+// 进行中的查询，等待同一域名的请求共享结果
+type dnsCall struct {
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+// 当前生效的解析器，未初始化时直接按系统解析拨号
+var upstreamDNS atomic.Pointer[dnsCache]
+
+var upstreamDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// 🔧 新增：沙箱、proxy 目标、LLM 上游和沙箱健康检查共用的 Transport（除拨号外与 http.DefaultTransport 相同）
+var upstreamTransport = newUpstreamTransport()
+
+var upstreamClient = &http.Client{Transport: upstreamTransport}
+
+func newUpstreamTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialUpstream
+	return transport
+}
+
+// 按配置初始化上游解析器，配置变化时清空缓存
+func initUpstreamDNS(config static.DNSConfig) error {
+	cache, err := newDNSCache(config)
+	if err != nil {
+		return err
+	}
+	upstreamDNS.Store(cache)
+	if len(cache.servers) > 0 || len(cache.overrides) > 0 {
+		log.Printf("🧭 Upstream DNS: servers %v, %d overrides", cache.servers, len(cache.overrides))
+	}
+	return nil
+}
+
+func newDNSCache(config static.DNSConfig) (*dnsCache, error) {
+	if config.DefaultTTL < 0 || config.MinTTL < 0 || config.MaxTTL < 0 || config.NegativeTTL < 0 {
+		return nil, fmt.Errorf("gateway.dns: TTLs must not be negative")
+	}
+	if config.MaxTTL > 0 && config.MinTTL > config.MaxTTL {
+		return nil, fmt.Errorf("gateway.dns: min_ttl must not exceed max_ttl")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2000
+	}
+
+	cache := &dnsCache{
+		config:    config,
+		overrides: make(map[string][]net.IP, len(config.Overrides)),
+		entries:   make(map[string]*dnsEntry),
+		inflight:  make(map[string]*dnsCall),
+	}
+	for _, server := range config.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		host, _, _ := net.SplitHostPort(server)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("gateway.dns: server %q must be an IP address", server)
+		}
+		cache.servers = append(cache.servers, server)
+	}
+	for host, value := range config.Overrides {
+		ips, err := parseOverrideAddresses(value)
+		if err != nil {
+			return nil, fmt.Errorf("gateway.dns: override %s: %v", host, err)
+		}
+		cache.overrides[normalizeDNSHost(host)] = ips
+	}
+
+	cache.lookup = cache.lookupSystem
+	if len(cache.servers) > 0 {
+		cache.lookup = cache.lookupServers
+	}
+	return cache, nil
+}
+
+// 静态解析的地址列表：逗号分隔的 IP
+func parseOverrideAddresses(value string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, address := range strings.Split(value, ",") {
+		ip := net.ParseIP(strings.Trim(strings.TrimSpace(address), "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", strings.TrimSpace(address))
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func normalizeDNSHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func dnsNotFound(host string) error {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func isDNSNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// 解析域名
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	key := normalizeDNSHost(host)
+	if ips, ok := c.overrides[key]; ok {
+		c.overrideHits.Add(1)
+		return ips, nil
+	}
+
+	c.mutex.Lock()
+	if entry := c.entries[key]; entry != nil && time.Now().Before(entry.expires) {
+		c.mutex.Unlock()
+		if entry.notFound {
+			c.negativeHits.Add(1)
+			return nil, dnsNotFound(host)
+		}
+		c.hits.Add(1)
+		return entry.ips, nil
+	}
+	c.misses.Add(1)
+	call := c.inflight[key]
+	if call == nil {
+		call = &dnsCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		go c.refresh(key, call)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.ips, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 查询并更新缓存（使用独立的超时，不受发起请求的取消影响）
+func (c *dnsCache) refresh(key string, call *dnsCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.config.Timeout)*time.Millisecond)
+	defer cancel()
+	ips, ttl, err := c.lookup(ctx, key)
+
+	now := time.Now()
+	c.mutex.Lock()
+	defer func() {
+		delete(c.inflight, key)
+		c.mutex.Unlock()
+		close(call.done)
+	}()
+
+	switch {
+	case err == nil:
+		c.store(key, &dnsEntry{ips: ips, expires: now.Add(ttl), updatedAt: now})
+		call.ips = ips
+	case isDNSNotFound(err):
+		c.store(key, &dnsEntry{notFound: true, expires: now.Add(time.Duration(c.config.NegativeTTL) * time.Second), updatedAt: now})
+		call.err = dnsNotFound(key)
+	default:
+		// DNS 服务器不可用时继续使用过期的地址
+		if entry := c.entries[key]; entry != nil && !entry.notFound {
+			c.stale.Add(1)
+			log.Printf("⚠️ DNS lookup for %s failed, using stale addresses: %v", key, err)
+			entry.expires = now.Add(dnsStaleRetry)
+			call.ips = entry.ips
+			return
+		}
+		c.errors.Add(1)
+		call.err = err
+	}
+}
+
+func (c *dnsCache) store(key string, entry *dnsEntry) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxDNSCacheEntries {
+		now := time.Now()
+		for name, cached := range c.entries {
+			if now.After(cached.expires) {
+				delete(c.entries, name)
+			}
+		}
+		if len(c.entries) >= maxDNSCacheEntries {
+			for name := range c.entries {
+				delete(c.entries, name)
+				break
+			}
+		}
+	}
+	c.entries[key] = entry
+}
+
+// 清空缓存（静态解析不受影响），返回清除的条目数
+func (c *dnsCache) flush() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := len(c.entries)
+	c.entries = make(map[string]*dnsEntry)
+	return count
+}
+
+// 系统解析不提供 TTL，按 default_ttl 缓存
+func (c *dnsCache) lookupSystem(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, time.Duration(c.config.DefaultTTL) * time.Second, nil
+}
+
+// 依次查询配置的 DNS 服务器，域名不存在时不再尝试下一个
+func (c *dnsCache) lookupServers(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	// 直接查询服务器时不读取 /etc/hosts，localhost 固定为回环地址
+	if host == "localhost" {
+		return []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}, time.Duration(c.config.MaxTTL) * time.Second, nil
+	}
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, dnsNotFound(host)
+	}
+	var lastErr error
+	for _, server := range c.servers {
+		ips, ttl, err := c.queryServer(ctx, server, name)
+		if err == nil {
+			return ips, ttl, nil
+		}
+		if isDNSNotFound(err) {
+			return nil, 0, dnsNotFound(host)
+		}
+		lastErr = err
+	}
+	return nil, 0, &net.DNSError{Err: lastErr.Error(), Name: host, Server: c.servers[len(c.servers)-1], IsTemporary: true}
+}
+
+// 同时查询 A 和 AAAA 记录，TTL 取所有记录中最小的值
+func (c *dnsCache) queryServer(ctx context.Context, server string, name dnsmessage.Name) ([]net.IP, time.Duration, error) {
+	type answer struct {
+		ips []net.IP
+		ttl uint32
+		err error
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	answers := make([]answer, len(types))
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i].ips, answers[i].ttl, answers[i].err = exchangeDNS(ctx, server, name, qtype)
+		}()
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	ttl := uint32(0)
+	for _, answer := range answers {
+		if answer.err != nil && !isDNSNotFound(answer.err) {
+			return nil, 0, answer.err
+		}
+		if len(answer.ips) > 0 && (len(ips) == 0 || answer.ttl < ttl) {
+			ttl = answer.ttl
+		}
+		ips = append(ips, answer.ips...)
+	}
+	if len(ips) == 0 {
+		return nil, 0, dnsNotFound(name.String())
+	}
+
+	duration := time.Duration(ttl) * time.Second
+	if minTTL := time.Duration(c.config.MinTTL) * time.Second; duration < minTTL {
+		duration = minTTL
+	}
+	if maxTTL := time.Duration(c.config.MaxTTL) * time.Second; maxTTL > 0 && duration > maxTTL {
+		duration = maxTTL
+	}
+	return ips, duration, nil
+}
+
+// 发送一次查询：先用 UDP，响应被截断时改用 TCP
+func exchangeDNS(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]net.IP, uint32, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response, err := exchangeDNSOver(ctx, "udp", server, query)
+	if err == nil {
+		var header dnsmessage.Header
+		var parser dnsmessage.Parser
+		if header, err = parser.Start(response); err == nil && header.Truncated {
+			response, err = exchangeDNSOver(ctx, "tcp", server, query)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var message dnsmessage.Message
+	if err := message.Unpack(response); err != nil {
+		return nil, 0, err
+	}
+	if message.ID != id || !message.Response {
+		return nil, 0, fmt.Errorf("unexpected DNS response from %s", server)
+	}
+	switch message.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, dnsNotFound(name.String())
+	default:
+		return nil, 0, fmt.Errorf("DNS server %s returned %s", server, message.RCode)
+	}
+
+	var ips []net.IP
+	ttl := uint32(0)
+	for _, resource := range message.Answers {
+		var ip net.IP
+		switch body := resource.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue
+		}
+		if len(ips) == 0 || resource.Header.TTL < ttl {
+			ttl = resource.Header.TTL
+		}
+		ips = append(ips, ip)
+	}
+	return ips, ttl, nil
+}
+
+func exchangeDNSOver(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buffer := make([]byte, 4096)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:n], nil
+	}
+
+	// TCP 消息前有 2 字节长度
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// 上游连接的拨号：解析域名后连接解析出的地址
+func dialUpstream(ctx context.Context, network, address string) (net.Conn, error) {
+	cache := upstreamDNS.Load()
+	host, port, err := net.SplitHostPort(address)
+	if cache == nil || err != nil {
+		return upstreamDialer.DialContext(ctx, network, address)
+	}
+	ips, err := cache.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return dialAddresses(ctx, network, host, port, ips)
+}
+
+// 连接解析出的地址，同时有 IPv4 和 IPv6 地址时按 Happy Eyeballs 连接
+func dialAddresses(ctx context.Context, network, host, port string, ips []net.IP) (net.Conn, error) {
+	settings := gatewaySettings().Network
+	primaries, fallbacks := splitAddressFamilies(ips, network, settings.PreferredFamily)
+	if len(primaries) == 0 && len(fallbacks) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return dialHappyEyeballs(ctx, network, port, primaries, fallbacks, time.Duration(settings.FallbackDelay)*time.Millisecond)
+}
+
+func (c *dnsCache) Stats() map[string]interface{} {
+	c.mutex.Lock()
+	entries := len(c.entries)
+	c.mutex.Unlock()
+	resolver := "system"
+	if len(c.servers) > 0 {
+		resolver = "servers"
+	}
+	return map[string]interface{}{
+		"resolver":      resolver,
+		"entries":       entries,
+		"overrides":     len(c.overrides),
+		"hits":          c.hits.Load(),
+		"negative_hits": c.negativeHits.Load(),
+		"override_hits": c.overrideHits.Load(),
+		"misses":        c.misses.Load(),
+		"stale":         c.stale.Load(),
+		"errors":        c.errors.Load(),
+	}
+}
+
+// 缓存条目，按域名排序
+func (c *dnsCache) snapshot() []gin.H {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	entries := make([]gin.H, 0, len(c.entries))
+	for host, entry := range c.entries {
+		addresses := make([]string, 0, len(entry.ips))
+		for _, ip := range entry.ips {
+			addresses = append(addresses, ip.String())
+		}
+		entries = append(entries, gin.H{
+			"host":        host,
+			"addresses":   addresses,
+			"not_found":   entry.notFound,
+			"expired":     now.After(entry.expires),
+			"ttl_seconds": entry.expires.Sub(now).Seconds(),
+			"updated_at":  entry.updatedAt.Unix(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["host"].(string) < entries[j]["host"].(string) })
+	return entries
+}
+
+// 🔧 新增：GET /admin/dns：解析器统计和缓存条目
+func (dr *DistributedRouter) dnsCacheHandler(c *gin.Context) {
+	cache := upstreamDNS.Load()
+	c.JSON(200, gin.H{"stats": cache.Stats(), "entries": cache.snapshot()})
+}
+
+// 🔧 新增：GET /admin/dns/resolve?host=sandbox.internal：按上游拨号的规则解析（会写入缓存）
+func (dr *DistributedRouter) dnsResolveHandler(c *gin.Context) {
+	host := c.Query("host")
+	if host == "" {
+		c.JSON(400, gin.H{"error": "host is required"})
+		return
+	}
+	cache := upstreamDNS.Load()
+	_, override := cache.overrides[normalizeDNSHost(host)]
+	startTime := time.Now()
+	ips, err := cache.resolve(c.Request.Context(), host)
+	if err != nil {
+		code := 502
+		if isDNSNotFound(err) {
+			code = 404
+		}
+		c.JSON(code, gin.H{"host": host, "error": err.Error()})
+		return
+	}
+	addresses := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, ip.String())
+	}
+	c.JSON(200, gin.H{
+		"host":       host,
+		"addresses":  addresses,
+		"override":   override,
+		"latency_ms": float64(time.Since(startTime).Microseconds()) / 1000,
+	})
+}
+
+// 🔧 新增：DELETE /admin/dns/cache：清空本实例的 DNS 缓存
+func (dr *DistributedRouter) flushDNSCacheHandler(c *gin.Context) {
+	c.JSON(200, gin.H{"flushed": upstreamDNS.Load().flush()})
+}
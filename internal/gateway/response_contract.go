@@ -0,0 +1,272 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 响应契约违规的处理方式
+const (
+	contractActionLog    = "log"    // 记录日志并计数（默认）
+	contractActionCount  = "count"  // 只计数
+	contractActionReject = "reject" // 记录日志、计数并返回 502
+
+	// 校验 schema 时最多缓冲的响应体大小，超出时只校验状态码
+	maxContractBodyBytes = 1 << 20
+	// 单个响应最多报告的违规数
+	maxContractViolations = 10
+)
+
+// 🔧 新增：上游响应契约：允许的状态码和响应体 JSON Schema，用于尽早发现悄悄出错的沙箱代码
+type RouteResponseContract struct {
+	Statuses []int       `json:"statuses,omitempty"` // 允许的状态码，为空时不限制
+	Schema   *jsonSchema `json:"schema,omitempty"`   // 响应体 JSON Schema（支持常用子集）
+	Action   string      `json:"action,omitempty"`   // log（默认）、count、reject
+}
+
+func (c *RouteResponseContract) validate() error {
+	switch c.Action {
+	case "", contractActionLog, contractActionCount, contractActionReject:
+	default:
+		return fmt.Errorf("invalid response_contract.action: %s", c.Action)
+	}
+	for _, status := range c.Statuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid response_contract status: %d", status)
+		}
+	}
+	if c.Schema != nil {
+		return c.Schema.check("$")
+	}
+	return nil
+}
+
+// 响应违反契约，action 为 reject 时作为错误返回
+type contractViolationError struct {
+	Violations []string
+}
+
+func (e *contractViolationError) Error() string {
+	return "upstream response violates contract: " + strings.Join(e.Violations, "; ")
+}
+
+// 按路由契约检查上游响应：需要校验 schema 时缓冲响应体并恢复，违规时记录并按 action 处理；
+// 返回非空表示响应应被替换为 502
+func (dr *DistributedRouter) checkResponseContract(route *RouteConfig, resp *http.Response) *contractViolationError {
+	contract := route.ResponseContract
+	if contract == nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		return nil
+	}
+
+	var violations []string
+	if len(contract.Statuses) > 0 && !slices.Contains(contract.Statuses, resp.StatusCode) {
+		violations = append(violations, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+
+	if contract.Schema != nil && (len(contract.Statuses) == 0 || slices.Contains(contract.Statuses, resp.StatusCode)) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxContractBodyBytes+1))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		switch {
+		case err != nil:
+			return nil
+		case len(body) > maxContractBodyBytes:
+			log.Printf("⚠️  Response for route %s is too large for contract validation", route.ID)
+		default:
+			var value interface{}
+			if err := json.Unmarshal(body, &value); err != nil {
+				violations = append(violations, "response body is not valid JSON")
+			} else {
+				contract.Schema.validate(value, "$", &violations)
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	dr.metrics.RecordContractViolation(route.ID)
+	dr.statsd.RecordContractViolation(route.ID)
+	if contract.Action != contractActionCount {
+		log.Printf("📜 Response for route %s violates contract: %s", route.ID, strings.Join(violations, "; "))
+	}
+	if contract.Action == contractActionReject {
+		return &contractViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// 违反契约被拒绝的响应：502 并返回违规详情（🔧 路由配置了 502 的 error_responses 时按模板返回）
+func writeContractViolation(w http.ResponseWriter, r *http.Request, route *RouteConfig, err *contractViolationError) {
+	w.Header().Set("Content-Type", "application/json")
+	writeRouteError(w, r, route, http.StatusBadGateway, map[string]interface{}{
+		"error":      "upstream response violates contract",
+		"violations": err.Violations,
+	})
+}
+
+// JSON Schema 子集：type、properties、required、additionalProperties（布尔）、items、enum、
+// minimum、maximum、minLength、maxLength、minItems、maxItems，其他关键字忽略
+type jsonSchema struct {
+	Type                 jsonSchemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+}
+
+// type 可以是单个类型名或类型名数组
+type jsonSchemaTypes []string
+
+func (t *jsonSchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = jsonSchemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("schema type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+func (t jsonSchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+var jsonSchemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// 保存路由时检查 schema 的类型名
+func (s *jsonSchema) check(path string) error {
+	for _, name := range s.Type {
+		if !jsonSchemaTypeNames[name] {
+			return fmt.Errorf("invalid schema type %q at %s", name, path)
+		}
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			continue
+		}
+		if err := property.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+func (s *jsonSchema) validate(value interface{}, path string, violations *[]string) {
+	report := func(format string, args ...interface{}) {
+		if len(*violations) < maxContractViolations {
+			*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(name string) bool { return jsonTypeMatches(name, value) }) {
+		report("expected %s, got %s", strings.Join(s.Type, " or "), jsonTypeName(value))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed interface{}) bool { return reflect.DeepEqual(allowed, value) }) {
+		report("value is not one of the allowed values")
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names) // 违规按属性名排序，输出稳定
+		for _, name := range names {
+			if schema := s.Properties[name]; schema != nil {
+				schema.validate(v[name], path+"."+name, violations)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				report("unexpected property %q", name)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, path+"["+strconv.Itoa(i)+"]", violations)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			report("expected at least %d characters, got %d", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("expected at most %d characters, got %d", *s.MaxLength, length)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			report("%v is less than minimum %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			report("%v is greater than maximum %v", v, *s.Maximum)
+		}
+	}
+}
+
+func jsonTypeMatches(name string, value interface{}) bool {
+	if name == "integer" {
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	}
+	return name == jsonTypeName(value)
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
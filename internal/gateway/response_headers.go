@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 🔧 新增：路由的上游响应头过滤，名称不区分大小写，支持 X-Internal-* 形式的前缀通配
+type RouteResponseHeaders struct {
+	Allow []string `json:"allow,omitempty"` // 只复制这些响应头，为空时复制全部
+	Deny  []string `json:"deny,omitempty"`  // 不复制这些响应头，优先于 allow
+
+	// 🔧 新增：返回给客户端前改写响应头（包括网关生成的响应），remove 同样移除网关添加的响应头
+	HeaderRules
+}
+
+// 逐跳响应头只对单个连接有效，不能转发给客户端（RFC 9110 7.6.1）
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func (f *RouteResponseHeaders) validate() error {
+	for _, name := range append(append([]string(nil), f.Allow...), f.Deny...) {
+		pattern := strings.TrimSuffix(name, "*")
+		if pattern == "" || strings.ContainsAny(pattern, " :*") {
+			return fmt.Errorf("invalid response header pattern: %q", name)
+		}
+	}
+	return f.HeaderRules.validate("response")
+}
+
+// 是否配置了改写规则
+func (f *RouteResponseHeaders) rewrites() bool {
+	return f != nil && (len(f.Set) > 0 || len(f.Add) > 0 || len(f.Remove) > 0)
+}
+
+// 响应头是否允许复制给客户端
+func (f *RouteResponseHeaders) permits(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.Deny {
+		if headerPatternMatch(pattern, name) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range f.Allow {
+		if headerPatternMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerPatternMatch(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix)
+	}
+	return strings.EqualFold(pattern, name)
+}
+
+// 复制上游响应头：去除逐跳响应头（包括 Connection 中列出的），再按路由过滤
+func copyResponseHeaders(dst, src http.Header, filter *RouteResponseHeaders) {
+	hopByHop := make(map[string]bool, len(hopByHopHeaders))
+	for _, name := range hopByHopHeaders {
+		hopByHop[name] = true
+	}
+	for _, value := range src.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				hopByHop[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	for key, values := range src {
+		if hopByHop[http.CanonicalHeaderKey(key)] || !filter.permits(key) {
+			continue
+		}
+		dst.Del(key)
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
+
+// 按路由过滤反向代理的响应头（逐跳响应头已由 ReverseProxy 去除）
+func filterResponseHeaders(header http.Header, filter *RouteResponseHeaders) {
+	if filter == nil {
+		return
+	}
+	for key := range header {
+		if !filter.permits(key) {
+			header.Del(key)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errResponseTooLarge = fmt.Errorf("response exceeds route size limit")
+
+// 🔧 新增：路由的响应大小上限（字节），路由未设置时使用全局配置，0 表示不限制
+func (route *RouteConfig) responseLimit() int64 {
+	if route.MaxResponseBytes > 0 {
+		return route.MaxResponseBytes
+	}
+	return gatewaySettings().MaxResponseBytes
+}
+
+// 限制响应大小：响应头延迟到第一次写入时发送，
+// 在发送响应头前超限（Content-Length 或首块数据）返回 502，已开始传输后超限则中止连接
+type responseLimiter struct {
+	http.ResponseWriter
+	limit       int64
+	written     int64
+	status      int
+	headersSent bool
+	exceeded    bool
+
+	// 超限时按路由的 error_responses 返回 502
+	route   *RouteConfig
+	request *http.Request
+}
+
+func (l *responseLimiter) WriteHeader(status int) {
+	if l.headersSent || l.status != 0 {
+		return
+	}
+	l.status = status
+	if length, err := strconv.ParseInt(l.Header().Get("Content-Length"), 10, 64); err == nil && length > l.limit {
+		l.reject()
+	}
+}
+
+func (l *responseLimiter) Write(data []byte) (int, error) {
+	if l.exceeded {
+		return 0, errResponseTooLarge
+	}
+	if l.written+int64(len(data)) > l.limit {
+		if !l.headersSent {
+			l.reject()
+		}
+		l.exceeded = true
+		return 0, errResponseTooLarge
+	}
+	l.sendHeaders()
+	n, err := l.ResponseWriter.Write(data)
+	l.written += int64(n)
+	return n, err
+}
+
+func (l *responseLimiter) Flush() {
+	if l.exceeded {
+		return
+	}
+	l.sendHeaders()
+	if flusher, ok := l.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (l *responseLimiter) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
+
+func (l *responseLimiter) sendHeaders() {
+	if l.headersSent {
+		return
+	}
+	l.headersSent = true
+	if l.status == 0 {
+		l.status = http.StatusOK
+	}
+	l.ResponseWriter.WriteHeader(l.status)
+}
+
+// 在发送响应头前超限：丢弃上游响应头，返回 502
+func (l *responseLimiter) reject() {
+	l.exceeded = true
+	l.headersSent = true
+	header := l.ResponseWriter.Header()
+	for key := range header {
+		header.Del(key)
+	}
+	header.Set("Content-Type", "application/json")
+	writeRouteError(l.ResponseWriter, l.request, l.route, http.StatusBadGateway, gin.H{"error": errResponseTooLarge.Error()})
+}
+
+// 处理器返回后：记录超限，已开始传输的响应中止连接，让客户端感知响应不完整
+func (dr *DistributedRouter) finishLimitedResponse(route *RouteConfig, l *responseLimiter) {
+	if !l.exceeded {
+		l.sendHeaders()
+		return
+	}
+	log.Printf("✂️  Response for route %s exceeded %d bytes", route.ID, l.limit)
+	dr.metrics.RecordResponseLimit(route.ID)
+	dr.statsd.RecordResponseLimit(route.ID)
+	if l.written > 0 {
+		panic(http.ErrAbortHandler)
+	}
+}
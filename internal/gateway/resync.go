@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 配置轮询模式
+const (
+	syncPollAlways   = "always"   // 始终按间隔轮询（默认）
+	syncPollFallback = "fallback" // 广播事件读取正常时跳过轮询，异常时恢复轮询
+)
+
+// 🔧 新增：全量重新同步：丢弃增量状态，从 Redis 重新加载全部路由
+func (rm *RouteManager) fullResync() (int64, error) {
+	ctx := context.Background()
+	versionText, err := rm.redisClient.Get(ctx, "gateway:config:version").Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to get config version: %v", err)
+	}
+	version, _ := strconv.ParseInt(versionText, 10, 64)
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	startTime := time.Now()
+	rm.loadAllRoutesFromRedis()
+	rm.lastConfigUpdate = version
+	rm.observeConfigVersion(version)
+	rm.syncStats.record(syncKindFullResync, rm.snapshot().size(), time.Since(startTime))
+	return version, nil
+}
+
+// POST /admin/sync/full：清空增量更新标记、提升配置版本并广播 RESYNC 事件，所有实例执行全量加载
+func (dr *DistributedRouter) fullSyncHandler(c *gin.Context) {
+	rm := dr.routeManager
+	if !rm.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	startTime := time.Now()
+	ctx := c.Request.Context()
+	if err := rm.redisClient.Del(ctx, "gateway:routes:updated").Err(); err != nil {
+		c.JSON(500, gin.H{"error": "failed to clear updated set: " + err.Error()})
+		return
+	}
+	// 版本提升且没有增量标记时，错过 RESYNC 事件的实例也会在下次定时同步时全量加载
+	rm.updateConfigVersion()
+
+	version, err := rm.fullResync()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	rm.publishRouteEvent(&RouteEvent{
+		EventID:   fmt.Sprintf("resync-%d", startTime.UnixNano()),
+		EventType: "RESYNC",
+		Timestamp: startTime.Unix(),
+		Source:    rm.instanceID,
+	})
+	opLogf(logCategorySync, logLevelInfo, "🔁 [SYNC] Full resync triggered | 实例: %s | 路由: %d", rm.instanceID, rm.snapshot().size())
+
+	c.JSON(200, gin.H{
+		"message":        "full resync triggered",
+		"instance_id":    rm.instanceID,
+		"config_version": version,
+		"routes":         rm.snapshot().size(),
+		"duration_ms":    time.Since(startTime).Milliseconds(),
+	})
+}
+
+// RESYNC 事件需要广播给所有网关实例，与健康事件一样直接 XREAD 读取事件流；
+// gateway.sync.poll 为 fallback 时，其他实例发布的路由事件也在这里应用（每个实例都能收到全部事件）
+func (rm *RouteManager) consumeBroadcastEvents() {
+	ctx := context.Background()
+	applyRouteEvents := gatewaySettings().Sync.Poll == syncPollFallback
+	handler := &RouteEventHandler{routeManager: rm}
+	lastID := "$"
+	for {
+		streams, err := rm.redisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{rm.eventStream.streamKey, lastID},
+			Count:   100,
+			Block:   5 * time.Second,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			opLogf(logCategorySync, logLevelError, "Error reading broadcast events: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		rm.broadcastReadAt.Store(time.Now().UnixNano())
+
+		// 🔧 修改：同一次读取到的路由事件一起应用（路由表只复制和发布一次），遇到 RESYNC 前先应用已收集的事件
+		var pending []*RouteEvent
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastID = message.ID
+				eventType := message.Values["event_type"]
+				if eventType != "RESYNC" && !(applyRouteEvents && (eventType == "CREATE" || eventType == "UPDATE" || eventType == "DELETE" || eventType == "BATCH")) {
+					continue
+				}
+				eventData, _ := message.Values["event_data"].(string)
+				var event RouteEvent
+				if err := json.Unmarshal([]byte(eventData), &event); err != nil || event.Source == rm.instanceID {
+					continue
+				}
+				if event.EventType != "RESYNC" {
+					pending = append(pending, &event)
+					continue
+				}
+				if len(pending) > 0 {
+					handler.HandleEvents(pending)
+					pending = nil
+				}
+				if _, err := rm.fullResync(); err != nil {
+					opLogf(logCategorySync, logLevelError, "❌ [SYNC] Full resync requested by %s failed: %v", event.Source, err)
+					continue
+				}
+				opLogf(logCategorySync, logLevelInfo, "🔁 [SYNC] Full resync requested by %s | 路由: %d", event.Source, rm.snapshot().size())
+			}
+		}
+		if len(pending) > 0 {
+			handler.HandleEvents(pending)
+		}
+	}
+}
+
+// fallback 轮询模式下广播事件读取是否正常（最近两个阻塞周期内成功读取过）
+func (rm *RouteManager) eventsHealthy() bool {
+	if !rm.redisEnabled || gatewaySettings().Sync.Poll != syncPollFallback {
+		return false
+	}
+	readAt := rm.broadcastReadAt.Load()
+	return readAt > 0 && time.Since(time.Unix(0, readAt)) < 15*time.Second
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HTTP 语义上幂等的方法，失败后可安全重试
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+func isIdempotentMethod(method string) bool {
+	return idempotentMethods[strings.ToUpper(method)]
+}
+
+// 判断请求失败后是否可以重试：幂等方法直接允许，
+// 非幂等方法（POST、PATCH 等）仅在路由显式标记 idempotent 或客户端携带 Idempotency-Key 时允许
+func retrySafe(route *RouteConfig, r *http.Request) bool {
+	if isIdempotentMethod(r.Method) || route.Idempotent {
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// 当前请求允许的最大尝试次数
+func retryAttempts(route *RouteConfig, r *http.Request) int {
+	attempts := gatewaySettings().RetryAttempts
+	if attempts < 1 || !retrySafe(route, r) {
+		return 1
+	}
+	return attempts
+}
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 🔧 新增：声明式 apply：routes 是完整的目标路由表。与当前路由表比较后，新增文档中新的路由、
+// 更新有变化的路由、删除文档中没有的路由，整体作为一个批量变更原子地写入；没有变化的路由不写入
+func (rm *RouteManager) ApplyRoutes(routes []RouteConfig, force, dryRun bool) (*RouteImportResult, error) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	current := rm.snapshot()
+	desired := make(map[string]bool, len(routes))
+	operations := make([]RouteBatchOperation, 0, len(routes))
+	unchanged := make([]string, 0)
+	errors := make(map[string]string)
+
+	for i := range routes {
+		route := routes[i]
+		if route.ID == "" {
+			errors[fmt.Sprintf("routes[%d]", i)] = "route ID is required"
+			continue
+		}
+		if desired[route.ID] {
+			errors[route.ID] = fmt.Sprintf("route %s appears more than once in the document", route.ID)
+			continue
+		}
+		desired[route.ID] = true
+
+		previous, exists := current.get(route.ID)
+		if !exists {
+			operations = append(operations, RouteBatchOperation{Op: routeBatchCreate, ID: route.ID, Route: &route})
+			continue
+		}
+		if code, err := rm.resolveCode(&previous); err == nil {
+			previous.Code = code
+		}
+		if len(diffRoutes(previous, route)) == 0 {
+			unchanged = append(unchanged, route.ID)
+			continue
+		}
+		operations = append(operations, RouteBatchOperation{Op: routeBatchUpdate, ID: route.ID, Route: &route})
+	}
+
+	// 文档本身有错误时不计算也不执行任何变更
+	if len(errors) > 0 {
+		result := newRouteBatchResult(dryRun)
+		result.Errors = errors
+		return result, errRouteBatchRejected
+	}
+
+	// 文档中没有的路由按 ID 排序删除，结果稳定
+	var removed []string
+	for id := range current.routes {
+		if !desired[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		operations = append(operations, RouteBatchOperation{Op: routeBatchDelete, ID: id})
+	}
+
+	result, err := rm.applyRouteBatchLocked(operations, force, dryRun)
+	result.Unchanged = unchanged
+	keys := make([]string, len(operations))
+	for i, operation := range operations {
+		keys[i] = operation.ID
+	}
+	rekeyRouteBatchErrors(result, keys)
+	return result, err
+}
+
+// 🔧 新增：POST /admin/routes/apply?dry_run=true&force=true
+// 请求体为完整的路由文档（JSON 或 YAML，格式与导出相同），网关计算差异后原子地新增、更新和删除路由
+func (dr *DistributedRouter) applyRoutesHandler(c *gin.Context) {
+	snapshot, err := readRouteDocument(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(snapshot.Routes) > maxRouteBatchOperations {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("a document can contain at most %d routes", maxRouteBatchOperations)})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := dr.routeManager.ApplyRoutes(snapshot.Routes, c.Query("force") == "true", dryRun)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if dryRun {
+		c.JSON(200, gin.H{"dry_run": true, "result": result})
+		return
+	}
+
+	c.Set(auditMessageKey, fmt.Sprintf("applied route table (%d created, %d updated, %d deleted, %d unchanged)",
+		len(result.Created), len(result.Updated), len(result.Deleted), len(result.Unchanged)))
+	c.JSON(200, gin.H{"message": "routes applied", "result": result})
+}
@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+// 路由认证方式
+const (
+	routeAuthDefault = ""      // 全局认证链：签名、访问令牌或 X-Api-Key（默认）
+	routeAuthKey     = "key"   // 只接受 X-Api-Key（网关密钥或消费者 Key）
+	routeAuthJWT     = "jwt"   // 只接受 Bearer 访问令牌
+	routeAuthHMAC    = "hmac"  // 只接受请求签名
+	routeAuthBasic   = "basic" // HTTP Basic 认证，凭据来自 gateway.basic_auth.credentials 或 Redis 凭据存储
+	routeAuthNone    = "none"  // 不认证（公开路由）
+
+	// 公开路由的调用方名称（访问日志、限流和配额按此名称统计）
+	anonymousPrincipal = "anonymous"
+)
+
+var (
+	errAccessTokenRequired = fmt.Errorf("bearer access token required")
+	errBasicAuthRequired   = fmt.Errorf("basic authentication required")
+	errInvalidBasicAuth    = fmt.Errorf("invalid basic auth credentials")
+)
+
+// 🔧 新增：路由级认证方式
+type RouteAuth struct {
+	Mode        string   `json:"mode"`                  // key、jwt、hmac、basic、none，为空时使用全局认证链
+	Credentials []string `json:"credentials,omitempty"` // basic：允许的凭据名称，为空时接受所有已配置的凭据
+}
+
+func (a *RouteAuth) validate() error {
+	switch a.Mode {
+	case routeAuthDefault, routeAuthKey, routeAuthJWT, routeAuthHMAC, routeAuthNone:
+		if len(a.Credentials) > 0 {
+			return fmt.Errorf("auth.credentials is only supported with auth mode basic")
+		}
+	case routeAuthBasic:
+	default:
+		return fmt.Errorf("invalid auth mode: %s", a.Mode)
+	}
+	return nil
+}
+
+// 路由的认证方式：public 路由等同于 none
+func (route *RouteConfig) authMode() string {
+	if route == nil {
+		return routeAuthDefault
+	}
+	if route.Public {
+		return routeAuthNone
+	}
+	if route.Auth == nil {
+		return routeAuthDefault
+	}
+	return route.Auth.Mode
+}
+
+// 认证前按租户请求头预先匹配路由，用于选择认证方式；未匹配时使用全局认证链
+func (dr *DistributedRouter) routeForAuth(r *http.Request) *RouteConfig {
+	probe := &gatewayPrincipal{}
+	if err := resolveTenant(r, probe); err != nil {
+		return nil
+	}
+	route, _ := dr.matchNormalizedRoute(matchPath(r), r.Method, probe.Tenant, requestHost(r))
+	return route
+}
+
+// 按路由的认证方式认证请求
+func (dr *DistributedRouter) authenticateRoute(r *http.Request, route *RouteConfig) (*gatewayPrincipal, error) {
+	settings := gatewaySettings()
+	switch route.authMode() {
+	case routeAuthNone:
+		return &gatewayPrincipal{Name: anonymousPrincipal, Method: routeAuthNone}, nil
+	case routeAuthKey:
+		principal, ok := dr.authenticateGatewayRequest(r)
+		if !ok {
+			return nil, errInvalidGatewayKey
+		}
+		return principal, nil
+	case routeAuthJWT:
+		token, ok := bearerToken(r)
+		if !ok || !settings.OAuth.Enabled {
+			return nil, errAccessTokenRequired
+		}
+		return dr.verifyBearerToken(r, settings, token)
+	case routeAuthHMAC:
+		if r.Header.Get("X-Gateway-Signature") == "" {
+			return nil, errSignatureRequired
+		}
+		return dr.verifyRequestSignature(r, settings.RequestSigning.MaxSkew, settings.RequestSigning.Keys)
+	case routeAuthBasic:
+		return dr.verifyBasicAuth(r, settings, route.Auth.Credentials)
+	default:
+		return dr.verifyGatewayRequest(r)
+	}
+}
+
+// 调用方的认证方式是否满足路由要求（认证后按解析出的租户重新匹配的路由可能与预匹配的不同）
+func (p *gatewayPrincipal) authenticatedFor(route *RouteConfig) bool {
+	if p == nil {
+		return true
+	}
+	switch mode := route.authMode(); mode {
+	case routeAuthNone:
+		return true
+	case routeAuthDefault:
+		return p.Method != routeAuthBasic && p.Method != routeAuthNone
+	case routeAuthBasic:
+		return p.Method == routeAuthBasic && (len(route.Auth.Credentials) == 0 || slices.Contains(route.Auth.Credentials, p.Name))
+	default:
+		return p.Method == mode
+	}
+}
+
+// 认证失败的响应：basic 路由带 WWW-Authenticate 以便客户端提示输入凭据
+func writeRouteAuthError(w http.ResponseWriter, route *RouteConfig, err error) {
+	if route.authMode() == routeAuthBasic {
+		realm := gatewaySettings().BasicAuth.Realm
+		if realm == "" {
+			realm = "dify-router"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
+}
+
+// 校验 HTTP Basic 凭据：密码与 bcrypt 哈希比较（可带 pepper），兼容旧的 hashAPIKey 哈希
+func (dr *DistributedRouter) verifyBasicAuth(r *http.Request, settings static.GatewayConfig, allowed []string) (*gatewayPrincipal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, errBasicAuthRequired
+	}
+
+	name, passwordHash, found := dr.basicCredential(settings, username)
+	if !found || (len(allowed) > 0 && !slices.Contains(allowed, name)) {
+		return nil, errInvalidBasicAuth
+	}
+
+	pepper := ""
+	if settings.APIKeyPepper != "" {
+		var err error
+		pepper, err = dr.secrets.Resolve(r.Context(), settings.APIKeyPepper)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth unavailable: %v", err)
+		}
+	}
+	if !basicPasswordMatches(passwordHash, pepper, password) {
+		return nil, errInvalidBasicAuth
+	}
+	return &gatewayPrincipal{Name: name, Method: routeAuthBasic}, nil
+}
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 批量变更的操作
+const (
+	routeBatchCreate = "create"
+	routeBatchUpdate = "update"
+	routeBatchDelete = "delete"
+)
+
+// 单个批量变更中的最大操作数
+const maxRouteBatchOperations = 1000
+
+// 🔧 新增：批量变更中有错误，整批都没有写入
+var errRouteBatchRejected = fmt.Errorf("batch rejected; no changes were applied")
+
+// 🔧 新增：批量变更中的一个操作。create 和 update 需要 route（update 的 id 可省略，取 route.id），delete 只需要 id
+type RouteBatchOperation struct {
+	Op    string       `json:"op"`
+	ID    string       `json:"id,omitempty"`
+	Route *RouteConfig `json:"route,omitempty"`
+}
+
+// 🔧 新增：原子地执行一组新增、更新和删除：先在路由表副本上逐个校验，任何操作出错时整批拒绝；
+// 通过后在一个 Redis 事务中写入，发布一个 BATCH 事件，并一次性替换路由表。同一路由在一批中只能出现一次
+func (rm *RouteManager) ApplyRouteBatch(operations []RouteBatchOperation, force, dryRun bool) (*RouteImportResult, error) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	return rm.applyRouteBatchLocked(operations, force, dryRun)
+}
+
+// 调用方需持有 rm.mutex
+func (rm *RouteManager) applyRouteBatchLocked(operations []RouteBatchOperation, force, dryRun bool) (*RouteImportResult, error) {
+	result := newRouteBatchResult(dryRun)
+
+	current := rm.snapshot()
+	next := current.clone()
+	events := make([]RouteEvent, 0, len(operations))
+	prepared := make(map[string]RouteConfig, len(operations)) // 写入的路由（包括不常驻内存的代码）
+	seen := make(map[string]bool, len(operations))
+	now := time.Now()
+
+	for i, operation := range operations {
+		key := fmt.Sprintf("operations[%d]", i)
+		id := operation.ID
+		if id == "" && operation.Route != nil {
+			id = operation.Route.ID
+		}
+		if id == "" {
+			result.Errors[key] = "route ID is required"
+			continue
+		}
+		if seen[id] {
+			result.Errors[key] = fmt.Sprintf("route %s appears more than once in the batch", id)
+			continue
+		}
+		seen[id] = true
+		previous, exists := current.get(id)
+
+		if operation.Op == routeBatchDelete {
+			if !exists {
+				result.Errors[key] = fmt.Sprintf("route %s not found", id)
+				continue
+			}
+			next.remove(id)
+			result.Deleted = append(result.Deleted, id)
+			events = append(events, RouteEvent{EventType: "DELETE", RouteID: id})
+			continue
+		}
+
+		if operation.Op != routeBatchCreate && operation.Op != routeBatchUpdate {
+			result.Errors[key] = fmt.Sprintf("invalid op: %q (use create, update or delete)", operation.Op)
+			continue
+		}
+		if operation.Route == nil {
+			result.Errors[key] = fmt.Sprintf("%s requires route", operation.Op)
+			continue
+		}
+		route := *operation.Route
+		if route.ID == "" {
+			route.ID = id
+		}
+		if route.ID != id {
+			result.Errors[key] = "route ID cannot be changed"
+			continue
+		}
+		switch {
+		case operation.Op == routeBatchCreate && exists:
+			result.Errors[key] = fmt.Sprintf("route %s already exists", id)
+			continue
+		case operation.Op == routeBatchUpdate && !exists:
+			result.Errors[key] = fmt.Sprintf("route %s not found", id)
+			continue
+		}
+		if err := rm.validateRouteConfiguration(route); err != nil {
+			result.Errors[key] = err.Error()
+			continue
+		}
+
+		route.UpdatedAt = now.Unix()
+		route.Version = time.Now().UnixNano()
+		event := RouteEvent{EventType: "CREATE", RouteID: id}
+		if exists {
+			if code, err := rm.resolveCode(&previous); err == nil {
+				previous.Code = code
+			}
+			if route.CreatedAt == 0 {
+				route.CreatedAt = previous.CreatedAt
+			}
+			if changes := diffRoutes(previous, route); len(changes) > 0 {
+				result.Changes[id] = changes
+				event.Changes = changes
+			}
+			event.EventType = "UPDATE"
+			result.Updated = append(result.Updated, id)
+		} else {
+			if route.CreatedAt == 0 {
+				route.CreatedAt = now.Unix()
+			}
+			result.Created = append(result.Created, id)
+		}
+		prepared[id] = route
+		next.put(id, route)
+		events = append(events, event)
+	}
+
+	// 重叠和默认路由按变更后的路由表检查，批内的路由之间同样会被发现
+	for _, id := range append(result.Created, result.Updated...) {
+		if err := defaultRouteConflict(next, next.routes[id]); err != nil {
+			result.Errors[id] = err.Error()
+		}
+		conflicts := routeConflicts(next, next.routes[id])
+		if len(conflicts) == 0 {
+			continue
+		}
+		result.Warnings[id] = conflicts
+		for _, conflict := range conflicts {
+			if conflict.Ambiguous && !force {
+				result.Errors[id] = errAmbiguousRoute.Error()
+				break
+			}
+		}
+	}
+	if limit := gatewaySettings().MaxCacheMemory; limit > 0 && next.memoryBytes > limit {
+		return result, fmt.Errorf("route cache memory limit exceeded: %d > %d bytes", next.memoryBytes, limit)
+	}
+	if len(result.Errors) > 0 {
+		return result, errRouteBatchRejected
+	}
+	if dryRun || len(events) == 0 {
+		return result, nil
+	}
+
+	// 同一个事务写入全部变更，失败时 Redis 和内存中的路由表都不变
+	if rm.redisEnabled {
+		ctx := context.Background()
+		pipe := rm.redisClient.TxPipeline()
+		for i := range events {
+			event := &events[i]
+			if event.EventType == "DELETE" {
+				pipe.HDel(ctx, "gateway:routes", event.RouteID)
+				pipe.SAdd(ctx, "gateway:routes:updated", "DELETE:"+event.RouteID)
+				continue
+			}
+			route := prepared[event.RouteID]
+			routeJSON, err := encodeStoredRoute(route)
+			if err != nil {
+				return result, fmt.Errorf("failed to encode route %s: %v", route.ID, err)
+			}
+			pipe.HSet(ctx, "gateway:routes", route.ID, routeJSON)
+			pipe.SAdd(ctx, "gateway:routes:updated", route.ID)
+			event.RouteData = &route
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return result, fmt.Errorf("failed to save routes to Redis: %v", err)
+		}
+		rm.updateConfigVersion()
+
+		// 其他实例收到一个事件后一次性应用全部变更
+		rm.publishRouteEvent(&RouteEvent{
+			EventID:   fmt.Sprintf("batch-%d", now.UnixNano()),
+			EventType: "BATCH",
+			Batch:     events,
+			Timestamp: now.Unix(),
+			Source:    rm.instanceID,
+		})
+	}
+
+	rm.storeTable(next)
+	select {
+	case rm.updateChannel <- struct{}{}:
+	default:
+	}
+	return result, nil
+}
+
+func newRouteBatchResult(dryRun bool) *RouteImportResult {
+	return &RouteImportResult{
+		RouteApplySummary: RouteApplySummary{
+			Created: make([]string, 0),
+			Updated: make([]string, 0),
+			Deleted: make([]string, 0),
+			Errors:  make(map[string]string),
+		},
+		DryRun:   dryRun,
+		Changes:  make(map[string]map[string]FieldChange),
+		Warnings: make(map[string][]RouteConflict),
+	}
+}
+
+// 批量变更的错误按操作序号（operations[序号]）记录，导入和 apply 换成调用方文档中的键
+func rekeyRouteBatchErrors(result *RouteImportResult, keys []string) {
+	for key, message := range result.Errors {
+		index, ok := strings.CutPrefix(key, "operations[")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+		if err != nil || i < 0 || i >= len(keys) {
+			continue
+		}
+		delete(result.Errors, key)
+		result.Errors[keys[i]] = message
+	}
+}
+
+// 🔧 新增：应用其他实例发布的 BATCH 事件，所有变更在同一个路由表快照中生效
+func (h *RouteEventHandler) handleBatchEvent(event *RouteEvent) error {
+	rm := h.routeManager
+	// 先校验全部操作再写入，批量事件整体应用或整体跳过（暂存快照中不会留下一半的变更）
+	for _, change := range event.Batch {
+		switch change.EventType {
+		case "CREATE", "UPDATE":
+			if change.RouteData == nil {
+				return fmt.Errorf("missing route data for %s %s in BATCH event", change.EventType, change.RouteID)
+			}
+		case "DELETE":
+		default:
+			return fmt.Errorf("invalid operation %s in BATCH event", change.EventType)
+		}
+	}
+
+	// 🔧 修改：由 HandleEvents 在写锁内调用，变更写入暂存快照，与同一批的其他事件一起发布
+	next := rm.stagedTable()
+	for _, change := range event.Batch {
+		if change.EventType == "DELETE" {
+			next.remove(change.RouteID)
+		} else {
+			next.put(change.RouteID, *change.RouteData)
+		}
+	}
+	opLogf(logCategoryEvent, logLevelInfo, "✅ [BATCH] 批量变更已应用: %d 个操作 (事件ID: %s)", len(event.Batch), event.EventID)
+	return nil
+}
+
+// 🔧 新增：POST /admin/routes/batch?dry_run=true&force=true
+// {"operations": [{"op": "create", "route": {...}}, {"op": "update", "route": {...}}, {"op": "delete", "id": "old"}]}
+func (dr *DistributedRouter) routeBatchHandler(c *gin.Context) {
+	var request struct {
+		Operations []RouteBatchOperation `json:"operations"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Operations) == 0 {
+		c.JSON(400, gin.H{"error": "operations is required"})
+		return
+	}
+	if len(request.Operations) > maxRouteBatchOperations {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("a batch can contain at most %d operations", maxRouteBatchOperations)})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := dr.routeManager.ApplyRouteBatch(request.Operations, c.Query("force") == "true", dryRun)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if dryRun {
+		c.JSON(200, gin.H{"dry_run": true, "result": result})
+		return
+	}
+
+	c.Set(auditMessageKey, fmt.Sprintf("applied route batch (%d created, %d updated, %d deleted)",
+		len(result.Created), len(result.Updated), len(result.Deleted)))
+	c.JSON(200, gin.H{"message": "batch applied", "result": result})
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 字段修改前后的值
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// 每次更新都会变化、不计入差异的字段
+var routeDiffIgnored = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// 超过该长度的字符串（通常是代码）在差异中以长度和摘要表示
+const routeDiffMaxValue = 256
+
+// 🔧 新增：计算路由两个版本之间修改的字段（按 JSON 字段名）
+func diffRoutes(previous, next RouteConfig) map[string]FieldChange {
+	before, after := routeFields(previous), routeFields(next)
+	changes := make(map[string]FieldChange)
+	for name, value := range after {
+		if routeDiffIgnored[name] || reflect.DeepEqual(before[name], value) {
+			continue
+		}
+		changes[name] = FieldChange{From: summarizeDiffValue(before[name]), To: summarizeDiffValue(value)}
+	}
+	for name, value := range before {
+		if _, exists := after[name]; !exists && !routeDiffIgnored[name] {
+			changes[name] = FieldChange{From: summarizeDiffValue(value), To: nil}
+		}
+	}
+	return changes
+}
+
+func routeFields(route RouteConfig) map[string]interface{} {
+	data, _ := json.Marshal(route)
+	fields := make(map[string]interface{})
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+func summarizeDiffValue(value interface{}) interface{} {
+	if text, ok := value.(string); ok && len(text) > routeDiffMaxValue {
+		sum := sha256.Sum256([]byte(text))
+		return fmt.Sprintf("<%d bytes, sha256:%x>", len(text), sum[:8])
+	}
+	return value
+}
+
+// 形如 "<prefix> method: \"GET\" -> \"POST\"; timeout: 30 -> 60"，字段按名称排序
+func describeFieldChanges(prefix string, changes map[string]FieldChange) string {
+	changes = currentRedactor().fieldChanges(changes) // 🔧 新增：按 gateway.redaction 的 JSON 路径脱敏
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, fmt.Sprintf("%s: %s -> %s", field, diffValueText(changes[field].From), diffValueText(changes[field].To)))
+	}
+	return prefix + " " + strings.Join(parts, "; ")
+}
+
+func diffValueText(value interface{}) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(b.String(), "\n")
+}
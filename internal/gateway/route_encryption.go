@@ -0,0 +1,325 @@
+package gateway
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	routeEncryptedField = "encrypted" // 存储的路由 JSON 中保存加密字段的键
+	routeEncryptedValue = "[ENCRYPTED]"
+)
+
+// 不能加密的字段：路由标识和迁移需要读取的版本信息
+var routeEncryptionReserved = map[string]bool{
+	"id":         true,
+	"version":    true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// 存储的加密字段
+type sealedRouteFields struct {
+	KeyID   string            `json:"key_id"`
+	DataKey string            `json:"data_key"` // 主密钥加密的数据密钥（nonce + 密文，base64）
+	Fields  map[string]string `json:"fields"`   // 字段名 -> 数据密钥加密的字段 JSON（nonce + 密文，base64）
+}
+
+// 🔧 新增：路由字段信封加密
+type routeCipher struct {
+	fields   []string
+	activeID string
+	keys     map[string]cipher.AEAD // 主密钥 ID -> AES-256-GCM
+}
+
+// 当前生效的加密配置，未启用时为 nil
+var activeRouteCipher atomic.Pointer[routeCipher]
+
+// 按配置初始化路由加密，必须在加载路由之前调用
+func initRouteEncryption(config static.RouteEncryptionConfig) error {
+	if !config.Enabled {
+		activeRouteCipher.Store(nil)
+		return nil
+	}
+
+	c := &routeCipher{fields: config.Fields, activeID: config.ActiveKey, keys: make(map[string]cipher.AEAD)}
+	if len(c.fields) == 0 {
+		c.fields = []string{"code", "metadata"}
+	}
+	for _, field := range c.fields {
+		if routeEncryptionReserved[field] {
+			return fmt.Errorf("route_encryption: field %s cannot be encrypted", field)
+		}
+	}
+
+	resolver := NewSecretResolver(nil, false)
+	for _, key := range config.Keys {
+		// 主密钥不能和密文一起保存在 Redis 中，也不要以明文写在配置文件里
+		if !strings.HasPrefix(key.Key, "env:") && !strings.HasPrefix(key.Key, "file:") {
+			return fmt.Errorf("route_encryption: key %s must be an env: or file: reference", key.ID)
+		}
+		value, err := resolver.Resolve(context.Background(), key.Key)
+		if err != nil {
+			return fmt.Errorf("route_encryption: key %s: %v", key.ID, err)
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("route_encryption: key %s must be 32 bytes encoded in base64", key.ID)
+		}
+		aead, err := newRouteAEAD(raw)
+		if err != nil {
+			return err
+		}
+		c.keys[key.ID] = aead
+	}
+	if c.keys[c.activeID] == nil {
+		return fmt.Errorf("route_encryption: active_key %q is not configured", c.activeID)
+	}
+
+	activeRouteCipher.Store(c)
+	log.Printf("🔐 Route encryption enabled (fields %v, key %s)", c.fields, c.activeID)
+	return nil
+}
+
+func newRouteAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 加密并 base64 编码（nonce 在密文之前）
+func sealValue(aead cipher.AEAD, plaintext, additional []byte) string {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additional))
+}
+
+func openValue(aead cipher.AEAD, sealed string, additional []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed ciphertext")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+}
+
+// 加密路由的指定字段，返回存储用的 JSON；字段密文绑定路由ID，不能挪到其他路由下解密
+func (c *routeCipher) seal(route RouteConfig) ([]byte, error) {
+	fields := routeFields(route)
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newRouteAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := sealedRouteFields{
+		KeyID:   c.activeID,
+		DataKey: sealValue(c.keys[c.activeID], dataKey, []byte(c.activeID)),
+		Fields:  make(map[string]string),
+	}
+	for _, name := range c.fields {
+		value, exists := fields[name]
+		if !exists {
+			continue
+		}
+		plaintext, _ := json.Marshal(value)
+		sealed.Fields[name] = sealValue(aead, plaintext, []byte(route.ID+"\x00"+name))
+		delete(fields, name)
+	}
+	if len(sealed.Fields) > 0 {
+		fields[routeEncryptedField] = sealed
+	}
+	return json.Marshal(fields)
+}
+
+// 解密存储的路由 JSON
+func (c *routeCipher) open(data []byte) (RouteConfig, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return RouteConfig{}, err
+	}
+	var route RouteConfig
+	sealedJSON, encrypted := fields[routeEncryptedField]
+	if !encrypted {
+		err := json.Unmarshal(data, &route)
+		return route, err
+	}
+	if c == nil {
+		return RouteConfig{}, fmt.Errorf("route is encrypted but gateway.route_encryption is not enabled")
+	}
+
+	var sealed sealedRouteFields
+	if err := json.Unmarshal(sealedJSON, &sealed); err != nil {
+		return RouteConfig{}, fmt.Errorf("malformed encrypted fields: %v", err)
+	}
+	keyAEAD := c.keys[sealed.KeyID]
+	if keyAEAD == nil {
+		return RouteConfig{}, fmt.Errorf("unknown encryption key: %s", sealed.KeyID)
+	}
+	dataKey, err := openValue(keyAEAD, sealed.DataKey, []byte(sealed.KeyID))
+	if err != nil {
+		return RouteConfig{}, fmt.Errorf("failed to decrypt data key: %v", err)
+	}
+	aead, err := newRouteAEAD(dataKey)
+	if err != nil {
+		return RouteConfig{}, err
+	}
+
+	var id string
+	json.Unmarshal(fields["id"], &id)
+	delete(fields, routeEncryptedField)
+	for name, value := range sealed.Fields {
+		plaintext, err := openValue(aead, value, []byte(id+"\x00"+name))
+		if err != nil {
+			return RouteConfig{}, fmt.Errorf("failed to decrypt field %s: %v", name, err)
+		}
+		fields[name] = plaintext
+	}
+	decrypted, _ := json.Marshal(fields)
+	err = json.Unmarshal(decrypted, &route)
+	return route, err
+}
+
+// 是否加密该字段
+func (c *routeCipher) encrypts(field string) bool {
+	if c == nil {
+		return false
+	}
+	for _, name := range c.fields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// 🔧 新增：路由写入 Redis 前的编码，启用加密时指定字段以密文保存
+func encodeStoredRoute(route RouteConfig) ([]byte, error) {
+	if c := activeRouteCipher.Load(); c != nil {
+		return c.seal(route)
+	}
+	return json.Marshal(route)
+}
+
+// 🔧 新增：解码 Redis 中的路由，兼容加密前写入的明文路由
+func decodeStoredRoute(data []byte) (RouteConfig, error) {
+	return activeRouteCipher.Load().open(data)
+}
+
+type routeEventJSON RouteEvent
+
+// 启用加密时，路由事件中的路由数据同样以存储格式（加密）发布，修改的字段中加密字段的值被隐藏
+func (e RouteEvent) MarshalJSON() ([]byte, error) {
+	c := activeRouteCipher.Load()
+	if c == nil || e.RouteData == nil {
+		return json.Marshal(routeEventJSON(e))
+	}
+	sealed, err := c.seal(*e.RouteData)
+	if err != nil {
+		return nil, err
+	}
+	e.RouteData = nil
+	if len(e.Changes) > 0 {
+		changes := make(map[string]FieldChange, len(e.Changes))
+		for name, change := range e.Changes {
+			if c.encrypts(name) {
+				change = FieldChange{From: routeEncryptedValue, To: routeEncryptedValue}
+			}
+			changes[name] = change
+		}
+		e.Changes = changes
+	}
+	return json.Marshal(struct {
+		routeEventJSON
+		SealedRoute json.RawMessage `json:"sealed_route"`
+	}{routeEventJSON(e), sealed})
+}
+
+func (e *RouteEvent) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		routeEventJSON
+		SealedRoute json.RawMessage `json:"sealed_route,omitempty"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*e = RouteEvent(decoded.routeEventJSON)
+	if len(decoded.SealedRoute) > 0 {
+		route, err := decodeStoredRoute(decoded.SealedRoute)
+		if err != nil {
+			return err
+		}
+		e.RouteData = &route
+	}
+	return nil
+}
+
+// 🔧 新增：用当前主密钥重新加密 Redis 中的全部路由（密钥轮换或开启加密后执行），路由版本不变、不发布事件
+func (rm *RouteManager) reencryptRoutes(ctx context.Context) (int, map[string]string, error) {
+	stored, err := rm.redisClient.HGetAll(ctx, "gateway:routes").Result()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to load routes from Redis: %v", err)
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	count := 0
+	failures := make(map[string]string)
+	for routeID, routeJSON := range stored {
+		route, err := decodeStoredRoute([]byte(routeJSON))
+		if err != nil {
+			failures[routeID] = err.Error()
+			continue
+		}
+		encoded, err := encodeStoredRoute(route)
+		if err != nil {
+			failures[routeID] = err.Error()
+			continue
+		}
+		// 只在路由未被并发修改时写回
+		current, err := rm.redisClient.HGet(ctx, "gateway:routes", routeID).Result()
+		if err != nil || current != routeJSON {
+			continue
+		}
+		if err := rm.redisClient.HSet(ctx, "gateway:routes", routeID, encoded).Err(); err != nil {
+			failures[routeID] = err.Error()
+			continue
+		}
+		count++
+	}
+	return count, failures, nil
+}
+
+// 🔧 新增：重新加密全部路由
+func (dr *DistributedRouter) reencryptRoutesHandler(c *gin.Context) {
+	if !dr.routeManager.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+	if activeRouteCipher.Load() == nil {
+		c.JSON(400, gin.H{"error": "gateway.route_encryption is not enabled"})
+		return
+	}
+	count, failures, err := dr.routeManager.reencryptRoutes(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"reencrypted": count, "errors": failures})
+}
@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+// 生成测试用的随机主密钥
+func newTestRouteAEAD(t *testing.T) cipher.AEAD {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	aead, err := newRouteAEAD(key)
+	if err != nil {
+		t.Fatalf("new aead: %v", err)
+	}
+	return aead
+}
+
+func TestRouteCipherSealOpenWithKeyRotation(t *testing.T) {
+	k1, k2 := newTestRouteAEAD(t), newTestRouteAEAD(t)
+	route := RouteConfig{
+		ID:       "orders",
+		Path:     "/orders",
+		Code:     "secret-code",
+		Target:   "http://orders.internal",
+		Metadata: map[string]string{"token": "secret-token"},
+	}
+
+	before := &routeCipher{fields: []string{"code", "metadata"}, activeID: "k1", keys: map[string]cipher.AEAD{"k1": k1}}
+	sealedK1, err := before.seal(route)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealedK1, []byte("secret")) {
+		t.Fatalf("sealed route leaks plaintext: %s", sealedK1)
+	}
+
+	// 轮换：k2 成为当前密钥，k1 仍在密钥环中
+	rotated := &routeCipher{fields: before.fields, activeID: "k2", keys: map[string]cipher.AEAD{"k1": k1, "k2": k2}}
+	sealedK2, err := rotated.seal(route)
+	if err != nil {
+		t.Fatalf("seal after rotation: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cipher  *routeCipher
+		data    []byte
+		wantErr bool
+	}{
+		{name: "same key", cipher: before, data: sealedK1},
+		{name: "old key after rotation", cipher: rotated, data: sealedK1},
+		{name: "new key after rotation", cipher: rotated, data: sealedK2},
+		{name: "old key removed", cipher: &routeCipher{fields: before.fields, activeID: "k2", keys: map[string]cipher.AEAD{"k2": k2}}, data: sealedK1, wantErr: true},
+		{name: "encryption disabled", cipher: nil, data: sealedK2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.open(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("open succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if got.Code != route.Code || got.Target != route.Target || got.Metadata["token"] != "secret-token" {
+				t.Fatalf("open = %+v, want %+v", got, route)
+			}
+		})
+	}
+}
+
+func TestRouteCipherRejectsMovedFields(t *testing.T) {
+	c := &routeCipher{fields: []string{"code"}, activeID: "k1", keys: map[string]cipher.AEAD{"k1": newTestRouteAEAD(t)}}
+	sealed, err := c.seal(RouteConfig{ID: "orders", Path: "/orders", Code: "secret-code"})
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	// 密文绑定路由ID，改到其他路由下无法解密
+	var fields map[string]json.RawMessage
+	json.Unmarshal(sealed, &fields)
+	fields["id"] = json.RawMessage(`"payments"`)
+	moved, _ := json.Marshal(fields)
+	if _, err := c.open(moved); err == nil {
+		t.Fatalf("open succeeded for a route moved to another id")
+	}
+}
+
+func TestScheduledChangeStorageSealsRoute(t *testing.T) {
+	c := &routeCipher{fields: []string{"code"}, activeID: "k1", keys: map[string]cipher.AEAD{"k1": newTestRouteAEAD(t)}}
+	activeRouteCipher.Store(c)
+	defer activeRouteCipher.Store(nil)
+
+	change := &ScheduledChange{ID: "c1", Action: scheduledUpdate, RouteID: "orders", Status: "pending",
+		Route: &RouteConfig{ID: "orders", Path: "/orders", Code: "secret-code"}}
+	data, err := encodeScheduledChange(change)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-code")) {
+		t.Fatalf("stored change leaks plaintext: %s", data)
+	}
+
+	decoded, err := decodeScheduledChange(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Route == nil || decoded.Route.Code != "secret-code" || decoded.Status != "pending" {
+		t.Fatalf("decode = %+v", decoded)
+	}
+
+	// 加密前写入的明文变更仍可读取
+	legacy, _ := json.Marshal(change)
+	decoded, err = decodeScheduledChange(legacy)
+	if err != nil || decoded.Route == nil || decoded.Route.Code != "secret-code" {
+		t.Fatalf("decode legacy = %+v, %v", decoded, err)
+	}
+}
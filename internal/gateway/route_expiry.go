@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"log"
+	"time"
+)
+
+// 🔧 新增：主节点定期删除已过期的路由（expires_at 已过），删除经 DELETE 事件同步到其他实例。
+// 过期路由在到期时刻已停止匹配，清理只负责从 Redis 和路由表中移除
+func (dr *DistributedRouter) runRouteExpiry() {
+	interval := gatewaySettings().RouteExpiry.SweepInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !dr.leader.IsLeader() {
+			continue
+		}
+		dr.sweepExpiredRoutes(time.Now().Unix())
+	}
+}
+
+// 删除到期的路由，返回删除的数量
+func (dr *DistributedRouter) sweepExpiredRoutes(now int64) int {
+	deleted := 0
+	for _, routeID := range dr.routeManager.snapshot().expired(now) {
+		// 清理期间路由可能已被更新（延长了有效期），删除前按最新快照再确认一次
+		route, exists := dr.routeManager.snapshot().get(routeID)
+		if !exists || route.ExpiresAt == 0 || route.ExpiresAt > now {
+			continue
+		}
+		if err := dr.routeManager.DeleteRoute(routeID); err != nil {
+			log.Printf("❌ Failed to delete expired route %s: %v", routeID, err)
+			continue
+		}
+		log.Printf("⌛ Expired route deleted: %s (expired at %s)", routeID, time.Unix(route.ExpiresAt, 0).UTC().Format(time.RFC3339))
+		deleted++
+	}
+	return deleted
+}
+
+// 生效时间窗口状态：pending（未到 active_from）、active 或 expired，没有设置窗口时为空
+func (route *RouteConfig) windowStatus(now int64) string {
+	switch {
+	case route.ActiveFrom == 0 && route.ExpiresAt == 0:
+		return ""
+	case route.ActiveFrom > 0 && now < route.ActiveFrom:
+		return "pending"
+	case route.ExpiresAt > 0 && now >= route.ExpiresAt:
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// 两条路由的生效时间窗口是否相交（未设置的一端视为不限）
+func routeWindowsOverlap(a, b RouteConfig) bool {
+	if a.ExpiresAt > 0 && b.ActiveFrom > 0 && a.ExpiresAt <= b.ActiveFrom {
+		return false
+	}
+	if b.ExpiresAt > 0 && a.ActiveFrom > 0 && b.ExpiresAt <= a.ActiveFrom {
+		return false
+	}
+	return true
+}
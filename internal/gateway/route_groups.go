@@ -0,0 +1,386 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	routeGroupsRedisKey     = "gateway:route-groups"
+	routeGroupRateKeyPrefix = "gateway:route-groups:rate:"
+	routeGroupRefreshPeriod = 5 * time.Second
+)
+
+// 🔧 新增：路由分组：成员路由通过 group_id 引用分组，匹配时路径为分组前缀加路由路径，
+// 并继承分组的默认超时、调用方限制和限流（分组内所有成员路由共享每分钟的请求额度）
+type RouteGroup struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Prefix      string   `json:"prefix,omitempty"`     // 成员路由的路径前缀，如 /api/v2
+	Timeout     int      `json:"timeout,omitempty"`    // 成员路由未设置 timeout 时使用
+	APIKeys     []string `json:"api_keys,omitempty"`   // 只允许这些调用方（消费者 Key 名称）调用成员路由，为空时不限制
+	RateLimit   int      `json:"rate_limit,omitempty"` // 成员路由合计每分钟最多请求数（所有实例共享），0 表示不限制
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+}
+
+func (g *RouteGroup) validate() error {
+	if g.Prefix != "" {
+		if !strings.HasPrefix(g.Prefix, "/") || strings.HasSuffix(g.Prefix, "/") || strings.Contains(g.Prefix, "//") || strings.ContainsAny(g.Prefix, "{}*") {
+			return fmt.Errorf("invalid group prefix: %s (expected /segment[/segment...] without parameters or wildcards)", g.Prefix)
+		}
+	}
+	if g.Timeout < 0 {
+		return fmt.Errorf("group timeout must not be negative")
+	}
+	if g.RateLimit < 0 {
+		return fmt.Errorf("group rate_limit must not be negative")
+	}
+	return nil
+}
+
+// 路由匹配使用的完整路径（分组前缀 + 路由路径；🔧 正则路由为分组前缀 + 去掉 ^、$ 的正则）
+func (route *RouteConfig) fullPath() string {
+	if route.PathRegex != "" {
+		return route.groupPrefix + pathRegexExpr(route.PathRegex)
+	}
+	return route.groupPrefix + route.Path
+}
+
+// 分组限流的本地计数窗口（Redis 不可用时使用）
+type routeGroupRateWindow struct {
+	minute int64
+	count  int
+}
+
+// 路由分组存储：分组保存在 Redis 哈希中，各实例定时刷新本地副本；未启用 Redis 时只保存在本地内存
+type RouteGroupStore struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	groups       map[string]*RouteGroup
+	windows      map[string]map[string]*routeGroupRateWindow // 分组ID -> 租户 -> 本地计数窗口
+	mutex        sync.RWMutex
+}
+
+func NewRouteGroupStore(redisClient *redis.Client, redisEnabled bool) *RouteGroupStore {
+	store := &RouteGroupStore{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		groups:       make(map[string]*RouteGroup),
+		windows:      make(map[string]map[string]*routeGroupRateWindow),
+	}
+	if redisEnabled {
+		store.refresh()
+	}
+	return store
+}
+
+// 从 Redis 重新加载分组
+func (s *RouteGroupStore) refresh() {
+	stored, err := s.redisClient.HGetAll(context.Background(), routeGroupsRedisKey).Result()
+	if err != nil {
+		log.Printf("Failed to load route groups: %v", err)
+		return
+	}
+	groups := make(map[string]*RouteGroup, len(stored))
+	for id, groupJSON := range stored {
+		var group RouteGroup
+		if err := json.Unmarshal([]byte(groupJSON), &group); err != nil {
+			continue
+		}
+		groups[id] = &group
+	}
+
+	s.mutex.Lock()
+	s.groups = groups
+	s.mutex.Unlock()
+}
+
+// 获取分组（本地副本）
+func (s *RouteGroupStore) get(id string) *RouteGroup {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.groups[id]
+}
+
+// 分组是否存在：本地副本中没有时直接查询 Redis（其他实例刚创建的分组）
+func (s *RouteGroupStore) exists(ctx context.Context, id string) bool {
+	if s.get(id) != nil {
+		return true
+	}
+	if !s.redisEnabled {
+		return false
+	}
+	exists, err := s.redisClient.HExists(ctx, routeGroupsRedisKey, id).Result()
+	return err == nil && exists
+}
+
+// 分组ID -> 前缀，只包含已存在的分组
+func (s *RouteGroupStore) prefixes() map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	prefixes := make(map[string]string, len(s.groups))
+	for id, group := range s.groups {
+		prefixes[id] = group.Prefix
+	}
+	return prefixes
+}
+
+// 列出分组，按ID排序
+func (s *RouteGroupStore) list() []*RouteGroup {
+	s.mutex.RLock()
+	groups := make([]*RouteGroup, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	s.mutex.RUnlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+// 保存分组（同ID分组被替换）
+func (s *RouteGroupStore) put(ctx context.Context, group *RouteGroup) error {
+	now := time.Now().Unix()
+	group.CreatedAt = now
+	if existing := s.get(group.ID); existing != nil {
+		group.CreatedAt = existing.CreatedAt
+	}
+	group.UpdatedAt = now
+
+	if s.redisEnabled {
+		groupJSON, _ := json.Marshal(group)
+		if err := s.redisClient.HSet(ctx, routeGroupsRedisKey, group.ID, groupJSON).Err(); err != nil {
+			return fmt.Errorf("failed to save route group: %v", err)
+		}
+	}
+	s.mutex.Lock()
+	s.groups[group.ID] = group
+	s.mutex.Unlock()
+
+	log.Printf("🗂️  Route group %s saved (prefix %q)", group.ID, group.Prefix)
+	return nil
+}
+
+// 删除分组，返回是否存在
+func (s *RouteGroupStore) delete(ctx context.Context, id string) (bool, error) {
+	exists := s.get(id) != nil
+	if s.redisEnabled {
+		removed, err := s.redisClient.HDel(ctx, routeGroupsRedisKey, id).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to delete route group: %v", err)
+		}
+		exists = exists || removed > 0
+	}
+	s.mutex.Lock()
+	delete(s.groups, id)
+	delete(s.windows, id)
+	s.mutex.Unlock()
+
+	if exists {
+		log.Printf("🗂️  Route group %s deleted", id)
+	}
+	return exists, nil
+}
+
+// 按分组每分钟的请求上限限流：Redis 可用时所有实例共享计数，否则按实例计数。
+// 🔧 修改：计数按租户隔离，各租户分别享有分组的限额，一个租户耗尽限额不影响其他租户
+func (s *RouteGroupStore) allow(ctx context.Context, group *RouteGroup, tenant string, now time.Time) bool {
+	if group.RateLimit <= 0 {
+		return true
+	}
+	minute := now.Unix() / 60
+
+	if s.redisEnabled {
+		key := routeGroupRateKeyPrefix + group.ID + ":" + tenant + ":" + strconv.FormatInt(minute, 10)
+		pipe := s.redisClient.TxPipeline()
+		count := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, 2*time.Minute)
+		if _, err := pipe.Exec(ctx); err == nil {
+			return count.Val() <= int64(group.RateLimit)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tenants := s.windows[group.ID]
+	if tenants == nil {
+		tenants = make(map[string]*routeGroupRateWindow)
+		s.windows[group.ID] = tenants
+	}
+	window := tenants[tenant]
+	if window == nil || window.minute != minute {
+		// 新的一分钟开始时丢弃其他租户已过期的窗口，避免租户计数无限累积
+		for name, stale := range tenants {
+			if stale.minute != minute {
+				delete(tenants, name)
+			}
+		}
+		window = &routeGroupRateWindow{minute: minute}
+		tenants[tenant] = window
+	}
+	if window.count >= group.RateLimit {
+		return false
+	}
+	window.count++
+	return true
+}
+
+// 定时刷新分组，前缀变化时重建成员路由的索引
+func (rm *RouteManager) refreshRouteGroups() {
+	ticker := time.NewTicker(routeGroupRefreshPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		rm.routeGroups.refresh()
+		rm.syncGroupPrefixes()
+	}
+}
+
+// 分组前缀（或分组的增删）与当前路由表不一致时，发布按新前缀索引成员路由的快照
+func (rm *RouteManager) syncGroupPrefixes() {
+	prefixes := rm.routeGroups.prefixes()
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	current := rm.snapshot()
+	if maps.Equal(current.groupPrefixes, prefixes) {
+		return
+	}
+	next := current.clone()
+	next.groupPrefixes = prefixes
+	for id, route := range next.routes {
+		if route.GroupID != "" {
+			next.reindex(id)
+		}
+	}
+	rm.storeTable(next)
+}
+
+// 分组的成员路由ID（按ID排序）
+func (rm *RouteManager) groupMembers(groupID string) []string {
+	members := make([]string, 0)
+	for id, route := range rm.snapshot().routes {
+		if route.GroupID == groupID {
+			members = append(members, id)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// 🔧 新增：应用路由分组的调用方限制和限流，返回继承分组默认超时的路由；请求被拒绝时返回 nil
+func (dr *DistributedRouter) applyRouteGroup(route *RouteConfig, w http.ResponseWriter, r *http.Request) *RouteConfig {
+	group := dr.routeManager.routeGroups.get(route.GroupID)
+	if group == nil {
+		return route
+	}
+
+	if len(group.APIKeys) > 0 {
+		principal := principalFromRequest(r)
+		if principal == nil || !slices.Contains(group.APIKeys, principal.Name) {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(gin.H{"error": "api key not permitted for this route group"})
+			return nil
+		}
+	}
+
+	tenant := ""
+	if principal := principalFromRequest(r); principal != nil {
+		tenant = principal.Tenant
+	}
+	now := time.Now()
+	if !dr.routeManager.routeGroups.allow(r.Context(), group, tenant, now) {
+		w.Header().Set("Retry-After", strconv.FormatInt(60-now.Unix()%60, 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(gin.H{"error": "route group rate limit exceeded", "group_id": group.ID})
+		return nil
+	}
+
+	if route.Timeout == 0 && group.Timeout > 0 {
+		grouped := *route
+		grouped.Timeout = group.Timeout
+		route = &grouped
+	}
+	return route
+}
+
+// 🔧 新增：列出路由分组及成员数量
+func (dr *DistributedRouter) listRouteGroupsHandler(c *gin.Context) {
+	groups := dr.routeManager.routeGroups.list()
+	listings := make([]gin.H, 0, len(groups))
+	for _, group := range groups {
+		listings = append(listings, gin.H{"group": group, "routes": len(dr.routeManager.groupMembers(group.ID))})
+	}
+	c.JSON(200, gin.H{"groups": listings, "count": len(listings)})
+}
+
+// 🔧 新增：查看路由分组及成员路由
+func (dr *DistributedRouter) getRouteGroupHandler(c *gin.Context) {
+	group := dr.routeManager.routeGroups.get(c.Param("id"))
+	if group == nil {
+		c.JSON(404, gin.H{"error": "route group not found"})
+		return
+	}
+	c.JSON(200, gin.H{"group": group, "routes": dr.routeManager.groupMembers(group.ID)})
+}
+
+// 🔧 新增：创建或替换路由分组，前缀变化立即在本实例生效，其他实例在刷新周期内生效
+func (dr *DistributedRouter) putRouteGroupHandler(c *gin.Context) {
+	var group RouteGroup
+	if err := c.BindJSON(&group); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	group.ID = c.Param("id")
+	if strings.TrimSpace(group.ID) == "" {
+		c.JSON(400, gin.H{"error": "group ID is required"})
+		return
+	}
+	if err := group.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := dr.routeManager.routeGroups.put(c.Request.Context(), &group); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	dr.routeManager.syncGroupPrefixes()
+	c.JSON(200, gin.H{"message": "route group saved", "group": group})
+}
+
+// 🔧 新增：删除路由分组（仍有成员路由时返回 409）
+func (dr *DistributedRouter) deleteRouteGroupHandler(c *gin.Context) {
+	id := c.Param("id")
+	if members := dr.routeManager.groupMembers(id); len(members) > 0 {
+		c.JSON(409, gin.H{"error": "route group still has member routes", "routes": members})
+		return
+	}
+	exists, err := dr.routeManager.routeGroups.delete(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(404, gin.H{"error": "route group not found"})
+		return
+	}
+	dr.routeManager.syncGroupPrefixes()
+	c.JSON(200, gin.H{"message": "route group deleted"})
+}
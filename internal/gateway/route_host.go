@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 🔧 新增：按请求 Host 匹配路由
+// 路由的 host 为空时对所有域名生效；设置为 api.example.com 时只匹配该域名，*.example.com 匹配其任意子域名（不含 example.com 本身）。
+// 匹配类型相同时，精确域名的路由优先于通配域名的路由，通配域名的路由优先于不限域名的路由
+const (
+	routeHostExactBonus    = 4
+	routeHostWildcardBonus = 2
+)
+
+// 校验路由的 host：只允许主机名（不含协议和端口），通配符只能作为最左侧的 *. 出现
+func validateRouteHost(host string) error {
+	if host == "" {
+		return nil
+	}
+	name := strings.TrimPrefix(host, "*.")
+	if name == "" || strings.ContainsAny(name, "*/:@ ") || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid host: %s", host)
+	}
+	return nil
+}
+
+// 路由 host 与请求主机名（小写，不含端口）的匹配结果，返回优先级加成；不匹配时 ok 为 false
+func routeHostBonus(pattern, host string) (bonus int, ok bool) {
+	switch {
+	case pattern == "":
+		return 0, true
+	case strings.HasPrefix(pattern, "*."):
+		suffix := pattern[1:]
+		if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return routeHostWildcardBonus, true
+		}
+		return 0, false
+	case pattern == host:
+		return routeHostExactBonus, true
+	}
+	return 0, false
+}
+
+// 路由是否对该主机名可见
+func routeVisibleToHost(route *RouteConfig, host string) bool {
+	_, ok := routeHostBonus(strings.ToLower(route.Host), host)
+	return ok
+}
@@ -0,0 +1,200 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const routeImportMaxBody = 10 << 20
+
+// 🔧 新增：批量导入的路由中有错误，整批都没有写入
+var errRouteImportRejected = fmt.Errorf("import rejected; no routes were applied")
+
+// 批量导入和批量变更结果
+type RouteImportResult struct {
+	RouteApplySummary
+	DryRun   bool                              `json:"dry_run"`
+	Changes  map[string]map[string]FieldChange `json:"changes,omitempty"`  // 更新的路由ID -> 修改的字段
+	Warnings map[string][]RouteConflict        `json:"warnings,omitempty"` // 路由ID -> 导入后重叠的路由
+	// 🔧 新增：声明式 apply 中与当前配置相同、未写入的路由
+	Unchanged []string `json:"unchanged,omitempty"`
+}
+
+// 🔧 新增：批量导入路由（新增或更新，不删除文档外的路由）。先按写入规则逐条校验，任何一条出错时整批拒绝；
+// 通过后与批量变更一样在一个 Redis 事务中写入、发布一个 BATCH 事件并一次性替换路由表。优先级相同的重叠（包括文档内的路由之间）视为错误，force 时只作为警告
+func (rm *RouteManager) ImportRoutes(routes []RouteConfig, force, dryRun bool) (*RouteImportResult, error) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	current := rm.snapshot()
+	operations := make([]RouteBatchOperation, 0, len(routes))
+	keys := make([]string, 0, len(routes)) // 操作序号 -> 文档中的路由ID（或 routes[序号]）
+	errors := make(map[string]string)
+	seen := make(map[string]bool, len(routes))
+
+	for i := range routes {
+		route := routes[i]
+		key := route.ID
+		if key == "" || seen[key] {
+			key = fmt.Sprintf("routes[%d]", i)
+		}
+		if seen[route.ID] {
+			errors[key] = fmt.Sprintf("duplicate route ID in document: %s", route.ID)
+			continue
+		}
+		seen[route.ID] = true
+
+		op := routeBatchCreate
+		if _, exists := current.get(route.ID); exists {
+			op = routeBatchUpdate
+		}
+		operations = append(operations, RouteBatchOperation{Op: op, ID: route.ID, Route: &route})
+		keys = append(keys, key)
+	}
+	if len(errors) > 0 {
+		result := newRouteBatchResult(dryRun)
+		result.Errors = errors
+		return result, errRouteImportRejected
+	}
+
+	// 与批量变更共用同一个事务写入路径
+	result, err := rm.applyRouteBatchLocked(operations, force, dryRun)
+	rekeyRouteBatchErrors(result, keys)
+	if err == errRouteBatchRejected {
+		err = errRouteImportRejected
+	}
+	return result, err
+}
+
+// 导入导出文档的格式：format 参数优先，其次按请求头判断，默认 JSON
+func routeDocumentFormat(c *gin.Context, header string) string {
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		return format
+	}
+	if strings.Contains(c.GetHeader(header), "yaml") {
+		return "yaml"
+	}
+	return "json"
+}
+
+// 读取请求中的路由文档（JSON 或 YAML，格式与导出和备份相同）
+func readRouteDocument(c *gin.Context) (*ConfigSnapshot, error) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, routeImportMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	format := routeDocumentFormat(c, "Content-Type")
+	if format == "yaml" {
+		// YAML 先转换为 JSON，字段名与路由的 JSON 字段一致
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid YAML document: %v", err)
+		}
+		if data, err = json.Marshal(document); err != nil {
+			return nil, fmt.Errorf("invalid YAML document: %v", err)
+		}
+	} else if format != "json" {
+		return nil, fmt.Errorf("format must be json or yaml")
+	}
+
+	var snapshot ConfigSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid route document: %v", err)
+	}
+	if snapshot.FormatVersion > backupFormatVersion {
+		return nil, fmt.Errorf("unsupported format version: %d", snapshot.FormatVersion)
+	}
+	if len(snapshot.Routes) == 0 {
+		return nil, fmt.Errorf("document contains no routes")
+	}
+	return &snapshot, nil
+}
+
+// 🔧 新增：批量导入路由（JSON 或 YAML，格式与导出和备份相同）
+func (dr *DistributedRouter) importRoutesHandler(c *gin.Context) {
+	snapshot, err := readRouteDocument(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := dr.routeManager.ImportRoutes(snapshot.Routes, c.Query("force") == "true", dryRun)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if dryRun {
+		c.JSON(200, gin.H{"dry_run": true, "result": result})
+		return
+	}
+
+	c.Set(auditMessageKey, fmt.Sprintf("imported %d routes (%d created, %d updated)",
+		len(snapshot.Routes), len(result.Created), len(result.Updated)))
+	c.JSON(200, gin.H{"message": "routes imported", "result": result})
+}
+
+// 🔧 新增：导出全部路由（包含延迟加载的代码），可直接用于导入
+func (dr *DistributedRouter) exportRoutesHandler(c *gin.Context) {
+	routes, err := dr.routeManager.loadPersistedRoutes(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		routes = searchRoutes(routes, query)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].ID < routes[j].ID })
+
+	snapshot := ConfigSnapshot{
+		FormatVersion: backupFormatVersion,
+		CreatedAt:     time.Now().Unix(),
+		InstanceID:    dr.routeManager.instanceID,
+		ConfigVersion: dr.routeManager.snapshot().configVersion,
+		Routes:        routes,
+	}
+
+	switch routeDocumentFormat(c, "Accept") {
+	case "yaml":
+		data, err := marshalRouteYAML(snapshot)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(200, "application/yaml", data)
+	case "json":
+		c.JSON(200, snapshot)
+	default:
+		c.JSON(400, gin.H{"error": "format must be json or yaml"})
+	}
+}
+
+// 按 JSON 字段名和字段顺序输出 YAML，多行代码以块文本输出
+func marshalRouteYAML(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// JSON 解析出的节点是行内样式，清除后按 YAML 的块样式输出
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
@@ -2,14 +2,15 @@ package gateway
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
-	"regexp"
+	"math/rand"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -20,49 +21,90 @@ import (
 type RouteManager struct {
 	redisClient      *redis.Client
 	eventStream      *EventStreamManager
-	routeCache       map[string]RouteConfig
-	routeVersions    map[string]int64 // 🔧 新增：内存中的路由版本
+	table            atomic.Pointer[routeTable] // 路由表快照（写时复制，读路径无锁）
 	router           *mux.Router
 	updateChannel    chan struct{}
-	mutex            sync.RWMutex
+	mutex            sync.Mutex // 写操作互斥锁，读路径使用快照
 	redisEnabled     bool
 	eventConsumers   []*EventConsumer
 	lastConfigUpdate int64            // 🔧 新增：最后配置更新时间
 	instanceID       string           // 🔧 新增：实例ID
+	codeCache        *codeCache       // 延迟加载代码的 LRU 缓存
+	matchCache       *matchCache      // 🔧 新增：路由匹配结果 LRU，为 nil 时不缓存
+	lazyCodeThreshold int             // 超过该大小的代码不常驻内存
+	watchMutex       sync.Mutex
+	tableChanged     chan struct{}    // 路由表变更通知，每次发布新快照时关闭并重建
+	syncInterval     atomic.Int64     // 🔧 新增：当前配置同步间隔（纳秒），可通过 /admin/runtime 调整
+	syncIntervalChanges chan time.Duration
+	outbox           *eventOutbox     // 🔧 新增：发布失败的路由事件（event_publish.mode 为 outbox 时）
+	routeGroups      *RouteGroupStore // 🔧 新增：路由分组（分组前缀参与路由匹配）
+	syncStats        *syncStats       // 🔧 新增：增量同步效果统计
+	broadcastReadAt  atomic.Int64     // 🔧 新增：广播事件最近一次成功读取的时间（UnixNano）
+	versionMutex     sync.Mutex
+	versions         configVersionTracker // 🔧 新增：全局配置版本及其与快照版本的对应
+	staging          bool        // 🔧 新增：批量应用事件中，路由变更写入暂存快照（持有 mutex 时访问）
+	staged           *routeTable // 暂存快照，批量应用结束时发布一次
 }
 
-func NewRouteManager(redisClient *redis.Client) *RouteManager {
+func NewRouteManager(redisClient *redis.Client, instanceID string) *RouteManager {
+	settings := gatewaySettings()
 	rm := &RouteManager{
 		redisClient:    redisClient,
-		routeCache:     make(map[string]RouteConfig),
-		routeVersions:  make(map[string]int64), // 🔧 初始化版本映射
 		router:         mux.NewRouter(),
 		updateChannel:  make(chan struct{}, 1),
 		redisEnabled:   true,
-		instanceID:     fmt.Sprintf("instance-%d", time.Now().UnixNano()), // 🔧 实例标识
+		instanceID:     instanceID, // 🔧 实例标识（gateway.instance_id）
+		codeCache:      newCodeCache(settings.CodeCacheMemory),
+		matchCache:     newMatchCache(settings.MatchCacheSize),
+		syncIntervalChanges: make(chan time.Duration, 1),
+		outbox:         newEventOutbox(),
+		syncStats:      newSyncStats(),
 	}
+	rm.storeTable(newRouteTable())
 
 	// 测试 Redis 连接
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
 	_, err := redisClient.Ping(ctx).Result()
+
+	// 🔧 新增：路由分组，加载路由前需要分组前缀
+	rm.routeGroups = NewRouteGroupStore(redisClient, err == nil)
 	if err != nil {
 		log.Printf("⚠️  Redis not available, using in-memory storage only")
 		rm.redisEnabled = false
 	} else {
+		// 延迟加载代码依赖 Redis，内存模式下代码必须常驻
+		rm.lazyCodeThreshold = settings.LazyCodeThreshold
+		rm.storeTable(rm.newTable())
+
 		// 初始化事件流管理器
 		rm.eventStream = NewEventStreamManager(redisClient)
 		
 		// 🔧 修改：使用增量加载代替全量加载
 		rm.loadRoutesIncremental()
 		
-		// 启动事件消费者
-		rm.startEventConsumers()
+		// 启动事件消费者（fallback 轮询模式下由广播读取应用路由事件）
+		if settings.Sync.Poll != syncPollFallback {
+			rm.startEventConsumers()
+		}
+
+		// 🔧 新增：重试发件箱中的事件
+		go rm.runEventOutbox()
+
+		// 🔧 新增：监听全量重新同步请求等广播事件
+		go rm.consumeBroadcastEvents()
+
+		// 🔧 新增：同步其他实例修改的路由分组
+		go rm.refreshRouteGroups()
 	}
 
-	// 🔧 修改：延长配置监听间隔到1分钟
-	go rm.watchConfigurationChanges(60 * time.Second)
+	// 🔧 修改：配置监听间隔由 gateway.sync.interval 配置
+	interval := time.Duration(settings.Sync.Interval) * time.Second
+	if interval <= 0 {
+		interval = 60 * time.Second
+	}
+	go rm.watchConfigurationChanges(interval)
 
 	return rm
 }
@@ -74,11 +116,12 @@ func (rm *RouteManager) loadRoutesIncremental() {
 	}
 
 	ctx := context.Background()
+	startTime := time.Now()
 	
 	// 1. 获取全局配置版本
 	configVersionJSON, err := rm.redisClient.Get(ctx, "gateway:config:version").Result()
 	if err != nil && err != redis.Nil {
-		log.Printf("Failed to get config version: %v", err)
+		opLogf(logCategorySync, logLevelWarn, "Failed to get config version: %v", err)
 		return
 	}
 
@@ -89,13 +132,14 @@ func (rm *RouteManager) loadRoutesIncremental() {
 
 	// 2. 如果版本没有变化，跳过加载
 	if currentConfigVersion <= rm.lastConfigUpdate {
+		rm.syncStats.recordUnchanged()
 		return
 	}
 
 	// 3. 获取有变更的路由ID列表
 	updatedRoutes, err := rm.redisClient.SMembers(ctx, "gateway:routes:updated").Result()
 	if err != nil && err != redis.Nil {
-		log.Printf("Failed to get updated routes: %v", err)
+		opLogf(logCategorySync, logLevelWarn, "Failed to get updated routes: %v", err)
 		return
 	}
 
@@ -104,8 +148,12 @@ func (rm *RouteManager) loadRoutesIncremental() {
 
 	updateCount := 0
 	deleteCount := 0
+	syncKind := syncKindIncremental
 
 	if len(updatedRoutes) > 0 {
+		// 在副本上批量应用变更，最后一次性原子替换
+		next := rm.snapshot().clone()
+
 		// 4. 增量更新：只加载有变更的路由
 		for _, routeID := range updatedRoutes {
 			if routeID == "" {
@@ -115,44 +163,46 @@ func (rm *RouteManager) loadRoutesIncremental() {
 			if strings.HasPrefix(routeID, "DELETE:") {
 				// 处理删除的路由
 				actualRouteID := strings.TrimPrefix(routeID, "DELETE:")
-				if _, exists := rm.routeCache[actualRouteID]; exists {
-					delete(rm.routeCache, actualRouteID)
-					delete(rm.routeVersions, actualRouteID)
+				if _, exists := next.get(actualRouteID); exists {
+					next.remove(actualRouteID)
 					deleteCount++
-					log.Printf("🗑️  Incremental delete: %s", actualRouteID)
+					opLogf(logCategorySync, logLevelDebug, "🗑️  Incremental delete: %s", actualRouteID)
 				}
 			} else {
 				// 处理新增/更新的路由
 				routeJSON, err := rm.redisClient.HGet(ctx, "gateway:routes", routeID).Result()
 				if err == nil {
-					var route RouteConfig
-					if err := json.Unmarshal([]byte(routeJSON), &route); err == nil {
+					if route, err := decodeStoredRoute([]byte(routeJSON)); err == nil {
 						// 检查版本，避免重复更新
-						if route.Version > rm.routeVersions[routeID] {
-							rm.routeCache[routeID] = route
-							rm.routeVersions[routeID] = route.Version
+						if route.Version > next.versions[routeID] {
+							next.put(routeID, route)
 							updateCount++
-							log.Printf("🔄 Incremental update: %s (v%d)", routeID, route.Version)
+							opLogf(logCategorySync, logLevelDebug, "🔄 Incremental update: %s (v%d)", routeID, route.Version)
 						}
 					}
 				}
 			}
 		}
 
+		rm.storeTable(next)
+
 		// 5. 清理更新标记
 		rm.redisClient.Del(ctx, "gateway:routes:updated")
 	} else {
 		// 6. 如果没有更新信息，回退到全量加载（安全机制）
-		log.Printf("⚠️  No update info, falling back to full load")
+		opLogf(logCategorySync, logLevelInfo, "⚠️  No update info, falling back to full load")
 		rm.loadAllRoutesFromRedis()
-		updateCount = len(rm.routeCache)
+		updateCount = rm.snapshot().size()
+		syncKind = syncKindFullFallback
 	}
 
 	// 7. 更新配置版本
 	rm.lastConfigUpdate = currentConfigVersion
+	rm.observeConfigVersion(currentConfigVersion)
+	rm.syncStats.record(syncKind, updateCount+deleteCount, time.Since(startTime))
 
-	log.Printf("📦 Incremental load: %d updated, %d deleted, total: %d routes", 
-		updateCount, deleteCount, len(rm.routeCache))
+	opLogf(logCategorySync, logLevelInfo, "📦 Incremental load: %d updated, %d deleted, total: %d routes", 
+		updateCount, deleteCount, rm.snapshot().size())
 }
 
 // 🔧 新增：全量加载（备用）
@@ -164,16 +214,14 @@ func (rm *RouteManager) loadAllRoutesFromRedis() {
 		return
 	}
 
-	rm.routeCache = make(map[string]RouteConfig)
-	rm.routeVersions = make(map[string]int64)
-
+	// 构建全新的路由表后原子替换，加载期间读路径继续使用旧快照
+	next := rm.newTable()
 	for routeID, routeJSON := range routes {
-		var route RouteConfig
-		if err := json.Unmarshal([]byte(routeJSON), &route); err == nil {
-			rm.routeCache[routeID] = route
-			rm.routeVersions[routeID] = route.Version
+		if route, err := decodeStoredRoute([]byte(routeJSON)); err == nil {
+			next.put(routeID, route)
 		}
 	}
+	rm.storeTable(next)
 }
 
 // 加载初始路由
@@ -192,14 +240,15 @@ func (rm *RouteManager) loadInitialRoutes() {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 
+	next := rm.snapshot().clone()
 	for _, routeJSON := range routes {
-		var route RouteConfig
-		if err := json.Unmarshal([]byte(routeJSON), &route); err == nil {
-			rm.routeCache[route.ID] = route
+		if route, err := decodeStoredRoute([]byte(routeJSON)); err == nil {
+			next.put(route.ID, route)
 		}
 	}
+	rm.storeTable(next)
 
-	log.Printf("Loaded %d routes from Redis", len(rm.routeCache))
+	log.Printf("Loaded %d routes from Redis", next.size())
 }
 
 // 启动事件消费者
@@ -235,8 +284,52 @@ type RouteEventHandler struct {
 }
 
 func (h *RouteEventHandler) HandleEvent(event *RouteEvent) error {
+	return h.HandleEvents([]*RouteEvent{event})[0]
+}
+
+// 🔧 新增：批量应用一次读取到的事件：路由变更写入同一个暂存快照，全部处理后只复制和发布一次路由表，
+// 大量单路由事件不再逐条复制整张路由表；返回与 events 一一对应的处理结果
+func (h *RouteEventHandler) HandleEvents(events []*RouteEvent) []error {
+	errs := make([]error, len(events))
+	observed := h.applyEvents(events, errs)
+
+	if observed > 0 {
+		// 🔧 新增：其他实例的变更已应用，已知的全局配置版本随之前进
+		h.routeManager.observeConfigVersion(observed)
+	}
+	return errs
+}
+
+// 🔧 新增：在写锁内应用一批事件，返回成功应用的最大配置版本；
+// 暂存状态的复位和解锁放在 defer 中，处理事件时发生 panic 也不会一直持有写锁，此时暂存的变更被丢弃不发布
+func (h *RouteEventHandler) applyEvents(events []*RouteEvent, errs []error) int64 {
+	rm := h.routeManager
+	var observed int64
+
+	rm.mutex.Lock()
+	rm.staging = true
+	defer func() {
+		rm.staging = false
+		rm.staged = nil
+		rm.mutex.Unlock()
+	}()
+
+	for i, event := range events {
+		errs[i] = h.handleEvent(event)
+		if errs[i] == nil && event.ConfigVersion > observed {
+			observed = event.ConfigVersion
+		}
+	}
+	if rm.staged != nil {
+		rm.storeTable(rm.staged)
+	}
+	return observed
+}
+
+// 处理单个事件（调用方需持有写锁）
+func (h *RouteEventHandler) handleEvent(event *RouteEvent) error {
 	startTime := time.Now()
-	log.Printf("🎬 [EVENT] 开始处理事件 | 类型: %s | ID: %s | 路由: %s", 
+	opLogf(logCategoryEvent, logLevelDebug, "🎬 [EVENT] 开始处理事件 | 类型: %s | ID: %s | 路由: %s", 
 		event.EventType, event.EventID, event.RouteID)
 
 	var err error
@@ -247,17 +340,28 @@ func (h *RouteEventHandler) HandleEvent(event *RouteEvent) error {
 		err = h.handleUpdateEvent(event)
 	case "DELETE":
 		err = h.handleDeleteEvent(event)
+	case "BATCH":
+		err = h.handleBatchEvent(event)
+	case "HEALTH_UPDATE":
+		// 由沙箱池的健康事件监听处理（广播到所有实例）
+		return nil
+	case "RESYNC":
+		// 由 consumeResyncEvents 处理（广播到所有实例）
+		return nil
+	case "SANDBOX_CHANGE":
+		// 🔧 新增：沙箱池变化通知，实例状态由健康事件和 Redis 同步
+		return nil
 	default:
-		log.Printf("❌ [EVENT] 未知事件类型: %s", event.EventType)
+		opLogf(logCategoryEvent, logLevelWarn, "❌ [EVENT] 未知事件类型: %s", event.EventType)
 		err = nil
 	}
 
 	duration := time.Since(startTime)
 	if err != nil {
-		log.Printf("💥 [EVENT] 事件处理失败 | 类型: %s | ID: %s | 耗时: %v | 错误: %v", 
+		opLogf(logCategoryEvent, logLevelError, "💥 [EVENT] 事件处理失败 | 类型: %s | ID: %s | 耗时: %v | 错误: %v", 
 			event.EventType, event.EventID, duration, err)
 	} else {
-		log.Printf("🎉 [EVENT] 事件处理成功 | 类型: %s | ID: %s | 耗时: %v", 
+		opLogf(logCategoryEvent, logLevelDebug, "🎉 [EVENT] 事件处理成功 | 类型: %s | ID: %s | 耗时: %v", 
 			event.EventType, event.EventID, duration)
 	}
 	
@@ -274,17 +378,13 @@ func (h *RouteEventHandler) handleCreateEvent(event *RouteEvent) error {
         targetRouteID = event.RouteID
     }
 
-    h.routeManager.mutex.Lock()
-    defer h.routeManager.mutex.Unlock()
-
     // 检查是否已存在
-    if existing, exists := h.routeManager.routeCache[targetRouteID]; exists {
-        log.Printf("⚠️ [CREATE] 路由已存在，将被覆盖: %s (原版本: %d)", targetRouteID, existing.Version)
+    if existing, exists := h.routeManager.currentTable().get(targetRouteID); exists {
+        opLogf(logCategoryEvent, logLevelWarn, "⚠️ [CREATE] 路由已存在，将被覆盖: %s (原版本: %d)", targetRouteID, existing.Version)
     }
 
-    h.routeManager.routeCache[targetRouteID] = *event.RouteData
-    h.routeManager.routeVersions[targetRouteID] = event.RouteData.Version
-    log.Printf("✅ [CREATE] 路由创建成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
+    h.routeManager.cacheRoute(targetRouteID, *event.RouteData)
+    opLogf(logCategoryEvent, logLevelInfo, "✅ [CREATE] 路由创建成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
     
     return nil
 }
@@ -299,51 +399,44 @@ func (h *RouteEventHandler) handleUpdateEvent(event *RouteEvent) error {
         targetRouteID = event.RouteID
     }
 
-    h.routeManager.mutex.Lock()
-    defer h.routeManager.mutex.Unlock()
-
-    log.Printf("📊 [UPDATE] 处理路由更新: %s (事件ID: %s)", targetRouteID, event.RouteID)
+    opLogf(logCategoryEvent, logLevelDebug, "📊 [UPDATE] 处理路由更新: %s (事件ID: %s)", targetRouteID, event.RouteID)
     
-    if existing, exists := h.routeManager.routeCache[targetRouteID]; exists {
-        log.Printf("📝 [UPDATE] 更新现有路由: %s", targetRouteID)
-        log.Printf("   📋 旧版本: %d, 新版本: %d", existing.Version, event.RouteData.Version)
+    if existing, exists := h.routeManager.currentTable().get(targetRouteID); exists {
+        opLogf(logCategoryEvent, logLevelDebug, "📝 [UPDATE] 更新现有路由: %s", targetRouteID)
+        opLogf(logCategoryEvent, logLevelDebug, "   📋 旧版本: %d, 新版本: %d", existing.Version, event.RouteData.Version)
+        if len(event.Changes) > 0 {
+            opLogf(logCategoryEvent, logLevelDebug, "   🔍 %s", describeFieldChanges("修改字段", event.Changes))
+        }
         
-        h.routeManager.routeCache[targetRouteID] = *event.RouteData
-        h.routeManager.routeVersions[targetRouteID] = event.RouteData.Version
-        log.Printf("✅ [UPDATE] 路由更新成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
+        h.routeManager.cacheRoute(targetRouteID, *event.RouteData)
+        opLogf(logCategoryEvent, logLevelInfo, "✅ [UPDATE] 路由更新成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
     } else {
-        log.Printf("⚠️ [UPDATE] 路由不存在，创建新路由: %s", targetRouteID)
-        h.routeManager.routeCache[targetRouteID] = *event.RouteData
-        h.routeManager.routeVersions[targetRouteID] = event.RouteData.Version
-        log.Printf("✅ [UPDATE] 新路由创建成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
+        opLogf(logCategoryEvent, logLevelWarn, "⚠️ [UPDATE] 路由不存在，创建新路由: %s", targetRouteID)
+        h.routeManager.cacheRoute(targetRouteID, *event.RouteData)
+        opLogf(logCategoryEvent, logLevelInfo, "✅ [UPDATE] 新路由创建成功: %s (版本: %d)", targetRouteID, event.RouteData.Version)
     }
     
     return nil
 }
 
 func (h *RouteEventHandler) handleDeleteEvent(event *RouteEvent) error {
-    h.routeManager.mutex.Lock()
-    defer h.routeManager.mutex.Unlock()
-
     targetRouteID := event.RouteID
     
-    log.Printf("🗑️ [DELETE] 处理路由删除: %s", targetRouteID)
+    opLogf(logCategoryEvent, logLevelDebug, "🗑️ [DELETE] 处理路由删除: %s", targetRouteID)
     
-    if _, exists := h.routeManager.routeCache[targetRouteID]; exists {
-        delete(h.routeManager.routeCache, targetRouteID)
-        delete(h.routeManager.routeVersions, targetRouteID)
-        log.Printf("✅ [DELETE] 路由删除成功: %s", targetRouteID)
+    if _, exists := h.routeManager.currentTable().get(targetRouteID); exists {
+        h.routeManager.evictRoute(targetRouteID)
+        opLogf(logCategoryEvent, logLevelInfo, "✅ [DELETE] 路由删除成功: %s", targetRouteID)
     } else {
-        log.Printf("⚠️ [DELETE] 路由不存在: %s", targetRouteID)
+        opLogf(logCategoryEvent, logLevelWarn, "⚠️ [DELETE] 路由不存在: %s", targetRouteID)
         // 尝试从事件数据中查找路由ID
         if event.RouteData != nil && event.RouteData.ID != "" {
             alternativeID := event.RouteData.ID
-            if _, exists := h.routeManager.routeCache[alternativeID]; exists {
-                delete(h.routeManager.routeCache, alternativeID)
-                delete(h.routeManager.routeVersions, alternativeID)
-                log.Printf("✅ [DELETE] 通过备用ID删除成功: %s", alternativeID)
+            if _, exists := h.routeManager.currentTable().get(alternativeID); exists {
+                h.routeManager.evictRoute(alternativeID)
+                opLogf(logCategoryEvent, logLevelInfo, "✅ [DELETE] 通过备用ID删除成功: %s", alternativeID)
             } else {
-                log.Printf("❌ [DELETE] 备用ID也不存在: %s", alternativeID)
+                opLogf(logCategoryEvent, logLevelWarn, "❌ [DELETE] 备用ID也不存在: %s", alternativeID)
             }
         }
     }
@@ -353,103 +446,288 @@ func (h *RouteEventHandler) handleDeleteEvent(event *RouteEvent) error {
 
 // 🔧 修改：配置监听方法，支持自定义间隔
 func (rm *RouteManager) watchConfigurationChanges(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	rm.syncInterval.Store(int64(interval))
+	timer := time.NewTimer(rm.nextSyncDelay())
+	defer timer.Stop()
 
-	log.Printf("⏰ Configuration watcher started (interval: %v)", interval)
+	log.Printf("⏰ Configuration watcher started (interval: %v, jitter: %ds)", interval, gatewaySettings().Sync.Jitter)
 
 	for {
 		select {
 		case <-rm.updateChannel:
 			rm.loadRoutesIncremental() // 🔧 使用增量加载
-		case <-ticker.C:
-			rm.checkForConfigurationUpdates()
+		case interval := <-rm.syncIntervalChanges:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(rm.nextSyncDelay())
+			log.Printf("⏰ Configuration sync interval changed to %v", interval)
+		case <-timer.C:
+			// 🔧 新增：fallback 模式下事件读取正常时跳过轮询
+			if rm.eventsHealthy() {
+				rm.syncStats.recordSkipped()
+			} else {
+				rm.checkForConfigurationUpdates()
+			}
+			timer.Reset(rm.nextSyncDelay())
 		}
 	}
 }
 
-func (rm *RouteManager) checkForConfigurationUpdates() {
-	if !rm.redisEnabled {
-		return
+// 下一次轮询前的等待时间：同步间隔加上随机抖动，避免大量实例同时读取 Redis
+func (rm *RouteManager) nextSyncDelay() time.Duration {
+	delay := rm.SyncInterval()
+	if jitter := time.Duration(gatewaySettings().Sync.Jitter) * time.Second; jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(jitter)))
 	}
+	return delay
+}
 
-	rm.loadRoutesIncremental() // 🔧 直接使用增量加载
+// 🔧 新增：运行时调整配置同步间隔
+func (rm *RouteManager) SetSyncInterval(interval time.Duration) {
+	rm.syncInterval.Store(int64(interval))
+	sendLatestInterval(rm.syncIntervalChanges, interval)
+}
+
+func (rm *RouteManager) SyncInterval() time.Duration {
+	return time.Duration(rm.syncInterval.Load())
 }
 
-// 🔧 新增：更新配置版本（在CUD操作中调用）
-func (rm *RouteManager) updateConfigVersion() {
+func (rm *RouteManager) checkForConfigurationUpdates() {
 	if !rm.redisEnabled {
 		return
 	}
 
-	ctx := context.Background()
-	newVersion := time.Now().UnixNano()
-	
-	err := rm.redisClient.Set(ctx, "gateway:config:version", newVersion, 0).Err()
-	if err != nil {
-		log.Printf("Failed to update config version: %v", err)
-	}
+	rm.loadRoutesIncremental() // 🔧 直接使用增量加载
 }
 
 // 关键算法：路由匹配
 func (rm *RouteManager) matchRoute(path, method string) *RouteConfig {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
+	return rm.matchTenantRoute(path, method, "", "")
+}
+
+// 🔧 新增：按租户和请求域名匹配路由，只匹配该租户的路由和共享路由、该域名的路由和不限域名的路由；
+// 同等匹配时域名路由优先于不限域名的路由，租户路由优先于共享路由
+func (rm *RouteManager) matchTenantRoute(path, method, tenant, host string) *RouteConfig {
+	table := rm.snapshot()
+	now := time.Now().Unix()
+
+	// 🔧 新增：命中匹配缓存时跳过匹配器（只缓存匹配成功的结果，避免扫描请求挤出热点条目）
+	var keyBuffer [256]byte
+	var cacheKey []byte
+	var epoch int
+	if rm.matchCache != nil {
+		cacheKey = appendMatchCacheKey(keyBuffer[:0], method, tenant, host, path)
+		epoch = table.windowEpoch(now)
+		if routeID, ok := rm.matchCache.get(cacheKey, table.configVersion, epoch); ok {
+			if route, exists := table.routes[routeID]; exists {
+				return &route
+			}
+		}
+	}
 
-	var matchedRoute *RouteConfig
-	var matchPriority int
+	var matchedID string
+	var matchPriority, matchDepth int
 
-	for _, route := range rm.routeCache {
-		priority := rm.calculateMatchPriority(route, path, method)
-		if priority > matchPriority {
-			matchedRoute = &route
+	// 🔧 新增：只检查路径索引给出的候选路由；同等优先级时静态前缀更长（更深节点上）的路由优先，其次是路由ID较小的
+	table.index.each(path, func(depth int, entry routeTrieEntry) {
+		priority, _ := rm.scoreRouteEntry(entry, path, method, tenant, host, now)
+		if priority == 0 {
+			return
+		}
+		if priority > matchPriority || (priority == matchPriority && depth > matchDepth) {
+			matchedID = entry.id
 			matchPriority = priority
+			matchDepth = depth
 		}
+	})
+
+	if matchPriority == 0 {
+		return nil
+	}
+	rm.matchCache.put(cacheKey, table.configVersion, epoch, matchedID)
+	matchedRoute := table.routes[matchedID]
+	return &matchedRoute
+}
+
+// 候选路由对请求的匹配优先级，不匹配时返回 0 和原因（原因用于匹配调试接口）
+func (rm *RouteManager) scoreRouteEntry(entry routeTrieEntry, path, method, tenant, host string, now int64) (int, string) {
+	if entry.tenant != "" && entry.tenant != tenant {
+		return 0, matchRejectTenant
+	}
+	// 🔧 新增：生效时间窗口之外的路由不参与匹配
+	if !entry.activeAt(now) {
+		return 0, matchRejectWindow
+	}
+	hostBonus, ok := routeHostBonus(entry.host, host)
+	if !ok {
+		return 0, matchRejectHost
 	}
+	priority := rm.calculateMatchPriority(entry, path, method)
+	if priority == 0 {
+		if !entry.allowsMethod(method) {
+			return 0, matchRejectMethod
+		}
+		return 0, matchRejectPath
+	}
+	// 🔧 新增：显式优先级覆盖按匹配类型计算的优先级
+	if entry.priority > 0 {
+		return entry.priority, ""
+	}
+	if entry.tenant != "" {
+		priority++
+	}
+	return priority + hostBonus, ""
+}
+
+// 🔧 新增：路径匹配但方法不匹配时，该路径允许的方法（用于 405 的 Allow 头）
+func (rm *RouteManager) allowedMethods(path, tenant, host string) []string {
+	table := rm.snapshot()
 
-	return matchedRoute
+	now := time.Now().Unix()
+	seen := make(map[string]bool)
+	var methods []string
+	table.index.each(path, func(_ int, entry routeTrieEntry) {
+		if (entry.tenant != "" && entry.tenant != tenant) || !entry.activeAt(now) {
+			return
+		}
+		if _, ok := routeHostBonus(entry.host, host); !ok {
+			return
+		}
+		if rm.calculateMatchPriority(entry, path, entry.methods[0]) > 0 {
+			for _, m := range entry.methods {
+				if !seen[m] {
+					seen[m] = true
+					methods = append(methods, m)
+				}
+			}
+		}
+	})
+	sort.Strings(methods)
+	return methods
 }
 
 // 计算匹配优先级
-func (rm *RouteManager) calculateMatchPriority(route RouteConfig, path, method string) int {
-	if route.Method != method && route.Method != "ANY" {
+func (rm *RouteManager) calculateMatchPriority(entry routeTrieEntry, path, method string) int {
+	if !entry.allowsMethod(method) {
+		return 0
+	}
+	matcher := entry.matcher
+
+	// 🔧 新增：正则路由只按正则匹配
+	if matcher.pathRegexp != nil {
+		if matcher.matchRegex(path) {
+			return matchPriorityRegex
+		}
 		return 0
 	}
 
 	// 1. 精确匹配最高优先级
-	if route.Path == path {
-		return 100
+	if matcher.matchExact(entry.path, path) {
+		return matchPriorityExact
 	}
 
 	// 2. 参数匹配次之 /users/{id}
-	if rm.matchPathWithParams(route.Path, path) {
-		return 90
+	if matcher.matchParams(path) {
+		return matchPriorityParam
 	}
 
 	// 3. 前缀匹配 /api/
-	if strings.HasPrefix(path, route.Path+"/") {
-		return 80
+	if matcher.matchPrefix(path) {
+		return matchPriorityPrefix
 	}
 
 	// 4. 通配符匹配 /api/*
-	if strings.Contains(route.Path, "*") {
-		pattern := strings.ReplaceAll(route.Path, "*", ".*")
-		if matched, _ := regexp.MatchString("^"+pattern+"$", path); matched {
-			return 70
-		}
+	if matcher.matchWildcard(path) {
+		return matchPriorityWildcard
 	}
 
 	return 0
 }
 
-// 匹配带参数的路由
-func (rm *RouteManager) matchPathWithParams(routePath, requestPath string) bool {
-	route := mux.NewRouter()
-	route.Path(routePath).Methods("GET")
-	
-	req, _ := http.NewRequest("GET", requestPath, nil)
-	var match mux.RouteMatch
-	return route.Match(req, &match)
+// 创建空路由表
+func (rm *RouteManager) newTable() *routeTable {
+	table := newRouteTable()
+	table.lazyThreshold = rm.lazyCodeThreshold
+	table.groupPrefixes = rm.routeGroups.prefixes()
+	return table
+}
+
+// 发布新的路由表快照，配置版本单调递增，并唤醒等待变更的 watch 请求
+func (rm *RouteManager) storeTable(next *routeTable) {
+	prev := rm.snapshot()
+	version := time.Now().UnixNano()
+	if prev != nil && version <= prev.configVersion {
+		version = prev.configVersion + 1
+	}
+	next.configVersion = version
+	next.stampChanges(prev, version)
+	if prev == nil {
+		// 🔧 修改：删除记录只在内存中，早于本进程第一个快照的版本需要全量同步
+		next.tombstoneFloor = version
+	}
+
+	rm.versionMutex.Lock()
+	rm.table.Store(next)
+	if !rm.redisEnabled {
+		// 内存模式只有一个实例，快照版本即配置版本
+		rm.versions.global = version
+	} else if rm.versions.pending > rm.versions.global {
+		rm.versions.global = rm.versions.pending
+	}
+	rm.versions.mark(rm.versions.global, version)
+	rm.versionMutex.Unlock()
+	rm.notifyTableChanged()
+}
+
+// 获取当前路由表快照（只读）
+func (rm *RouteManager) snapshot() *routeTable {
+	return rm.table.Load()
+}
+
+// 写锁内的最新路由表（调用方需持有写锁）：批量应用事件时包括尚未发布的暂存变更
+func (rm *RouteManager) currentTable() *routeTable {
+	if rm.staged != nil {
+		return rm.staged
+	}
+	return rm.snapshot()
+}
+
+// 🔧 新增：批量应用事件时的暂存快照（调用方需持有写锁），整批只在首次写入时复制一次
+func (rm *RouteManager) stagedTable() *routeTable {
+	if rm.staged == nil {
+		rm.staged = rm.snapshot().clone()
+	}
+	return rm.staged
+}
+
+// 写入单条路由：复制快照、修改后原子替换（调用方需持有写锁）；
+// 🔧 修改：批量应用事件时写入暂存快照，由 HandleEvents 统一发布
+func (rm *RouteManager) cacheRoute(routeID string, route RouteConfig) {
+	// 🔧 新增：路由更新后旧版本的延迟加载代码不会再被读取，立即释放缓存容量
+	rm.codeCache.invalidate(routeID)
+	if rm.staging {
+		rm.stagedTable().put(routeID, route)
+		return
+	}
+	next := rm.snapshot().clone()
+	next.put(routeID, route)
+	rm.storeTable(next)
+}
+
+// 移除单条路由：复制快照、修改后原子替换（调用方需持有写锁）；批量应用事件时写入暂存快照
+func (rm *RouteManager) evictRoute(routeID string) {
+	rm.codeCache.invalidate(routeID)
+	if rm.staging {
+		rm.stagedTable().remove(routeID)
+		return
+	}
+	next := rm.snapshot().clone()
+	next.remove(routeID)
+	rm.storeTable(next)
 }
 
 // 添加路由（发布事件 + 持久化存储）
@@ -461,6 +739,12 @@ func (rm *RouteManager) AddRoute(route RouteConfig) error {
 	if err := rm.validateRouteConfiguration(route); err != nil {
 		return err
 	}
+	if err := defaultRouteConflict(rm.snapshot(), route); err != nil {
+		return err
+	}
+	if err := rm.checkCacheMemory(route.ID, route); err != nil {
+		return err
+	}
 
 	// 设置时间戳和版本
 	now := time.Now().Unix()
@@ -473,7 +757,7 @@ func (rm *RouteManager) AddRoute(route RouteConfig) error {
 	// 保存到Redis（持久化存储）
 	if rm.redisEnabled {
 		ctx := context.Background()
-		routeJSON, _ := json.Marshal(route)
+		routeJSON, _ := encodeStoredRoute(route)
 		
 		// 🔧 修复：保存到Redis哈希表
 		err := rm.redisClient.HSet(ctx, "gateway:routes", route.ID, routeJSON).Err()
@@ -498,17 +782,14 @@ func (rm *RouteManager) AddRoute(route RouteConfig) error {
 			RouteID:   route.ID,
 			RouteData: &route,
 			Timestamp: now,
-			Source:    "route-manager",
+			Source:    rm.instanceID,
 		}
 
-		if err := rm.eventStream.PublishRouteEvent(context.Background(), event); err != nil {
-			log.Printf("Failed to publish CREATE event: %v", err)
-		}
+		rm.publishRouteEvent(event)
 	}
 
 	// 更新内存缓存
-	rm.routeCache[route.ID] = route
-	rm.routeVersions[route.ID] = route.Version
+	rm.cacheRoute(route.ID, route)
 
 	// 通知更新
 	select {
@@ -521,24 +802,38 @@ func (rm *RouteManager) AddRoute(route RouteConfig) error {
 }
 
 // 更新路由（发布事件 + 持久化存储）
-func (rm *RouteManager) UpdateRoute(routeID string, newRoute RouteConfig) error {
+// 🔧 修改：返回修改的字段
+func (rm *RouteManager) UpdateRoute(routeID string, newRoute RouteConfig) (map[string]FieldChange, error) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 
 	// 检查路由是否存在
-	if _, exists := rm.routeCache[routeID]; !exists {
-		return fmt.Errorf("route %s not found", routeID)
+	previous, exists := rm.snapshot().get(routeID)
+	if !exists {
+		return nil, fmt.Errorf("route %s not found", routeID)
 	}
 
 	// 验证新的路由配置
 	if err := rm.validateRouteConfiguration(newRoute); err != nil {
-		return err
+		return nil, err
 	}
 
 	// 确保ID一致
 	if routeID != newRoute.ID {
-		return fmt.Errorf("route ID cannot be changed")
+		return nil, fmt.Errorf("route ID cannot be changed")
+	}
+	if err := defaultRouteConflict(rm.snapshot(), newRoute); err != nil {
+		return nil, err
+	}
+	if err := rm.checkCacheMemory(routeID, newRoute); err != nil {
+		return nil, err
+	}
+
+	// 🔧 新增：计算修改的字段（未常驻内存的代码先加载再比较）
+	if code, err := rm.resolveCode(&previous); err == nil {
+		previous.Code = code
 	}
+	changes := diffRoutes(previous, newRoute)
 
 	// 设置更新时间戳和版本
 	newRoute.UpdatedAt = time.Now().Unix()
@@ -547,7 +842,7 @@ func (rm *RouteManager) UpdateRoute(routeID string, newRoute RouteConfig) error
 	// 保存到Redis（持久化存储）
 	if rm.redisEnabled {
 		ctx := context.Background()
-		routeJSON, _ := json.Marshal(newRoute)
+		routeJSON, _ := encodeStoredRoute(newRoute)
 		
 		// 🔧 修复：更新Redis哈希表
 		err := rm.redisClient.HSet(ctx, "gateway:routes", routeID, routeJSON).Err()
@@ -571,18 +866,16 @@ func (rm *RouteManager) UpdateRoute(routeID string, newRoute RouteConfig) error
 			EventType: "UPDATE",
 			RouteID:   routeID,
 			RouteData: &newRoute,
+			Changes:   changes,
 			Timestamp: time.Now().Unix(),
-			Source:    "route-manager",
+			Source:    rm.instanceID,
 		}
 
-		if err := rm.eventStream.PublishRouteEvent(context.Background(), event); err != nil {
-			log.Printf("Failed to publish UPDATE event: %v", err)
-		}
+		rm.publishRouteEvent(event)
 	}
 
 	// 更新内存缓存
-	rm.routeCache[routeID] = newRoute
-	rm.routeVersions[routeID] = newRoute.Version // 🔧 更新版本映射
+	rm.cacheRoute(routeID, newRoute)
 
 	// 通知更新
 	select {
@@ -590,7 +883,7 @@ func (rm *RouteManager) UpdateRoute(routeID string, newRoute RouteConfig) error
 	default:
 	}
 
-	return nil
+	return changes, nil
 }
 
 // 删除路由（发布事件 + 持久化存储）
@@ -624,17 +917,14 @@ func (rm *RouteManager) DeleteRoute(routeID string) error {
 			EventType: "DELETE",
 			RouteID:   routeID,
 			Timestamp: time.Now().Unix(),
-			Source:    "route-manager",
+			Source:    rm.instanceID,
 		}
 
-		if err := rm.eventStream.PublishRouteEvent(context.Background(), event); err != nil {
-			log.Printf("Failed to publish DELETE event: %v", err)
-		}
+		rm.publishRouteEvent(event)
 	}
 
 	// 从内存缓存删除
-	delete(rm.routeCache, routeID)
-	delete(rm.routeVersions, routeID) // 🔧 清理版本映射
+	rm.evictRoute(routeID)
 
 	// 通知更新
 	select {
@@ -650,20 +940,38 @@ func (rm *RouteManager) validateRouteConfiguration(route RouteConfig) error {
 	if route.ID == "" {
 		return fmt.Errorf("route ID is required")
 	}
-	if route.Path == "" {
+	if route.Path == "" && route.PathRegex == "" {
 		return fmt.Errorf("route path is required")
 	}
-	if route.Method == "" {
-		return fmt.Errorf("route method is required")
+	// 🔧 新增：正则路由
+	if route.PathRegex != "" {
+		if route.Path != "" {
+			return fmt.Errorf("path and path_regex cannot be used together")
+		}
+		if err := validatePathRegex(route.PathRegex); err != nil {
+			return err
+		}
+	}
+	if probePaths[route.fullPath()] {
+		return fmt.Errorf("path %s is reserved for load balancer probes", route.fullPath())
+	}
+	// 🔧 修改：method 与 methods 二选一
+	if err := validateRouteMethods(route); err != nil {
+		return err
 	}
 	if route.Handler == "" {
 		return fmt.Errorf("route handler is required")
 	}
+	if maxCodeSize := gatewaySettings().MaxCodeSize; maxCodeSize > 0 && len(route.Code) > maxCodeSize {
+		return fmt.Errorf("route code size %d exceeds limit of %d bytes", len(route.Code), maxCodeSize)
+	}
 
 	validHandlers := map[string]bool{
 		"sandbox": true,
 		"proxy":   true,
+		"llm":     true,
 		"static":  true,
+		"echo":    true, // 🔧 新增：返回网关看到的请求，用于调试
 	}
 	if !validHandlers[route.Handler] {
 		return fmt.Errorf("invalid handler type: %s", route.Handler)
@@ -680,19 +988,198 @@ func (rm *RouteManager) validateRouteConfiguration(route RouteConfig) error {
 		}
 	}
 
+	if route.Handler == "proxy" || (route.Handler == "llm" && route.Target != "") {
+		target, err := url.Parse(route.Target)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("invalid proxy target: %s", route.Target)
+		}
+	}
+	if err := validateRouteResolve(route); err != nil {
+		return err
+	}
+
+	if route.Handler == "llm" {
+		if route.LLM == nil {
+			return fmt.Errorf("llm routes require llm configuration")
+		}
+		if err := route.LLM.validate(route.Target); err != nil {
+			return err
+		}
+	}
+
+	switch route.Locality {
+	case "", LocalityAny, LocalityPreferLocal, LocalityRequireLocal:
+	default:
+		return fmt.Errorf("invalid locality: %s", route.Locality)
+	}
+
+	if err := validateRouteHost(route.Host); err != nil {
+		return err
+	}
+
+	if route.Priority < 0 || route.Priority > maxRoutePriority {
+		return fmt.Errorf("priority must be between 0 and %d", maxRoutePriority)
+	}
+
+	if route.ActiveFrom < 0 || route.ExpiresAt < 0 {
+		return fmt.Errorf("active_from and expires_at must not be negative")
+	}
+	if route.ActiveFrom > 0 && route.ExpiresAt > 0 && route.ExpiresAt <= route.ActiveFrom {
+		return fmt.Errorf("expires_at must be after active_from")
+	}
+
+	if err := validateRouteTags(route.Tags); err != nil {
+		return err
+	}
+
+	if route.DocsURL != "" {
+		docs, err := url.Parse(route.DocsURL)
+		if err != nil || (docs.Scheme != "http" && docs.Scheme != "https") || docs.Host == "" {
+			return fmt.Errorf("invalid docs_url: %s", route.DocsURL)
+		}
+	}
+
+	if route.MaxResponseBytes < 0 {
+		return fmt.Errorf("max_response_bytes must not be negative")
+	}
+
+	if route.Auth != nil {
+		if err := route.Auth.validate(); err != nil {
+			return err
+		}
+	}
+
+	if route.ResponseContract != nil {
+		if err := route.ResponseContract.validate(); err != nil {
+			return err
+		}
+	}
+
+	if route.ResponseHeaders != nil {
+		if err := route.ResponseHeaders.validate(); err != nil {
+			return err
+		}
+	}
+	if route.RequestHeaders != nil {
+		if err := route.RequestHeaders.validate("request"); err != nil {
+			return err
+		}
+	}
+	if err := validateRouteErrorResponses(route.ErrorResponses); err != nil {
+		return err
+	}
+
+	if route.SLO != nil {
+		if err := route.SLO.validate(); err != nil {
+			return err
+		}
+	}
+	if route.Canary != nil {
+		if err := route.Canary.validate(&route); err != nil {
+			return err
+		}
+	}
+	if route.Policy != nil {
+		if err := route.Policy.validate(); err != nil {
+			return err
+		}
+	}
+	if route.RewritePath != nil {
+		if err := route.RewritePath.validate(&route); err != nil {
+			return err
+		}
+	}
+	if route.GroupID != "" && !rm.routeGroups.exists(context.Background(), route.GroupID) {
+		return fmt.Errorf("route group not found: %s", route.GroupID)
+	}
+	if route.Signing != nil {
+		if err := route.Signing.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// 获取所有路由
-func (rm *RouteManager) GetAllRoutes() []RouteConfig {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
+// 检查写入后路由缓存是否超出内存限制
+func (rm *RouteManager) checkCacheMemory(routeID string, route RouteConfig) error {
+	limit := gatewaySettings().MaxCacheMemory
+	if limit <= 0 {
+		return nil
+	}
+	if projected := rm.snapshot().memoryAfterPut(routeID, route); projected > limit {
+		return fmt.Errorf("route cache memory limit exceeded: %d > %d bytes", projected, limit)
+	}
+	return nil
+}
+
+// 获取路由代码，大代码块首次执行时从 Redis 加载并放入 LRU
+func (rm *RouteManager) resolveCode(route *RouteConfig) (string, error) {
+	// 延迟加载的路由在内存中没有代码；带有代码的副本（金丝雀版本）直接使用
+	if route.Code != "" || !rm.snapshot().lazyCode[route.ID] {
+		return route.Code, nil
+	}
 
-	routes := make([]RouteConfig, 0, len(rm.routeCache))
-	for _, route := range rm.routeCache {
+	if code, ok := rm.codeCache.get(route.ID, route.Version); ok {
+		return code, nil
+	}
+
+	routeJSON, err := rm.redisClient.HGet(context.Background(), "gateway:routes", route.ID).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to load code for route %s: %v", route.ID, err)
+	}
+
+	stored, err := decodeStoredRoute([]byte(routeJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode route %s: %v", route.ID, err)
+	}
+
+	rm.codeCache.put(route.ID, stored.Version, stored.Code)
+	return stored.Code, nil
+}
+
+// 路由缓存内存统计
+func (rm *RouteManager) cacheStats() map[string]interface{} {
+	table := rm.snapshot()
+	settings := gatewaySettings()
+	return map[string]interface{}{
+		"routes":              table.size(),
+		"memory_bytes":        table.memoryBytes,
+		"max_memory_bytes":    settings.MaxCacheMemory,
+		"max_code_size":       settings.MaxCodeSize,
+		"lazy_code_threshold": rm.lazyCodeThreshold,
+		"lazy_code_routes":    len(table.lazyCode),
+		"code_cache":          rm.codeCache.stats(),
+		"match_cache":         rm.matchCache.stats(),
+	}
+}
+
+// 读取持久化的完整路由（Redis 可用时以 Redis 为准，包含延迟加载的代码）
+func (rm *RouteManager) loadPersistedRoutes(ctx context.Context) ([]RouteConfig, error) {
+	if !rm.redisEnabled {
+		return rm.GetAllRoutes(), nil
+	}
+
+	stored, err := rm.redisClient.HGetAll(ctx, "gateway:routes").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load routes from Redis: %v", err)
+	}
+
+	routes := make([]RouteConfig, 0, len(stored))
+	for routeID, routeJSON := range stored {
+		route, err := decodeStoredRoute([]byte(routeJSON))
+		if err != nil {
+			log.Printf("Skipping undecodable route %s: %v", routeID, err)
+			continue
+		}
 		routes = append(routes, route)
 	}
-	return routes
+	return routes, nil
+}
+
+// 获取所有路由
+func (rm *RouteManager) GetAllRoutes() []RouteConfig {
+	return rm.snapshot().list()
 }
 
 // 获取事件流管理器（用于管理接口）
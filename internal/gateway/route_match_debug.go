@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 候选路由未匹配的原因
+const (
+	matchRejectTenant = "tenant mismatch"
+	matchRejectWindow = "outside active window"
+	matchRejectHost   = "host mismatch"
+	matchRejectMethod = "method mismatch"
+	matchRejectPath   = "path mismatch"
+)
+
+// 匹配结果的来源
+const (
+	matchSourceRoute            = "route"              // 路由匹配
+	matchSourceDefaultRoute     = "default_route"      // 没有路由匹配，使用按 Host 配置的默认路由
+	matchSourceRedirect         = "redirect"           // 末尾斜杠策略为 redirect，返回 308
+	matchSourceMethodNotAllowed = "method_not_allowed" // 路径匹配但方法不匹配，返回 405
+	matchSourceReserved         = "reserved"           // 网关内置的路径（探针、OAuth 令牌端点、隧道接入、Dify 接口），不经过路由匹配
+	matchSourceNone             = "none"               // 返回 404
+)
+
+// 路径索引给出的一条候选路由
+type RouteMatchCandidate struct {
+	RouteID  string   `json:"route_id"`
+	Path     string   `json:"path"`
+	Methods  []string `json:"methods"`
+	Tenant   string   `json:"tenant,omitempty"`
+	Host     string   `json:"host,omitempty"`
+	Depth    int      `json:"depth"`    // 静态前缀段数，优先级相同时更深的路由优先
+	Priority int      `json:"priority"` // 匹配时的优先级（含租户和域名加成），未匹配为 0
+	Matched  bool     `json:"matched"`
+	Reason   string   `json:"reason,omitempty"` // 未匹配的原因
+	Winner   bool     `json:"winner,omitempty"`
+}
+
+// 🔧 新增：路由匹配调试结果
+type RouteMatchExplanation struct {
+	Method         string                `json:"method"`
+	Path           string                `json:"path"` // 规范化后用于匹配的路径
+	Host           string                `json:"host,omitempty"`
+	Tenant         string                `json:"tenant,omitempty"`
+	Source         string                `json:"source"`
+	MatchedPath    string                `json:"matched_path,omitempty"` // 末尾斜杠策略为 ignore 时实际匹配的路径
+	RedirectTo     string                `json:"redirect_to,omitempty"`
+	AllowedMethods []string              `json:"allowed_methods,omitempty"`
+	Route          *RouteConfig          `json:"route"`
+	Priority       int                   `json:"priority,omitempty"`
+	Candidates     []RouteMatchCandidate `json:"candidates"`
+}
+
+// 按匹配算法检查路径上的全部候选路由，返回胜出的路由ID、优先级和每条候选的得分（不读写匹配缓存）
+func (rm *RouteManager) explainMatch(path, method, tenant, host string) (string, int, []RouteMatchCandidate) {
+	table := rm.snapshot()
+	now := time.Now().Unix()
+
+	candidates := make([]RouteMatchCandidate, 0)
+	var matchedID string
+	var matchPriority, matchDepth int
+	table.index.each(path, func(depth int, entry routeTrieEntry) {
+		priority, reason := rm.scoreRouteEntry(entry, path, method, tenant, host, now)
+		candidates = append(candidates, RouteMatchCandidate{
+			RouteID:  entry.id,
+			Path:     entry.path,
+			Methods:  entry.methods,
+			Tenant:   entry.tenant,
+			Host:     entry.host,
+			Depth:    depth,
+			Priority: priority,
+			Matched:  priority > 0,
+			Reason:   reason,
+		})
+		if priority > matchPriority || (priority > 0 && priority == matchPriority && depth > matchDepth) {
+			matchedID = entry.id
+			matchPriority = priority
+			matchDepth = depth
+		}
+	})
+
+	for i := range candidates {
+		candidates[i].Winner = candidates[i].RouteID == matchedID
+	}
+	// 按胜出顺序排列：匹配的在前，优先级高、静态前缀深、路由ID小的在前
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Depth != b.Depth {
+			return a.Depth > b.Depth
+		}
+		return a.RouteID < b.RouteID
+	})
+	return matchedID, matchPriority, candidates
+}
+
+// 网关在路由匹配之前处理的路径
+func reservedGatewayPath(path string) bool {
+	switch path {
+	case "/healthz", "/readyz":
+		return true
+	}
+	settings := gatewaySettings()
+	if settings.OAuth.Enabled && settings.OAuth.TokenPath != "" && path == settings.OAuth.TokenPath {
+		return true
+	}
+	if settings.Tunnel.Enabled && settings.Tunnel.Path != "" && path == settings.Tunnel.Path {
+		return true
+	}
+	if settings.Dify.Enabled {
+		prefix := "/" + strings.Trim(settings.Dify.PathPrefix, "/")
+		return path == prefix+"/openapi.json" || path == prefix+"/extension" || strings.HasPrefix(path, prefix+"/tools/")
+	}
+	return false
+}
+
+// 🔧 新增：路由匹配调试：按网关的路径规范化和匹配算法找出请求会命中的路由，列出全部候选路由及得分，不转发请求。
+// GET /admin/routes/match?path=/foo&method=POST&host=x.com&tenant=acme
+func (dr *DistributedRouter) matchRouteHandler(c *gin.Context) {
+	rawPath := c.Query("path")
+	if !strings.HasPrefix(rawPath, "/") {
+		c.JSON(400, gin.H{"error": "path is required and must start with /"})
+		return
+	}
+	requestURL, err := url.ParseRequestURI(rawPath)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid path: " + err.Error()})
+		return
+	}
+	method := strings.ToUpper(c.DefaultQuery("method", http.MethodGet))
+
+	// 与网关端口相同的路径规范化
+	collapse := gatewaySettings().PathNormalization.CollapseSlashes
+	requestURL.Path = cleanRequestPath(requestURL.Path, collapse)
+	if requestURL.RawPath != "" {
+		requestURL.RawPath = cleanRequestPath(requestURL.RawPath, collapse)
+	}
+	request := &http.Request{Method: method, URL: requestURL, Host: c.Query("host")}
+
+	explanation := &RouteMatchExplanation{
+		Method: method,
+		Path:   matchPath(request),
+		Host:   requestHost(request),
+		Tenant: c.Query("tenant"),
+		Source: matchSourceNone,
+	}
+	if reservedGatewayPath(requestURL.Path) {
+		explanation.Source = matchSourceReserved
+		explanation.Candidates = make([]RouteMatchCandidate, 0)
+		c.JSON(200, explanation)
+		return
+	}
+
+	rm := dr.routeManager
+	matchedID, priority, candidates := rm.explainMatch(explanation.Path, method, explanation.Tenant, explanation.Host)
+	explanation.Candidates = candidates
+
+	// 末尾斜杠策略：字面未匹配时按去掉或补上末尾斜杠的路径再匹配
+	if policy := gatewaySettings().PathNormalization.TrailingSlash; matchedID == "" && (policy == trailingSlashIgnore || policy == trailingSlashRedirect) {
+		if alternate, ok := toggleTrailingSlash(explanation.Path); ok {
+			if altID, altPriority, altCandidates := rm.explainMatch(alternate, method, explanation.Tenant, explanation.Host); altID != "" {
+				explanation.Candidates = altCandidates
+				if policy == trailingSlashRedirect {
+					explanation.Source = matchSourceRedirect
+					explanation.RedirectTo = alternate
+				} else {
+					matchedID, priority = altID, altPriority
+					explanation.MatchedPath = alternate
+				}
+			}
+		}
+	}
+
+	switch {
+	case matchedID != "":
+		if route, exists := rm.snapshot().get(matchedID); exists {
+			explanation.Source = matchSourceRoute
+			explanation.Route = &route
+			explanation.Priority = priority
+		}
+	case explanation.Source == matchSourceRedirect:
+	default:
+		if methods := rm.allowedMethods(explanation.Path, explanation.Tenant, explanation.Host); len(methods) > 0 {
+			explanation.Source = matchSourceMethodNotAllowed
+			explanation.AllowedMethods = methods
+		} else if fallback := dr.defaultRouteFor(explanation.Host, explanation.Tenant); fallback != nil {
+			explanation.Source = matchSourceDefaultRoute
+			explanation.Route = fallback
+		}
+	}
+	c.JSON(200, explanation)
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// 请求方法：大写字母组成的 token
+var routeMethodPattern = regexp.MustCompile(`^[A-Z]+$`)
+
+// 🔧 新增：路由接受的方法，method（单个方法或 ANY）与 methods（方法列表，如 [GET, HEAD]）二选一
+func (route *RouteConfig) methodList() []string {
+	if len(route.Methods) > 0 {
+		return route.Methods
+	}
+	return []string{route.Method}
+}
+
+func validateRouteMethods(route RouteConfig) error {
+	if len(route.Methods) == 0 {
+		if route.Method == "" {
+			return fmt.Errorf("route method is required")
+		}
+		return nil
+	}
+	if route.Method != "" {
+		return fmt.Errorf("method and methods cannot be used together")
+	}
+	seen := make(map[string]bool, len(route.Methods))
+	for _, method := range route.Methods {
+		switch {
+		case method == "ANY":
+			return fmt.Errorf("methods must list specific methods; use method: ANY to accept all methods")
+		case !routeMethodPattern.MatchString(method):
+			return fmt.Errorf("invalid method in methods: %q (use uppercase, e.g. GET)", method)
+		case seen[method]:
+			return fmt.Errorf("duplicate method in methods: %s", method)
+		}
+		seen[method] = true
+	}
+	return nil
+}
+
+// 路由是否接受该方法
+func (route *RouteConfig) allowsMethod(method string) bool {
+	for _, m := range route.methodList() {
+		if m == method || m == "ANY" {
+			return true
+		}
+	}
+	return false
+}
+
+// 两条路由接受的方法是否有交集
+func routeMethodsOverlap(a, b RouteConfig) bool {
+	for _, method := range a.methodList() {
+		if method == "ANY" || b.allowsMethod(method) {
+			return true
+		}
+	}
+	return false
+}
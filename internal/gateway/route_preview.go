@@ -0,0 +1,154 @@
+package gateway
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 🔧 新增：路由更新预览（dry_run），不持久化、不发布事件
+type RoutePreview struct {
+	Changes           map[string]FieldChange `json:"changes"`
+	MatchType         string                 `json:"match_type"`
+	EffectivePriority int                    `json:"effective_priority"`
+	PrioritySource    string                 `json:"priority_source"`
+	Conflicts         []RouteConflict        `json:"conflicts"`
+}
+
+// 与路由竞争同一批请求的其他路由
+type RouteConflict struct {
+	RouteID           string   `json:"route_id"`
+	Path              string   `json:"path"`
+	Method            string   `json:"method"`
+	Methods           []string `json:"methods,omitempty"` // 🔧 新增：按方法列表匹配的路由
+	EffectivePriority int      `json:"effective_priority"`
+	Winner            string   `json:"winner"`              // 两者都匹配时胜出的路由ID
+	Ambiguous         bool     `json:"ambiguous,omitempty"` // 🔧 新增：优先级相同，胜出者只由路由ID决定
+}
+
+// 🔧 新增：创建或更新路由时与现有路由的优先级相同且会匹配同一请求
+var errAmbiguousRoute = fmt.Errorf("route overlaps existing routes with the same priority; set priority to order them or use force=true")
+
+// 按与 UpdateRoute 相同的规则校验更新，返回修改的字段、更新后的优先级和冲突的路由
+func (rm *RouteManager) PreviewUpdate(routeID string, newRoute RouteConfig) (*RoutePreview, error) {
+	table := rm.snapshot()
+	previous, exists := table.get(routeID)
+	if !exists {
+		return nil, fmt.Errorf("route %s not found", routeID)
+	}
+	if err := rm.validateRouteConfiguration(newRoute); err != nil {
+		return nil, err
+	}
+	if routeID != newRoute.ID {
+		return nil, fmt.Errorf("route ID cannot be changed")
+	}
+	if err := rm.checkCacheMemory(routeID, newRoute); err != nil {
+		return nil, err
+	}
+
+	if code, err := rm.resolveCode(&previous); err == nil {
+		previous.Code = code
+	}
+	table.resolveGroup(&newRoute)
+	preview := &RoutePreview{
+		Changes:   diffRoutes(previous, newRoute),
+		Conflicts: routeConflicts(table, newRoute),
+	}
+	preview.MatchType, preview.EffectivePriority, preview.PrioritySource = effectiveRoutePriority(newRoute)
+	return preview, nil
+}
+
+// 🔧 新增：创建或更新前检查与现有路由的重叠：优先级相同的重叠（包括完全重复的路由）返回 errAmbiguousRoute，
+// force 时只作为警告；其他重叠由优先级决定胜出者，作为警告返回。无效的路由不检查，由写入时的校验返回错误
+func (rm *RouteManager) checkRouteConflicts(route RouteConfig, force bool) ([]RouteConflict, error) {
+	if rm.validateRouteConfiguration(route) != nil {
+		return nil, nil
+	}
+	table := rm.snapshot()
+	table.resolveGroup(&route)
+	conflicts := routeConflicts(table, route)
+	if !force {
+		for _, conflict := range conflicts {
+			if conflict.Ambiguous {
+				return conflicts, errAmbiguousRoute
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// 路径相同、方法、租户和域名有交集的其他路由（按路由ID排序）
+func routeConflicts(table *routeTable, route RouteConfig) []RouteConflict {
+	_, priority, _ := effectiveRoutePriority(route)
+	conflicts := make([]RouteConflict, 0)
+	for _, other := range table.routes {
+		if other.ID == route.ID || !routesOverlap(route, other) {
+			continue
+		}
+		_, otherPriority, _ := effectiveRoutePriority(other)
+		winner := route.ID
+		if otherPriority > priority || (otherPriority == priority && other.ID < route.ID) {
+			winner = other.ID
+		}
+		conflicts = append(conflicts, RouteConflict{
+			RouteID:           other.ID,
+			Path:              other.fullPath(),
+			Method:            other.Method,
+			Methods:           other.Methods,
+			EffectivePriority: otherPriority,
+			Winner:            winner,
+			Ambiguous:         otherPriority == priority,
+		})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].RouteID < conflicts[j].RouteID })
+	return conflicts
+}
+
+// 两条路由是否会匹配同一个请求（路径按字面比较；🔧 正则路由与精确路径的路由按正则是否匹配该路径判断）
+func routesOverlap(a, b RouteConfig) bool {
+	caseInsensitive := gatewaySettings().PathNormalization.CaseInsensitive
+	if a.PathRegex == "" && b.PathRegex != "" {
+		a, b = b, a
+	}
+	if a.PathRegex != "" && b.PathRegex == "" {
+		re, err := compileRoutePathRegex(a, caseInsensitive)
+		if err != nil || strings.ContainsAny(b.fullPath(), "{*") || !re.MatchString(b.fullPath()) {
+			return false
+		}
+	} else if caseInsensitive {
+		if !strings.EqualFold(a.fullPath(), b.fullPath()) {
+			return false
+		}
+	} else if a.fullPath() != b.fullPath() {
+		return false
+	}
+	if !routeMethodsOverlap(a, b) {
+		return false
+	}
+	if a.Tenant != "" && b.Tenant != "" && a.Tenant != b.Tenant {
+		return false
+	}
+	// 🔧 新增：生效时间窗口不相交的路由不会同时匹配
+	if !routeWindowsOverlap(a, b) {
+		return false
+	}
+	return routeHostsOverlap(strings.ToLower(a.Host), strings.ToLower(b.Host))
+}
+
+// 两个路由 host 是否存在同时匹配的域名
+func routeHostsOverlap(a, b string) bool {
+	if a == "" || b == "" || a == b {
+		return true
+	}
+	if strings.HasPrefix(a, "*.") {
+		if _, ok := routeHostBonus(a, strings.TrimPrefix(b, "*.")); ok {
+			return true
+		}
+	}
+	if strings.HasPrefix(b, "*.") {
+		if _, ok := routeHostBonus(b, strings.TrimPrefix(a, "*.")); ok {
+			return true
+		}
+	}
+	return false
+}
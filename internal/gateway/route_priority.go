@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"strings"
+	"time"
+)
+
+// 🔧 新增：路由匹配优先级
+// 未设置 priority 的路由按匹配类型计算：精确 100、参数 90、正则 85、前缀 80、通配符 70，
+// 租户路由 +1，精确域名路由 +4、通配域名路由 +2；设置 priority 后直接使用该值（不再叠加）。
+// 优先级相同时静态前缀更长的路由优先，其次是路由ID较小的
+const (
+	matchPriorityExact    = 100
+	matchPriorityParam    = 90
+	matchPriorityRegex    = 85 // 🔧 新增：path_regex 路由
+	matchPriorityPrefix   = 80
+	matchPriorityWildcard = 70
+
+	maxRoutePriority = 1000000
+)
+
+// 路由列表中的路由及其生效的优先级
+type routeListing struct {
+	RouteConfig
+	MatchType         string `json:"match_type"`              // exact、param、regex、wildcard（exact 路由的子路径按 prefix 匹配）
+	EffectivePriority int    `json:"effective_priority"`      // 按 match_type 匹配时的优先级
+	PrioritySource    string `json:"priority_source"`         // explicit 或 heuristic
+	FullPath          string `json:"full_path,omitempty"`     // 🔧 新增：分组路由匹配的完整路径（分组前缀 + path）
+	WindowStatus      string `json:"window_status,omitempty"` // 🔧 新增：设置了生效时间窗口时为 pending、active 或 expired
+	CodeLazy          bool   `json:"code_lazy,omitempty"`     // 🔧 新增：代码未常驻内存，列表中 code 为空，详情接口返回完整代码
+}
+
+// 路由按路径形式的匹配类型及对应的优先级
+func effectiveRoutePriority(route RouteConfig) (matchType string, priority int, source string) {
+	switch {
+	case route.PathRegex != "":
+		matchType, priority = "regex", matchPriorityRegex
+	case strings.Contains(route.Path, "{"):
+		matchType, priority = "param", matchPriorityParam
+	case strings.Contains(route.Path, "*"):
+		matchType, priority = "wildcard", matchPriorityWildcard
+	default:
+		matchType, priority = "exact", matchPriorityExact
+	}
+	if route.Priority > 0 {
+		return matchType, route.Priority, "explicit"
+	}
+	if route.Tenant != "" {
+		priority++
+	}
+	switch {
+	case route.Host == "":
+	case strings.HasPrefix(route.Host, "*."):
+		priority += routeHostWildcardBonus
+	default:
+		priority += routeHostExactBonus
+	}
+	return matchType, priority, "heuristic"
+}
+
+func withEffectivePriority(routes []RouteConfig) []routeListing {
+	listings := make([]routeListing, 0, len(routes))
+	now := time.Now().Unix()
+	for _, route := range routes {
+		matchType, priority, source := effectiveRoutePriority(route)
+		listing := routeListing{RouteConfig: route, MatchType: matchType, EffectivePriority: priority, PrioritySource: source}
+		if route.GroupID != "" {
+			listing.FullPath = route.fullPath()
+		}
+		listing.WindowStatus = route.windowStatus(now)
+		listings = append(listings, listing)
+	}
+	return listings
+}
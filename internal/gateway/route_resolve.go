@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 按路由解析规则缓存的 Transport 数上限，超出时关闭空闲连接后重建
+const maxRouteTransports = 256
+
+// 🔧 新增：proxy 路由的静态解析（类似 curl --resolve）：目标域名 -> IP（多个地址用逗号分隔）。
+// 只改变连接的地址，Host 头和 TLS 证书校验仍使用目标域名；优先于 gateway.dns 的 overrides 和 DNS
+func validateRouteResolve(route RouteConfig) error {
+	if len(route.Resolve) == 0 {
+		return nil
+	}
+	if route.Handler != "proxy" {
+		return fmt.Errorf("resolve is only supported for proxy routes")
+	}
+	for host, value := range route.Resolve {
+		if host == "" || strings.ContainsAny(host, ":/[] ") {
+			return fmt.Errorf("invalid resolve host %q: use a hostname without port", host)
+		}
+		if _, err := parseOverrideAddresses(value); err != nil {
+			return fmt.Errorf("invalid resolve entry for %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// 解析规则相同的路由共用一个 Transport：连接池按目标地址复用，不能与按 DNS 解析建立的连接混用
+var routeTransports = struct {
+	mutex      sync.Mutex
+	transports map[string]*http.Transport
+}{transports: make(map[string]*http.Transport)}
+
+// 路由转发使用的 Transport，没有 resolve 时使用共用的上游 Transport
+func routeTransport(route *RouteConfig) http.RoundTripper {
+	if len(route.Resolve) == 0 {
+		return upstreamTransport
+	}
+	overrides := make(map[string][]net.IP, len(route.Resolve))
+	entries := make([]string, 0, len(route.Resolve))
+	for host, value := range route.Resolve {
+		// 已在写入路由时校验
+		ips, _ := parseOverrideAddresses(value)
+		overrides[normalizeDNSHost(host)] = ips
+		entries = append(entries, normalizeDNSHost(host)+"="+value)
+	}
+	sort.Strings(entries)
+	key := strings.Join(entries, ";")
+
+	routeTransports.mutex.Lock()
+	defer routeTransports.mutex.Unlock()
+	if transport := routeTransports.transports[key]; transport != nil {
+		return transport
+	}
+	if len(routeTransports.transports) >= maxRouteTransports {
+		for _, transport := range routeTransports.transports {
+			transport.CloseIdleConnections()
+		}
+		routeTransports.transports = make(map[string]*http.Transport)
+	}
+	transport := upstreamTransport.Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			if ips, ok := overrides[normalizeDNSHost(host)]; ok {
+				return dialAddresses(ctx, network, host, port, ips)
+			}
+		}
+		return dialUpstream(ctx, network, address)
+	}
+	routeTransports.transports[key] = transport
+	return transport
+}
@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 路由标签：小写字母、数字开头，可包含 . _ : / -，如 team-a、env:prod
+const maxRouteTags = 16
+
+var routeTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._:/-]{0,62}$`)
+
+// 路由列表分页的最大页大小
+const maxRouteListLimit = 1000
+
+// 🔧 新增：按关键字搜索路由，任一字段或标签包含关键字（忽略大小写）即命中
+func searchRoutes(routes []RouteConfig, query string) []RouteConfig {
+	query = strings.ToLower(query)
+	matched := make([]RouteConfig, 0)
+	for _, route := range routes {
+		fields := []string{route.ID, route.Path, route.PathRegex, route.Host, route.Tenant, route.Description, route.ContactOwner, route.DocsURL}
+		fields = append(fields, route.Tags...)
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), query) {
+				matched = append(matched, route)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// 校验路由标签
+func validateRouteTags(tags []string) error {
+	if len(tags) > maxRouteTags {
+		return fmt.Errorf("a route can have at most %d tags", maxRouteTags)
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !routeTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q: use lowercase letters, digits and . _ : / -", tag)
+		}
+		if seen[tag] {
+			return fmt.Errorf("duplicate tag: %s", tag)
+		}
+		seen[tag] = true
+	}
+	return nil
+}
+
+func (route *RouteConfig) hasTag(tag string) bool {
+	for _, t := range route.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// 🔧 新增：路由列表的筛选、排序和分页参数
+// GET /admin/routes?q=hello&tag=team-a&tag=env:prod&handler=sandbox&method=POST&tenant=acme&host=api.example.com&group_id=v2&sort=-updated_at&limit=50&offset=100
+type routeListQuery struct {
+	Query   string
+	Tags    []string // 需要同时带有的标签
+	Handler string
+	Method  string
+	Tenant  string
+	Host    string
+	GroupID string
+	Sort    string // id（默认）、path、priority、created_at、updated_at，前缀 - 表示降序
+	Limit   int    // 0 表示不分页
+	Offset  int
+}
+
+// 路由列表可排序的字段
+var routeListSorts = map[string]func(a, b *routeListing) int{
+	"id": func(a, b *routeListing) int { return strings.Compare(a.ID, b.ID) },
+	"path": func(a, b *routeListing) int {
+		return strings.Compare(a.Path+a.PathRegex, b.Path+b.PathRegex)
+	},
+	"priority":   func(a, b *routeListing) int { return a.EffectivePriority - b.EffectivePriority },
+	"created_at": func(a, b *routeListing) int { return compareInt64(a.CreatedAt, b.CreatedAt) },
+	"updated_at": func(a, b *routeListing) int { return compareInt64(a.UpdatedAt, b.UpdatedAt) },
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func parseRouteListQuery(c *gin.Context) (routeListQuery, error) {
+	query := routeListQuery{
+		Query:   strings.TrimSpace(c.Query("q")),
+		Tags:    c.QueryArray("tag"),
+		Handler: c.Query("handler"),
+		Method:  strings.ToUpper(c.Query("method")),
+		Tenant:  c.Query("tenant"),
+		Host:    c.Query("host"),
+		GroupID: c.Query("group_id"),
+		Sort:    c.DefaultQuery("sort", "id"),
+	}
+	if _, ok := routeListSorts[strings.TrimPrefix(query.Sort, "-")]; !ok {
+		return query, fmt.Errorf("invalid sort: %s (use id, path, priority, created_at or updated_at, prefix - for descending)", query.Sort)
+	}
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 || query.Limit > maxRouteListLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxRouteListLimit)
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return query, nil
+}
+
+// 路由是否满足筛选条件（不含关键字搜索）
+func (query *routeListQuery) matches(route *RouteConfig) bool {
+	if query.Handler != "" && route.Handler != query.Handler {
+		return false
+	}
+	if query.Method != "" && !slices.Contains(route.methodList(), query.Method) {
+		return false
+	}
+	if query.Tenant != "" && route.Tenant != query.Tenant {
+		return false
+	}
+	if query.Host != "" && !strings.EqualFold(route.Host, query.Host) {
+		return false
+	}
+	if query.GroupID != "" && route.GroupID != query.GroupID {
+		return false
+	}
+	for _, tag := range query.Tags {
+		if !route.hasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// 筛选、排序并分页，返回当前页和筛选后的总数
+func (query *routeListQuery) apply(routes []RouteConfig) ([]routeListing, int) {
+	if query.Query != "" {
+		routes = searchRoutes(routes, query.Query)
+	}
+	filtered := make([]RouteConfig, 0, len(routes))
+	for i := range routes {
+		if query.matches(&routes[i]) {
+			filtered = append(filtered, routes[i])
+		}
+	}
+
+	listings := withEffectivePriority(filtered)
+	descending := strings.HasPrefix(query.Sort, "-")
+	compare := routeListSorts[strings.TrimPrefix(query.Sort, "-")]
+	sort.SliceStable(listings, func(i, j int) bool {
+		result := compare(&listings[i], &listings[j])
+		if result == 0 {
+			// 相同时按路由ID排序，分页顺序稳定
+			return listings[i].ID < listings[j].ID
+		}
+		if descending {
+			return result > 0
+		}
+		return result < 0
+	})
+
+	total := len(listings)
+	if query.Offset >= total {
+		return listings[:0], total
+	}
+	listings = listings[query.Offset:]
+	if query.Limit > 0 && len(listings) > query.Limit {
+		listings = listings[:query.Limit]
+	}
+	return listings, total
+}
@@ -0,0 +1,407 @@
+package gateway
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// 路由的固定内存开销估算（结构体、map 条目与匹配器）
+	routeMemoryOverhead = 512
+	// 保留的删除记录上限，超出后最早的记录被丢弃，更早版本的增量请求需要全量同步
+	maxRouteTombstones = 10000
+)
+
+// 不可变路由表快照（写时复制）
+// 读路径通过 atomic.Pointer 无锁获取快照，写路径复制后整体替换，
+// 批量导入或全量重载期间请求匹配不会被阻塞
+type routeTable struct {
+	routes        map[string]RouteConfig
+	versions      map[string]int64
+	matchers      map[string]*routeMatcher
+	index         *routeTrie             // 🔧 新增：按路径段组织的路由索引，匹配时只检查候选路由
+	lazyCode      map[string]bool        // 代码未常驻内存、需要从 Redis 加载的路由
+	sizes         map[string]int64       // 每条路由的内存估算
+	memoryBytes   int64                  // 路由表内存估算总量
+	lazyThreshold int                    // 超过该大小的代码不常驻内存，0 表示全部常驻
+	configVersion int64                  // 快照对应的配置版本，每次发布新快照时递增
+	groupPrefixes map[string]string      // 🔧 新增：路由分组ID -> 前缀（整体替换，快照之间共享）
+	windows       map[string]routeWindow // 🔧 新增：设置了生效时间窗口的路由
+	defaults      map[string]bool        // 🔧 新增：is_default 路由
+
+	// 变更追踪（用于 watch/增量同步）
+	createdAt      map[string]int64 // 路由首次出现时的配置版本
+	changedAt      map[string]int64 // 路由最后一次变更时的配置版本
+	tombstones     map[string]int64 // 已删除路由及删除时的配置版本
+	tombstoneFloor int64            // 早于该版本的删除记录已被丢弃
+	touched        map[string]bool  // 本快照发布前被修改过的路由
+	rebuilt        bool             // 全新构建的路由表，发布时与旧快照逐条比较
+
+	etagOnce sync.Once // 🔧 新增：路由列表的 ETag，按快照内容计算一次
+	etag     string
+
+	boundsOnce sync.Once // 🔧 新增：窗口边界（升序），按快照计算一次
+	bounds     []int64
+	epochCache atomic.Pointer[windowEpochSample] // 最近一秒的窗口纪元
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		routes:     make(map[string]RouteConfig),
+		versions:   make(map[string]int64),
+		matchers:   make(map[string]*routeMatcher),
+		index:      newRouteTrie(gatewaySettings().PathNormalization.CaseInsensitive),
+		lazyCode:   make(map[string]bool),
+		sizes:      make(map[string]int64),
+		windows:    make(map[string]routeWindow),
+		defaults:   make(map[string]bool),
+		createdAt:  make(map[string]int64),
+		changedAt:  make(map[string]int64),
+		tombstones: make(map[string]int64),
+		rebuilt:    true,
+	}
+}
+
+// 复制路由表，匹配器是只读的，可以在快照之间共享
+func (t *routeTable) clone() *routeTable {
+	next := &routeTable{
+		routes:        make(map[string]RouteConfig, len(t.routes)),
+		versions:      make(map[string]int64, len(t.versions)),
+		matchers:      make(map[string]*routeMatcher, len(t.matchers)),
+		index:         t.index.clone(),
+		lazyCode:      make(map[string]bool, len(t.lazyCode)),
+		sizes:         make(map[string]int64, len(t.sizes)),
+		memoryBytes:   t.memoryBytes,
+		lazyThreshold: t.lazyThreshold,
+		configVersion: t.configVersion,
+		groupPrefixes: t.groupPrefixes,
+		windows:       make(map[string]routeWindow, len(t.windows)),
+		defaults:      make(map[string]bool, len(t.defaults)),
+
+		createdAt:      make(map[string]int64, len(t.createdAt)),
+		changedAt:      make(map[string]int64, len(t.changedAt)),
+		tombstones:     make(map[string]int64, len(t.tombstones)),
+		tombstoneFloor: t.tombstoneFloor,
+		touched:        make(map[string]bool),
+	}
+	for id, route := range t.routes {
+		next.routes[id] = route
+	}
+	for id, version := range t.versions {
+		next.versions[id] = version
+	}
+	for id, matcher := range t.matchers {
+		next.matchers[id] = matcher
+	}
+	for id, lazy := range t.lazyCode {
+		next.lazyCode[id] = lazy
+	}
+	for id, size := range t.sizes {
+		next.sizes[id] = size
+	}
+	for id, window := range t.windows {
+		next.windows[id] = window
+	}
+	for id := range t.defaults {
+		next.defaults[id] = true
+	}
+	for id, version := range t.createdAt {
+		next.createdAt[id] = version
+	}
+	for id, version := range t.changedAt {
+		next.changedAt[id] = version
+	}
+	for id, version := range t.tombstones {
+		next.tombstones[id] = version
+	}
+	return next
+}
+
+// 写入路由并编译匹配器（只能在尚未发布的快照上调用）
+func (t *routeTable) put(routeID string, route RouteConfig) {
+	t.remove(routeID)
+
+	// 大代码块不常驻内存，首次执行时从 Redis 延迟加载
+	if t.lazyThreshold > 0 && len(route.Code) > t.lazyThreshold {
+		route.Code = ""
+		t.lazyCode[routeID] = true
+	}
+
+	size := estimateRouteMemory(route)
+	grouped := t.resolveGroup(&route)
+	t.routes[routeID] = route
+	t.versions[routeID] = route.Version
+	t.matchers[routeID] = compileRouteMatcher(route)
+	if grouped {
+		t.index.insert(routeID, route, t.matchers[routeID])
+	}
+	t.sizes[routeID] = size
+	t.memoryBytes += size
+	if route.ActiveFrom > 0 || route.ExpiresAt > 0 {
+		t.windows[routeID] = routeWindow{activeFrom: route.ActiveFrom, expiresAt: route.ExpiresAt}
+	}
+	if route.IsDefault {
+		t.defaults[routeID] = true
+	}
+}
+
+// 按分组前缀设置路由的匹配路径；引用的分组尚未同步到本实例时返回 false，路由暂不参与匹配
+func (t *routeTable) resolveGroup(route *RouteConfig) bool {
+	if route.GroupID == "" {
+		route.groupPrefix = ""
+		return true
+	}
+	prefix, ok := t.groupPrefixes[route.GroupID]
+	route.groupPrefix = prefix
+	return ok
+}
+
+// 分组前缀变化后重建路由的匹配器和索引，路由配置不变（只能在尚未发布的快照上调用）
+func (t *routeTable) reindex(routeID string) {
+	route, exists := t.routes[routeID]
+	if !exists {
+		return
+	}
+	t.index.remove(routeID, route.indexPath())
+	grouped := t.resolveGroup(&route)
+	t.routes[routeID] = route
+	t.matchers[routeID] = compileRouteMatcher(route)
+	if grouped {
+		t.index.insert(routeID, route, t.matchers[routeID])
+	}
+}
+
+// 移除路由（只能在尚未发布的快照上调用）
+func (t *routeTable) remove(routeID string) {
+	if t.touched != nil {
+		t.touched[routeID] = true
+	}
+	if route, exists := t.routes[routeID]; exists {
+		t.index.remove(routeID, route.indexPath())
+	}
+	t.memoryBytes -= t.sizes[routeID]
+	delete(t.routes, routeID)
+	delete(t.versions, routeID)
+	delete(t.matchers, routeID)
+	delete(t.lazyCode, routeID)
+	delete(t.sizes, routeID)
+	delete(t.windows, routeID)
+	delete(t.defaults, routeID)
+}
+
+// 路由的生效时间窗口（Unix 秒，0 表示不限）
+type routeWindow struct {
+	activeFrom int64
+	expiresAt  int64
+}
+
+// 某一秒的窗口纪元
+type windowEpochSample struct {
+	now   int64
+	epoch int
+}
+
+// 🔧 新增：窗口纪元：已经过去的窗口边界数。路由进入或离开窗口时纪元变化，匹配缓存随之失效；
+// 边界按快照排序一次，纪元按秒缓存，同一秒内的请求不再遍历窗口（只能在已发布的快照上调用）
+func (t *routeTable) windowEpoch(now int64) int {
+	if len(t.windows) == 0 {
+		return 0
+	}
+	if sample := t.epochCache.Load(); sample != nil && sample.now == now {
+		return sample.epoch
+	}
+	t.boundsOnce.Do(func() {
+		bounds := make([]int64, 0, 2*len(t.windows))
+		for _, window := range t.windows {
+			if window.activeFrom > 0 {
+				bounds = append(bounds, window.activeFrom)
+			}
+			if window.expiresAt > 0 {
+				bounds = append(bounds, window.expiresAt)
+			}
+		}
+		sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+		t.bounds = bounds
+	})
+	epoch := sort.Search(len(t.bounds), func(i int) bool { return t.bounds[i] > now })
+	t.epochCache.Store(&windowEpochSample{now: now, epoch: epoch})
+	return epoch
+}
+
+// 已过期的路由ID
+func (t *routeTable) expired(now int64) []string {
+	var ids []string
+	for id, window := range t.windows {
+		if window.expiresAt > 0 && now >= window.expiresAt {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 获取路由
+func (t *routeTable) get(routeID string) (RouteConfig, bool) {
+	route, exists := t.routes[routeID]
+	return route, exists
+}
+
+// 列出全部路由
+func (t *routeTable) list() []RouteConfig {
+	routes := make([]RouteConfig, 0, len(t.routes))
+	for _, route := range t.routes {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// 路由数量
+func (t *routeTable) size() int {
+	return len(t.routes)
+}
+
+// 发布前记录变更版本：增量修改只处理被修改的路由，重建的路由表与旧快照逐条比较
+func (t *routeTable) stampChanges(prev *routeTable, version int64) {
+	if t.rebuilt {
+		if prev != nil {
+			t.tombstoneFloor = prev.tombstoneFloor
+			for id, deletedAt := range prev.tombstones {
+				t.tombstones[id] = deletedAt
+			}
+		}
+		for id, route := range t.routes {
+			delete(t.tombstones, id)
+			t.createdAt[id] = version
+			t.changedAt[id] = version
+			if prev == nil {
+				continue
+			}
+			if _, existed := prev.routes[id]; existed {
+				t.createdAt[id] = prev.createdAt[id]
+				if prev.versions[id] == route.Version {
+					t.changedAt[id] = prev.changedAt[id]
+				}
+			}
+		}
+		if prev != nil {
+			for id := range prev.routes {
+				if _, exists := t.routes[id]; !exists {
+					t.tombstones[id] = version
+				}
+			}
+		}
+	} else {
+		for id := range t.touched {
+			if _, exists := t.routes[id]; exists {
+				if _, existed := prev.routes[id]; !existed {
+					t.createdAt[id] = version
+				}
+				t.changedAt[id] = version
+				delete(t.tombstones, id)
+			} else {
+				delete(t.createdAt, id)
+				delete(t.changedAt, id)
+				t.tombstones[id] = version
+			}
+		}
+	}
+
+	t.touched = nil
+	t.rebuilt = false
+	t.pruneTombstones()
+}
+
+// 丢弃最早的删除记录，控制内存占用
+func (t *routeTable) pruneTombstones() {
+	if len(t.tombstones) <= maxRouteTombstones {
+		return
+	}
+
+	versions := make([]int64, 0, len(t.tombstones))
+	for _, version := range t.tombstones {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	floor := versions[len(versions)-maxRouteTombstones-1]
+	for id, version := range t.tombstones {
+		if version <= floor {
+			delete(t.tombstones, id)
+		}
+	}
+	if floor > t.tombstoneFloor {
+		t.tombstoneFloor = floor
+	}
+}
+
+// 计算指定版本之后的变更；删除记录已被丢弃时需要全量同步
+func (t *routeTable) deltaSince(since int64) *RouteDelta {
+	delta := &RouteDelta{
+		Since:         since,
+		ConfigVersion: t.configVersion,
+		Created:       make([]RouteConfig, 0),
+		Updated:       make([]RouteConfig, 0),
+		Deleted:       make([]string, 0),
+	}
+
+	if since < t.tombstoneFloor {
+		return t.fullDelta()
+	}
+
+	for id, changedAt := range t.changedAt {
+		if changedAt <= since {
+			continue
+		}
+		if t.createdAt[id] > since {
+			delta.Created = append(delta.Created, t.routes[id])
+		} else {
+			delta.Updated = append(delta.Updated, t.routes[id])
+		}
+	}
+	for id, deletedAt := range t.tombstones {
+		if deletedAt > since {
+			delta.Deleted = append(delta.Deleted, id)
+		}
+	}
+	return delta
+}
+
+// 全量增量：副本需要用 Created 整体替换本地路由
+func (t *routeTable) fullDelta() *RouteDelta {
+	return &RouteDelta{
+		ConfigVersion: t.configVersion,
+		FullResync:    true,
+		Created:       t.list(),
+		Updated:       make([]RouteConfig, 0),
+		Deleted:       make([]string, 0),
+	}
+}
+
+// 写入路由后路由表的内存估算
+func (t *routeTable) memoryAfterPut(routeID string, route RouteConfig) int64 {
+	if t.lazyThreshold > 0 && len(route.Code) > t.lazyThreshold {
+		route.Code = ""
+	}
+	return t.memoryBytes - t.sizes[routeID] + estimateRouteMemory(route)
+}
+
+// 估算单条路由常驻内存大小
+func estimateRouteMemory(route RouteConfig) int64 {
+	size := len(route.ID) + len(route.Path) + len(route.Method) + len(route.Handler) +
+		len(route.SandboxType) + len(route.Code) + len(route.Target) +
+		len(route.Description) + len(route.DocsURL) + len(route.ContactOwner) + len(route.GroupID)
+	if route.Canary != nil {
+		size += len(route.Canary.Code) + len(route.Canary.Target)
+	}
+	for key, value := range route.Metadata {
+		size += len(key) + len(value)
+	}
+	for _, tag := range route.Tags {
+		size += len(tag)
+	}
+	for _, method := range route.Methods {
+		size += len(method)
+	}
+	return int64(size) + routeMemoryOverhead
+}
@@ -0,0 +1,354 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const routeTemplatesRedisKey = "gateway:route_templates"
+
+// 模板占位符 {{name}}（与路径参数 {id} 区分）
+var (
+	routeTemplatePlaceholder   = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+	routeTemplateParameterName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// 路由模板参数
+type RouteTemplateParameter struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"` // 未设置默认值的参数实例化时必须提供
+}
+
+// 🔧 新增：路由模板：route 为路由 JSON，字符串中的 {{name}} 在实例化时替换为参数值；
+// 整个字符串就是一个占位符时按参数的 JSON 类型替换（例如 "timeout": "{{timeout}}" 可以替换为数字）
+type RouteTemplate struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Parameters  []RouteTemplateParameter `json:"parameters,omitempty"`
+	Route       map[string]interface{}   `json:"route"`
+	CreatedAt   int64                    `json:"created_at"`
+	UpdatedAt   int64                    `json:"updated_at"`
+}
+
+// 校验模板：route 必填，引用的参数必须声明（id 由实例化请求提供，无需声明）
+func (t *RouteTemplate) validate() error {
+	if len(t.Route) == 0 {
+		return fmt.Errorf("route is required")
+	}
+	declared := map[string]bool{"id": true}
+	for _, parameter := range t.Parameters {
+		if !routeTemplateParameterName.MatchString(parameter.Name) {
+			return fmt.Errorf("invalid parameter name: %q", parameter.Name)
+		}
+		if parameter.Name == "id" {
+			return fmt.Errorf("parameter id is reserved for the route ID")
+		}
+		if declared[parameter.Name] {
+			return fmt.Errorf("duplicate parameter: %s", parameter.Name)
+		}
+		declared[parameter.Name] = true
+	}
+	for _, name := range routeTemplateReferences(t.Route) {
+		if !declared[name] {
+			return fmt.Errorf("route references undeclared parameter: %s", name)
+		}
+	}
+	return nil
+}
+
+// 按参数生成路由：未知参数和缺少的必填参数都会报错
+func (t *RouteTemplate) instantiate(id string, params map[string]interface{}) (RouteConfig, error) {
+	values := map[string]interface{}{"id": id}
+	for _, parameter := range t.Parameters {
+		value, ok := params[parameter.Name]
+		if !ok {
+			if parameter.Default == nil {
+				return RouteConfig{}, fmt.Errorf("missing parameter: %s", parameter.Name)
+			}
+			value = parameter.Default
+		}
+		values[parameter.Name] = value
+	}
+	for name := range params {
+		if _, ok := values[name]; !ok || name == "id" {
+			return RouteConfig{}, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+
+	rendered, err := json.Marshal(renderRouteTemplate(t.Route, values))
+	if err != nil {
+		return RouteConfig{}, err
+	}
+	var route RouteConfig
+	if err := json.Unmarshal(rendered, &route); err != nil {
+		return RouteConfig{}, fmt.Errorf("template %s renders an invalid route: %v", t.Name, err)
+	}
+	route.ID = id
+	if route.Metadata == nil {
+		route.Metadata = make(map[string]string)
+	}
+	route.Metadata["template"] = t.Name
+	return route, nil
+}
+
+// 替换 JSON 值中的占位符
+func renderRouteTemplate(value interface{}, values map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if match := routeTemplatePlaceholder.FindStringSubmatch(v); match != nil && match[0] == v {
+			return values[match[1]]
+		}
+		return routeTemplatePlaceholder.ReplaceAllStringFunc(v, func(placeholder string) string {
+			name := routeTemplatePlaceholder.FindStringSubmatch(placeholder)[1]
+			if s, ok := values[name].(string); ok {
+				return s
+			}
+			return diffValueText(values[name])
+		})
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered[key] = renderRouteTemplate(item, values)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered[i] = renderRouteTemplate(item, values)
+		}
+		return rendered
+	}
+	return value
+}
+
+// 模板引用的参数名（去重、排序）
+func routeTemplateReferences(route map[string]interface{}) []string {
+	encoded, _ := json.Marshal(route)
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range routeTemplatePlaceholder.FindAllStringSubmatch(string(encoded), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 🔧 新增：路由模板存储：启用 Redis 时直接读写 Redis（模板只在管理接口中使用），否则保存在本地内存
+type RouteTemplateStore struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	templates    map[string]*RouteTemplate
+	mutex        sync.RWMutex
+}
+
+func NewRouteTemplateStore(redisClient *redis.Client, redisEnabled bool) *RouteTemplateStore {
+	return &RouteTemplateStore{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		templates:    make(map[string]*RouteTemplate),
+	}
+}
+
+// 获取模板
+func (s *RouteTemplateStore) Get(ctx context.Context, name string) (*RouteTemplate, error) {
+	if !s.redisEnabled {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		return s.templates[name], nil
+	}
+
+	templateJSON, err := s.redisClient.HGet(ctx, routeTemplatesRedisKey, name).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load route template: %v", err)
+	}
+	var template RouteTemplate
+	if err := json.Unmarshal([]byte(templateJSON), &template); err != nil {
+		return nil, fmt.Errorf("failed to decode route template %s: %v", name, err)
+	}
+	return &template, nil
+}
+
+// 保存模板（同名模板被替换）
+func (s *RouteTemplateStore) Put(ctx context.Context, template *RouteTemplate) error {
+	existing, err := s.Get(ctx, template.Name)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	template.CreatedAt = now
+	if existing != nil {
+		template.CreatedAt = existing.CreatedAt
+	}
+	template.UpdatedAt = now
+
+	if s.redisEnabled {
+		templateJSON, _ := json.Marshal(template)
+		if err := s.redisClient.HSet(ctx, routeTemplatesRedisKey, template.Name, templateJSON).Err(); err != nil {
+			return fmt.Errorf("failed to save route template: %v", err)
+		}
+	} else {
+		s.mutex.Lock()
+		s.templates[template.Name] = template
+		s.mutex.Unlock()
+	}
+
+	log.Printf("📐 Route template %s saved", template.Name)
+	return nil
+}
+
+// 删除模板，返回是否存在
+func (s *RouteTemplateStore) Delete(ctx context.Context, name string) (bool, error) {
+	exists := false
+	if s.redisEnabled {
+		removed, err := s.redisClient.HDel(ctx, routeTemplatesRedisKey, name).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to delete route template: %v", err)
+		}
+		exists = removed > 0
+	} else {
+		s.mutex.Lock()
+		_, exists = s.templates[name]
+		delete(s.templates, name)
+		s.mutex.Unlock()
+	}
+
+	if exists {
+		log.Printf("📐 Route template %s deleted", name)
+	}
+	return exists, nil
+}
+
+// 列出模板，按名称排序
+func (s *RouteTemplateStore) List(ctx context.Context) ([]*RouteTemplate, error) {
+	var templates []*RouteTemplate
+	if s.redisEnabled {
+		stored, err := s.redisClient.HGetAll(ctx, routeTemplatesRedisKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load route templates: %v", err)
+		}
+		for _, templateJSON := range stored {
+			var template RouteTemplate
+			if err := json.Unmarshal([]byte(templateJSON), &template); err != nil {
+				continue
+			}
+			templates = append(templates, &template)
+		}
+	} else {
+		s.mutex.RLock()
+		for _, template := range s.templates {
+			templates = append(templates, template)
+		}
+		s.mutex.RUnlock()
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// 🔧 新增：列出路由模板
+func (dr *DistributedRouter) listRouteTemplatesHandler(c *gin.Context) {
+	templates, err := dr.routeTemplates.List(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if templates == nil {
+		templates = []*RouteTemplate{}
+	}
+	c.JSON(200, gin.H{"templates": templates, "count": len(templates)})
+}
+
+// 🔧 新增：创建或替换路由模板
+func (dr *DistributedRouter) putRouteTemplateHandler(c *gin.Context) {
+	var template RouteTemplate
+	if err := c.BindJSON(&template); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	template.Name = c.Param("name")
+	if strings.TrimSpace(template.Name) == "" {
+		c.JSON(400, gin.H{"error": "template name is required"})
+		return
+	}
+	if err := template.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := dr.routeTemplates.Put(c.Request.Context(), &template); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "route template saved", "template": template})
+}
+
+// 🔧 新增：删除路由模板（已生成的路由不受影响）
+func (dr *DistributedRouter) deleteRouteTemplateHandler(c *gin.Context) {
+	exists, err := dr.routeTemplates.Delete(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(404, gin.H{"error": "route template not found"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "route template deleted"})
+}
+
+// 🔧 新增：按模板创建路由，生成的路由带有 metadata.template
+func (dr *DistributedRouter) instantiateRouteTemplateHandler(c *gin.Context) {
+	var req struct {
+		ID     string                 `json:"id"`
+		Params map[string]interface{} `json:"params"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ID == "" {
+		c.JSON(400, gin.H{"error": "route ID is required"})
+		return
+	}
+
+	template, err := dr.routeTemplates.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if template == nil {
+		c.JSON(404, gin.H{"error": "route template not found"})
+		return
+	}
+
+	route, err := template.instantiate(req.ID, req.Params)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, exists := dr.routeManager.snapshot().get(route.ID); exists {
+		c.JSON(409, gin.H{"error": "route already exists: " + route.ID})
+		return
+	}
+	if err := dr.routeManager.AddRoute(route); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "route added", "id": route.ID, "template": template.Name, "route": route})
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"sort"
+	"strings"
+)
+
+// 🔧 新增：路由路径索引（按路径段组织的基数树）
+// 每条路由挂在其路径静态前缀（第一个含 { 或 * 的段之前的各段）对应的节点上。匹配时沿请求路径逐段下行，
+// 只有途经节点上的路由才可能匹配（精确、参数、前缀和通配符匹配都要求静态前缀逐段相同），
+// 再由预编译的匹配器确认并计算优先级，查找代价取决于路径深度和候选数量，与路由总数无关。
+// 节点写时复制：属于当前索引的节点原地修改，与其他快照共享的节点先复制，路由变更时只重建受影响的路径。
+type routeTrie struct {
+	root            *routeTrieNode
+	caseInsensitive bool
+}
+
+type routeTrieNode struct {
+	owner    *routeTrie // 创建该节点的索引
+	children map[string]*routeTrieNode
+	entries  []routeTrieEntry // 按路由ID排序
+}
+
+// 匹配所需的路由字段，查找时无需读取完整的路由配置
+type routeTrieEntry struct {
+	id       string
+	methods  []string // 🔧 修改：路由的方法列表（method 或 methods）
+	path     string
+	tenant   string
+	host     string // 小写
+	priority int    // 显式优先级，0 表示使用匹配类型的默认优先级
+	matcher  *routeMatcher
+
+	activeFrom int64 // 生效时间窗口（Unix 秒），0 表示不限
+	expiresAt  int64
+}
+
+// 路由是否接受该请求方法（ANY 接受所有方法）
+func (e routeTrieEntry) allowsMethod(method string) bool {
+	for _, m := range e.methods {
+		if m == method || m == "ANY" {
+			return true
+		}
+	}
+	return false
+}
+
+// 路由在该时刻是否处于生效时间窗口内
+func (e routeTrieEntry) activeAt(now int64) bool {
+	return (e.activeFrom == 0 || now >= e.activeFrom) && (e.expiresAt == 0 || now < e.expiresAt)
+}
+
+func newRouteTrie(caseInsensitive bool) *routeTrie {
+	t := &routeTrie{caseInsensitive: caseInsensitive}
+	t.root = &routeTrieNode{owner: t}
+	return t
+}
+
+// 复制索引，节点在写入前保持共享
+func (t *routeTrie) clone() *routeTrie {
+	return &routeTrie{root: t.root, caseInsensitive: t.caseInsensitive}
+}
+
+// 返回可以原地修改的节点
+func (t *routeTrie) own(n *routeTrieNode) *routeTrieNode {
+	if n == nil {
+		return &routeTrieNode{owner: t}
+	}
+	if n.owner == t {
+		return n
+	}
+	c := &routeTrieNode{owner: t, entries: append([]routeTrieEntry(nil), n.entries...)}
+	if len(n.children) > 0 {
+		c.children = make(map[string]*routeTrieNode, len(n.children))
+		for segment, child := range n.children {
+			c.children[segment] = child
+		}
+	}
+	return c
+}
+
+// 路由路径的静态前缀段，不以 / 开头的路径挂在根节点（🔧 正则路由按 indexPath 计算）
+func (t *routeTrie) staticSegments(path string) []string {
+	if !strings.HasPrefix(path, "/") {
+		return nil
+	}
+	if t.caseInsensitive {
+		path = strings.ToLower(path)
+	}
+	var segments []string
+	for _, segment := range strings.Split(path[1:], "/") {
+		if strings.ContainsAny(segment, "{*") {
+			break
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// 添加路由
+func (t *routeTrie) insert(id string, route RouteConfig, matcher *routeMatcher) {
+	t.root = t.own(t.root)
+	node := t.root
+	for _, segment := range t.staticSegments(route.indexPath()) {
+		child := t.own(node.children[segment])
+		if node.children == nil {
+			node.children = make(map[string]*routeTrieNode)
+		}
+		node.children[segment] = child
+		node = child
+	}
+
+	i := sort.Search(len(node.entries), func(i int) bool { return node.entries[i].id >= id })
+	node.entries = append(node.entries, routeTrieEntry{})
+	copy(node.entries[i+1:], node.entries[i:])
+	node.entries[i] = routeTrieEntry{id: id, methods: route.methodList(), path: route.fullPath(), tenant: route.Tenant, host: strings.ToLower(route.Host), priority: route.Priority, matcher: matcher, activeFrom: route.ActiveFrom, expiresAt: route.ExpiresAt}
+}
+
+// 移除路由，并删除因此变空的节点
+func (t *routeTrie) remove(id, path string) {
+	segments := t.staticSegments(path)
+	t.root = t.own(t.root)
+	nodes := []*routeTrieNode{t.root}
+	node := t.root
+	for _, segment := range segments {
+		child := node.children[segment]
+		if child == nil {
+			return
+		}
+		child = t.own(child)
+		node.children[segment] = child
+		nodes = append(nodes, child)
+		node = child
+	}
+
+	for i, entry := range node.entries {
+		if entry.id == id {
+			node.entries = append(node.entries[:i], node.entries[i+1:]...)
+			break
+		}
+	}
+	for i := len(segments); i > 0; i-- {
+		if len(nodes[i].entries) > 0 || len(nodes[i].children) > 0 {
+			break
+		}
+		delete(nodes[i-1].children, segments[i-1])
+	}
+}
+
+// 按从浅到深的顺序访问请求路径途经节点上的候选路由，depth 为节点深度（静态前缀段数）
+func (t *routeTrie) each(path string, visit func(depth int, entry routeTrieEntry)) {
+	node := t.root
+	for _, entry := range node.entries {
+		visit(0, entry)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return
+	}
+	if t.caseInsensitive {
+		path = strings.ToLower(path)
+	}
+
+	rest := path[1:]
+	for depth := 1; ; depth++ {
+		segment, next, more := strings.Cut(rest, "/")
+		if node = node.children[segment]; node == nil {
+			return
+		}
+		for _, entry := range node.entries {
+			visit(depth, entry)
+		}
+		if !more {
+			return
+		}
+		rest = next
+	}
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 120 * time.Second
+)
+
+// 唤醒所有等待路由表变更的请求
+func (rm *RouteManager) notifyTableChanged() {
+	rm.watchMutex.Lock()
+	defer rm.watchMutex.Unlock()
+
+	if rm.tableChanged != nil {
+		close(rm.tableChanged)
+	}
+	rm.tableChanged = make(chan struct{})
+}
+
+// 获取当前的变更通知通道
+func (rm *RouteManager) tableChangedChannel() <-chan struct{} {
+	rm.watchMutex.Lock()
+	defer rm.watchMutex.Unlock()
+
+	if rm.tableChanged == nil {
+		rm.tableChanged = make(chan struct{})
+	}
+	return rm.tableChanged
+}
+
+// 🔧 修改：阻塞直到全局配置版本超过 since，或上下文结束。since 由本实例返回时，
+// 本实例的路由表在该版本之后发生变化（如应用了其他实例的事件）也立即返回
+func (rm *RouteManager) waitForVersion(ctx context.Context, since int64, instanceID string) bool {
+	var local int64
+	own := false
+	if since > 0 && instanceID == rm.instanceID {
+		if _, local, own = rm.localVersionFor(since); !own {
+			// 对应记录已丢弃，立即返回全量
+			return true
+		}
+	}
+
+	for {
+		// 先获取通知通道再检查版本，避免错过两者之间发生的变更
+		changed := rm.tableChangedChannel()
+		global, table := rm.versionedSnapshot()
+		if global > since || (own && table.configVersion > local) {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// 🔧 新增：长轮询监听路由变更，全局配置版本前进后只返回增量（since_version 和 instance_id 取自上一次响应）
+func (dr *DistributedRouter) watchRoutesHandler(c *gin.Context) {
+	since, err := strconv.ParseInt(c.DefaultQuery("since_version", "0"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid since_version"})
+		return
+	}
+
+	timeout := defaultWatchTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds <= 0 {
+			c.JSON(400, gin.H{"error": "invalid timeout"})
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	rm := dr.routeManager
+	instanceID := c.Query("instance_id")
+	if !rm.waitForVersion(ctx, since, instanceID) {
+		version, _ := rm.versionedSnapshot()
+		c.JSON(200, gin.H{
+			"changed":        false,
+			"config_version": version,
+			"instance_id":    rm.instanceID,
+		})
+		return
+	}
+
+	delta := rm.routeDelta(since, instanceID)
+	c.JSON(200, gin.H{
+		"changed":        true,
+		"config_version": delta.ConfigVersion,
+		"instance_id":    rm.instanceID,
+		"delta":          delta,
+	})
+}
+
+// 🔧 新增：返回指定版本以来的路由增量，可基于纯 HTTP 构建只读副本。
+// since 和 instance_id 取自上一次响应，版本不是本实例返回的（如经负载均衡落到其他实例）时返回全量
+func (dr *DistributedRouter) routeDeltaHandler(c *gin.Context) {
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid since"})
+		return
+	}
+
+	c.JSON(200, dr.routeManager.routeDelta(since, c.Query("instance_id")))
+}
@@ -2,12 +2,15 @@ package gateway
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,55 +28,190 @@ type DistributedRouter struct {
 	routeManager   *RouteManager
 	sandboxPool    *SandboxPool
 	loadBalancer   *LoadBalancer
+	leader         *LeaderElector
+	backupManager  *BackupManager
+	chaos          *ChaosManager
+	secrets        *SecretResolver
+	nonces         *nonceStore
+	concurrency    *adaptiveConcurrency // 🔧 新增：按上游自适应并发限制
+	sandboxWait    sandboxWaitStats     // 🔧 新增：等待可用沙箱的统计
+	panics         panicStats           // 🔧 新增：两个端口的 panic 统计
+	executionCancel executionCancelStats // 🔧 新增：超时取消的统计
+	keyConcurrency keyConcurrencyLimiter // 🔧 新增：消费者 Key 的并发上限
+	basicAuth      *BasicCredentialStore // 🔧 新增：Basic 凭据存储
+	routeTemplates *RouteTemplateStore   // 🔧 新增：路由模板存储
+	scheduledChanges *ScheduledChangeStore // 🔧 新增：定时生效的路由变更
+	tracer         *Tracer               // 🔧 新增：请求追踪采样与导出（未启用时为 nil）
+	logForwarder   *LogForwarder
+	metrics        *GatewayMetrics
+	otlpExporter   *otlpMetricsExporter
+	statsd         *statsdClient
+	slo            *SLOTracker
+	canaries       *CanaryMonitor // 🔧 新增：金丝雀版本错误率统计与自动回滚
+	migrations     *MigrationRunner
+	llmKeys        *LLMKeyPools
+	llmCache       *LLMCache
+	draining       atomic.Bool // 🔧 新增：收到退出信号后为 true，/readyz 返回 503
+	runtime        *runtimeState // 🔧 新增：运行时可调设置
+	tunnels        *TunnelRegistry // 🔧 新增：沙箱反向隧道
+	sandboxChanges *sandboxChangeLog // 🔧 新增：沙箱池变化记录
 	gatewayPort    int
 	managementPort int
 }
 
-func NewDistributedRouter(redisAddr, redisPassword string) *DistributedRouter {
+func NewDistributedRouter(redisAddr, redisPassword string) (*DistributedRouter, error) {
+	// 🔧 新增：实例标识，所有网关日志以其为前缀
+	instanceID := resolveInstanceID(gatewaySettings().InstanceID)
+	log.SetPrefix("[" + instanceID + "] ")
+
+	// 🔧 新增：未配置 Key 时按 gateway_key_unset / admin_key_unset 拒绝、启动失败或放行，并在启动时警告
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		warnings, err := middleware.KeyUnsetWarnings(config)
+		if err != nil {
+			return nil, err
+		}
+		for _, warning := range warnings {
+			log.Printf("⚠️  %s", warning)
+		}
+	}
+
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     redisAddr,
 		Password: redisPassword,
 		DB:       0,
 	})
 
-	// 测试 Redis 连接
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	_, err := rdb.Ping(ctx).Result()
-	if err != nil {
-		if err.Error() == "NOAUTH Authentication required." {
-			log.Printf("❌ Redis authentication failed. Please check your Redis password in config.yaml")
-			log.Printf("💡 You can:")
-			log.Printf("   1. Set the correct password in conf/config.yaml")
-			log.Printf("   2. Disable Redis authentication: redis-cli -> CONFIG SET requirepass \"\"")
-			log.Printf("   3. Or run without Redis (routes will be stored in memory only)")
-		} else {
-			log.Printf("❌ Failed to connect to Redis at %s: %v", redisAddr, err)
-		}
-		// 继续运行，但使用内存存储
-		log.Printf("⚠️  Running with in-memory storage only. Routes will not be persisted.")
-	} else {
-		log.Printf("✅ Successfully connected to Redis at %s", redisAddr)
+	// 测试 Redis 连接（🔧 按 redis.startup 失败、等待或降级）
+	if err := checkRedisAtStartup(rdb, redisAddr); err != nil {
+		return nil, err
+	}
+
+	// 🔧 新增：健康策略中的组件名
+	if err := validateHealthPolicy(gatewaySettings().Health); err != nil {
+		return nil, err
+	}
+
+	// 🔧 新增：路由字段加密（加载路由之前初始化）
+	if err := initRouteEncryption(gatewaySettings().RouteEncryption); err != nil {
+		return nil, err
 	}
 
+	// 🔧 新增：上游拨号的 DNS 解析器
+	if err := initUpstreamDNS(gatewaySettings().DNS); err != nil {
+		return nil, err
+	}
+	if err := validateNetworkConfig(gatewaySettings().Network); err != nil {
+		return nil, err
+	}
+	if err := validateEventPublishConfig(gatewaySettings().EventPublish); err != nil {
+		return nil, err
+	}
+
+	routeManager := NewRouteManager(rdb, instanceID)
+
+	// 主节点选举（定时任务只在主节点运行）
+	leader := NewLeaderElector(rdb, routeManager.instanceID, routeManager.redisEnabled)
+	leader.Start()
+
 	router := &DistributedRouter{
 		redisClient:    rdb,
 		ginRouter:      gin.New(),
 		muxRouter:      mux.NewRouter(),
-		routeManager:   NewRouteManager(rdb),
+		routeManager:   routeManager,
 		sandboxPool:    NewSandboxPool(rdb),
 		loadBalancer:   NewLoadBalancer(),
+		leader:         leader,
+		chaos:          NewChaosManager(rdb, routeManager.redisEnabled),
+		secrets:        NewSecretResolver(rdb, routeManager.redisEnabled),
+		nonces:         newNonceStore(rdb, routeManager.redisEnabled),
+		concurrency:    newAdaptiveConcurrency(),
+		basicAuth:      NewBasicCredentialStore(rdb, routeManager.redisEnabled),
+		routeTemplates: NewRouteTemplateStore(rdb, routeManager.redisEnabled),
+		scheduledChanges: NewScheduledChangeStore(rdb, routeManager.redisEnabled),
+		slo:            NewSLOTracker(),
+		canaries:       NewCanaryMonitor(),
+		llmKeys:        NewLLMKeyPools(rdb, routeManager.redisEnabled),
+		llmCache:       NewLLMCache(rdb, routeManager.redisEnabled),
+		runtime:        newRuntimeState(),
+		sandboxChanges: newSandboxChangeLog(rdb, routeManager.redisEnabled),
 		gatewayPort:    8080,
 		managementPort: 8081,
 	}
 
+	// 配置备份
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		// OTLP 指标与日志导出
+		var extraSinks []LogSink
+		if otlp := config.Telemetry.OTLP; otlp.Enabled {
+			client := newOTLPClient(otlp, config.Telemetry.ServiceName, routeManager.instanceID, router.secrets)
+			if otlp.Logs {
+				extraSinks = append(extraSinks, &otlpLogSink{client: client})
+			}
+			if otlp.Metrics {
+				router.metrics = NewGatewayMetrics()
+				router.otlpExporter = newOTLPMetricsExporter(client, router)
+				router.otlpExporter.Start()
+			}
+			// 🔧 新增：请求追踪
+			if otlp.Traces {
+				router.tracer = NewTracer(client, otlp.TraceSampleRate, rdb, routeManager.redisEnabled)
+			}
+		}
+
+		// StatsD 指标
+		if config.Telemetry.StatsD.Enabled {
+			statsd, err := newStatsDClient(config.Telemetry.StatsD, routeManager.instanceID)
+			if err != nil {
+				log.Printf("⚠️  StatsD disabled: %v", err)
+			} else {
+				statsd.Start(router)
+				router.statsd = statsd
+			}
+		}
+
+		// 访问/审计日志转发
+		router.logForwarder = newLogForwarderFromConfig(config.LogForwarding, routeManager.instanceID, router.secrets, extraSinks...)
+
+		backupManager, err := NewBackupManager(config.Backup, routeManager, leader)
+		if err != nil {
+			log.Printf("⚠️  Backup disabled: %v", err)
+		} else {
+			backupManager.Start()
+			router.backupManager = backupManager
+		}
+	}
+
+	// 🔧 新增：主节点执行 Redis 存储结构迁移
+	if routeManager.redisEnabled {
+		router.migrations = NewMigrationRunner(rdb, leader, routeManager.instanceID)
+		router.migrations.Start()
+	}
+
+	// 🔧 新增：沙箱反向隧道
+	router.tunnels = NewTunnelRegistry(router.sandboxPool)
+	router.tunnels.onChange = router.recordSandboxChange
+	if gatewaySettings().Tunnel.Enabled {
+		go router.tunnels.runHealthChecks()
+	}
+
+	// 沙箱健康状态变化通过事件流同步到其他实例
+	if routeManager.redisEnabled {
+		router.sandboxPool.EnableHealthEvents(routeManager.GetEventStream(), routeManager.instanceID)
+	}
+	router.sandboxPool.StartHealthChecks(leader)
+
+	go router.runSLOEvaluator()
+	go router.runCanaryEvaluator()
+	go router.runScheduledChanges()
+	go router.runRouteExpiry()
+
 	router.setupRoutes()
-	return router
+	return router, nil
 }
 
 func (dr *DistributedRouter) SetLoadBalancerStrategy(strategy string) {
 	dr.loadBalancer.SetStrategy(strategy)
+	dr.sandboxPool.loadBalancer.SetStrategy(strategy) // 🔧 修复：沙箱选择使用的是沙箱池的负载均衡器
 }
 
 func (dr *DistributedRouter) SetPorts(gatewayPort, managementPort int) {
@@ -90,22 +228,67 @@ func (dr *DistributedRouter) setupRoutes() {
 }
 
 func (dr *DistributedRouter) setupGinRoutes() {
-	dr.ginRouter.Use(gin.Recovery())
-	dr.ginRouter.Use(dr.corsMiddleware())
-	dr.ginRouter.Use(gin.Logger())
+	// 🔧 修改：恢复、访问日志和 CORS 由共用的中间件链处理（adminHandler）
 
 	// 管理接口 - 添加管理员认证
 	adminGroup := dr.ginRouter.Group("/admin")
+	adminGroup.Use(dr.auditLogMiddleware())
 	adminGroup.Use(middleware.AdminAuth())
+	adminGroup.Use(dr.adminCSRFMiddleware())
 	{
+		adminGroup.GET("/csrf-token", dr.csrfTokenHandler)
 		adminGroup.GET("/routes", dr.listRoutesHandler)
+		adminGroup.GET("/routes/watch", dr.watchRoutesHandler)
+		adminGroup.GET("/routes/delta", dr.routeDeltaHandler)
 		adminGroup.POST("/routes", dr.addRouteHandler)
+		adminGroup.POST("/import/dify", dr.importDifyHandler)
+		adminGroup.POST("/routes/import", dr.importRoutesHandler)
+		adminGroup.POST("/routes/batch", dr.routeBatchHandler) // 🔧 新增：原子批量变更
+		adminGroup.POST("/routes/apply", dr.applyRoutesHandler) // 🔧 新增：声明式应用完整路由表
+		adminGroup.POST("/routes/import/openapi", dr.importOpenAPIHandler)
+		adminGroup.GET("/routes/export", dr.exportRoutesHandler)
+		adminGroup.GET("/routes/match", dr.matchRouteHandler) // 🔧 新增：路由匹配调试（不转发）
 		adminGroup.PUT("/routes/:id", dr.updateRouteHandler)
 		adminGroup.DELETE("/routes/:id", dr.deleteRouteHandler)
+
+		// 🔧 新增：路由模板
+		adminGroup.GET("/route-templates", dr.listRouteTemplatesHandler)
+		adminGroup.PUT("/route-templates/:name", dr.putRouteTemplateHandler)
+		adminGroup.DELETE("/route-templates/:name", dr.deleteRouteTemplateHandler)
+		adminGroup.POST("/route-templates/:name/instantiate", dr.instantiateRouteTemplateHandler)
+
+		// 🔧 新增：路由分组
+		adminGroup.GET("/route-groups", dr.listRouteGroupsHandler)
+		adminGroup.GET("/route-groups/:id", dr.getRouteGroupHandler)
+		adminGroup.PUT("/route-groups/:id", dr.putRouteGroupHandler)
+		adminGroup.DELETE("/route-groups/:id", dr.deleteRouteGroupHandler)
+
+		// 🔧 新增：定时生效的路由变更（POST/PUT/DELETE /routes 带 effective_at 参数时创建）
+		adminGroup.GET("/scheduled-changes", dr.listScheduledChangesHandler)
+		adminGroup.DELETE("/scheduled-changes/:id", dr.cancelScheduledChangeHandler)
+
 		adminGroup.GET("/sandboxes", dr.listSandboxesHandler)
 		adminGroup.POST("/sandboxes/register", dr.registerSandboxHandler)
 		adminGroup.DELETE("/sandboxes/:id", dr.deleteSandboxHandler)
+		adminGroup.GET("/sandboxes/changes", dr.listSandboxChangesHandler) // 🔧 新增：最近的沙箱池变化
+		adminGroup.GET("/tunnels", dr.listTunnelsHandler)
+		adminGroup.GET("/dns", dr.dnsCacheHandler) // 🔧 新增：上游 DNS 缓存
+		adminGroup.GET("/dns/resolve", dr.dnsResolveHandler)
+		adminGroup.DELETE("/dns/cache", dr.flushDNSCacheHandler)
 		adminGroup.GET("/health", dr.healthHandler)
+		adminGroup.GET("/version", dr.versionHandler)
+		adminGroup.GET("/stats", dr.statsHandler)
+		adminGroup.GET("/slo", dr.sloHandler)
+		adminGroup.GET("/canaries", dr.canariesHandler)
+		adminGroup.GET("/gateways", dr.listGatewaysHandler)
+		adminGroup.GET("/runtime", dr.getRuntimeHandler)
+		adminGroup.PATCH("/runtime", dr.patchRuntimeHandler)
+		adminGroup.GET("/migrations", dr.migrationStatusHandler)
+		adminGroup.POST("/migrations/apply", dr.applyMigrationsHandler)
+
+		// LLM 代理
+		adminGroup.GET("/llm/usage", dr.llmUsageHandler)
+		adminGroup.GET("/llm/keys", dr.llmKeyPoolsHandler)
 
 		// 事件流管理接口
 		adminGroup.GET("/events/stream-info", dr.getStreamInfoHandler)
@@ -117,63 +300,253 @@ func (dr *DistributedRouter) setupGinRoutes() {
 		adminGroup.GET("/config/version", dr.getConfigVersionHandler)
 		adminGroup.GET("/events/stats", dr.getEventStatsHandler)
 		adminGroup.POST("/sync/trigger", dr.triggerSyncHandler)
+		adminGroup.POST("/sync/full", dr.fullSyncHandler)
+		adminGroup.GET("/sync/stats", dr.syncStatsHandler)
 		adminGroup.GET("/routes/:routeId/details", dr.getRouteDetailsHandler)
 		adminGroup.POST("/events/cleanup", dr.cleanupEventsHandler)
+
+		// 备份管理接口
+		adminGroup.GET("/backups", dr.listBackupsHandler)
+		adminGroup.POST("/backups", dr.createBackupHandler)
+		adminGroup.POST("/backups/:name/restore", dr.restoreBackupHandler)
+
+		// 🔧 新增：路由字段加密
+		adminGroup.POST("/route-encryption/reencrypt", dr.reencryptRoutesHandler)
+
+		// 故障注入接口
+		adminGroup.GET("/chaos", dr.listChaosRulesHandler)
+		adminGroup.PUT("/chaos/:routeId", dr.setChaosRuleHandler)
+		adminGroup.DELETE("/chaos/:routeId", dr.deleteChaosRuleHandler)
+
+		// 消费者 Key 生成
+		adminGroup.POST("/api-keys/generate", dr.generateAPIKeyHandler)
+
+		// 🔧 新增：Basic 凭据管理
+		adminGroup.GET("/basic-auth/credentials", dr.listBasicCredentialsHandler)
+		adminGroup.PUT("/basic-auth/credentials/:name", dr.putBasicCredentialHandler)
+		adminGroup.DELETE("/basic-auth/credentials/:name", dr.deleteBasicCredentialHandler)
+
+		// 密钥管理接口（只写，不返回密钥值）
+		adminGroup.GET("/secrets", dr.listSecretsHandler)
+		adminGroup.PUT("/secrets/:name", dr.putSecretHandler)
+		adminGroup.DELETE("/secrets/:name", dr.deleteSecretHandler)
+
+		// 🔧 新增：追踪采样
+		adminGroup.GET("/tracing/sampling", dr.getTraceSamplingHandler)
+		adminGroup.PUT("/tracing/sampling/:routeId", dr.putTraceSamplingHandler)
+		adminGroup.DELETE("/tracing/sampling/:routeId", dr.deleteTraceSamplingHandler)
+
+		// 性能验证接口
+		adminGroup.POST("/bench/loadgen", dr.loadGenHandler)
 	}
 }
 
 func (dr *DistributedRouter) setupMuxRoutes() {
+	// 路径由 gatewayHandler 规范化，不使用 mux 的清理重定向
+	dr.muxRouter.SkipClean(true)
+
+	// 🔧 修改：访问日志、panic 恢复和 CORS 使用与管理端口相同的中间件链（在路径规范化和方法覆盖之后执行）
+	for _, m := range dr.sharedMiddleware(serverGateway) {
+		dr.muxRouter.Use(mux.MiddlewareFunc(m))
+	}
+
+	// OAuth2 令牌端点（无需网关认证，使用客户端凭证）
+	if oauth := gatewaySettings().OAuth; oauth.Enabled && oauth.TokenPath != "" {
+		dr.muxRouter.HandleFunc(oauth.TokenPath, dr.oauthTokenHandler)
+	}
+
+	// 🔧 新增：沙箱 agent 接入反向隧道（使用隧道令牌，无需网关认证）
+	if tunnel := gatewaySettings().Tunnel; tunnel.Enabled && tunnel.Path != "" {
+		dr.muxRouter.HandleFunc(tunnel.Path, dr.tunnelConnectHandler)
+	}
+
+	// 🔧 新增：Dify 外部工具/API 扩展接口
+	if dify := gatewaySettings().Dify; dify.Enabled {
+		dr.setupDifyRoutes(dify.PathPrefix)
+	}
+
 	// 使用Mux处理所有动态路由，添加业务认证
 	dr.muxRouter.PathPrefix("/").HandlerFunc(dr.authenticatedRouteHandler)
 }
 
 // 认证路由处理器
 func (dr *DistributedRouter) authenticatedRouteHandler(w http.ResponseWriter, r *http.Request) {
-	// 检查业务网关认证
-	if !dr.authenticateGatewayRequest(r) {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(gin.H{"error": "invalid gateway api key"})
+	// 检查业务网关认证（🔧 新增：按路由的认证方式，公开路由不需要认证；默认为 API Key、请求签名或访问令牌）
+	route := dr.routeForAuth(r)
+	principal, err := dr.authenticateRoute(r, route)
+	if err != nil {
+		writeRouteAuthError(w, route, err)
 		return
 	}
 	
-	// 认证通过，继续处理路由
-	dr.dynamicRouteHandler(w, r)
+	if info := logInfoFromRequest(r); info != nil {
+		info.Principal = principal.Name
+	}
+
+	// 🔧 新增：解析租户
+	if err := resolveTenant(r, principal); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	// 认证通过，继续处理路由（路由匹配后校验访问范围）
+	dr.dynamicRouteHandler(w, withPrincipal(r, principal))
 }
 
-// 网关认证检查
-func (dr *DistributedRouter) authenticateGatewayRequest(r *http.Request) bool {
+// 网关认证检查：网关密钥不限制路由，消费者 Key 带有访问范围
+func (dr *DistributedRouter) authenticateGatewayRequest(r *http.Request) (*gatewayPrincipal, bool) {
 	apiKey := r.Header.Get("X-Api-Key")
 	config := static.GetDifySandboxGlobalConfigurations()
-	
+
 	// 使用网关密钥进行认证
-	expectedKey := config.App.GatewayKey
-	if expectedKey == "" {
-		expectedKey = config.App.Key // 兼容旧配置
+	expectedKey := middleware.GatewayKey(config)
+	// 🔧 新增：未配置网关 Key 且 gateway_key_unset 为 open 时，不带 Key 或 Key 不匹配的请求以匿名身份放行
+	open := expectedKey == "" && middleware.KeyUnsetMode(config.App.GatewayKeyUnset) == middleware.KeyUnsetOpen
+	if apiKey == "" {
+		if open {
+			return &gatewayPrincipal{Name: "anonymous", Method: routeAuthKey}, true
+		}
+		return nil, false
 	}
-	
-	return expectedKey != "" && expectedKey == apiKey
+	if middleware.KeyMatches(expectedKey, apiKey) {
+		return &gatewayPrincipal{Name: "gateway", Method: routeAuthKey}, true
+	}
+
+	// 🔧 新增：消费者 Key（支持哈希存储）
+	if key := dr.matchConsumerKey(r.Context(), config.Gateway, apiKey); key != nil {
+		return &gatewayPrincipal{Name: key.Name, Scope: key.RouteScope, Method: routeAuthKey, MaxConcurrency: key.MaxConcurrency}, true
+	}
+	if open {
+		return &gatewayPrincipal{Name: "anonymous", Method: routeAuthKey}, true
+	}
+	return nil, false
 }
 
 func (dr *DistributedRouter) dynamicRouteHandler(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
+	path := matchPath(r)
 	method := r.Method
 
-	// 查找匹配的路由
-	route := dr.routeManager.matchRoute(path, method)
+	// 查找匹配的路由（按路径规范化策略处理末尾斜杠）
+	route, redirect := dr.matchNormalizedRoute(path, method, tenantFromRequest(r), requestHost(r))
+	if redirect != "" {
+		location, _ := toggleTrailingSlash(r.URL.EscapedPath())
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, location, http.StatusPermanentRedirect)
+		return
+	}
 	if route == nil {
+		// 🔧 新增：路径存在但方法不匹配时返回 405
+		if methods := dr.routeManager.allowedMethods(path, tenantFromRequest(r), requestHost(r)); len(methods) > 0 {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(gin.H{"error": "method not allowed", "allowed_methods": methods})
+			return
+		}
+		// 🔧 新增：按 Host 配置的默认路由
+		if fallback := dr.defaultRoute(r); fallback != nil {
+			dr.serveRoute(fallback, w, r)
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(gin.H{"error": "route not found"})
 		return
 	}
 
+	dr.serveRoute(route, w, r)
+}
+
+// 🔧 新增：执行已确定的路由（授权、故障注入、按处理器分发），供路由匹配和 Dify 工具调用共用
+func (dr *DistributedRouter) serveRoute(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
+	if info := logInfoFromRequest(r); info != nil {
+		info.RouteID = route.ID
+		info.Route = route
+	}
+	// 🔧 新增：按路由的采样率决定是否追踪
+	dr.tracer.start(route, r)
+
+	// 🔧 新增：其他租户或其他域名的路由视为不存在
+	if !routeVisibleToTenant(route, tenantFromRequest(r)) || !routeVisibleToHost(route, requestHost(r)) {
+		writeRouteError(w, r, route, http.StatusNotFound, gin.H{"error": "route not found"})
+		return
+	}
+
+	// 🔧 新增：认证方式不满足路由要求时返回 401
+	if principal := principalFromRequest(r); !principal.authenticatedFor(route) {
+		writeRouteAuthError(w, route, fmt.Errorf("route requires %s authentication", route.authMode()))
+		return
+	}
+
+	// 🔧 新增：访问范围校验（已认证但无权调用该路由返回 403）
+	if !principalFromRequest(r).allows(route) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(gin.H{"error": "api key not permitted for this route"})
+		return
+	}
+
+	// 🔧 新增：路由分组的调用方限制、限流和默认超时
+	if route.GroupID != "" {
+		if route = dr.applyRouteGroup(route, w, r); route == nil {
+			return
+		}
+	}
+
+	// 🔧 新增：授权策略（规则或 OPA）
+	if allowed, reason, err := dr.authorizePolicy(route, r); err != nil {
+		writeRouteError(w, r, route, http.StatusServiceUnavailable, gin.H{"error": "policy evaluation failed: " + err.Error()})
+		return
+	} else if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(gin.H{"error": "request denied by policy", "reason": reason})
+		return
+	}
+
+	// 🔧 新增：消费者 Key 的并发上限
+	release, ok := dr.keyConcurrency.acquire(principalFromRequest(r))
+	if !ok {
+		principal := principalFromRequest(r)
+		dr.statsd.RecordKeyConcurrencyRejected(principal.Name)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(gin.H{"error": "too many concurrent requests for this api key", "max_concurrency": principal.MaxConcurrency})
+		return
+	}
+	defer release()
+
+	// 故障注入（仅对配置了规则的路由生效）
+	if dr.chaos.inject(route, w, r) {
+		return
+	}
+
+	// 🔧 新增：金丝雀分流
+	route = dr.selectRouteVariant(route, w, r)
+
+	// 🔧 新增：响应大小限制
+	if limit := route.responseLimit(); limit > 0 {
+		limiter := &responseLimiter{ResponseWriter: w, limit: limit, route: route, request: r}
+		defer dr.finishLimitedResponse(route, limiter)
+		w = limiter
+	}
+
+	// 🔧 新增：按路由改写请求头和响应头
+	r = route.RequestHeaders.applyTo(route, r)
+	if route.ResponseHeaders.rewrites() {
+		w = &headerRewriter{ResponseWriter: w, rules: &route.ResponseHeaders.HeaderRules, route: route, request: r}
+	}
+
 	// 根据处理器类型路由
 	switch route.Handler {
 	case "sandbox":
 		dr.handleSandboxRequest(route, w, r)
 	case "proxy":
 		dr.handleProxyRequest(route, w, r)
+	case "llm":
+		dr.handleLLMRequest(route, w, r)
 	case "static":
 		dr.handleStaticRequest(route, w, r)
+	case "echo":
+		dr.handleEchoRequest(route, w, r)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(gin.H{"error": "unknown handler type"})
@@ -181,42 +554,121 @@ func (dr *DistributedRouter) dynamicRouteHandler(w http.ResponseWriter, r *http.
 }
 
 func (dr *DistributedRouter) handleSandboxRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
-	// 获取健康的沙箱实例
-	instance, err := dr.sandboxPool.GetHealthyInstance(route.SandboxType)
+	// 获取路由代码（大代码块可能需要从 Redis 延迟加载）
+	code, err := dr.routeManager.resolveCode(route)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
+		writeRouteError(w, r, route, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 构建符合沙箱期望的请求格式
 	executionReq := map[string]interface{}{
-		"language":       "python3",
-		"code":           route.Code,
+		"language":       sandboxLanguage(route.SandboxType), // 🔧 修复：按沙箱类型传递语言
+		"code":           code,
 		"preload":        "",
 		"enable_network": true,
 		"timeout":        route.Timeout,
 	}
+	// 🔧 新增：配置了路径改写时，把原路径、改写后的路径和路径参数传给沙箱
+	if route.RewritePath != nil {
+		path, params := route.rewriteRequestPath(r)
+		executionReq["metadata"] = map[string]interface{}{
+			"original_path":  r.URL.Path,
+			"rewritten_path": path,
+			"path_params":    params,
+		}
+	}
+
+	// 🔧 新增：转发失败时换一个实例重试，非幂等请求需满足重试条件
+	attempts := retryAttempts(route, r)
+	tried := make(map[string]bool)
+	var lastErr error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		// 获取健康的沙箱实例（🔧 新增：跳过已达到自适应并发上限的实例；首次尝试没有可用实例时按 sandbox_wait 等待）
+		var instance *SandboxInstance
+		var release func(time.Duration, bool)
+		if attempt == 1 {
+			instance, release, err = dr.waitForSandbox(route, tried, r)
+		} else {
+			instance, release, err = dr.selectAdmittedInstance(route, tried)
+		}
+		if err != nil {
+			if lastErr != nil {
+				break
+			}
+			if retryAfter := sandboxRetryAfter(err); retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			writeRouteError(w, r, route, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 转发到沙箱执行，传递原始请求
+		start := time.Now()
+		resp, err := dr.sendToSandbox(route, instance, executionReq, r)
+		if err == nil {
+			rtt := time.Since(start)
+			// 🔧 新增：校验上游响应契约
+			if violation := dr.checkResponseContract(route, resp); violation != nil {
+				resp.Body.Close()
+				writeContractViolation(w, r, route, violation)
+			} else {
+				writeSandboxResponse(w, resp, route.ResponseHeaders)
+			}
+			release(rtt, upstreamOverloaded(resp.StatusCode))
+			dr.sandboxPool.ReleaseInstance(instance)
+			return
+		}
+		if r.Context().Err() != nil {
+			release(0, false) // 客户端取消不计入样本
+		} else {
+			release(time.Since(start), true)
+		}
+		dr.sandboxPool.ReleaseInstance(instance)
+
+		lastErr = err
+		tried[instance.ID] = true
+		if attempt < attempts {
+			log.Printf("🔁 Retrying route %s after sandbox %s failed: %v", route.ID, instance.ID, err)
+		}
+	}
 
-	// 转发到沙箱执行，传递原始请求
-	dr.forwardToSandbox(instance, executionReq, w, r)
+	writeRouteError(w, r, route, http.StatusBadGateway, gin.H{"error": "sandbox unavailable: " + lastErr.Error()})
 }
 
 func (dr *DistributedRouter) forwardToSandbox(instance *SandboxInstance, reqData map[string]interface{}, w http.ResponseWriter, r *http.Request) {
+	resp, err := dr.sendToSandbox(nil, instance, reqData, r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(gin.H{"error": "sandbox unavailable: " + err.Error()})
+		return
+	}
+	writeSandboxResponse(w, resp, nil)
+}
+
+// 向沙箱实例发送执行请求，仅在收到响应前失败时返回错误
+func (dr *DistributedRouter) sendToSandbox(route *RouteConfig, instance *SandboxInstance, reqData map[string]interface{}, r *http.Request) (*http.Response, error) {
 	timeout := 30 * time.Second
 	if to, ok := reqData["timeout"].(int); ok {
 		timeout = time.Duration(to) * time.Second
 	}
 
-	client := &http.Client{Timeout: timeout}
+	client := &http.Client{Timeout: timeout, Transport: upstreamTransport}
+	// 🔧 新增：隧道实例经 agent 的连接发送
+	if instance.Tunnel {
+		transport, err := dr.tunnels.transport(instance.ID)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = transport
+	}
 
 	reqJSON, _ := json.Marshal(reqData)
 	
 	req, err := http.NewRequest("POST", instance.URL+"/run", bytes.NewBuffer(reqJSON))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -232,33 +684,61 @@ func (dr *DistributedRouter) forwardToSandbox(instance *SandboxInstance, reqData
 		}
 	}
 	req.Header.Set("X-Api-Key", apiKey)
+	// 🔧 新增：执行ID，超时后按它通知沙箱取消执行
+	executionID := randomHex(16)
+	req.Header.Set(executionIDHeader, executionID)
+
+	// 🔧 新增：转发客户端证书身份
+	copyClientCertHeaders(req.Header, r.Header)
+	// 🔧 新增：路由改写的请求头
+	if route != nil {
+		route.RequestHeaders.copyTo(req.Header, r.Header)
+	}
+
+	// 🔧 新增：按路由配置对出站请求签名
+	if route != nil && route.Signing != nil {
+		if err := dr.signOutboundRequest(r.Context(), route.Signing, req, reqJSON); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %v", err)
+		}
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(gin.H{"error": "sandbox unavailable: " + err.Error()})
-		return
+		if isExecutionTimeout(err) {
+			dr.cancelExecution(instance, client, executionID, apiKey)
+		}
+		return nil, err
 	}
-	defer resp.Body.Close()
+	resp.Body = &cancelOnTimeoutBody{ReadCloser: resp.Body, cancel: func() {
+		dr.cancelExecution(instance, client, executionID, apiKey)
+	}}
+	return resp, nil
+}
 
-	// 复制响应头
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
+// 沙箱类型 -> 沙箱执行接口的 language 参数
+func sandboxLanguage(sandboxType string) string {
+	switch sandboxType {
+	case "nodejs":
+		return "nodejs"
+	case "go":
+		return "go"
+	default:
+		return "python3"
 	}
+}
+
+// 将沙箱响应写回客户端
+func writeSandboxResponse(w http.ResponseWriter, resp *http.Response, filter *RouteResponseHeaders) {
+	defer resp.Body.Close()
+
+	// 复制响应头（🔧 去除逐跳响应头并按路由过滤）
+	copyResponseHeaders(w.Header(), resp.Header, filter)
 
 	// 流式传输响应
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
 
-func (dr *DistributedRouter) handleProxyRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
-	// TODO: 实现代理请求处理
-	w.WriteHeader(http.StatusNotImplemented)
-	json.NewEncoder(w).Encode(gin.H{"error": "proxy handler not implemented"})
-}
-
 func (dr *DistributedRouter) handleStaticRequest(route *RouteConfig, w http.ResponseWriter, r *http.Request) {
 	// TODO: 实现静态文件处理
 	w.WriteHeader(http.StatusNotImplemented)
@@ -267,8 +747,38 @@ func (dr *DistributedRouter) handleStaticRequest(route *RouteConfig, w http.Resp
 
 // 管理接口处理器
 func (dr *DistributedRouter) listRoutesHandler(c *gin.Context) {
-	routes := dr.routeManager.GetAllRoutes()
-	c.JSON(200, gin.H{"routes": routes})
+	version, table := dr.routeManager.versionedSnapshot()
+
+	// 🔧 修改：ETag 按路由表内容生成，各实例内容相同时一致；轮询方在配置未变化时只收到 304
+	etag := table.listETag(time.Now().Unix())
+	c.Header("ETag", etag)
+	c.Header("X-Config-Version", strconv.FormatInt(version, 10))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 🔧 新增：q 按关键字搜索路由（ID、路径、域名、租户、说明、负责人和标签，忽略大小写），
+	// 按标签、处理器、方法、租户、域名和分组筛选，排序并分页（不传 limit 时返回全部）
+	query, err := parseRouteListQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	routes, total := query.apply(table.list())
+	// 🔧 新增：延迟加载的路由在列表中没有代码，标记出来，避免被误认为代码为空
+	for i := range routes {
+		routes[i].CodeLazy = table.lazyCode[routes[i].ID]
+	}
+	response := gin.H{"routes": routes, "config_version": version, "total": total}
+	if query.Limit > 0 {
+		response["limit"] = query.Limit
+		response["offset"] = query.Offset
+		if next := query.Offset + len(routes); next < total {
+			response["next_offset"] = next
+		}
+	}
+	c.JSON(200, response)
 }
 
 func (dr *DistributedRouter) addRouteHandler(c *gin.Context) {
@@ -278,12 +788,29 @@ func (dr *DistributedRouter) addRouteHandler(c *gin.Context) {
 		return
 	}
 
+	// 🔧 新增：带 effective_at 时到期后由主节点创建
+	if c.Query("effective_at") != "" {
+		dr.scheduleRouteChange(c, scheduledCreate, route.ID, &route)
+		return
+	}
+
+	// 🔧 新增：与现有路由重叠时返回警告，优先级相同时拒绝（force=true 时仍然创建）
+	conflicts, err := dr.routeManager.checkRouteConflicts(route, c.Query("force") == "true")
+	if err != nil {
+		c.JSON(409, gin.H{"error": err.Error(), "conflicts": conflicts})
+		return
+	}
+
 	if err := dr.routeManager.AddRoute(route); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "route added", "id": route.ID})
+	response := gin.H{"message": "route added", "id": route.ID}
+	if len(conflicts) > 0 {
+		response["warnings"] = conflicts
+	}
+	c.JSON(200, response)
 }
 
 func (dr *DistributedRouter) updateRouteHandler(c *gin.Context) {
@@ -295,16 +822,51 @@ func (dr *DistributedRouter) updateRouteHandler(c *gin.Context) {
 		return
 	}
 
-	if err := dr.routeManager.UpdateRoute(id, route); err != nil {
+	// 🔧 新增：dry_run 只返回修改的字段、更新后的优先级和冲突的路由，不持久化
+	if c.Query("dry_run") == "true" {
+		preview, err := dr.routeManager.PreviewUpdate(id, route)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"dry_run": true, "id": id, "preview": preview})
+		return
+	}
+
+	if c.Query("effective_at") != "" {
+		dr.scheduleRouteChange(c, scheduledUpdate, id, &route)
+		return
+	}
+
+	conflicts, err := dr.routeManager.checkRouteConflicts(route, c.Query("force") == "true")
+	if err != nil {
+		c.JSON(409, gin.H{"error": err.Error(), "conflicts": conflicts})
+		return
+	}
+
+	changes, err := dr.routeManager.UpdateRoute(id, route)
+	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "route updated", "id": route.ID})
+	// 🔧 新增：修改的字段记入审计日志并返回
+	if len(changes) > 0 {
+		c.Set(auditMessageKey, describeFieldChanges("route "+id, changes))
+	}
+	response := gin.H{"message": "route updated", "id": route.ID, "changes": changes}
+	if len(conflicts) > 0 {
+		response["warnings"] = conflicts
+	}
+	c.JSON(200, response)
 }
 
 func (dr *DistributedRouter) deleteRouteHandler(c *gin.Context) {
 	id := c.Param("id")
+	if c.Query("effective_at") != "" {
+		dr.scheduleRouteChange(c, scheduledDelete, id, nil)
+		return
+	}
 	if err := dr.routeManager.DeleteRoute(id); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
@@ -325,70 +887,89 @@ func (dr *DistributedRouter) registerSandboxHandler(c *gin.Context) {
 		return
 	}
 
+	// 🔧 新增：IPv6 地址必须加方括号
+	if err := validateSandboxURL(instance.URL); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 隧道实例只能由 agent 接入
+	instance.Tunnel = false
 	if err := dr.sandboxPool.RegisterInstance(&instance); err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
+	dr.recordSandboxChange(&SandboxChange{Action: sandboxChangeRegistered, Sandbox: &instance, Actor: sandboxActorAdmin, ClientIP: c.ClientIP()})
 
 	c.JSON(200, gin.H{"message": "sandbox registered"})
 }
 
 func (dr *DistributedRouter) deleteSandboxHandler(c *gin.Context) {
 	id := c.Param("id")
+	instance, exists := dr.sandboxPool.GetAllInstances()[id]
+	dr.tunnels.close(id) // 🔧 新增：隧道实例同时断开隧道（agent 会重新接入）
 	if err := dr.sandboxPool.RemoveInstance(id); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.JSON(200, gin.H{"message": "sandbox deleted"})
-}
-
-func (dr *DistributedRouter) healthHandler(c *gin.Context) {
-	// 检查Redis连接
-	_, err := dr.redisClient.Ping(context.Background()).Result()
-	if err != nil {
-		c.JSON(503, gin.H{
-			"status": "unhealthy",
-			"error":  "Redis connection failed: " + err.Error(),
-		})
-		return
+	if exists {
+		dr.recordSandboxChange(&SandboxChange{Action: sandboxChangeRemoved, Sandbox: instance, Actor: sandboxActorAdmin, ClientIP: c.ClientIP()})
 	}
 
-	c.JSON(200, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().Unix(),
-		"routes":    len(dr.routeManager.GetAllRoutes()),
-		"sandboxes": len(dr.sandboxPool.GetAllInstances()),
-	})
+	c.JSON(200, gin.H{"message": "sandbox deleted"})
 }
 
-func (dr *DistributedRouter) corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+func (dr *DistributedRouter) Run(addr string) error {
+	// 🔧 新增：端口确定后注册本实例
+	dr.startSelfRegistration()
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	managementServer := &http.Server{Addr: ":" + strconv.Itoa(dr.managementPort), Handler: dr.adminHandler()}
+	gatewayServer := &http.Server{Addr: ":" + strconv.Itoa(dr.gatewayPort), Handler: dr.gatewayHandler()}
 
-		c.Next()
+	// 🔧 新增：按 network.listen_addresses 监听（IPv4、IPv6 或双栈）
+	listenAddrs := gatewaySettings().Network.ListenAddresses
+	managementListeners, err := listenAddresses(dr.managementPort, listenAddrs)
+	if err != nil {
+		return err
+	}
+	gatewayListeners, err := listenAddresses(dr.gatewayPort, listenAddrs)
+	if err != nil {
+		closeListeners(managementListeners)
+		return err
 	}
-}
 
-func (dr *DistributedRouter) Run(addr string) error {
+	// 🔧 新增：收到退出信号后排空再关闭
+	done := make(chan struct{})
+	go func() {
+		dr.shutdownOnSignal(gatewayServer, managementServer)
+		close(done)
+	}()
+
 	// 启动Gin服务器（管理API）
 	go func() {
-		managementAddr := ":" + strconv.Itoa(dr.managementPort)
-		log.Printf("Starting management API on %s", managementAddr)
-		if err := dr.ginRouter.Run(managementAddr); err != nil {
+		log.Printf("Starting management API on %s", listenerAddrs(managementListeners))
+		if err := serveListeners(managementListeners, managementServer.Serve); err != nil && err != http.ErrServerClosed {
 			log.Printf("Gin server error: %v", err)
 		}
 	}()
 
 	// 启动Mux服务器（动态路由）
-	gatewayAddr := ":" + strconv.Itoa(dr.gatewayPort)
-	log.Printf("Starting gateway server on %s", gatewayAddr)
-	return http.ListenAndServe(gatewayAddr, dr.muxRouter)
+	log.Printf("Starting gateway server on %s", listenerAddrs(gatewayListeners))
+	if tlsSettings := gatewaySettings().TLS; tlsSettings.Enabled {
+		// 🔧 新增：网关端口 TLS/mTLS
+		if gatewayServer.TLSConfig, err = gatewayTLSConfig(tlsSettings); err != nil {
+			return err
+		}
+		log.Printf("🔒 Gateway TLS enabled (client_auth: %s)", tlsSettings.ClientAuth)
+		err = serveListeners(gatewayListeners, func(listener net.Listener) error {
+			return gatewayServer.ServeTLS(listener, tlsSettings.CertFile, tlsSettings.KeyFile)
+		})
+	} else {
+		err = serveListeners(gatewayListeners, gatewayServer.Serve)
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	<-done
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,266 @@
+package gateway
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+const benchRouteCount = 10000
+
+var benchConfigOnce sync.Once
+
+// 初始化压测用的全局配置
+func initBenchConfig(b *testing.B) {
+	b.Helper()
+	benchConfigOnce.Do(func() {
+		path := filepath.Join(os.TempDir(), "dify-router-bench-config.yaml")
+		content := "app:\n  gateway_key: bench-gateway-key\n  admin_key: bench-admin-key\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			b.Fatalf("write bench config: %v", err)
+		}
+		if err := static.InitConfig(path); err != nil {
+			b.Fatalf("init bench config: %v", err)
+		}
+	})
+}
+
+// 构建不依赖 Redis 的路由管理器，包含 n 条混合类型的路由
+func newBenchRouteManager(n int) *RouteManager {
+	rm := &RouteManager{updateChannel: make(chan struct{}, 1)}
+	table := newRouteTable()
+
+	for i := 0; i < n; i++ {
+		var path string
+		switch i % 4 {
+		case 0:
+			path = fmt.Sprintf("/api/v1/exact-%d", i)
+		case 1:
+			path = fmt.Sprintf("/api/v1/users-%d/{id}", i)
+		case 2:
+			path = fmt.Sprintf("/api/v1/prefix-%d", i)
+		default:
+			path = fmt.Sprintf("/api/v1/wild-%d/*", i)
+		}
+		route := RouteConfig{
+			ID:          fmt.Sprintf("route-%d", i),
+			Path:        path,
+			Method:      "GET",
+			Handler:     "sandbox",
+			SandboxType: "python",
+			Version:     int64(i + 1),
+		}
+		table.put(route.ID, route)
+	}
+	rm.table.Store(table)
+	return rm
+}
+
+func BenchmarkMatchRouteExact(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/exact-%d", benchRouteCount/2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rm.matchRoute(path, "GET") == nil {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+func BenchmarkMatchRouteParam(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/users-%d/42", benchRouteCount/2+1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rm.matchRoute(path, "GET") == nil {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+func BenchmarkMatchRouteWildcard(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/wild-%d/a/b/c", benchRouteCount/2+3)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rm.matchRoute(path, "GET") == nil {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+func BenchmarkMatchRouteMiss(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rm.matchRoute("/not/registered", "GET") != nil {
+			b.Fatal("unexpected match")
+		}
+	}
+}
+
+func BenchmarkMatchRouteParallel(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/prefix-%d/child", benchRouteCount/2+2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rm.matchRoute(path, "GET")
+		}
+	})
+}
+
+// 🔧 新增：命中匹配缓存的路径（与 BenchmarkMatchRouteParam 相同的请求），不应慢于直接查找路径索引
+func BenchmarkMatchRouteCached(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	rm.matchCache = newMatchCache(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/users-%d/42", benchRouteCount/2+1)
+	rm.matchRoute(path, "GET")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rm.matchRoute(path, "GET") == nil {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+// 并发命中同一个缓存条目（与 BenchmarkMatchRouteParallel 相同的请求）
+func BenchmarkMatchRouteCachedParallel(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	rm.matchCache = newMatchCache(benchRouteCount)
+	path := fmt.Sprintf("/api/v1/prefix-%d/child", benchRouteCount/2+2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rm.matchRoute(path, "GET")
+		}
+	})
+}
+
+// 有大量生效时间窗口的路由时命中缓存：窗口纪元按快照和秒计算，不随窗口路由数增长
+func BenchmarkMatchRouteCachedWindowed(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	rm.matchCache = newMatchCache(benchRouteCount)
+	table := rm.table.Load().clone()
+	now := time.Now().Unix()
+	for i := 0; i < benchRouteCount; i += 2 {
+		route := table.routes[fmt.Sprintf("route-%d", i)]
+		route.ActiveFrom = now - 3600
+		route.ExpiresAt = now + 3600
+		table.put(route.ID, route)
+	}
+	rm.table.Store(table)
+	path := fmt.Sprintf("/api/v1/users-%d/42", benchRouteCount/2+1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rm.matchRoute(path, "GET") == nil {
+				b.Fatal("expected a match")
+			}
+		}
+	})
+}
+
+// 路由变更时索引的增量更新：复制索引并写入一条路由，只复制途经的节点
+func BenchmarkRouteIndexUpdate(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	index := rm.table.Load().index
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		route := RouteConfig{
+			ID:     fmt.Sprintf("route-%d", i%benchRouteCount),
+			Path:   fmt.Sprintf("/api/v2/updated-%d", i),
+			Method: "GET",
+		}
+		next := index.clone()
+		next.insert(route.ID, route, compileRouteMatcher(route))
+	}
+}
+
+// 🔧 新增：一次读取到的 100 条单路由事件一起应用：整批只复制和发布一次路由表，而不是每条事件复制一次
+func BenchmarkApplyRouteEvents(b *testing.B) {
+	rm := newBenchRouteManager(benchRouteCount)
+	handler := &RouteEventHandler{routeManager: rm}
+	events := make([]*RouteEvent, 100)
+	for i := range events {
+		route := RouteConfig{
+			ID:      fmt.Sprintf("route-%d", i*97),
+			Path:    fmt.Sprintf("/api/v3/updated-%d", i),
+			Method:  "GET",
+			Handler: "echo",
+		}
+		events[i] = &RouteEvent{EventType: "UPDATE", RouteID: route.ID, RouteData: &route}
+	}
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, err := range handler.HandleEvents(events) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAuthenticateGatewayRequest(b *testing.B) {
+	initBenchConfig(b)
+	dr := &DistributedRouter{}
+	req := httptest.NewRequest("GET", "/api/v1/exact-0", nil)
+	req.Header.Set("X-Api-Key", "bench-gateway-key")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := dr.authenticateGatewayRequest(req); !ok {
+			b.Fatal("expected authentication to pass")
+		}
+	}
+}
+
+func BenchmarkForwardToSandbox(b *testing.B) {
+	initBenchConfig(b)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":0,"message":"success","data":{"stdout":"ok\n"}}`))
+	}))
+	defer upstream.Close()
+
+	dr := &DistributedRouter{}
+	instance := &SandboxInstance{ID: "bench", URL: upstream.URL, Type: "python", Status: "healthy"}
+	reqData := map[string]interface{}{
+		"language": "python3",
+		"code":     "print('ok')",
+		"timeout":  5,
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/exact-0", nil)
+		req.Header.Set("X-Api-Key", "bench-gateway-key")
+		recorder := httptest.NewRecorder()
+		dr.forwardToSandbox(instance, reqData, recorder, req)
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	utilslog "github.com/dify-router/dify-router/internal/utils/log"
+	"github.com/gin-gonic/gin"
+)
+
+// 审计日志附加说明（处理器通过 c.Set 写入，auditLogMiddleware 记入 message）
+const auditMessageKey = "audit_message"
+
+// 运行时历史记录保留条数
+const runtimeHistoryLimit = 50
+
+var runtimeLogLevels = map[string]int{
+	"debug": utilslog.LOG_LEVEL_DEBUG,
+	"info":  utilslog.LOG_LEVEL_INFO,
+	"warn":  utilslog.LOG_LEVEL_WARN,
+	"error": utilslog.LOG_LEVEL_ERROR,
+}
+
+// 🔧 新增：本实例的运行时设置（不持久化，重启后恢复配置文件中的值）
+type RuntimeSettings struct {
+	LogLevel            string `json:"log_level"`
+	SyncInterval        int    `json:"sync_interval"`         // 配置同步间隔（秒）
+	HealthCheckInterval int    `json:"health_check_interval"` // 沙箱健康检查间隔（秒）
+	DebugCapture        bool   `json:"debug_capture"`         // 记录网关请求的请求头与响应状态
+}
+
+// PATCH /admin/runtime 请求体，只修改提供的字段
+type runtimeSettingsPatch struct {
+	LogLevel            *string `json:"log_level"`
+	SyncInterval        *int    `json:"sync_interval"`
+	HealthCheckInterval *int    `json:"health_check_interval"`
+	DebugCapture        *bool   `json:"debug_capture"`
+}
+
+// 一次运行时设置修改
+type RuntimeChange struct {
+	Time      int64                  `json:"time"`
+	Principal string                 `json:"principal"`
+	ClientIP  string                 `json:"client_ip"`
+	Changes   map[string]FieldChange `json:"changes"`
+}
+
+type runtimeState struct {
+	logLevel     string
+	debugCapture atomic.Bool
+	history      []RuntimeChange
+	mutex        sync.Mutex
+}
+
+func newRuntimeState() *runtimeState {
+	// 应用日志默认级别为 debug
+	return &runtimeState{logLevel: "debug"}
+}
+
+func (dr *DistributedRouter) runtimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		LogLevel:            dr.runtime.logLevel,
+		SyncInterval:        int(dr.routeManager.SyncInterval() / time.Second),
+		HealthCheckInterval: int(dr.sandboxPool.HealthCheckInterval() / time.Second),
+		DebugCapture:        dr.runtime.debugCapture.Load(),
+	}
+}
+
+// GET /admin/runtime：当前设置与修改历史
+func (dr *DistributedRouter) getRuntimeHandler(c *gin.Context) {
+	dr.runtime.mutex.Lock()
+	defer dr.runtime.mutex.Unlock()
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"settings":    dr.runtimeSettings(),
+		"history":     append([]RuntimeChange(nil), dr.runtime.history...),
+	})
+}
+
+// PATCH /admin/runtime：修改本实例的运行时设置，立即生效
+func (dr *DistributedRouter) patchRuntimeHandler(c *gin.Context) {
+	var patch runtimeSettingsPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.LogLevel != nil {
+		if _, ok := runtimeLogLevels[strings.ToLower(*patch.LogLevel)]; !ok {
+			c.JSON(400, gin.H{"error": "log_level must be debug, info, warn or error"})
+			return
+		}
+	}
+	if patch.SyncInterval != nil && *patch.SyncInterval < 1 {
+		c.JSON(400, gin.H{"error": "sync_interval must be at least 1 second"})
+		return
+	}
+	if patch.HealthCheckInterval != nil && *patch.HealthCheckInterval < 1 {
+		c.JSON(400, gin.H{"error": "health_check_interval must be at least 1 second"})
+		return
+	}
+
+	dr.runtime.mutex.Lock()
+	defer dr.runtime.mutex.Unlock()
+
+	current := dr.runtimeSettings()
+	changes := make(map[string]FieldChange)
+	if patch.LogLevel != nil {
+		level := strings.ToLower(*patch.LogLevel)
+		if level != current.LogLevel {
+			utilslog.SetLogLevel(runtimeLogLevels[level])
+			dr.runtime.logLevel = level
+			changes["log_level"] = FieldChange{From: current.LogLevel, To: level}
+		}
+	}
+	if patch.SyncInterval != nil && *patch.SyncInterval != current.SyncInterval {
+		dr.routeManager.SetSyncInterval(time.Duration(*patch.SyncInterval) * time.Second)
+		changes["sync_interval"] = FieldChange{From: current.SyncInterval, To: *patch.SyncInterval}
+	}
+	if patch.HealthCheckInterval != nil && *patch.HealthCheckInterval != current.HealthCheckInterval {
+		dr.sandboxPool.SetHealthCheckInterval(time.Duration(*patch.HealthCheckInterval) * time.Second)
+		changes["health_check_interval"] = FieldChange{From: current.HealthCheckInterval, To: *patch.HealthCheckInterval}
+	}
+	if patch.DebugCapture != nil && *patch.DebugCapture != current.DebugCapture {
+		dr.runtime.debugCapture.Store(*patch.DebugCapture)
+		changes["debug_capture"] = FieldChange{From: current.DebugCapture, To: *patch.DebugCapture}
+	}
+
+	if len(changes) > 0 {
+		change := RuntimeChange{
+			Time:      time.Now().Unix(),
+			Principal: "admin",
+			ClientIP:  c.ClientIP(),
+			Changes:   changes,
+		}
+		dr.runtime.history = append(dr.runtime.history, change)
+		if len(dr.runtime.history) > runtimeHistoryLimit {
+			dr.runtime.history = dr.runtime.history[len(dr.runtime.history)-runtimeHistoryLimit:]
+		}
+		message := describeFieldChanges("runtime", changes)
+		c.Set(auditMessageKey, message)
+		log.Printf("🔧 Runtime settings changed by %s from %s: %s", change.Principal, change.ClientIP, message)
+	}
+
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"settings":    dr.runtimeSettings(),
+		"changes":     changes,
+	})
+}
+
+// 调试捕获：记录请求头（凭据已脱敏）、响应状态与耗时；🔧 请求地址按 gateway.redaction 脱敏
+func (dr *DistributedRouter) captureRequest(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w}
+	next.ServeHTTP(recorder, r)
+
+	headers := redactedHeaderLines(r.Header)
+	log.Printf("🐞 %s %s -> %d (%v) from %s [%s]", r.Method, currentRedactor().text(r.URL.RequestURI()), recorder.status, time.Since(start), clientIP(r), strings.Join(headers, " | "))
+}
+
+// 发送最新的间隔值，未被消费的旧值被替换
+func sendLatestInterval(changes chan time.Duration, interval time.Duration) {
+	for {
+		select {
+		case changes <- interval:
+			return
+		default:
+			select {
+			case <-changes:
+			default:
+			}
+		}
+	}
+}
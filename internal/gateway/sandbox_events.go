@@ -0,0 +1,240 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 沙箱池变化
+const (
+	sandboxChangeRegistered = "registered" // 通过管理接口或隧道接入注册
+	sandboxChangeRemoved    = "removed"    // 通过管理接口删除
+	sandboxChangeEvicted    = "evicted"    // 网关自动移除（如隧道断开超过 grace）
+)
+
+// 触发变化的一方
+const (
+	sandboxActorAdmin       = "admin"
+	sandboxActorTunnelAgent = "tunnel-agent"
+	sandboxActorGateway     = "gateway"
+)
+
+// 保留的最近变化条数
+const maxSandboxChanges = 1000
+
+// 🔧 新增：沙箱池变化记录，发布为 SANDBOX_CHANGE 事件、转发日志并推送 webhook
+type SandboxChange struct {
+	Action     string           `json:"action"` // registered、removed 或 evicted
+	SandboxID  string           `json:"sandbox_id"`
+	Sandbox    *SandboxInstance `json:"sandbox,omitempty"`
+	Actor      string           `json:"actor"`               // admin、tunnel-agent 或 gateway
+	ClientIP   string           `json:"client_ip,omitempty"` // 管理请求或 agent 的来源地址
+	Reason     string           `json:"reason,omitempty"`
+	InstanceID string           `json:"instance_id"` // 执行变化的网关实例
+	Timestamp  int64            `json:"timestamp"`
+}
+
+func (change *SandboxChange) describe() string {
+	message := fmt.Sprintf("Sandbox %s %s by %s", change.SandboxID, change.Action, change.Actor)
+	if change.ClientIP != "" {
+		message += " from " + change.ClientIP
+	}
+	if change.Reason != "" {
+		message += ": " + change.Reason
+	}
+	return message
+}
+
+// 最近的沙箱池变化：Redis 可用时所有网关实例共享，否则只保留在本实例
+type sandboxChangeLog struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	local        []SandboxChange
+	mutex        sync.Mutex
+
+	webhooksSent   atomic.Int64
+	webhooksFailed atomic.Int64
+}
+
+const sandboxChangesKey = "gateway:sandbox:changes"
+
+func newSandboxChangeLog(redisClient *redis.Client, redisEnabled bool) *sandboxChangeLog {
+	return &sandboxChangeLog{redisClient: redisClient, redisEnabled: redisEnabled}
+}
+
+func (l *sandboxChangeLog) append(ctx context.Context, change *SandboxChange) error {
+	if !l.redisEnabled {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.local = append(l.local, *change)
+		if len(l.local) > maxSandboxChanges {
+			l.local = l.local[len(l.local)-maxSandboxChanges:]
+		}
+		return nil
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	pipe := l.redisClient.TxPipeline()
+	pipe.LPush(ctx, sandboxChangesKey, data)
+	pipe.LTrim(ctx, sandboxChangesKey, 0, maxSandboxChanges-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// 最近的变化，新的在前
+func (l *sandboxChangeLog) recent(ctx context.Context, limit int) ([]SandboxChange, error) {
+	changes := make([]SandboxChange, 0)
+	if !l.redisEnabled {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		for i := len(l.local) - 1; i >= 0 && len(changes) < limit; i-- {
+			changes = append(changes, l.local[i])
+		}
+		return changes, nil
+	}
+	values, err := l.redisClient.LRange(ctx, sandboxChangesKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		var change SandboxChange
+		if err := json.Unmarshal([]byte(value), &change); err == nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (l *sandboxChangeLog) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"webhooks_sent":   l.webhooksSent.Load(),
+		"webhooks_failed": l.webhooksFailed.Load(),
+	}
+}
+
+// 🔧 新增：记录沙箱池变化：写日志和指标，再异步保存、发布事件和推送 webhook（不阻塞注册或删除）
+func (dr *DistributedRouter) recordSandboxChange(change *SandboxChange) {
+	change.InstanceID = dr.routeManager.instanceID
+	change.Timestamp = time.Now().Unix()
+	if change.Sandbox != nil {
+		sandbox := *change.Sandbox
+		change.Sandbox = &sandbox
+		change.SandboxID = sandbox.ID
+	}
+	message := change.describe()
+	log.Printf("📦 %s", message)
+
+	sandboxType := ""
+	if change.Sandbox != nil {
+		sandboxType = change.Sandbox.Type
+	}
+	dr.statsd.RecordSandboxChange(change.Action, sandboxType)
+	dr.logForwarder.Emit(LogEvent{
+		Type:      logEventSandboxChange,
+		Timestamp: time.Unix(change.Timestamp, 0),
+		Principal: change.Actor,
+		ClientIP:  change.ClientIP,
+		Message:   message,
+	})
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := dr.sandboxChanges.append(ctx, change); err != nil {
+			log.Printf("⚠️ Failed to save sandbox change: %v", err)
+		}
+		if dr.routeManager.redisEnabled {
+			event := &RouteEvent{
+				EventID:       fmt.Sprintf("sandbox-%s-%d", change.SandboxID, time.Now().UnixNano()),
+				EventType:     "SANDBOX_CHANGE",
+				SandboxChange: change,
+				Source:        change.InstanceID,
+			}
+			if err := dr.routeManager.eventStream.PublishRouteEvent(ctx, event); err != nil {
+				log.Printf("⚠️ Failed to publish SANDBOX_CHANGE event: %v", err)
+			}
+		}
+		for _, webhook := range gatewaySettings().SandboxEvents.Webhooks {
+			if len(webhook.Actions) > 0 && !slices.Contains(webhook.Actions, change.Action) {
+				continue
+			}
+			if err := dr.sendSandboxWebhook(webhook, change); err != nil {
+				dr.sandboxChanges.webhooksFailed.Add(1)
+				log.Printf("⚠️ Sandbox change webhook %s failed: %v", webhook.URL, err)
+				continue
+			}
+			dr.sandboxChanges.webhooksSent.Add(1)
+		}
+	}()
+}
+
+func (dr *DistributedRouter) sendSandboxWebhook(webhook static.SandboxWebhookConfig, change *SandboxChange) error {
+	timeout := time.Duration(webhook.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, ref := range webhook.Headers {
+		value, err := dr.secrets.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve header %s: %v", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// 🔧 新增：GET /admin/sandboxes/changes?limit=50：最近的沙箱池变化（新的在前）
+func (dr *DistributedRouter) listSandboxChangesHandler(c *gin.Context) {
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSandboxChanges {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSandboxChanges)})
+			return
+		}
+	}
+	changes, err := dr.sandboxChanges.recent(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"changes": changes, "count": len(changes)})
+}
@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 等待期间在没有实例变化通知时重新尝试的间隔（并发名额释放不会发出通知）
+const sandboxWaitPollInterval = 250 * time.Millisecond
+
+// 🔧 新增：等待可用沙箱的统计
+type sandboxWaitStats struct {
+	waiting   atomic.Int64
+	waited    atomic.Int64
+	recovered atomic.Int64
+	expired   atomic.Int64
+}
+
+func (s *sandboxWaitStats) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"waiting":         s.waiting.Load(),
+		"waited_total":    s.waited.Load(),
+		"recovered_total": s.recovered.Load(),
+		"expired_total":   s.expired.Load(),
+	}
+}
+
+// 选择沙箱实例；没有可用实例且配置了 sandbox_wait.max_wait 时，在等待预算内挂起请求，
+// 直到健康检查或扩容带回可用实例、等待超时或客户端断开
+func (dr *DistributedRouter) waitForSandbox(route *RouteConfig, tried map[string]bool, r *http.Request) (*SandboxInstance, func(time.Duration, bool), error) {
+	maxWait := time.Duration(gatewaySettings().SandboxWait.MaxWait) * time.Second
+	changed := dr.sandboxPool.changes()
+	instance, release, err := dr.selectAdmittedInstance(route, tried)
+	if err == nil || maxWait <= 0 {
+		return instance, release, err
+	}
+
+	dr.sandboxWait.waited.Add(1)
+	dr.sandboxWait.waiting.Add(1)
+	defer dr.sandboxWait.waiting.Add(-1)
+
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	poll := time.NewTicker(sandboxWaitPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-changed:
+		case <-poll.C:
+		case <-deadline.C:
+			dr.sandboxWait.expired.Add(1)
+			return nil, nil, err
+		case <-r.Context().Done():
+			return nil, nil, err
+		}
+
+		changed = dr.sandboxPool.changes()
+		if instance, release, err = dr.selectAdmittedInstance(route, tried); err == nil {
+			dr.sandboxWait.recovered.Add(1)
+			return instance, release, nil
+		}
+	}
+}
+
+// 没有可用沙箱时的 Retry-After：启用等待时为 sandbox_wait.retry_after，仅达到并发上限时为 1 秒
+func sandboxRetryAfter(err error) string {
+	settings := gatewaySettings().SandboxWait
+	if settings.MaxWait > 0 && settings.RetryAfter > 0 {
+		return strconv.Itoa(settings.RetryAfter)
+	}
+	if err == errConcurrencyLimited {
+		return "1"
+	}
+	return ""
+}
@@ -0,0 +1,376 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	scheduledChangesRedisKey   = "gateway:scheduled_changes"
+	scheduledHistoryRedisKey   = "gateway:scheduled_changes:history"
+	scheduledChangesPeriod     = time.Second
+	scheduledChangesHistoryMax = 100
+
+	scheduledCreate = "create"
+	scheduledUpdate = "update"
+	scheduledDelete = "delete"
+)
+
+// 🔧 新增：定时生效的路由变更，由主节点在 effective_at 到达后执行并发布路由事件
+type ScheduledChange struct {
+	ID          string       `json:"id"`
+	Action      string       `json:"action"` // create、update、delete
+	RouteID     string       `json:"route_id"`
+	Route       *RouteConfig `json:"route,omitempty"`
+	EffectiveAt int64        `json:"effective_at"` // 生效时间（Unix 秒）
+	CreatedAt   int64        `json:"created_at"`
+	AppliedAt   int64        `json:"applied_at,omitempty"`
+	Status      string       `json:"status"` // pending、applied、failed
+	Error       string       `json:"error,omitempty"`
+}
+
+// 🔧 新增：定时变更在 Redis 中的存储格式，路由经 encodeStoredRoute 编码（启用加密时加密字段为密文）；
+// route 字段仅用于读取加密前写入的明文变更
+type storedScheduledChange struct {
+	ScheduledChange
+	Route       *RouteConfig    `json:"route,omitempty"`
+	StoredRoute json.RawMessage `json:"stored_route,omitempty"`
+}
+
+func encodeScheduledChange(change *ScheduledChange) ([]byte, error) {
+	stored := storedScheduledChange{ScheduledChange: *change}
+	stored.ScheduledChange.Route = nil
+	if change.Route != nil {
+		routeJSON, err := encodeStoredRoute(*change.Route)
+		if err != nil {
+			return nil, err
+		}
+		stored.StoredRoute = routeJSON
+	}
+	return json.Marshal(stored)
+}
+
+func decodeScheduledChange(data []byte) (*ScheduledChange, error) {
+	var stored storedScheduledChange
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	change := stored.ScheduledChange
+	change.Route = stored.Route
+	if len(stored.StoredRoute) > 0 {
+		route, err := decodeStoredRoute(stored.StoredRoute)
+		if err != nil {
+			return nil, err
+		}
+		change.Route = &route
+	}
+	return &change, nil
+}
+
+// 定时变更存储：待执行的变更保存在 Redis 哈希中，执行结果保存在有上限的历史列表中；未启用 Redis 时保存在本地内存
+type ScheduledChangeStore struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	pending      map[string]*ScheduledChange
+	history      []*ScheduledChange // 最新的在前
+	mutex        sync.Mutex
+}
+
+func NewScheduledChangeStore(redisClient *redis.Client, redisEnabled bool) *ScheduledChangeStore {
+	return &ScheduledChangeStore{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		pending:      make(map[string]*ScheduledChange),
+	}
+}
+
+// 保存待执行的变更
+func (s *ScheduledChangeStore) Add(ctx context.Context, change *ScheduledChange) error {
+	if s.redisEnabled {
+		changeJSON, err := encodeScheduledChange(change)
+		if err != nil {
+			return fmt.Errorf("failed to encode scheduled change: %v", err)
+		}
+		if err := s.redisClient.HSet(ctx, scheduledChangesRedisKey, change.ID, changeJSON).Err(); err != nil {
+			return fmt.Errorf("failed to save scheduled change: %v", err)
+		}
+		return nil
+	}
+
+	s.mutex.Lock()
+	s.pending[change.ID] = change
+	s.mutex.Unlock()
+	return nil
+}
+
+// 取出待执行的变更；返回 nil 表示变更不存在或已被其他实例取出（保证每个变更只执行一次）
+func (s *ScheduledChangeStore) Take(ctx context.Context, id string) (*ScheduledChange, error) {
+	if !s.redisEnabled {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		change := s.pending[id]
+		delete(s.pending, id)
+		return change, nil
+	}
+
+	changeJSON, err := s.redisClient.HGet(ctx, scheduledChangesRedisKey, id).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scheduled change: %v", err)
+	}
+	removed, err := s.redisClient.HDel(ctx, scheduledChangesRedisKey, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled change: %v", err)
+	}
+	if removed == 0 {
+		return nil, nil
+	}
+
+	change, err := decodeScheduledChange([]byte(changeJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled change %s: %v", id, err)
+	}
+	return change, nil
+}
+
+// 待执行的变更，按生效时间排序
+func (s *ScheduledChangeStore) Pending(ctx context.Context) ([]*ScheduledChange, error) {
+	var changes []*ScheduledChange
+	if s.redisEnabled {
+		stored, err := s.redisClient.HGetAll(ctx, scheduledChangesRedisKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load scheduled changes: %v", err)
+		}
+		for id, changeJSON := range stored {
+			change, err := decodeScheduledChange([]byte(changeJSON))
+			if err != nil {
+				log.Printf("Skipping undecodable scheduled change %s: %v", id, err)
+				continue
+			}
+			changes = append(changes, change)
+		}
+	} else {
+		s.mutex.Lock()
+		for _, change := range s.pending {
+			changes = append(changes, change)
+		}
+		s.mutex.Unlock()
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].EffectiveAt != changes[j].EffectiveAt {
+			return changes[i].EffectiveAt < changes[j].EffectiveAt
+		}
+		return changes[i].CreatedAt < changes[j].CreatedAt
+	})
+	return changes, nil
+}
+
+// 记录执行结果
+func (s *ScheduledChangeStore) Record(ctx context.Context, change *ScheduledChange) {
+	if s.redisEnabled {
+		changeJSON, err := encodeScheduledChange(change)
+		if err != nil {
+			log.Printf("Failed to record scheduled change %s: %v", change.ID, err)
+			return
+		}
+		pipe := s.redisClient.TxPipeline()
+		pipe.LPush(ctx, scheduledHistoryRedisKey, changeJSON)
+		pipe.LTrim(ctx, scheduledHistoryRedisKey, 0, scheduledChangesHistoryMax-1)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to record scheduled change %s: %v", change.ID, err)
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	s.history = append([]*ScheduledChange{change}, s.history...)
+	if len(s.history) > scheduledChangesHistoryMax {
+		s.history = s.history[:scheduledChangesHistoryMax]
+	}
+	s.mutex.Unlock()
+}
+
+// 最近执行的变更，最新的在前
+func (s *ScheduledChangeStore) History(ctx context.Context) ([]*ScheduledChange, error) {
+	if !s.redisEnabled {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return append([]*ScheduledChange{}, s.history...), nil
+	}
+
+	stored, err := s.redisClient.LRange(ctx, scheduledHistoryRedisKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scheduled change history: %v", err)
+	}
+	changes := make([]*ScheduledChange, 0, len(stored))
+	for _, changeJSON := range stored {
+		change, err := decodeScheduledChange([]byte(changeJSON))
+		if err != nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// 主节点定时执行到期的变更
+func (dr *DistributedRouter) runScheduledChanges() {
+	ticker := time.NewTicker(scheduledChangesPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !dr.leader.IsLeader() {
+			continue
+		}
+		dr.applyDueChanges(context.Background(), time.Now())
+	}
+}
+
+func (dr *DistributedRouter) applyDueChanges(ctx context.Context, now time.Time) {
+	pending, err := dr.scheduledChanges.Pending(ctx)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+	for _, due := range pending {
+		if due.EffectiveAt > now.Unix() {
+			break
+		}
+		change, err := dr.scheduledChanges.Take(ctx, due.ID)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			continue
+		}
+		if change == nil {
+			continue
+		}
+
+		err = dr.applyScheduledChange(change)
+		change.AppliedAt = time.Now().Unix()
+		change.Status = "applied"
+		if err != nil {
+			change.Status = "failed"
+			change.Error = err.Error()
+			log.Printf("❌ Scheduled %s of route %s failed: %v", change.Action, change.RouteID, err)
+		} else {
+			log.Printf("⏰ Scheduled %s of route %s applied (change %s)", change.Action, change.RouteID, change.ID)
+		}
+		dr.scheduledChanges.Record(ctx, change)
+	}
+}
+
+// 执行变更，路由事件由路由管理器照常发布
+func (dr *DistributedRouter) applyScheduledChange(change *ScheduledChange) error {
+	switch change.Action {
+	case scheduledCreate:
+		return dr.routeManager.AddRoute(*change.Route)
+	case scheduledUpdate:
+		_, err := dr.routeManager.UpdateRoute(change.RouteID, *change.Route)
+		return err
+	case scheduledDelete:
+		return dr.routeManager.DeleteRoute(change.RouteID)
+	}
+	return fmt.Errorf("unknown action: %s", change.Action)
+}
+
+// 解析 effective_at：RFC3339 时间或 Unix 秒
+func parseEffectiveAt(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid effective_at: %s (expected RFC3339 or Unix seconds)", value)
+	}
+	return t, nil
+}
+
+// 🔧 新增：带有 effective_at 的路由变更请求不立即执行，校验后保存为定时变更（202）
+func (dr *DistributedRouter) scheduleRouteChange(c *gin.Context, action, routeID string, route *RouteConfig) {
+	effectiveAt, err := parseEffectiveAt(c.Query("effective_at"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	if !effectiveAt.After(now) {
+		c.JSON(400, gin.H{"error": "effective_at must be in the future"})
+		return
+	}
+
+	if route != nil {
+		if err := dr.routeManager.validateRouteConfiguration(*route); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if action == scheduledUpdate && route.ID != routeID {
+			c.JSON(400, gin.H{"error": "route ID cannot be changed"})
+			return
+		}
+	}
+
+	random := make([]byte, 8)
+	rand.Read(random)
+	change := &ScheduledChange{
+		ID:          hex.EncodeToString(random),
+		Action:      action,
+		RouteID:     routeID,
+		Route:       route,
+		EffectiveAt: effectiveAt.Unix(),
+		CreatedAt:   now.Unix(),
+		Status:      "pending",
+	}
+	if err := dr.scheduledChanges.Add(c.Request.Context(), change); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(auditMessageKey, fmt.Sprintf("scheduled %s of route %s at %s", action, routeID, effectiveAt.UTC().Format(time.RFC3339)))
+	c.JSON(202, gin.H{"message": "route change scheduled", "change": change})
+}
+
+// 🔧 新增：列出待执行的定时变更和最近的执行结果
+func (dr *DistributedRouter) listScheduledChangesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	pending, err := dr.scheduledChanges.Pending(ctx)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	history, err := dr.scheduledChanges.History(ctx)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if pending == nil {
+		pending = []*ScheduledChange{}
+	}
+	c.JSON(200, gin.H{"pending": pending, "history": history})
+}
+
+// 🔧 新增：取消尚未执行的定时变更
+func (dr *DistributedRouter) cancelScheduledChangeHandler(c *gin.Context) {
+	change, err := dr.scheduledChanges.Take(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if change == nil {
+		c.JSON(404, gin.H{"error": "scheduled change not found"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "scheduled change cancelled", "change": change})
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// 通过认证的调用方及其可访问的路由范围
+type gatewayPrincipal struct {
+	Name   string
+	Scope  static.RouteScope
+	Tenant string                 // 🔧 新增：解析出的租户（未启用多租户时为空）
+	Method string                 // 🔧 新增：认证方式 key、jwt、hmac、basic、none
+	Claims map[string]interface{} // 🔧 新增：访问令牌的全部声明（授权策略使用）
+
+	MaxConcurrency int // 🔧 新增：消费者 Key 的并发上限（0 表示不限制）
+
+	tenantClaim string // 访问令牌携带的租户声明
+}
+
+type principalContextKey struct{}
+
+func withPrincipal(r *http.Request, principal *gatewayPrincipal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal))
+}
+
+func principalFromRequest(r *http.Request) *gatewayPrincipal {
+	principal, _ := r.Context().Value(principalContextKey{}).(*gatewayPrincipal)
+	return principal
+}
+
+// 是否允许调用该路由：范围为空表示不限制，否则满足任一条件即可
+func (p *gatewayPrincipal) allows(route *RouteConfig) bool {
+	if p == nil {
+		return true
+	}
+	scope := p.Scope
+	if len(scope.RouteIDs) == 0 && len(scope.PathPrefixes) == 0 && len(scope.Groups) == 0 {
+		return true
+	}
+
+	for _, id := range scope.RouteIDs {
+		if id == route.ID {
+			return true
+		}
+	}
+	for _, prefix := range scope.PathPrefixes {
+		if pathHasSegmentPrefix(route.fullPath(), prefix) {
+			return true
+		}
+	}
+	// 🔧 新增：路由分组（group_id）与 metadata.group 都按分组范围授权
+	for _, g := range scope.Groups {
+		if g == route.Metadata["group"] || g == route.GroupID {
+			return true
+		}
+	}
+	return false
+}
+
+// 🔧 新增：按完整路径段匹配前缀，/tenant1 匹配 /tenant1 和 /tenant1/...，不匹配 /tenant10 或 /tenant1-admin
+func pathHasSegmentPrefix(path, prefix string) bool {
+	if path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// 将 OAuth scope（空格分隔）解析为路由范围：route:<id>、prefix:<path>、group:<name>，其他 scope 不限制路由
+func routeScopeFromOAuth(scope string) static.RouteScope {
+	var routeScope static.RouteScope
+	for _, s := range strings.Fields(scope) {
+		kind, value, found := strings.Cut(s, ":")
+		if !found || value == "" {
+			continue
+		}
+		switch kind {
+		case "route":
+			routeScope.RouteIDs = append(routeScope.RouteIDs, value)
+		case "prefix":
+			routeScope.PathPrefixes = append(routeScope.PathPrefixes, value)
+		case "group":
+			routeScope.Groups = append(routeScope.Groups, value)
+		}
+	}
+	return routeScope
+}
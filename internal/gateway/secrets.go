@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	secretsRedisKey = "gateway:secrets"
+	secretCacheTTL  = 30 * time.Second
+)
+
+// 密钥引用解析：配置中只保存引用，真实值在使用时解析，轮换后无需重启
+//
+//	env:NAME     读取环境变量
+//	file:/path   读取文件内容（去除首尾空白）
+//	secret:NAME  读取 Redis 中由管理接口写入的密钥
+//
+// 不带前缀的值按明文处理。
+type SecretResolver struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	cache        map[string]cachedSecret
+	mutex        sync.Mutex
+}
+
+type cachedSecret struct {
+	value     string
+	expiresAt time.Time
+}
+
+func NewSecretResolver(redisClient *redis.Client, redisEnabled bool) *SecretResolver {
+	return &SecretResolver{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		cache:        make(map[string]cachedSecret),
+	}
+}
+
+// 解析密钥引用，结果缓存 secretCacheTTL
+func (sr *SecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	sr.mutex.Lock()
+	cached, ok := sr.cache[ref]
+	sr.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	value, err := sr.lookup(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	sr.mutex.Lock()
+	sr.cache[ref] = cachedSecret{value: value, expiresAt: time.Now().Add(secretCacheTTL)}
+	sr.mutex.Unlock()
+	return value, nil
+}
+
+func (sr *SecretResolver) lookup(ctx context.Context, ref string) (string, error) {
+	scheme, name, found := strings.Cut(ref, ":")
+	if !found {
+		return ref, nil
+	}
+
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret env %s is not set", name)
+		}
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "secret":
+		if !sr.redisEnabled {
+			return "", fmt.Errorf("secret %s requires Redis", name)
+		}
+		value, err := sr.redisClient.HGet(ctx, secretsRedisKey, name).Result()
+		if err == redis.Nil {
+			return "", fmt.Errorf("secret %s not found", name)
+		}
+		if err != nil {
+			return "", err
+		}
+		return value, nil
+	default:
+		return ref, nil
+	}
+}
+
+// 清除缓存，使轮换后的密钥立即生效
+func (sr *SecretResolver) Invalidate(ref string) {
+	sr.mutex.Lock()
+	delete(sr.cache, ref)
+	sr.mutex.Unlock()
+}
+
+// 🔧 新增：列出密钥名称（不返回密钥值）
+func (dr *DistributedRouter) listSecretsHandler(c *gin.Context) {
+	if !dr.routeManager.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	names, err := dr.redisClient.HKeys(c.Request.Context(), secretsRedisKey).Result()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"secrets": names})
+}
+
+// 🔧 新增：写入或轮换密钥
+func (dr *DistributedRouter) putSecretHandler(c *gin.Context) {
+	if !dr.routeManager.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Value == "" {
+		c.JSON(400, gin.H{"error": "value is required"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := dr.redisClient.HSet(c.Request.Context(), secretsRedisKey, name, req.Value).Err(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	dr.secrets.Invalidate("secret:" + name)
+
+	c.JSON(200, gin.H{"message": "secret stored", "name": name})
+}
+
+// 🔧 新增：删除密钥
+func (dr *DistributedRouter) deleteSecretHandler(c *gin.Context) {
+	if !dr.routeManager.redisEnabled {
+		c.JSON(503, gin.H{"error": "Redis not available"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := dr.redisClient.HDel(c.Request.Context(), secretsRedisKey, name).Err(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	dr.secrets.Invalidate("secret:" + name)
+
+	c.JSON(200, gin.H{"message": "secret deleted", "name": name})
+}
@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/middleware"
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/redis/go-redis/v9"
+)
+
+// 自检结果状态
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// 启动前需要的 Redis 键及其类型（不存在的键视为正常）
+var selfCheckRedisKeys = []struct {
+	key      string
+	keyType  string
+	required bool // 缺少时给出警告（新部署或 Redis 数据丢失）
+}{
+	{"gateway:routes", "hash", true},
+	{"gateway:routes:updated", "set", false},
+	{"gateway:config:version", "string", false},
+	{schemaVersionKey, "string", false},
+	{schemaHistoryKey, "list", false},
+	{"gateway:route:events", "stream", false},
+	{routeGroupsRedisKey, "hash", false},
+	{routeTemplatesRedisKey, "hash", false},
+	{scheduledChangesRedisKey, "hash", false},
+	{scheduledHistoryRedisKey, "list", false},
+	{secretsRedisKey, "hash", false},
+	{chaosRedisKey, "hash", false},
+	{basicCredentialsRedisKey, "hash", false},
+	{traceSamplingRedisKey, "hash", false},
+	{gatewayRegistryKey, "hash", false},
+}
+
+// 单项检查结果
+type SelfCheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok、warn、fail、skip
+	Message string `json:"message,omitempty"`
+}
+
+// 🔧 新增：自检报告（router check），任何一项 fail 时进程以非零状态退出
+type SelfCheckReport struct {
+	Checks []SelfCheckResult `json:"checks"`
+	Failed int               `json:"failed"`
+	Warned int               `json:"warned"`
+}
+
+// 自检选项
+type SelfCheckOptions struct {
+	Routes    bool // 试加载 Redis 中的全部路由
+	SkipPorts bool // 不检查监听端口（在运行中的实例旁执行时）
+}
+
+func (r *SelfCheckReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, SelfCheckResult{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	switch status {
+	case checkFail:
+		r.Failed++
+	case checkWarn:
+		r.Warned++
+	}
+}
+
+// 🔧 新增：部署前自检：校验配置、Redis 连接和键布局、监听端口是否空闲，可选试加载路由。
+// 调用前需要已加载配置（static.InitConfig）
+func RunSelfCheck(options SelfCheckOptions) *SelfCheckReport {
+	report := &SelfCheckReport{Checks: make([]SelfCheckResult, 0)}
+	config := static.GetDifySandboxGlobalConfigurations()
+	if config == nil {
+		report.add("config", checkFail, "configuration is not loaded")
+		return report
+	}
+
+	checkSelfConfig(report, config)
+	if options.SkipPorts {
+		report.add("ports", checkSkip, "")
+	} else {
+		checkListenPorts(report, config)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Addr,
+		Password: config.Redis.Password,
+		DB:       0,
+	})
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		// 降级模式下没有 Redis 也能启动，其他策略下启动会失败
+		status := checkFail
+		if config.Redis.Startup == "" || config.Redis.Startup == redisStartupDegraded {
+			status = checkWarn
+		}
+		report.add("redis", status, "redis unavailable at %s: %v", config.Redis.Addr, err)
+		report.add("redis_keys", checkSkip, "")
+		report.add("routes", checkSkip, "")
+		return report
+	}
+	report.add("redis", checkOK, "connected to %s", config.Redis.Addr)
+
+	checkRedisKeys(ctx, report, rdb)
+	if options.Routes {
+		checkRouteLoading(ctx, report, rdb)
+	} else {
+		report.add("routes", checkSkip, "")
+	}
+	return report
+}
+
+// 配置项之间的约束和引用的文件、密钥
+func checkSelfConfig(report *SelfCheckReport, config *static.DifySandboxGlobalConfigurations) {
+	var problems []string
+	for name, port := range map[string]int{"app.port": config.App.Port, "gateway.port": config.Gateway.Port} {
+		if port <= 0 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("%s must be between 1 and 65535", name))
+		}
+	}
+	if config.App.Port == config.Gateway.Port {
+		problems = append(problems, "app.port and gateway.port must differ")
+	}
+	if config.Redis.Addr == "" {
+		problems = append(problems, "redis.addr is required")
+	}
+	switch config.Redis.Startup {
+	case "", redisStartupDegraded, redisStartupWait, redisStartupFail:
+	default:
+		problems = append(problems, fmt.Sprintf("invalid redis.startup: %s", config.Redis.Startup))
+	}
+	warnings, err := middleware.KeyUnsetWarnings(config)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, warning := range warnings {
+		report.add("auth", checkWarn, "%s", warning)
+	}
+	if err := initRouteEncryption(config.Gateway.RouteEncryption); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := newDNSCache(config.Gateway.DNS); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateNetworkConfig(config.Gateway.Network); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateEventPublishConfig(config.Gateway.EventPublish); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if tlsSettings := config.Gateway.TLS; tlsSettings.Enabled {
+		if _, err := tls.LoadX509KeyPair(tlsSettings.CertFile, tlsSettings.KeyFile); err != nil {
+			problems = append(problems, fmt.Sprintf("gateway.tls: %v", err))
+		}
+		if _, err := gatewayTLSConfig(tlsSettings); err != nil {
+			problems = append(problems, fmt.Sprintf("gateway.tls: %v", err))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			report.add("config", checkFail, "%s", problem)
+		}
+		return
+	}
+	report.add("config", checkOK, "")
+}
+
+// 监听端口是否空闲
+func checkListenPorts(report *SelfCheckReport, config *static.DifySandboxGlobalConfigurations) {
+	for _, port := range []int{config.App.Port, config.Gateway.Port} {
+		name := "port " + strconv.Itoa(port)
+		listeners, err := listenAddresses(port, config.Gateway.Network.ListenAddresses)
+		if err != nil {
+			report.add(name, checkFail, "%v", err)
+			continue
+		}
+		closeListeners(listeners)
+		report.add(name, checkOK, "free")
+	}
+}
+
+// 已存在的键类型必须与网关的用法一致，存储版本不能比当前二进制新
+func checkRedisKeys(ctx context.Context, report *SelfCheckReport, rdb *redis.Client) {
+	for _, expected := range selfCheckRedisKeys {
+		keyType, err := rdb.Type(ctx, expected.key).Result()
+		switch {
+		case err != nil:
+			report.add(expected.key, checkFail, "%v", err)
+		case keyType == "none" && expected.required:
+			report.add(expected.key, checkWarn, "missing (expected on an initialized deployment)")
+		case keyType != "none" && keyType != expected.keyType:
+			report.add(expected.key, checkFail, "has type %s, expected %s", keyType, expected.keyType)
+		}
+	}
+
+	value, err := rdb.Get(ctx, schemaVersionKey).Result()
+	if err == redis.Nil {
+		report.add("redis_keys", checkOK, "schema not initialized; migrations will run on startup")
+		return
+	}
+	version, convErr := strconv.Atoi(value)
+	latest, pending := 0, 0
+	for _, migration := range redisMigrations {
+		latest = migration.Version
+		if migration.Version > version {
+			pending++
+		}
+	}
+	switch {
+	case err != nil:
+		report.add("redis_keys", checkFail, "failed to read schema version: %v", err)
+	case convErr != nil:
+		report.add("redis_keys", checkFail, "invalid schema version: %q", value)
+	case version > latest:
+		report.add("redis_keys", checkFail, "storage schema version %d is newer than this binary (%d)", version, latest)
+	case pending > 0:
+		report.add("redis_keys", checkOK, "schema version %d; %d migrations pending", version, pending)
+	default:
+		report.add("redis_keys", checkOK, "schema version %d", version)
+	}
+}
+
+// 按启动时的规则解码、校验并编入路由表，不发布任何变更
+func checkRouteLoading(ctx context.Context, report *SelfCheckReport, rdb *redis.Client) {
+	stored, err := rdb.HGetAll(ctx, "gateway:routes").Result()
+	if err != nil {
+		report.add("routes", checkFail, "failed to load routes: %v", err)
+		return
+	}
+
+	rm := &RouteManager{
+		redisClient:       rdb,
+		redisEnabled:      true,
+		routeGroups:       NewRouteGroupStore(rdb, true),
+		lazyCodeThreshold: gatewaySettings().LazyCodeThreshold,
+	}
+	table := rm.newTable()
+	invalid := 0
+	for routeID, routeJSON := range stored {
+		route, err := decodeStoredRoute([]byte(routeJSON))
+		if err == nil {
+			err = rm.validateRouteConfiguration(route)
+		}
+		if err != nil {
+			report.add("route "+routeID, checkFail, "%v", err)
+			invalid++
+			continue
+		}
+		table.put(routeID, route)
+	}
+
+	for _, route := range table.list() {
+		for _, conflict := range routeConflicts(table, route) {
+			if conflict.Ambiguous && route.ID < conflict.RouteID {
+				report.add("route "+route.ID, checkWarn, "overlaps %s with the same priority", conflict.RouteID)
+			}
+		}
+	}
+	if limit := gatewaySettings().MaxCacheMemory; limit > 0 && table.memoryBytes > limit {
+		report.add("routes", checkFail, "route cache memory %d exceeds max_cache_memory %d", table.memoryBytes, limit)
+		return
+	}
+	if invalid > 0 {
+		report.add("routes", checkFail, "%d of %d routes failed to load", invalid, len(stored))
+		return
+	}
+	report.add("routes", checkOK, "%d routes loaded (%d bytes)", table.size(), table.memoryBytes)
+}
@@ -0,0 +1,11 @@
+package gateway
+
+import "github.com/dify-router/dify-router/internal/static"
+
+// 获取网关配置，配置未初始化时返回零值（各功能按默认行为处理）
+func gatewaySettings() static.GatewayConfig {
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		return config.Gateway
+	}
+	return static.GatewayConfig{}
+}
@@ -0,0 +1,281 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sloMinuteBuckets  = 360 // 6 小时分钟桶，用于燃烧率
+	sloHourBuckets    = 720 // 30 天小时桶，用于错误预算
+	sloDefaultWindow  = 720 // 默认预算窗口（小时）
+	sloEvaluatePeriod = 30 * time.Second
+	sloAlertNone      = "none"
+	sloAlertTicket    = "ticket" // 慢速燃烧：6h 与 30m 燃烧率均 > 6
+	sloAlertPage      = "page"   // 快速燃烧：1h 与 5m 燃烧率均 > 14.4
+	sloFastBurnRate   = 14.4
+	sloSlowBurnRate   = 6.0
+)
+
+// 路由 SLO：请求状态码 < 500 且耗时不超过阈值视为成功
+type RouteSLO struct {
+	LatencyThresholdMs int     `json:"latency_threshold_ms,omitempty"` // 0 表示只考核可用性
+	AvailabilityTarget float64 `json:"availability_target"`            // 如 0.999
+	WindowHours        int     `json:"window_hours,omitempty"`         // 错误预算窗口，默认 720（30 天）
+}
+
+func (s *RouteSLO) validate() error {
+	if s.AvailabilityTarget <= 0 || s.AvailabilityTarget >= 1 {
+		return fmt.Errorf("slo availability_target must be between 0 and 1")
+	}
+	if s.LatencyThresholdMs < 0 {
+		return fmt.Errorf("slo latency_threshold_ms must not be negative")
+	}
+	if s.WindowHours < 0 || s.WindowHours > sloHourBuckets {
+		return fmt.Errorf("slo window_hours must be between 1 and %d", sloHourBuckets)
+	}
+	return nil
+}
+
+func (s *RouteSLO) window() int {
+	if s.WindowHours <= 0 {
+		return sloDefaultWindow
+	}
+	return s.WindowHours
+}
+
+type sloBucket struct {
+	stamp int64 // 桶对应的分钟/小时序号
+	good  int64
+	total int64
+}
+
+type routeSLOState struct {
+	minutes    [sloMinuteBuckets]sloBucket
+	hours      [sloHourBuckets]sloBucket
+	alert      string
+	alertSince time.Time
+}
+
+func addToBucket(bucket *sloBucket, stamp int64, good bool) {
+	if bucket.stamp != stamp {
+		*bucket = sloBucket{stamp: stamp}
+	}
+	bucket.total++
+	if good {
+		bucket.good++
+	}
+}
+
+// 汇总最近 n 个桶（当前桶序号为 now）
+func sumBuckets(buckets []sloBucket, now int64, n int) (good, total int64) {
+	for i := 0; i < n && i < len(buckets); i++ {
+		stamp := now - int64(i)
+		bucket := buckets[int(stamp%int64(len(buckets)))]
+		if bucket.stamp == stamp {
+			good += bucket.good
+			total += bucket.total
+		}
+	}
+	return good, total
+}
+
+// SLO 统计（当前实例）
+type SLOTracker struct {
+	routes map[string]*routeSLOState
+	mutex  sync.Mutex
+}
+
+func NewSLOTracker() *SLOTracker {
+	return &SLOTracker{routes: make(map[string]*routeSLOState)}
+}
+
+// 记录一次请求结果
+func (t *SLOTracker) Record(route *RouteConfig, status int, duration time.Duration, now time.Time) {
+	if t == nil || route == nil || route.SLO == nil {
+		return
+	}
+	good := status > 0 && status < 500
+	if threshold := route.SLO.LatencyThresholdMs; threshold > 0 && duration > time.Duration(threshold)*time.Millisecond {
+		good = false
+	}
+	minute := now.Unix() / 60
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state := t.routes[route.ID]
+	if state == nil {
+		state = &routeSLOState{alert: sloAlertNone}
+		t.routes[route.ID] = state
+	}
+	addToBucket(&state.minutes[int(minute%sloMinuteBuckets)], minute, good)
+	hour := minute / 60
+	addToBucket(&state.hours[int(hour%sloHourBuckets)], hour, good)
+}
+
+// 时间窗口统计
+type SLOWindow struct {
+	Total        int64   `json:"total"`
+	Good         int64   `json:"good"`
+	Availability float64 `json:"availability"`
+	BurnRate     float64 `json:"burn_rate"`
+}
+
+// 路由 SLO 报告
+type SLOReport struct {
+	RouteID     string               `json:"route_id"`
+	SLO         RouteSLO             `json:"slo"`
+	Windows     map[string]SLOWindow `json:"windows"`
+	ErrorBudget gin.H                `json:"error_budget"`
+	Alert       string               `json:"alert"`
+	AlertSince  int64                `json:"alert_since,omitempty"`
+}
+
+func newSLOWindow(good, total int64, target float64) SLOWindow {
+	window := SLOWindow{Total: total, Good: good, Availability: 1}
+	if total > 0 {
+		window.Availability = float64(good) / float64(total)
+		window.BurnRate = (1 - window.Availability) / (1 - target)
+	}
+	return window
+}
+
+// 生成报告（调用方持有锁）
+func (t *SLOTracker) report(route *RouteConfig, state *routeSLOState, now time.Time) SLOReport {
+	slo := *route.SLO
+	minute := now.Unix() / 60
+	windows := make(map[string]SLOWindow, 4)
+	for name, minutes := range map[string]int{"5m": 5, "30m": 30, "1h": 60, "6h": 360} {
+		good, total := sumBuckets(state.minutes[:], minute, minutes)
+		windows[name] = newSLOWindow(good, total, slo.AvailabilityTarget)
+	}
+
+	good, total := sumBuckets(state.hours[:], minute/60, slo.window())
+	allowedBad := float64(total) * (1 - slo.AvailabilityTarget)
+	remaining := 1.0
+	if allowedBad > 0 {
+		remaining = 1 - float64(total-good)/allowedBad
+	}
+
+	report := SLOReport{
+		RouteID: route.ID,
+		SLO:     slo,
+		Windows: windows,
+		ErrorBudget: gin.H{
+			"window_hours": slo.window(),
+			"total":        total,
+			"bad":          total - good,
+			"allowed_bad":  allowedBad,
+			"remaining":    remaining,
+		},
+		Alert: state.alert,
+	}
+	if state.alert != sloAlertNone {
+		report.AlertSince = state.alertSince.Unix()
+	}
+	return report
+}
+
+// 所有配置了 SLO 的路由报告
+func (t *SLOTracker) Reports(routes []RouteConfig, now time.Time) []SLOReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	reports := make([]SLOReport, 0)
+	for i := range routes {
+		route := &routes[i]
+		if route.SLO == nil {
+			continue
+		}
+		state := t.routes[route.ID]
+		if state == nil {
+			state = &routeSLOState{alert: sloAlertNone}
+		}
+		reports = append(reports, t.report(route, state, now))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].RouteID < reports[j].RouteID })
+	return reports
+}
+
+// 定时评估燃烧率并在告警状态变化时发出告警
+func (dr *DistributedRouter) runSLOEvaluator() {
+	ticker := time.NewTicker(sloEvaluatePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		dr.evaluateSLOs(time.Now())
+	}
+}
+
+func (dr *DistributedRouter) evaluateSLOs(now time.Time) {
+	t := dr.slo
+	routes := dr.routeManager.snapshot().list()
+	active := make(map[string]bool, len(routes))
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := range routes {
+		route := &routes[i]
+		if route.SLO == nil {
+			continue
+		}
+		active[route.ID] = true
+		state := t.routes[route.ID]
+		if state == nil {
+			continue
+		}
+
+		report := t.report(route, state, now)
+		alert := sloAlertNone
+		switch {
+		case report.Windows["1h"].BurnRate > sloFastBurnRate && report.Windows["5m"].BurnRate > sloFastBurnRate:
+			alert = sloAlertPage
+		case report.Windows["6h"].BurnRate > sloSlowBurnRate && report.Windows["30m"].BurnRate > sloSlowBurnRate:
+			alert = sloAlertTicket
+		}
+
+		if dr.statsd != nil {
+			for name, window := range report.Windows {
+				dr.statsd.Gauge("slo.burn_rate", window.BurnRate, "route:"+route.ID, "window:"+name)
+			}
+			dr.statsd.Gauge("slo.error_budget_remaining", report.ErrorBudget["remaining"].(float64), "route:"+route.ID)
+		}
+
+		if alert != state.alert {
+			message := fmt.Sprintf("SLO alert for route %s changed %s -> %s (burn rate 5m=%.2f 1h=%.2f 30m=%.2f 6h=%.2f)",
+				route.ID, state.alert, alert,
+				report.Windows["5m"].BurnRate, report.Windows["1h"].BurnRate,
+				report.Windows["30m"].BurnRate, report.Windows["6h"].BurnRate)
+			log.Printf("🚨 %s", message)
+			dr.logForwarder.Emit(LogEvent{
+				Type:      logEventSLOAlert,
+				Timestamp: now,
+				RouteID:   route.ID,
+				Message:   message,
+			})
+			state.alert = alert
+			state.alertSince = now
+		}
+	}
+
+	// 清理已删除或取消 SLO 的路由
+	for routeID := range t.routes {
+		if !active[routeID] {
+			delete(t.routes, routeID)
+		}
+	}
+}
+
+// 🔧 新增：查看路由 SLO 与错误预算
+func (dr *DistributedRouter) sloHandler(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"slos":        dr.slo.Reports(dr.routeManager.snapshot().list(), time.Now()),
+	})
+}
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis 启动策略
+const (
+	redisStartupDegraded = "degraded" // 不可用时以内存模式继续运行（默认）
+	redisStartupWait     = "wait"     // 按间隔重试直到连接成功或超时
+	redisStartupFail     = "fail"     // 不可用时直接启动失败
+)
+
+// 🔧 新增：启动时检查 Redis 连接，按 redis.startup 决定失败、等待重试还是降级运行
+func checkRedisAtStartup(rdb *redis.Client, redisAddr string) error {
+	settings := static.RedisConfig{}
+	if config := static.GetDifySandboxGlobalConfigurations(); config != nil {
+		settings = config.Redis
+	}
+
+	err := pingRedis(rdb)
+	if err == nil {
+		log.Printf("✅ Successfully connected to Redis at %s", redisAddr)
+		return nil
+	}
+	logRedisStartupError(redisAddr, err)
+
+	switch settings.Startup {
+	case redisStartupFail:
+		return fmt.Errorf("redis unavailable at %s: %w", redisAddr, err)
+	case redisStartupWait:
+		interval := time.Duration(settings.StartupRetryInterval) * time.Second
+		if interval <= 0 {
+			interval = 2 * time.Second
+		}
+		var deadline time.Time
+		if settings.StartupTimeout > 0 {
+			deadline = time.Now().Add(time.Duration(settings.StartupTimeout) * time.Second)
+		}
+		for attempt := 2; ; attempt++ {
+			if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+				return fmt.Errorf("redis unavailable at %s after %ds: %w", redisAddr, settings.StartupTimeout, err)
+			}
+			log.Printf("⏳ Waiting for Redis at %s (retry in %v)", redisAddr, interval)
+			time.Sleep(interval)
+			if err = pingRedis(rdb); err == nil {
+				log.Printf("✅ Successfully connected to Redis at %s after %d attempts", redisAddr, attempt)
+				return nil
+			}
+		}
+	default:
+		// 继续运行，但使用内存存储
+		log.Printf("⚠️  Running with in-memory storage only. Routes will not be persisted.")
+		return nil
+	}
+}
+
+func pingRedis(rdb *redis.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return rdb.Ping(ctx).Err()
+}
+
+func logRedisStartupError(redisAddr string, err error) {
+	if err.Error() == "NOAUTH Authentication required." {
+		log.Printf("❌ Redis authentication failed. Please check your Redis password in config.yaml")
+		log.Printf("💡 You can:")
+		log.Printf("   1. Set the correct password in conf/config.yaml")
+		log.Printf("   2. Disable Redis authentication: redis-cli -> CONFIG SET requirepass \"\"")
+		log.Printf("   3. Or run without Redis (routes will be stored in memory only)")
+		return
+	}
+	log.Printf("❌ Failed to connect to Redis at %s: %v", redisAddr, err)
+}
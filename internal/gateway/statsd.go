@@ -0,0 +1,230 @@
+package gateway
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+// StatsD/DogStatsD 客户端：指标行进入有界队列，后台合并成 UDP 数据包发送
+type statsdClient struct {
+	config   static.StatsDConfig
+	conn     net.Conn
+	tags     string // 预先格式化的全局标签
+	lines    chan string
+	dropped  atomic.Int64
+	stopChan chan struct{}
+}
+
+func newStatsDClient(config static.StatsDConfig, instanceID string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = 1432
+	}
+
+	client := &statsdClient{
+		config:   config,
+		conn:     conn,
+		lines:    make(chan string, 10000),
+		stopChan: make(chan struct{}),
+	}
+	// 🔧 新增：所有指标带 instance、version 和 git_commit 标签
+	build := static.GetBuildInfo()
+	client.tags = client.formatTags(append(append([]string(nil), config.Tags...),
+		"instance:"+instanceID, "version:"+build.Version, "git_commit:"+build.GitCommit))
+	return client, nil
+}
+
+func (c *statsdClient) formatTags(tags []string) string {
+	if c.config.TagFormat == "none" || len(tags) == 0 {
+		return ""
+	}
+	return strings.Join(tags, ",")
+}
+
+// 生成一行指标：<prefix><name>:<value>|<type>|#<tags>
+func (c *statsdClient) line(name, value, metricType string, tags ...string) string {
+	var b strings.Builder
+	b.WriteString(c.config.Prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(metricType)
+
+	if c.config.TagFormat != "none" && (c.tags != "" || len(tags) > 0) {
+		b.WriteString("|#")
+		b.WriteString(c.tags)
+		for i, tag := range tags {
+			if i > 0 || c.tags != "" {
+				b.WriteByte(',')
+			}
+			b.WriteString(tag)
+		}
+	}
+	return b.String()
+}
+
+func (c *statsdClient) send(line string) {
+	select {
+	case c.lines <- line:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *statsdClient) Count(name string, value int64, tags ...string) {
+	c.send(c.line(name, strconv.FormatInt(value, 10), "c", tags...))
+}
+
+func (c *statsdClient) Gauge(name string, value float64, tags ...string) {
+	c.send(c.line(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags...))
+}
+
+func (c *statsdClient) Timing(name string, duration time.Duration, tags ...string) {
+	c.send(c.line(name, strconv.FormatFloat(float64(duration.Microseconds())/1000, 'f', 3, 64), "ms", tags...))
+}
+
+// 记录一次网关请求
+func (c *statsdClient) RecordRequest(routeID, method string, status int, duration time.Duration, requestBytes, bytes int64) {
+	if c == nil {
+		return
+	}
+	if routeID == "" {
+		routeID = "unmatched"
+	}
+	tags := []string{
+		"route:" + routeID,
+		"method:" + method,
+		"status:" + strconv.Itoa(status),
+		"status_class:" + statusClass(status),
+	}
+	c.Count("requests", 1, tags...)
+	c.Timing("request.duration", duration, tags...)
+	c.Count("request.bytes", requestBytes, tags...)
+	c.Count("response.bytes", bytes, tags...)
+}
+
+// 记录一次响应超过路由大小限制
+func (c *statsdClient) RecordResponseLimit(routeID string) {
+	if c == nil {
+		return
+	}
+	c.Count("response.limit_exceeded", 1, "route:"+routeID)
+}
+
+// 记录一次网关处理器 panic
+func (c *statsdClient) RecordPanic(routeID string) {
+	if c == nil {
+		return
+	}
+	if routeID == "" {
+		routeID = "unmatched"
+	}
+	c.Count("panics", 1, "route:"+routeID)
+}
+
+// 记录一次上游响应违反路由契约
+func (c *statsdClient) RecordContractViolation(routeID string) {
+	if c == nil {
+		return
+	}
+	c.Count("response.contract_violations", 1, "route:"+routeID)
+}
+
+// 记录一次消费者 Key 因并发上限被拒绝
+func (c *statsdClient) RecordKeyConcurrencyRejected(keyName string) {
+	if c == nil {
+		return
+	}
+	c.Count("api_key.concurrency_rejected", 1, "key:"+keyName)
+}
+
+// 记录一次沙箱池变化（registered、removed、evicted）
+func (c *statsdClient) RecordSandboxChange(action, sandboxType string) {
+	if c == nil {
+		return
+	}
+	c.Count("sandbox.change", 1, "action:"+action, "type:"+sandboxType)
+}
+
+// 启动发送循环与状态指标上报
+func (c *statsdClient) Start(dr *DistributedRouter) {
+	flushInterval := time.Duration(c.config.FlushInterval) * time.Millisecond
+	if flushInterval <= 0 {
+		flushInterval = 100 * time.Millisecond
+	}
+	gaugeInterval := time.Duration(c.config.GaugeInterval) * time.Second
+	if gaugeInterval <= 0 {
+		gaugeInterval = 10 * time.Second
+	}
+
+	go func() {
+		flushTicker := time.NewTicker(flushInterval)
+		gaugeTicker := time.NewTicker(gaugeInterval)
+		defer flushTicker.Stop()
+		defer gaugeTicker.Stop()
+
+		packet := make([]byte, 0, c.config.MaxPacketSize)
+		flush := func() {
+			if len(packet) > 0 {
+				c.conn.Write(packet)
+				packet = packet[:0]
+			}
+		}
+
+		for {
+			select {
+			case line := <-c.lines:
+				// 单个数据包内以换行分隔多条指标
+				if len(packet) > 0 && len(packet)+1+len(line) > c.config.MaxPacketSize {
+					flush()
+				}
+				if len(packet) > 0 {
+					packet = append(packet, '\n')
+				}
+				packet = append(packet, line...)
+			case <-flushTicker.C:
+				flush()
+			case <-gaugeTicker.C:
+				c.reportGauges(dr)
+			case <-c.stopChan:
+				flush()
+				c.conn.Close()
+				return
+			}
+		}
+	}()
+	log.Printf("📊 StatsD export started (address: %s, prefix: %s)", c.config.Address, c.config.Prefix)
+}
+
+func (c *statsdClient) reportGauges(dr *DistributedRouter) {
+	healthy := 0
+	for _, instance := range dr.sandboxPool.GetAllInstances() {
+		if instance.Status == "healthy" {
+			healthy++
+		}
+	}
+	c.Gauge("routes", float64(dr.routeManager.snapshot().size()))
+	c.Gauge("events.outbox_depth", float64(dr.routeManager.outbox.depth()))
+	c.Gauge("match_cache.hit_ratio", dr.routeManager.matchCache.hitRatio())
+	fallbacks, lastDuration := dr.routeManager.syncStats.fallbacksAndLastDuration()
+	c.Gauge("sync.full_fallbacks", float64(fallbacks))
+	c.Gauge("sync.last_duration_ms", float64(lastDuration.Microseconds())/1000)
+	c.Gauge("sandboxes.healthy", float64(healthy))
+	if dropped := c.dropped.Swap(0); dropped > 0 {
+		c.Count("statsd.dropped", dropped)
+	}
+}
+
+func (c *statsdClient) Stop() {
+	close(c.stopChan)
+}
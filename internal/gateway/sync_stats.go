@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 配置同步方式
+const (
+	syncKindIncremental  = "incremental"   // 按 gateway:routes:updated 增量加载
+	syncKindFullFallback = "full_fallback" // 版本变化但没有增量标记，回退到全量加载
+	syncKindFullResync   = "full_resync"   // /admin/sync/full 或 RESYNC 事件触发的全量加载
+)
+
+// 🔧 新增：增量同步效果统计（本实例自启动以来）
+type syncStats struct {
+	checks        int64 // 同步检查次数（含版本未变化的检查）
+	unchanged     int64 // 版本未变化而跳过的次数
+	skipped       int64 // 事件读取正常而跳过的轮询次数（sync.poll 为 fallback）
+	counts        map[string]int64
+	routesApplied int64 // 累计应用的路由变更数（更新 + 删除，全量加载计路由总数）
+	lastKind      string
+	lastApplied   int
+	lastDuration  time.Duration
+	lastSyncAt    time.Time
+	lastCheckAt   time.Time // 最近一次成功检查配置版本（含版本未变化和事件正常而跳过轮询）
+	totalDuration time.Duration
+	maxDuration   time.Duration
+	mutex         sync.Mutex
+}
+
+func newSyncStats() *syncStats {
+	return &syncStats{counts: make(map[string]int64)}
+}
+
+func (s *syncStats) recordUnchanged() {
+	s.mutex.Lock()
+	s.checks++
+	s.unchanged++
+	s.lastCheckAt = time.Now()
+	s.mutex.Unlock()
+}
+
+func (s *syncStats) recordSkipped() {
+	s.mutex.Lock()
+	s.skipped++
+	s.lastCheckAt = time.Now()
+	s.mutex.Unlock()
+}
+
+func (s *syncStats) record(kind string, applied int, duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if kind != syncKindFullResync {
+		s.checks++
+	}
+	s.counts[kind]++
+	s.routesApplied += int64(applied)
+	s.lastKind = kind
+	s.lastApplied = applied
+	s.lastDuration = duration
+	s.lastSyncAt = time.Now()
+	s.lastCheckAt = s.lastSyncAt
+	s.totalDuration += duration
+	if duration > s.maxDuration {
+		s.maxDuration = duration
+	}
+}
+
+// 最近一次成功检查配置的时间，尚未成功检查时为零值
+func (s *syncStats) lastCheck() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastCheckAt
+}
+
+// 全量回退次数与最近一次同步耗时（用于指标上报）
+func (s *syncStats) fallbacksAndLastDuration() (int64, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.counts[syncKindFullFallback], s.lastDuration
+}
+
+func (s *syncStats) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	syncs := s.counts[syncKindIncremental] + s.counts[syncKindFullFallback] + s.counts[syncKindFullResync]
+	stats := map[string]interface{}{
+		"checks":          s.checks,
+		"unchanged":       s.unchanged,
+		"skipped_polls":   s.skipped,
+		"incremental":     s.counts[syncKindIncremental],
+		"full_fallbacks":  s.counts[syncKindFullFallback],
+		"full_resyncs":    s.counts[syncKindFullResync],
+		"routes_applied":  s.routesApplied,
+		"max_duration_ms": float64(s.maxDuration.Microseconds()) / 1000,
+	}
+	if loads := s.counts[syncKindIncremental] + s.counts[syncKindFullFallback]; loads > 0 {
+		stats["fallback_ratio"] = float64(s.counts[syncKindFullFallback]) / float64(loads)
+	}
+	if syncs > 0 {
+		stats["avg_duration_ms"] = float64(s.totalDuration.Microseconds()) / 1000 / float64(syncs)
+		stats["avg_routes_applied"] = float64(s.routesApplied) / float64(syncs)
+		stats["last"] = map[string]interface{}{
+			"kind":           s.lastKind,
+			"routes_applied": s.lastApplied,
+			"duration_ms":    float64(s.lastDuration.Microseconds()) / 1000,
+			"time":           s.lastSyncAt.Unix(),
+		}
+	}
+	return stats
+}
+
+// GET /admin/sync/stats：增量同步效果
+func (dr *DistributedRouter) syncStatsHandler(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"instance_id":    dr.routeManager.instanceID,
+		"redis_enabled":  dr.routeManager.redisEnabled,
+		"config_version": dr.routeManager.lastConfigUpdate,
+		"sync_interval":  int(dr.routeManager.SyncInterval() / time.Second),
+		"stats":          dr.routeManager.syncStats.Stats(),
+	})
+}
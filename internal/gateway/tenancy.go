@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errTenantRequired = fmt.Errorf("tenant is required")
+	errTenantMismatch = fmt.Errorf("tenant header does not match access token")
+)
+
+// 解析请求的租户并记录到调用方：访问令牌中的租户声明优先，请求头必须与之一致
+func resolveTenant(r *http.Request, principal *gatewayPrincipal) error {
+	tenancy := gatewaySettings().Tenancy
+	if !tenancy.Enabled {
+		return nil
+	}
+
+	var header string
+	if tenancy.Header != "" {
+		header = r.Header.Get(tenancy.Header)
+	}
+	tenant := principal.tenantClaim
+	switch {
+	case tenant == "":
+		tenant = header
+	case header != "" && header != tenant:
+		return errTenantMismatch
+	}
+	if tenant == "" && tenancy.Required {
+		return errTenantRequired
+	}
+
+	principal.Tenant = tenant
+	if info := logInfoFromRequest(r); info != nil {
+		info.Tenant = tenant
+	}
+	return nil
+}
+
+// 解析租户失败时的响应：缺少租户返回 400，与令牌不一致返回 403
+func writeTenantError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if err == errTenantMismatch {
+		status = http.StatusForbidden
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(gin.H{"error": err.Error()})
+}
+
+// 请求的租户
+func tenantFromRequest(r *http.Request) string {
+	if principal := principalFromRequest(r); principal != nil {
+		return principal.Tenant
+	}
+	return ""
+}
+
+// 路由是否对该租户可见：未设置租户的路由所有租户共享
+func routeVisibleToTenant(route *RouteConfig, tenant string) bool {
+	return route.Tenant == "" || route.Tenant == tenant
+}
@@ -0,0 +1,376 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	traceSamplingRedisKey      = "gateway:tracing:sampling"
+	traceSamplingRefreshPeriod = 5 * time.Second
+	traceSamplingDefaultKey    = "*" // 覆盖配置文件中默认采样率的条目
+	traceExportInterval        = 5 * time.Second
+	traceExportBatchSize       = 512
+	traceQueueSize             = 4096
+)
+
+// 🔧 新增：请求追踪 span（网关作为 SERVER span，上游收到的 traceparent 以该 span 为父节点）
+type traceSpan struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+}
+
+// W3C traceparent 头的值（采样标志始终为 01，只有采样的请求才会创建 span）
+func (s *traceSpan) traceparent() string {
+	return "00-" + s.TraceID + "-" + s.SpanID + "-01"
+}
+
+// 解析 traceparent，返回 trace ID、父 span ID 和父节点是否采样
+func parseTraceparent(value string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false, false
+	}
+	if !isLowerHex(parts[1]) || !isLowerHex(parts[2]) || strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return "", "", false, false
+	}
+	return parts[1], parts[2], flags&1 == 1, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 🔧 新增：追踪采样与导出：默认采样率来自 telemetry.otlp.trace_sample_rate，按路由的采样率存储在 Redis 中，
+// 各实例定时刷新本地副本（未启用 Redis 时只保存在本地内存）；采样的请求在结束时导出 span
+type Tracer struct {
+	redisClient  *redis.Client
+	redisEnabled bool
+	client       *otlpClient
+	defaultRate  float64
+	rates        map[string]float64 // 路由ID -> 采样率，"*" 覆盖默认采样率
+	mutex        sync.RWMutex
+	queue        chan map[string]interface{}
+	dropped      uint64
+}
+
+func NewTracer(client *otlpClient, defaultRate float64, redisClient *redis.Client, redisEnabled bool) *Tracer {
+	t := &Tracer{
+		redisClient:  redisClient,
+		redisEnabled: redisEnabled,
+		client:       client,
+		defaultRate:  defaultRate,
+		rates:        make(map[string]float64),
+		queue:        make(chan map[string]interface{}, traceQueueSize),
+	}
+	if redisEnabled {
+		t.refresh()
+		go t.refreshLoop()
+	}
+	go t.exportLoop()
+	return t
+}
+
+func (t *Tracer) refreshLoop() {
+	ticker := time.NewTicker(traceSamplingRefreshPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.refresh()
+	}
+}
+
+// 从 Redis 重新加载按路由的采样率
+func (t *Tracer) refresh() {
+	stored, err := t.redisClient.HGetAll(context.Background(), traceSamplingRedisKey).Result()
+	if err != nil {
+		log.Printf("Failed to load trace sampling rates: %v", err)
+		return
+	}
+
+	rates := make(map[string]float64, len(stored))
+	for routeID, value := range stored {
+		if rate, err := strconv.ParseFloat(value, 64); err == nil {
+			rates[routeID] = rate
+		}
+	}
+
+	t.mutex.Lock()
+	t.rates = rates
+	t.mutex.Unlock()
+}
+
+// 路由的采样率：路由设置 > 运行时默认值 > 配置文件默认值
+func (t *Tracer) rate(routeID string) float64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if rate, ok := t.rates[routeID]; ok {
+		return rate
+	}
+	if rate, ok := t.rates[traceSamplingDefaultKey]; ok {
+		return rate
+	}
+	return t.defaultRate
+}
+
+// 设置采样率（routeID 为 "*" 时设置默认值）
+func (t *Tracer) SetRate(routeID string, rate float64) error {
+	if t.redisEnabled {
+		if err := t.redisClient.HSet(context.Background(), traceSamplingRedisKey, routeID, strconv.FormatFloat(rate, 'f', -1, 64)).Err(); err != nil {
+			return fmt.Errorf("failed to save trace sampling rate: %v", err)
+		}
+	}
+
+	t.mutex.Lock()
+	t.rates[routeID] = rate
+	t.mutex.Unlock()
+
+	log.Printf("🔭 Trace sampling rate for %s set to %g", routeID, rate)
+	return nil
+}
+
+// 删除采样率设置，恢复默认值，返回是否存在
+func (t *Tracer) DeleteRate(routeID string) (bool, error) {
+	t.mutex.RLock()
+	_, exists := t.rates[routeID]
+	t.mutex.RUnlock()
+
+	if t.redisEnabled {
+		removed, err := t.redisClient.HDel(context.Background(), traceSamplingRedisKey, routeID).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to delete trace sampling rate: %v", err)
+		}
+		exists = exists || removed > 0
+	}
+
+	t.mutex.Lock()
+	delete(t.rates, routeID)
+	t.mutex.Unlock()
+	return exists, nil
+}
+
+// 当前的采样设置
+func (t *Tracer) Sampling() gin.H {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	routes := make([]gin.H, 0, len(t.rates))
+	defaultRate, source := t.defaultRate, "config"
+	for routeID, rate := range t.rates {
+		if routeID == traceSamplingDefaultKey {
+			defaultRate, source = rate, "runtime"
+			continue
+		}
+		routes = append(routes, gin.H{"route_id": routeID, "rate": rate})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i]["route_id"].(string) < routes[j]["route_id"].(string) })
+	return gin.H{"default_rate": defaultRate, "default_source": source, "routes": routes}
+}
+
+// 路由匹配后决定是否采样：上游已采样的请求始终采样，否则按路由的采样率；
+// 采样的请求把新的 traceparent 传给上游，未采样的请求保持原样
+func (t *Tracer) start(route *RouteConfig, r *http.Request) {
+	if t == nil {
+		return
+	}
+	info := logInfoFromRequest(r)
+	if info == nil {
+		return
+	}
+
+	traceID, parentID, parentSampled, ok := parseTraceparent(r.Header.Get("traceparent"))
+	if !parentSampled && mathrand.Float64() >= t.rate(route.ID) {
+		return
+	}
+	if !ok {
+		traceID, parentID = randomHex(16), ""
+	}
+
+	span := &traceSpan{TraceID: traceID, SpanID: randomHex(8), ParentSpanID: parentID}
+	r.Header.Set("traceparent", span.traceparent())
+	info.Trace = span
+}
+
+// 请求结束时把采样的 span 放入导出队列，队列满时丢弃
+func (t *Tracer) finish(info *requestLogInfo, r *http.Request, status int, start time.Time, duration time.Duration) {
+	if t == nil || info.Trace == nil {
+		return
+	}
+
+	name := r.Method
+	if info.Route != nil {
+		name = r.Method + " " + info.Route.fullPath()
+	}
+	attributes := []map[string]interface{}{
+		otlpAttribute("http.request.method", r.Method),
+		otlpAttribute("url.path", currentRedactor().text(r.URL.Path)),
+		otlpAttribute("http.response.status_code", status),
+		otlpAttribute("client.address", clientIP(r)),
+	}
+	if info.RouteID != "" {
+		attributes = append(attributes, otlpAttribute("gateway.route_id", info.RouteID))
+	}
+	if info.Variant != "" {
+		attributes = append(attributes, otlpAttribute("gateway.route.variant", info.Variant))
+	}
+	if info.Tenant != "" {
+		attributes = append(attributes, otlpAttribute("gateway.tenant", info.Tenant))
+	}
+
+	// 状态码：1 OK，2 ERROR（服务端 span 只把 5xx 视为错误）
+	statusCode := 1
+	if status >= 500 {
+		statusCode = 2
+	}
+	span := map[string]interface{}{
+		"traceId":           info.Trace.TraceID,
+		"spanId":            info.Trace.SpanID,
+		"name":              name,
+		"kind":              2, // SPAN_KIND_SERVER
+		"startTimeUnixNano": unixNano(start),
+		"endTimeUnixNano":   unixNano(start.Add(duration)),
+		"attributes":        attributes,
+		"status":            map[string]interface{}{"code": statusCode},
+	}
+	if info.Trace.ParentSpanID != "" {
+		span["parentSpanId"] = info.Trace.ParentSpanID
+	}
+
+	select {
+	case t.queue <- span:
+	default:
+		t.mutex.Lock()
+		t.dropped++
+		t.mutex.Unlock()
+	}
+}
+
+// 定时批量导出 span
+func (t *Tracer) exportLoop() {
+	ticker := time.NewTicker(traceExportInterval)
+	defer ticker.Stop()
+
+	batch := make([]map[string]interface{}, 0, traceExportBatchSize)
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) < traceExportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		payload := map[string]interface{}{
+			"resourceSpans": []map[string]interface{}{{
+				"resource": t.client.resource(),
+				"scopeSpans": []map[string]interface{}{{
+					"scope": map[string]interface{}{"name": otlpScopeName},
+					"spans": batch,
+				}},
+			}},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.client.client.Timeout)
+		if err := t.client.post(ctx, "/v1/traces", payload); err != nil {
+			log.Printf("⚠️  OTLP trace export failed (%d spans): %v", len(batch), err)
+		}
+		cancel()
+		batch = make([]map[string]interface{}, 0, traceExportBatchSize)
+	}
+}
+
+// 🔧 新增：查看追踪采样设置
+func (dr *DistributedRouter) getTraceSamplingHandler(c *gin.Context) {
+	if dr.tracer == nil {
+		c.JSON(404, gin.H{"error": "tracing is not enabled (telemetry.otlp.traces)"})
+		return
+	}
+	sampling := dr.tracer.Sampling()
+	dr.tracer.mutex.RLock()
+	sampling["dropped_spans"] = dr.tracer.dropped
+	dr.tracer.mutex.RUnlock()
+	c.JSON(200, sampling)
+}
+
+// 🔧 新增：设置路由（或默认 "*"）的追踪采样率，所有实例在刷新周期内生效
+func (dr *DistributedRouter) putTraceSamplingHandler(c *gin.Context) {
+	if dr.tracer == nil {
+		c.JSON(404, gin.H{"error": "tracing is not enabled (telemetry.otlp.traces)"})
+		return
+	}
+	var req struct {
+		Rate *float64 `json:"rate"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Rate == nil || *req.Rate < 0 || *req.Rate > 1 {
+		c.JSON(400, gin.H{"error": "rate must be between 0 and 1"})
+		return
+	}
+
+	routeID := c.Param("routeId")
+	if err := dr.tracer.SetRate(routeID, *req.Rate); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "trace sampling rate updated", "route_id": routeID, "rate": *req.Rate})
+}
+
+// 🔧 新增：删除路由的采样率设置，恢复默认采样率
+func (dr *DistributedRouter) deleteTraceSamplingHandler(c *gin.Context) {
+	if dr.tracer == nil {
+		c.JSON(404, gin.H{"error": "tracing is not enabled (telemetry.otlp.traces)"})
+		return
+	}
+	exists, err := dr.tracer.DeleteRate(c.Param("routeId"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(404, gin.H{"error": "trace sampling rate not found"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "trace sampling rate deleted"})
+}
+
+// 采样请求的追踪ID
+func (info *requestLogInfo) traceID() string {
+	if info.Trace == nil {
+		return ""
+	}
+	return info.Trace.TraceID
+}
@@ -0,0 +1,446 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 🔧 新增：沙箱反向隧道
+// NAT 或防火墙后的沙箱主机运行 agent（router agent），主动连接网关端口的隧道路径并以 HTTP Upgrade 切换协议，
+// 之后由网关在这条连接上发送 HTTP 请求、agent 转发给本机沙箱。agent 为每个沙箱保持多条连接，
+// 每条连接同一时间承载一个请求；网关把这些连接作为该沙箱专用 http.Transport 的连接来源，
+// 执行请求与直连沙箱走相同的选择、重试和并发控制。
+// 隧道实例只注册在 agent 连接的网关实例上（不写入 Redis），多实例部署时 agent 需要连接每个网关实例。
+const (
+	tunnelProtocol = "dify-tunnel"
+
+	tunnelHeaderSandboxID   = "X-Tunnel-Sandbox-Id"
+	tunnelHeaderSandboxType = "X-Tunnel-Sandbox-Type"
+	tunnelHeaderRegion      = "X-Tunnel-Region"
+	tunnelHeaderZone        = "X-Tunnel-Zone"
+	tunnelHeaderCancelPath  = "X-Tunnel-Cancel-Path"
+
+	tunnelProbeTimeout = 5 * time.Second
+)
+
+var (
+	errTunnelClosed = errors.New("sandbox tunnel closed")
+	tunnelIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+)
+
+// 隧道连接注册表，每个沙箱一条隧道
+type TunnelRegistry struct {
+	pool     *SandboxPool
+	tunnels  map[string]*sandboxTunnel
+	mutex    sync.Mutex
+	onChange func(change *SandboxChange) // 🔧 新增：隧道接入注册和超时移除实例时通知
+}
+
+// 一个沙箱的全部隧道连接
+type sandboxTunnel struct {
+	id          string
+	remoteAddr  string
+	connectedAt int64
+	idle        chan net.Conn // 尚未交给 Transport 的连接
+	transport   *http.Transport
+	open        atomic.Int64 // 打开的连接数（空闲 + Transport 持有）
+	closed      chan struct{}
+	expiry      *time.Timer // 所有连接断开后的移除计时
+}
+
+func NewTunnelRegistry(pool *SandboxPool) *TunnelRegistry {
+	return &TunnelRegistry{pool: pool, tunnels: make(map[string]*sandboxTunnel)}
+}
+
+// 沙箱的隧道 Transport，隧道不存在时返回错误
+func (tr *TunnelRegistry) transport(sandboxID string) (*http.Transport, error) {
+	if tr == nil {
+		return nil, errTunnelClosed
+	}
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tunnel, exists := tr.tunnels[sandboxID]
+	if !exists {
+		return nil, errTunnelClosed
+	}
+	return tunnel.transport, nil
+}
+
+// 是否可以为沙箱接入新连接（升级前检查，接入时再确认一次）
+func (tr *TunnelRegistry) check(sandboxID string) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.checkLocked(sandboxID)
+}
+
+func (tr *TunnelRegistry) checkLocked(sandboxID string) error {
+	if tunnel, exists := tr.tunnels[sandboxID]; exists {
+		if limit := gatewaySettings().Tunnel.MaxConnections; tunnel.open.Load() >= int64(limit) {
+			return fmt.Errorf("sandbox %s already has %d tunnel connections", sandboxID, limit)
+		}
+		return nil
+	}
+	if existing, registered := tr.pool.GetAllInstances()[sandboxID]; registered && !existing.Tunnel {
+		return fmt.Errorf("sandbox %s is registered without a tunnel", sandboxID)
+	}
+	return nil
+}
+
+// 接入一条连接：首条连接时注册沙箱实例，不能接入时返回错误（调用方关闭连接）
+func (tr *TunnelRegistry) attach(instance *SandboxInstance, conn net.Conn, remoteAddr string) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if err := tr.checkLocked(instance.ID); err != nil {
+		return err
+	}
+	settings := gatewaySettings().Tunnel
+	tunnel, exists := tr.tunnels[instance.ID]
+	if !exists {
+		tunnel = &sandboxTunnel{
+			id:          instance.ID,
+			remoteAddr:  remoteAddr,
+			connectedAt: time.Now().Unix(),
+			idle:        make(chan net.Conn, settings.MaxConnections),
+			closed:      make(chan struct{}),
+		}
+		tunnel.transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return tunnel.take(ctx)
+			},
+			MaxIdleConnsPerHost: settings.MaxConnections,
+			IdleConnTimeout:     90 * time.Second,
+			DisableCompression:  true,
+		}
+		tr.tunnels[instance.ID] = tunnel
+		log.Printf("🚇 Sandbox %s connected over tunnel from %s", instance.ID, remoteAddr)
+	}
+	_, registered := tr.pool.GetAllInstances()[instance.ID]
+	if tunnel.expiry != nil {
+		tunnel.expiry.Stop()
+		tunnel.expiry = nil
+	}
+
+	tunnel.open.Add(1)
+	tunnel.idle <- &tunnelConn{Conn: conn, onClose: func() { tr.detach(tunnel) }}
+
+	instance.URL = "http://tunnel." + instance.ID
+	instance.Tunnel = true
+	instance.Status = "healthy"
+	instance.LastPing = time.Now().Unix()
+	tr.pool.putLocalInstance(instance)
+	if !registered && tr.onChange != nil {
+		tr.onChange(&SandboxChange{Action: sandboxChangeRegistered, Sandbox: instance, Actor: sandboxActorTunnelAgent, ClientIP: remoteAddr})
+	}
+	return nil
+}
+
+// 连接关闭：最后一条连接断开后实例标记为 unhealthy，grace 秒内没有新连接时移除
+func (tr *TunnelRegistry) detach(tunnel *sandboxTunnel) {
+	if tunnel.open.Add(-1) > 0 {
+		return
+	}
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if tr.tunnels[tunnel.id] != tunnel || tunnel.open.Load() > 0 {
+		return
+	}
+	tr.pool.setLocalHealth(tunnel.id, "unhealthy")
+	grace := time.Duration(gatewaySettings().Tunnel.Grace) * time.Second
+	tunnel.expiry = time.AfterFunc(grace, func() {
+		tr.mutex.Lock()
+		expired := tr.tunnels[tunnel.id] == tunnel && tunnel.open.Load() == 0
+		tr.mutex.Unlock()
+		if expired {
+			instance := tr.pool.GetAllInstances()[tunnel.id]
+			tr.remove(tunnel)
+			log.Printf("🚇 Sandbox %s tunnel closed; instance removed", tunnel.id)
+			if instance != nil && tr.onChange != nil {
+				tr.onChange(&SandboxChange{Action: sandboxChangeEvicted, Sandbox: instance, Actor: sandboxActorGateway,
+					Reason: fmt.Sprintf("tunnel disconnected for more than %ds", gatewaySettings().Tunnel.Grace)})
+			}
+		}
+	})
+}
+
+// 关闭沙箱的隧道并移除实例
+func (tr *TunnelRegistry) close(sandboxID string) {
+	tr.mutex.Lock()
+	tunnel, exists := tr.tunnels[sandboxID]
+	tr.mutex.Unlock()
+	if exists {
+		tr.remove(tunnel)
+	}
+}
+
+// 移除隧道；关闭连接会回调 detach，必须在释放锁之后进行
+func (tr *TunnelRegistry) remove(tunnel *sandboxTunnel) {
+	tr.mutex.Lock()
+	if tr.tunnels[tunnel.id] != tunnel {
+		tr.mutex.Unlock()
+		return
+	}
+	delete(tr.tunnels, tunnel.id)
+	close(tunnel.closed)
+	tr.mutex.Unlock()
+
+	tunnel.transport.CloseIdleConnections()
+	for {
+		select {
+		case conn := <-tunnel.idle:
+			conn.Close()
+		default:
+			tr.pool.removeLocalInstance(tunnel.id)
+			return
+		}
+	}
+}
+
+// 取出一条可用的空闲连接，等待 agent 补充连接直到 ctx 结束
+func (t *sandboxTunnel) take(ctx context.Context) (net.Conn, error) {
+	for {
+		select {
+		case conn := <-t.idle:
+			if tunnelConnAlive(conn) {
+				return conn, nil
+			}
+			conn.Close()
+		case <-t.closed:
+			return nil, errTunnelClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 关闭已断开的空闲连接（agent 退出后，未被取用的连接只能靠定期检查发现）
+func (t *sandboxTunnel) pruneIdle() {
+	for i := len(t.idle); i > 0; i-- {
+		select {
+		case conn := <-t.idle:
+			if tunnelConnAlive(conn) {
+				t.idle <- conn
+			} else {
+				conn.Close()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// 空闲连接是否仍然可用：agent 在收到请求前不会发送数据，短暂读取超时说明连接正常，读到 EOF 或数据说明连接已失效
+func tunnelConnAlive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var buf [1]byte
+	_, err := conn.Read(buf[:])
+	conn.SetReadDeadline(time.Time{})
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// 隧道连接，关闭时通知注册表
+type tunnelConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *tunnelConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+	return err
+}
+
+// 升级请求中已读入缓冲区的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// 定期经隧道探测沙箱健康状态
+func (tr *TunnelRegistry) runHealthChecks() {
+	for {
+		time.Sleep(tr.pool.HealthCheckInterval())
+		tr.mutex.Lock()
+		tunnels := make([]*sandboxTunnel, 0, len(tr.tunnels))
+		for _, tunnel := range tr.tunnels {
+			tunnels = append(tunnels, tunnel)
+		}
+		tr.mutex.Unlock()
+
+		for _, tunnel := range tunnels {
+			tunnel.pruneIdle()
+			if tunnel.open.Load() == 0 {
+				continue
+			}
+			status := "unhealthy"
+			client := &http.Client{Timeout: tunnelProbeTimeout, Transport: tunnel.transport}
+			resp, err := client.Get("http://tunnel." + tunnel.id + "/health")
+			if err != nil {
+				opLogf(logCategoryHealth, logLevelWarn, "❌ Sandbox %s is unhealthy over tunnel: %v", tunnel.id, err)
+			} else {
+				if resp.StatusCode == http.StatusOK {
+					status = "healthy"
+				}
+				resp.Body.Close()
+			}
+			tr.pool.setLocalHealth(tunnel.id, status)
+		}
+	}
+}
+
+// 隧道状态
+func (tr *TunnelRegistry) list() []gin.H {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tunnels := make([]gin.H, 0, len(tr.tunnels))
+	for _, tunnel := range tr.tunnels {
+		tunnels = append(tunnels, gin.H{
+			"sandbox_id":   tunnel.id,
+			"remote_addr":  tunnel.remoteAddr,
+			"connected_at": tunnel.connectedAt,
+			"connections":  tunnel.open.Load(),
+			"idle":         len(tunnel.idle),
+		})
+	}
+	sort.Slice(tunnels, func(i, j int) bool {
+		return tunnels[i]["sandbox_id"].(string) < tunnels[j]["sandbox_id"].(string)
+	})
+	return tunnels
+}
+
+// 写入只属于本实例的沙箱（隧道实例），不写入 Redis
+func (sp *SandboxPool) putLocalInstance(instance *SandboxInstance) {
+	sp.mutex.Lock()
+	if existing, exists := sp.instances[instance.ID]; exists && existing.Tunnel {
+		existing.Status, existing.LastPing = instance.Status, instance.LastPing
+	} else {
+		sp.instances[instance.ID] = instance
+	}
+	sp.mutex.Unlock()
+	sp.notifyChange()
+}
+
+// 更新本实例沙箱的健康状态
+func (sp *SandboxPool) setLocalHealth(instanceID, status string) {
+	sp.mutex.Lock()
+	instance, exists := sp.instances[instanceID]
+	changed := exists && instance.Status != status
+	if exists {
+		instance.Status = status
+		if status == "healthy" {
+			instance.LastPing = time.Now().Unix()
+		}
+	}
+	sp.mutex.Unlock()
+	if changed {
+		opLogf(logCategoryHealth, logLevelInfo, "🩺 Sandbox %s tunnel health changed: %s", instanceID, status)
+		sp.notifyChange()
+	}
+}
+
+func (sp *SandboxPool) removeLocalInstance(instanceID string) {
+	sp.mutex.Lock()
+	if instance, exists := sp.instances[instanceID]; exists && instance.Tunnel {
+		delete(sp.instances, instanceID)
+	}
+	sp.mutex.Unlock()
+	sp.notifyChange()
+}
+
+// 🔧 新增：agent 接入隧道（网关端口，使用隧道令牌认证）
+func (dr *DistributedRouter) tunnelConnectHandler(w http.ResponseWriter, r *http.Request) {
+	writeError := func(status int, message string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(gin.H{"error": message})
+	}
+
+	if r.Method != http.MethodGet || r.Header.Get("Upgrade") != tunnelProtocol {
+		w.Header().Set("Upgrade", tunnelProtocol)
+		writeError(http.StatusUpgradeRequired, "tunnel requires Upgrade: "+tunnelProtocol)
+		return
+	}
+	token, err := dr.secrets.Resolve(r.Context(), gatewaySettings().Tunnel.Token)
+	if err != nil || token == "" {
+		writeError(http.StatusServiceUnavailable, "tunnel token is not configured")
+		return
+	}
+	presented, _ := bearerToken(r)
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		writeError(http.StatusUnauthorized, "invalid tunnel token")
+		return
+	}
+
+	instance := &SandboxInstance{
+		ID:         r.Header.Get(tunnelHeaderSandboxID),
+		Type:       r.Header.Get(tunnelHeaderSandboxType),
+		Region:     r.Header.Get(tunnelHeaderRegion),
+		Zone:       r.Header.Get(tunnelHeaderZone),
+		CancelPath: r.Header.Get(tunnelHeaderCancelPath),
+	}
+	if !tunnelIDPattern.MatchString(instance.ID) {
+		writeError(http.StatusBadRequest, "invalid "+tunnelHeaderSandboxID)
+		return
+	}
+	switch instance.Type {
+	case "python", "nodejs", "go":
+	default:
+		writeError(http.StatusBadRequest, "invalid "+tunnelHeaderSandboxType+": "+strconv.Quote(instance.Type))
+		return
+	}
+
+	if err := dr.tunnels.check(instance.ID); err != nil {
+		writeError(http.StatusConflict, err.Error())
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(http.StatusInternalServerError, "connection does not support tunneling")
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("❌ Failed to accept tunnel from %s: %v", clientIP(r), err)
+		return
+	}
+	// 清除服务器设置的读写超时，隧道连接长期保持
+	conn.SetDeadline(time.Time{})
+	if buffered.Reader.Buffered() > 0 {
+		conn = &bufferedConn{Conn: conn, reader: buffered.Reader}
+	}
+	if _, err := fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: %s\r\nConnection: Upgrade\r\n\r\n", tunnelProtocol); err != nil {
+		conn.Close()
+		return
+	}
+	if err := dr.tunnels.attach(instance, conn, clientIP(r)); err != nil {
+		log.Printf("⚠️ Rejected tunnel connection from %s: %v", clientIP(r), err)
+		conn.Close()
+	}
+}
+
+// 🔧 新增：隧道状态（本网关实例上的隧道）
+func (dr *DistributedRouter) listTunnelsHandler(c *gin.Context) {
+	c.JSON(200, gin.H{"enabled": gatewaySettings().Tunnel.Enabled, "tunnels": dr.tunnels.list()})
+}
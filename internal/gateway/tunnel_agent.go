@@ -0,0 +1,246 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tunnelAgentDialTimeout = 10 * time.Second
+	tunnelAgentMinBackoff  = time.Second
+	tunnelAgentMaxBackoff  = 30 * time.Second
+)
+
+// 🔧 新增：沙箱侧 agent 的选项（router agent）
+type TunnelAgentOptions struct {
+	GatewayURL  string // 网关隧道地址，如 https://gateway.example.com/_tunnel，没有路径时使用 /_tunnel
+	Token       string // 隧道令牌，支持 env: 和 file: 引用
+	SandboxID   string
+	SandboxType string // python、nodejs 或 go
+	SandboxURL  string // 本机沙箱地址
+	Region      string
+	Zone        string
+	Connections int    // 保持的隧道连接数，即网关经隧道的最大并发请求数
+	CAFile      string // 校验网关证书的 CA（默认使用系统 CA）
+	CancelPath  string // 沙箱取消执行的接口路径，为空时使用网关的 execution_cancel.default_path
+}
+
+// 🔧 新增：运行 agent：保持 Connections 条到网关的隧道连接，把网关经隧道发来的请求转发给本机沙箱，
+// 连接断开后按指数退避重连，直到 ctx 结束
+func RunTunnelAgent(ctx context.Context, options TunnelAgentOptions) error {
+	gatewayURL, err := url.Parse(options.GatewayURL)
+	if err != nil || (gatewayURL.Scheme != "http" && gatewayURL.Scheme != "https") || gatewayURL.Host == "" {
+		return fmt.Errorf("invalid gateway url: %q", options.GatewayURL)
+	}
+	if gatewayURL.Path == "" || gatewayURL.Path == "/" {
+		gatewayURL.Path = "/_tunnel"
+	}
+	sandboxURL, err := url.Parse(options.SandboxURL)
+	if err != nil || sandboxURL.Host == "" {
+		return fmt.Errorf("invalid sandbox url: %q", options.SandboxURL)
+	}
+	if !tunnelIDPattern.MatchString(options.SandboxID) {
+		return fmt.Errorf("invalid sandbox id: %q", options.SandboxID)
+	}
+	token, err := NewSecretResolver(nil, false).Resolve(ctx, options.Token)
+	if err != nil {
+		return fmt.Errorf("failed to resolve tunnel token: %w", err)
+	}
+	if token == "" {
+		return errors.New("tunnel token is required")
+	}
+	if options.Connections <= 0 {
+		options.Connections = 1
+	}
+
+	var tlsConfig *tls.Config
+	if gatewayURL.Scheme == "https" {
+		tlsConfig = &tls.Config{ServerName: gatewayURL.Hostname(), NextProtos: []string{"http/1.1"}}
+		if options.CAFile != "" {
+			pem, err := os.ReadFile(options.CAFile)
+			if err != nil {
+				return fmt.Errorf("failed to read ca file: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in %s", options.CAFile)
+			}
+		}
+	}
+
+	agent := &tunnelAgent{
+		options:   options,
+		gateway:   gatewayURL,
+		token:     token,
+		tlsConfig: tlsConfig,
+		server:    &http.Server{Handler: httputil.NewSingleHostReverseProxy(sandboxURL)},
+	}
+	log.Printf("🚇 Tunnel agent for sandbox %s: %d connections to %s, forwarding to %s",
+		options.SandboxID, options.Connections, gatewayURL.Redacted(), sandboxURL.Redacted())
+
+	var wg sync.WaitGroup
+	for i := 0; i < options.Connections; i++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			agent.runSlot(ctx, slot)
+		}(i)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+type tunnelAgent struct {
+	options   TunnelAgentOptions
+	gateway   *url.URL
+	token     string
+	tlsConfig *tls.Config
+	server    *http.Server
+}
+
+// 保持一条隧道连接：断开后重连，连接成功后重置退避
+func (a *tunnelAgent) runSlot(ctx context.Context, slot int) {
+	backoff := tunnelAgentMinBackoff
+	for ctx.Err() == nil {
+		conn, err := a.connect(ctx)
+		if err != nil {
+			log.Printf("⚠️ Tunnel connection %d failed: %v (retrying in %s)", slot, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > tunnelAgentMaxBackoff {
+				backoff = tunnelAgentMaxBackoff
+			}
+			continue
+		}
+		backoff = tunnelAgentMinBackoff
+		a.serve(ctx, conn)
+	}
+}
+
+// 连接网关并完成协议升级
+func (a *tunnelAgent) connect(ctx context.Context) (net.Conn, error) {
+	address := a.gateway.Host
+	if a.gateway.Port() == "" {
+		port := "80"
+		if a.gateway.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(a.gateway.Hostname(), port)
+	}
+	dialer := &net.Dialer{Timeout: tunnelAgentDialTimeout, KeepAlive: 15 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if a.tlsConfig != nil {
+		tlsConn := tls.Client(conn, a.tlsConfig)
+		handshakeCtx, cancel := context.WithTimeout(ctx, tunnelAgentDialTimeout)
+		err := tlsConn.HandshakeContext(handshakeCtx)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, a.gateway.String(), nil)
+	request.Header.Set("Upgrade", tunnelProtocol)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Authorization", "Bearer "+a.token)
+	request.Header.Set(tunnelHeaderSandboxID, a.options.SandboxID)
+	request.Header.Set(tunnelHeaderSandboxType, a.options.SandboxType)
+	if a.options.Region != "" {
+		request.Header.Set(tunnelHeaderRegion, a.options.Region)
+	}
+	if a.options.Zone != "" {
+		request.Header.Set(tunnelHeaderZone, a.options.Zone)
+	}
+	if a.options.CancelPath != "" {
+		request.Header.Set(tunnelHeaderCancelPath, a.options.CancelPath)
+	}
+
+	conn.SetDeadline(time.Now().Add(tunnelAgentDialTimeout))
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		body := make([]byte, 512)
+		n, _ := response.Body.Read(body)
+		response.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("gateway returned %s: %s", response.Status, strings.TrimSpace(string(body[:n])))
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// 在隧道连接上处理网关发来的请求，直到连接断开
+func (a *tunnelAgent) serve(ctx context.Context, conn net.Conn) {
+	listener := &singleConnListener{conn: conn, done: make(chan struct{})}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	a.server.Serve(listener)
+}
+
+// 只返回一条连接的 Listener：第二次 Accept 阻塞到这条连接关闭，Serve 随之返回
+type singleConnListener struct {
+	conn     net.Conn
+	accepted bool
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	if !l.accepted {
+		l.accepted = true
+		return &listenerConn{Conn: l.conn, listener: l}, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// 关闭时结束所属的 Listener
+type listenerConn struct {
+	net.Conn
+	listener *singleConnListener
+}
+
+func (c *listenerConn) Close() error {
+	err := c.Conn.Close()
+	c.listener.Close()
+	return err
+}
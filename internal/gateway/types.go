@@ -6,16 +6,47 @@ import "time"
 type RouteConfig struct {
 	ID          string            `json:"id"`
 	Path        string            `json:"path"`
+	PathRegex   string            `json:"path_regex,omitempty"` // 🔧 新增：按正则匹配整个路径（与 path 二选一）
 	Method      string            `json:"method"`
-	Handler     string            `json:"handler"` // "sandbox", "proxy", "static"
+	Methods     []string          `json:"methods,omitempty"` // 🔧 新增：方法列表（如 GET、HEAD），与 method 二选一
+	Handler     string            `json:"handler"` // "sandbox", "proxy", "llm", "static", "echo"
 	SandboxType string            `json:"sandbox_type,omitempty"` // "python", "nodejs", "go"
 	Code        string            `json:"code,omitempty"`
 	Target      string            `json:"target,omitempty"`
+	Resolve     map[string]string `json:"resolve,omitempty"` // 🔧 新增：proxy 目标域名的静态解析（域名 -> IP，逗号分隔），类似 curl --resolve
 	Timeout     int               `json:"timeout,omitempty"`
+	Idempotent  bool              `json:"idempotent,omitempty"` // 🔧 新增：标记为幂等后非幂等方法也允许失败重试
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Signing     *RouteSigning     `json:"signing,omitempty"` // 🔧 新增：出站请求签名
+	SLO         *RouteSLO         `json:"slo,omitempty"`     // 🔧 新增：路由 SLO
+	LLM         *RouteLLM         `json:"llm,omitempty"` // 🔧 新增：LLM 代理配置（handler: llm）
+	Locality    string            `json:"locality,omitempty"` // 🔧 新增：沙箱就近策略 prefer-local、require-local、any（默认）
+	Tenant      string            `json:"tenant,omitempty"`   // 🔧 新增：所属租户，为空时所有租户共享
+	Host        string            `json:"host,omitempty"`     // 🔧 新增：匹配的请求域名，支持 *.example.com，为空时匹配所有域名
+	Description  string           `json:"description,omitempty"`   // 🔧 新增：路由说明
+	DocsURL      string           `json:"docs_url,omitempty"`      // 🔧 新增：文档或运维手册链接
+	ContactOwner string           `json:"contact_owner,omitempty"` // 🔧 新增：负责人或值班联系方式
+	Tags         []string         `json:"tags,omitempty"`          // 🔧 新增：标签（如 team-a、env:prod），用于筛选路由列表
+	Priority    int               `json:"priority,omitempty"` // 🔧 新增：显式匹配优先级，设置后覆盖按匹配类型计算的优先级
+	IsDefault   bool              `json:"is_default,omitempty"` // 🔧 新增：默认路由，没有路由匹配时代替内置 404（按 tenant、host 限定范围）
+	Canary      *RouteCanary      `json:"canary,omitempty"`   // 🔧 新增：按权重分流到新代码或新目标
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty"` // 🔧 新增：响应大小上限，超出返回 502 或中止传输
+	RequestHeaders *RouteRequestHeaders `json:"request_headers,omitempty"` // 🔧 新增：转发给上游前改写请求头
+	ResponseHeaders *RouteResponseHeaders `json:"response_headers,omitempty"` // 🔧 新增：上游响应头过滤与响应头改写
+	ResponseContract *RouteResponseContract `json:"response_contract,omitempty"` // 🔧 新增：上游响应契约校验
+	ErrorResponses map[string]*RouteErrorResponse `json:"error_responses,omitempty"` // 🔧 新增：自定义 404、502、503 错误响应（键为状态码）
+	Public      bool              `json:"public,omitempty"`   // 🔧 新增：公开路由，不需要网关认证
+	Auth        *RouteAuth        `json:"auth,omitempty"`     // 🔧 新增：路由级认证方式
+	Policy      *RoutePolicy      `json:"policy,omitempty"`   // 🔧 新增：授权策略（规则或 OPA）
+	GroupID     string            `json:"group_id,omitempty"` // 🔧 新增：所属路由分组，匹配路径为分组前缀加 path
+	RewritePath *RoutePathRewrite `json:"rewrite_path,omitempty"` // 🔧 新增：转发前改写请求路径
+	ActiveFrom  int64             `json:"active_from,omitempty"` // 🔧 新增：开始生效时间（Unix 秒），之前不参与匹配
+	ExpiresAt   int64             `json:"expires_at,omitempty"`  // 🔧 新增：过期时间（Unix 秒），之后不参与匹配并由主节点删除
 	CreatedAt   int64             `json:"created_at,omitempty"`
 	UpdatedAt   int64             `json:"updated_at,omitempty"`
 	Version     int64             `json:"version,omitempty"` // 🔧 新增：版本号
+
+	groupPrefix string // 路由表写入时按 group_id 解析出的分组前缀（不持久化）
 }
 
 // 配置版本信息
@@ -26,14 +57,38 @@ type ConfigVersion struct {
 }
 
 
+// 路由增量（自某个配置版本以来的变更）
+type RouteDelta struct {
+	Since         int64         `json:"since"`
+	ConfigVersion int64         `json:"config_version"`
+	FullResync    bool          `json:"full_resync"` // 删除记录已过期或 since 不是本实例返回的版本，Created 为全量路由
+	InstanceID    string        `json:"instance_id"` // 🔧 新增：返回该增量的实例，下一次请求原样带上
+	Created       []RouteConfig `json:"created"`
+	Updated       []RouteConfig `json:"updated"`
+	Deleted       []string      `json:"deleted"`
+}
+
+// 批量变更结果
+type RouteApplySummary struct {
+	Created []string          `json:"created"`
+	Updated []string          `json:"updated"`
+	Deleted []string          `json:"deleted"`
+	Errors  map[string]string `json:"errors,omitempty"` // 路由ID -> 错误信息
+}
+
 // 沙箱服务实例
 type SandboxInstance struct {
-	ID       string `json:"id"`
-	URL      string `json:"url"`
-	Type     string `json:"type"`
-	Status   string `json:"status"` // "healthy", "unhealthy", "starting"
-	Load     int    `json:"load"`   // 当前负载
-	LastPing int64  `json:"last_ping"`
+	ID         string  `json:"id"`
+	URL        string  `json:"url"`
+	Type       string  `json:"type"`
+	Status     string  `json:"status"`         // "healthy", "unhealthy", "starting"
+	Load       int     `json:"load"`           // 当前负载（进行中的请求数）
+	Cost       float64 `json:"cost,omitempty"` // 🔧 新增：相对成本权重（如 spot 0.3、按需 1、GPU 4），默认 1
+	LastPing   int64   `json:"last_ping"`
+	Region     string  `json:"region,omitempty"` // 🔧 新增：所在区域
+	Zone       string  `json:"zone,omitempty"`   // 🔧 新增：所在可用区
+	Tunnel     bool    `json:"tunnel,omitempty"`      // 🔧 新增：经反向隧道连接到本网关实例（只在本实例可用，不写入 Redis）
+	CancelPath string  `json:"cancel_path,omitempty"` // 🔧 新增：取消执行的接口路径（如 /cancel），为空时使用 gateway.execution_cancel.default_path
 }
 
 // 负载均衡器接口
@@ -45,9 +100,14 @@ type LoadBalancerInterface interface {
 // 路由事件
 type RouteEvent struct {
 	EventID   string      `json:"event_id"`
-	EventType string      `json:"event_type"` // CREATE, UPDATE, DELETE, HEALTH_UPDATE
+	EventType string      `json:"event_type"` // CREATE, UPDATE, DELETE, BATCH, HEALTH_UPDATE, RESYNC, SANDBOX_CHANGE
 	RouteID   string      `json:"route_id"`
 	RouteData *RouteConfig `json:"route_data,omitempty"`
+	Sandbox   *SandboxInstance `json:"sandbox,omitempty"` // 🔧 新增：HEALTH_UPDATE 事件的沙箱状态
+	Changes   map[string]FieldChange `json:"changes,omitempty"` // 🔧 新增：UPDATE 事件中修改的字段
+	SandboxChange *SandboxChange `json:"sandbox_change,omitempty"` // 🔧 新增：SANDBOX_CHANGE 事件的沙箱池变化
+	Batch     []RouteEvent `json:"batch,omitempty"` // 🔧 新增：BATCH 事件中按顺序应用的 CREATE、UPDATE、DELETE
+	ConfigVersion int64   `json:"config_version,omitempty"` // 🔧 新增：发布时的全局配置版本
 	Timestamp int64       `json:"timestamp"`
 	Source    string      `json:"source"`
 }
@@ -0,0 +1,14 @@
+package gateway
+
+import (
+	"github.com/dify-router/dify-router/internal/static"
+	"github.com/gin-gonic/gin"
+)
+
+// 🔧 新增：当前实例的构建信息
+func (dr *DistributedRouter) versionHandler(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"instance_id": dr.routeManager.instanceID,
+		"build":       static.GetBuildInfo(),
+	})
+}
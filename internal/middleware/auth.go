@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 	"github.com/dify-router/dify-router/internal/static"
 )
@@ -12,12 +15,14 @@ func GatewayAuth() gin.HandlerFunc {
 		apiKey := c.GetHeader("X-Api-Key")
 		
 		// 优先级：gateway_key > key（向后兼容）
-		expectedKey := config.App.GatewayKey
-		if expectedKey == "" {
-			expectedKey = config.App.Key // 兼容旧配置
-		}
+		expectedKey := GatewayKey(config)
 		
-		if expectedKey == "" || expectedKey != apiKey {
+		// 🔧 新增：未配置 Key 且为 open 模式时不认证
+		if expectedKey == "" && KeyUnsetMode(config.App.GatewayKeyUnset) == KeyUnsetOpen {
+			c.Next()
+			return
+		}
+		if !KeyMatches(expectedKey, apiKey) {
 			c.AbortWithStatusJSON(401, gin.H{
 				"error": "invalid gateway api key",
 			})
@@ -34,12 +39,14 @@ func AdminAuth() gin.HandlerFunc {
 		apiKey := c.GetHeader("X-Api-Key")
 		
 		// 优先级：admin_key > key（向后兼容）
-		expectedKey := config.App.AdminKey
-		if expectedKey == "" {
-			expectedKey = config.App.Key // 兼容旧配置
-		}
+		expectedKey := AdminKey(config)
 		
-		if expectedKey == "" || expectedKey != apiKey {
+		// 🔧 新增：未配置 Key 且为 open 模式时不认证
+		if expectedKey == "" && KeyUnsetMode(config.App.AdminKeyUnset) == KeyUnsetOpen {
+			c.Next()
+			return
+		}
+		if !KeyMatches(expectedKey, apiKey) {
 			c.AbortWithStatusJSON(401, gin.H{
 				"error": "invalid admin api key",
 			})
@@ -49,6 +56,79 @@ func AdminAuth() gin.HandlerFunc {
 	}
 }
 
+// 🔧 新增：未配置 Key 时的行为
+const (
+	KeyUnsetDenyAll  = "deny-all" // 拒绝所有请求（默认）
+	KeyUnsetRequired = "required" // 启动失败
+	KeyUnsetOpen     = "open"     // 不认证（dev 为别名）
+)
+
+// KeyUnsetMode 规范化配置的模式，空值为 deny-all，dev 等同于 open
+func KeyUnsetMode(mode string) string {
+	switch mode {
+	case "", KeyUnsetDenyAll:
+		return KeyUnsetDenyAll
+	case "dev":
+		return KeyUnsetOpen
+	}
+	return mode
+}
+
+// GatewayKey 网关端口使用的 Key：gateway_key > key
+func GatewayKey(config *static.DifySandboxGlobalConfigurations) string {
+	if config.App.GatewayKey != "" {
+		return config.App.GatewayKey
+	}
+	return config.App.Key // 兼容旧配置
+}
+
+// AdminKey 管理端口使用的 Key：admin_key > key
+func AdminKey(config *static.DifySandboxGlobalConfigurations) string {
+	if config.App.AdminKey != "" {
+		return config.App.AdminKey
+	}
+	return config.App.Key // 兼容旧配置
+}
+
+// KeyUnsetWarnings 检查 Key 配置：模式无效或 required 模式下未配置 Key 时返回错误，
+// 未配置 Key 时返回需要在启动时输出的警告
+func KeyUnsetWarnings(config *static.DifySandboxGlobalConfigurations) ([]string, error) {
+	var warnings []string
+	for _, entry := range []struct {
+		name, key, mode, effect string
+	}{
+		{"gateway_key", GatewayKey(config), config.App.GatewayKeyUnset, "gateway requests"},
+		{"admin_key", AdminKey(config), config.App.AdminKeyUnset, "admin API requests"},
+	} {
+		mode := KeyUnsetMode(entry.mode)
+		switch mode {
+		case KeyUnsetDenyAll, KeyUnsetRequired, KeyUnsetOpen:
+		default:
+			return nil, fmt.Errorf("invalid app.%s_unset: %s (expected deny-all, required, open or dev)", entry.name, entry.mode)
+		}
+		if entry.key != "" {
+			continue
+		}
+		switch mode {
+		case KeyUnsetRequired:
+			return nil, fmt.Errorf("app.%s is not configured and app.%s_unset is required", entry.name, entry.name)
+		case KeyUnsetOpen:
+			warnings = append(warnings, fmt.Sprintf("app.%s is not configured; %s are NOT authenticated (app.%s_unset: open)", entry.name, entry.effect, entry.name))
+		default:
+			warnings = append(warnings, fmt.Sprintf("app.%s is not configured; all %s are rejected with 401 (app.%s_unset: deny-all)", entry.name, entry.effect, entry.name))
+		}
+	}
+	return warnings, nil
+}
+
+// KeyMatches 常量时间比较密钥，避免时序攻击；未配置密钥时始终失败
+func KeyMatches(expectedKey, apiKey string) bool {
+	if expectedKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expectedKey), []byte(apiKey)) == 1
+}
+
 // Auth 通用认证（保持向后兼容）
 func Auth() gin.HandlerFunc {
 	return GatewayAuth() // 默认使用网关认证
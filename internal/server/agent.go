@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dify-router/dify-router/internal/gateway"
+)
+
+// 🔧 新增：main agent 子命令：在 NAT 或防火墙后的沙箱主机上运行，主动连接网关的隧道，转发网关发来的执行请求
+func Agent(args []string) int {
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	options := gateway.TunnelAgentOptions{}
+	flags.StringVar(&options.GatewayURL, "gateway", "", "gateway tunnel url, e.g. https://gateway.example.com/_tunnel")
+	flags.StringVar(&options.Token, "token", "env:GATEWAY_TUNNEL_TOKEN", "tunnel token (env: and file: references are resolved)")
+	flags.StringVar(&options.SandboxID, "id", "", "sandbox id")
+	flags.StringVar(&options.SandboxType, "type", "python", "sandbox type: python, nodejs or go")
+	flags.StringVar(&options.SandboxURL, "sandbox", "http://127.0.0.1:8194", "local sandbox url")
+	flags.StringVar(&options.Region, "region", "", "sandbox region")
+	flags.StringVar(&options.Zone, "zone", "", "sandbox zone")
+	flags.IntVar(&options.Connections, "connections", 4, "tunnel connections to keep open (max concurrent requests)")
+	flags.StringVar(&options.CAFile, "ca-file", "", "CA bundle for verifying the gateway certificate")
+	flags.StringVar(&options.CancelPath, "cancel-path", "", "sandbox endpoint for cancelling timed-out executions, e.g. /cancel")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := gateway.RunTunnelAgent(ctx, options); err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	return 0
+}
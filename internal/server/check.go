@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dify-router/dify-router/internal/gateway"
+	"github.com/dify-router/dify-router/internal/static"
+)
+
+var checkStatusIcons = map[string]string{
+	"ok":   "✅",
+	"warn": "⚠️ ",
+	"fail": "❌",
+	"skip": "⏭️ ",
+}
+
+// 🔧 新增：main check 子命令：部署流水线中校验配置、Redis 和端口，有失败项时返回非零退出码
+func Check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	configPath := flags.String("config", "conf/config.yaml", "config file")
+	routes := flags.Bool("routes", false, "dry-run loading all routes from Redis")
+	skipPorts := flags.Bool("skip-ports", false, "do not check that listener ports are free")
+	strict := flags.Bool("strict", false, "treat warnings as failures")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var report *gateway.SelfCheckReport
+	if err := static.InitConfig(*configPath); err != nil {
+		report = &gateway.SelfCheckReport{
+			Checks: []gateway.SelfCheckResult{{Name: "config", Status: "fail", Message: err.Error()}},
+			Failed: 1,
+		}
+	} else {
+		report = gateway.RunSelfCheck(gateway.SelfCheckOptions{Routes: *routes, SkipPorts: *skipPorts})
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printCheckReport(os.Stdout, report)
+	}
+
+	if report.Failed > 0 || (*strict && report.Warned > 0) {
+		return 1
+	}
+	return 0
+}
+
+func printCheckReport(w io.Writer, report *gateway.SelfCheckReport) {
+	for _, check := range report.Checks {
+		line := fmt.Sprintf("%s %-28s %s", checkStatusIcons[check.Status], check.Name, check.Status)
+		if check.Message != "" {
+			line += ": " + check.Message
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\n%d checks, %d failed, %d warnings\n", len(report.Checks), report.Failed, report.Warned)
+}
@@ -27,7 +27,10 @@ func initGatewayServer() {
 	}
 
 	// 创建分布式路由器，传入 Redis 地址和密码
-	router := gateway.NewDistributedRouter(config.Redis.Addr, config.Redis.Password)
+	router, err := gateway.NewDistributedRouter(config.Redis.Addr, config.Redis.Password)
+	if err != nil {
+		log.Panic("Failed to create gateway router: %v", err)
+	}
 	
 	// 设置负载均衡策略
 	router.SetLoadBalancerStrategy(config.Gateway.LoadBalancerStrategy)
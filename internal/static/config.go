@@ -1,18 +1,22 @@
 package static
 
 import (
+	"gopkg.in/yaml.v3"
+	"os"
 	"sync"
-    "os"
-    "gopkg.in/yaml.v3"
 )
 
 // App配置
 type AppConfig struct {
 	Port       int    `yaml:"port"`
 	Debug      bool   `yaml:"debug"`
-	GatewayKey string `yaml:"gateway_key"`  // 新增：网关 Key
-	AdminKey   string `yaml:"admin_key"`    // 新增：管理 Key
-	Key        string `yaml:"key"`          // 保留：向后兼容
+	GatewayKey string `yaml:"gateway_key"` // 新增：网关 Key
+	AdminKey   string `yaml:"admin_key"`   // 新增：管理 Key
+	Key        string `yaml:"key"`         // 保留：向后兼容
+
+	// 🔧 新增：未配置 Key 时的行为：deny-all（默认，拒绝所有请求）、required（启动失败）、open/dev（不认证，仅用于开发）
+	GatewayKeyUnset string `yaml:"gateway_key_unset"`
+	AdminKeyUnset   string `yaml:"admin_key_unset"`
 }
 
 // 代理配置
@@ -25,10 +29,405 @@ type ProxyConfig struct {
 // 网关配置
 type GatewayConfig struct {
 	Port                 int    `yaml:"port"`
+	InstanceID           string `yaml:"instance_id"` // 实例标识：hostname、env:NAME 或固定值，为空时使用 instance-<时间戳>
 	RedisAddr            string `yaml:"redis_addr"`
 	LoadBalancerStrategy string `yaml:"load_balancer_strategy"`
+	CostSpillLoad        int    `yaml:"cost_spill_load"` // cost-aware 策略：实例进行中的请求达到该值后溢出到更贵的实例
 	HealthCheckInterval  int    `yaml:"health_check_interval"`
+	Region               string `yaml:"region"`            // 网关所在区域，用于就近选择沙箱
+	Zone                 string `yaml:"zone"`              // 网关所在可用区
+	HealthCheckMode      string `yaml:"health_check_mode"` // leader：仅主节点探测并同步给其他实例；local：每个实例独立探测
 	CorsEnabled          bool   `yaml:"cors_enabled"`
+
+	// 🔧 新增：网关端口 CORS（cors_enabled 为 true 时生效），与管理端口独立
+	CORS CORSConfig `yaml:"cors"`
+
+	// 路由缓存内存限制
+	MaxCodeSize       int   `yaml:"max_code_size"`       // 单条路由代码最大字节数，0 表示不限制
+	MaxCacheMemory    int64 `yaml:"max_cache_memory"`    // 路由缓存最大内存（字节），0 表示不限制
+	LazyCodeThreshold int   `yaml:"lazy_code_threshold"` // 超过该大小的代码不常驻内存，首次执行时从 Redis 加载
+	CodeCacheMemory   int64 `yaml:"code_cache_memory"`   // 延迟加载代码的 LRU 缓存容量（字节）
+	MatchCacheSize    int   `yaml:"match_cache_size"`    // 路由匹配结果 LRU 的条目数，0 表示不缓存
+
+	// 沙箱转发失败重试（非幂等请求仅在路由标记 idempotent 或携带 Idempotency-Key 时重试）
+	RetryAttempts int `yaml:"retry_attempts"` // 最大尝试次数（含首次），1 表示不重试
+
+	// 响应大小上限（字节），路由可用 max_response_bytes 覆盖；超出时返回 502 或中止传输，0 表示不限制
+	MaxResponseBytes int64 `yaml:"max_response_bytes"`
+
+	// 没有可用沙箱时挂起请求等待容量恢复
+	SandboxWait SandboxWaitConfig `yaml:"sandbox_wait"`
+
+	// 执行超时后通知沙箱取消执行
+	ExecutionCancel ExecutionCancelConfig `yaml:"execution_cancel"`
+
+	// 按上游（沙箱实例、代理目标）自适应并发限制
+	AdaptiveConcurrency AdaptiveConcurrencyConfig `yaml:"adaptive_concurrency"`
+
+	// 消费者 API Key（可限制访问范围）
+	APIKeys      []APIKeyConfig `yaml:"api_keys"`
+	APIKeyPepper string         `yaml:"api_key_pepper"` // Key 哈希使用的 pepper（密钥引用）
+
+	// 客户端请求签名校验
+	RequestSigning RequestSigningConfig `yaml:"request_signing"`
+
+	// OAuth2 客户端凭证授权
+	OAuth OAuthConfig `yaml:"oauth"`
+
+	// HTTP Basic 认证凭据（路由 auth.mode 为 basic 时使用）
+	BasicAuth BasicAuthConfig `yaml:"basic_auth"`
+
+	// 网关实例自注册（服务发现）
+	Discovery DiscoveryConfig `yaml:"discovery"`
+
+	// Dify 外部工具集成
+	Dify DifyIntegrationConfig `yaml:"dify"`
+
+	// LLM 代理的上游 Key 池
+	LLMKeyPools []LLMKeyPoolConfig `yaml:"llm_key_pools"`
+
+	// LLM 响应缓存（路由通过 llm.cache 启用）
+	LLMCache LLMCacheConfig `yaml:"llm_cache"`
+
+	// 多租户：从请求头或访问令牌解析租户
+	Tenancy TenancyConfig `yaml:"tenancy"`
+
+	// 路由匹配前的请求路径规范化
+	PathNormalization PathNormalizationConfig `yaml:"path_normalization"`
+
+	// POST 请求的方法覆盖（X-HTTP-Method-Override）
+	MethodOverride MethodOverrideConfig `yaml:"method_override"`
+
+	// 没有路由匹配时按 Host 使用的默认路由
+	DefaultRoutes []DefaultRouteConfig `yaml:"default_routes"`
+
+	// 路由事件发布可靠性
+	EventPublish EventPublishConfig `yaml:"event_publish"`
+
+	// 配置同步轮询
+	Sync SyncConfig `yaml:"sync"`
+
+	// 过期路由清理
+	RouteExpiry RouteExpiryConfig `yaml:"route_expiry"`
+
+	// NAT 后的沙箱通过反向隧道接入
+	Tunnel TunnelConfig `yaml:"tunnel"`
+
+	// 沙箱池变化（注册、删除、移除）通知
+	SandboxEvents SandboxEventsConfig `yaml:"sandbox_events"`
+
+	// 上游拨号的 DNS 解析与缓存
+	DNS DNSConfig `yaml:"dns"`
+
+	// 监听地址与上游连接的地址族（IPv4、IPv6、双栈）
+	Network NetworkConfig `yaml:"network"`
+
+	// 优雅关闭：排空后再关闭监听
+	Shutdown ShutdownConfig `yaml:"shutdown"`
+
+	// 健康检查：组件状态汇总为整体状态的策略
+	Health HealthConfig `yaml:"health"`
+
+	// 网关端口 TLS 与客户端证书（mTLS）
+	TLS GatewayTLSConfig `yaml:"tls"`
+
+	// 授权策略引擎（路由 policy.opa 使用的 OPA 边车）
+	Policy PolicyConfig `yaml:"policy"`
+
+	// 路由敏感字段加密存储
+	RouteEncryption RouteEncryptionConfig `yaml:"route_encryption"`
+
+	// 日志脱敏（访问日志、调试捕获和审计记录）
+	Redaction RedactionConfig `yaml:"redaction"`
+
+	// 运行日志（事件、健康检查、同步）的级别、去重与限流
+	OperationalLogs OperationalLogConfig `yaml:"operational_logs"`
+}
+
+// 运行日志按分类（event、health、sync）设置级别，重复消息和超出限流的行被省略并计数
+type OperationalLogConfig struct {
+	DedupWindow int               `yaml:"dedup_window"` // 相同消息在窗口内（秒）只输出一次，0 表示不去重
+	RateLimit   int               `yaml:"rate_limit"`   // 每个分类每秒最多输出的行数，0 表示不限
+	Levels      map[string]string `yaml:"levels"`       // 分类 -> 最低输出级别 debug、info、warn、error，默认 info
+}
+
+// 写入访问日志、调试捕获和审计记录之前的脱敏规则，匹配的内容替换为 [REDACTED]。
+// Authorization、Cookie、X-Api-Key 等凭据请求头始终脱敏
+type RedactionConfig struct {
+	Headers   []string `yaml:"headers"`    // 额外需要隐藏值的请求头
+	JSONPaths []string `yaml:"json_paths"` // 审计记录中路由字段的 JSON 路径，如 $.metadata.api_token、$..password（任意层级）
+	Patterns  []string `yaml:"patterns"`   // 正则，作用于路径、查询参数、User-Agent、请求头值和审计说明
+}
+
+// 路由敏感字段在 Redis 中加密存储（信封加密）：每条路由生成随机数据密钥，用 AES-256-GCM 加密指定字段，
+// 数据密钥再用主密钥加密后与路由一起保存；管理接口和内存中的路由仍为明文
+type RouteEncryptionConfig struct {
+	Enabled   bool                  `yaml:"enabled"`
+	Fields    []string              `yaml:"fields"`     // 加密的路由字段（JSON 名称），默认 code、metadata
+	ActiveKey string                `yaml:"active_key"` // 加密新数据使用的主密钥 ID，轮换时旧密钥保留在 keys 中用于解密
+	Keys      []EncryptionKeyConfig `yaml:"keys"`
+}
+
+// 主密钥：base64 编码的 32 字节密钥，使用 env:NAME 或 file:/path 引用（不能保存在 Redis 中）
+type EncryptionKeyConfig struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"`
+}
+
+// OPA 边车：路由的 policy.opa 为策略路径时，把请求上下文 POST 到 <opa_url>/v1/data/<路径>，
+// 结果为 true 或 {"allow": true} 时放行
+type PolicyConfig struct {
+	OPAURL    string `yaml:"opa_url"`    // 如 http://127.0.0.1:8181
+	TimeoutMs int    `yaml:"timeout_ms"` // 单次查询超时（毫秒）
+	FailOpen  bool   `yaml:"fail_open"`  // OPA 不可用时放行，默认返回 503
+}
+
+// 路由事件发布：fire-and-forget 失败只记录日志；outbox 失败的事件进入本地发件箱，后台按顺序重试，其他实例不会丢失变更
+type EventPublishConfig struct {
+	Mode          string `yaml:"mode"`           // fire-and-forget 或 outbox
+	RetryInterval int    `yaml:"retry_interval"` // 发件箱重试间隔（秒）
+	MaxEvents     int    `yaml:"max_events"`     // 发件箱容量，超出时丢弃最旧的事件（由定时增量同步兜底）
+}
+
+// 配置同步轮询：每个实例按 interval 加上 [0, jitter) 的随机延迟检查 Redis 中的配置版本
+type SyncConfig struct {
+	Interval int    `yaml:"interval"` // 轮询间隔（秒），可通过 /admin/runtime 临时调整
+	Jitter   int    `yaml:"jitter"`   // 随机抖动上限（秒），避免大量实例同时读取 Redis
+	Poll     string `yaml:"poll"`     // always：始终轮询；fallback：每个实例直接读取事件流，读取正常时跳过轮询
+}
+
+// 🔧 新增：过期路由清理：主节点定期删除 expires_at 已过的路由（过期后立即停止匹配，不依赖清理）
+type RouteExpiryConfig struct {
+	SweepInterval int `yaml:"sweep_interval"` // 清理间隔（秒），0 表示不删除过期路由
+}
+
+// 🔧 新增：沙箱反向隧道：沙箱主机上的 agent 主动连接网关端口并保持长连接，网关经隧道转发执行请求，沙箱无需入站连通
+type TunnelConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	Path           string `yaml:"path"`            // 网关端口上的隧道接入路径
+	Token          string `yaml:"token"`           // agent 接入令牌（密钥引用，如 env:GATEWAY_TUNNEL_TOKEN）
+	MaxConnections int    `yaml:"max_connections"` // 每个沙箱最多的隧道连接数（即经隧道的最大并发执行数）
+	Grace          int    `yaml:"grace"`           // 最后一条连接断开后保留实例的时间（秒），期间标记为 unhealthy
+}
+
+// 🔧 新增：网关端口和管理端口的监听地址，以及上游连接优先使用的地址族。
+// 上游同时解析出 IPv4 和 IPv6 地址时按 Happy Eyeballs 连接：先连接首选地址族，fallback_delay 毫秒内未成功则并行连接另一地址族
+type NetworkConfig struct {
+	ListenAddresses []string `yaml:"listen_addresses"` // 监听的本地 IP，如 ["0.0.0.0", "::"]；为空时监听所有地址（双栈）
+	PreferredFamily string   `yaml:"preferred_family"` // auto（按解析结果的顺序）、ipv4 或 ipv6
+	FallbackDelay   int      `yaml:"fallback_delay"`   // 开始连接另一地址族前等待的时间（毫秒）
+}
+
+// 🔧 新增：上游拨号（沙箱、proxy 目标、LLM 上游、沙箱健康检查）共用的 DNS 解析器：
+// servers 为空时使用系统解析（含 /etc/hosts 和 search 域），结果缓存 default_ttl 秒；
+// 配置了 servers 时直接查询这些服务器，按记录的 TTL（限制在 min_ttl 与 max_ttl 之间）缓存
+type DNSConfig struct {
+	Servers     []string          `yaml:"servers"`      // DNS 服务器（host 或 host:port），按顺序尝试
+	DefaultTTL  int               `yaml:"default_ttl"`  // 系统解析结果的缓存时间（秒），0 表示不缓存
+	MinTTL      int               `yaml:"min_ttl"`      // 记录 TTL 的下限（秒）
+	MaxTTL      int               `yaml:"max_ttl"`      // 记录 TTL 的上限（秒）
+	NegativeTTL int               `yaml:"negative_ttl"` // 域名不存在时的缓存时间（秒），0 表示不缓存
+	Timeout     int               `yaml:"timeout"`      // 单次查询超时（毫秒）
+	Overrides   map[string]string `yaml:"overrides"`    // 静态解析：域名 -> IP（多个地址用逗号分隔），优先于 DNS
+}
+
+// 🔧 新增：沙箱实例注册、删除或被移除时，除发布 SANDBOX_CHANGE 事件和转发日志外，还可以推送给 webhook
+type SandboxEventsConfig struct {
+	Webhooks []SandboxWebhookConfig `yaml:"webhooks"`
+}
+
+type SandboxWebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // 值支持密钥引用：env:NAME、file:/path、secret:NAME
+	Actions []string          `yaml:"actions"` // 推送的变化：registered、removed、evicted，为空时全部推送
+	Timeout int               `yaml:"timeout"` // 秒
+}
+
+// 网关端口 TLS：client_auth 为 request（有证书时校验）或 require（必须提供证书）时启用 mTLS，
+// 校验通过的客户端证书身份以请求头转发给沙箱和代理上游
+type GatewayTLSConfig struct {
+	Enabled           bool                    `yaml:"enabled"`
+	CertFile          string                  `yaml:"cert_file"`
+	KeyFile           string                  `yaml:"key_file"`
+	ClientAuth        string                  `yaml:"client_auth"`    // none、request、require
+	ClientCAFile      string                  `yaml:"client_ca_file"` // 校验客户端证书的 CA
+	ClientCertHeaders ClientCertHeadersConfig `yaml:"client_cert_headers"`
+}
+
+// 客户端证书身份请求头，为空的项不转发；客户端自带的同名请求头总是被移除
+type ClientCertHeadersConfig struct {
+	Subject     string `yaml:"subject"`     // 证书主题 DN
+	SAN         string `yaml:"san"`         // 主题备用名称（DNS、URI、email、IP）
+	Fingerprint string `yaml:"fingerprint"` // 证书 SHA-256 指纹
+}
+
+// 收到 SIGTERM/SIGINT 后 /readyz 立即返回 503（/healthz 仍为 200），等待 drain_period 让负载均衡摘除实例后再关闭监听
+type ShutdownConfig struct {
+	DrainPeriod int `yaml:"drain_period"` // 排空等待时间（秒）
+	Timeout     int `yaml:"timeout"`      // 关闭监听后等待进行中请求完成的最长时间（秒）
+}
+
+// 🔧 新增：/admin/health 与 /readyz 共用的健康策略。组件（redis、event_consumers、sandbox_pool、config_staleness）
+// 为 unhealthy 时，critical 中的组件使整体为 unhealthy（返回 503），其他组件使整体为 degraded
+type HealthConfig struct {
+	Critical           []string `yaml:"critical"`             // 关键组件，默认为空（依赖异常时仍接收流量）
+	MaxConfigStaleness int      `yaml:"max_config_staleness"` // 超过该秒数没有成功检查配置时 config_staleness 为 unhealthy，0 表示 3 倍同步间隔
+}
+
+// 默认路由：替代内置的 404 响应，例如转发到旧系统或返回自定义 404
+type DefaultRouteConfig struct {
+	Host    string `yaml:"host"`     // 请求主机名（不含端口），支持 *.example.com；* 匹配所有主机
+	RouteID string `yaml:"route_id"` // 处理请求的路由
+}
+
+// 方法覆盖：只能通过 POST 的客户端用请求头指定实际方法，覆盖在路由匹配前生效并记入审计日志
+type MethodOverrideConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Header  string   `yaml:"header"`  // 覆盖请求头
+	Methods []string `yaml:"methods"` // 允许覆盖为的方法，其他方法返回 400
+}
+
+// 请求路径规范化：. 和 .. 段始终被去除，其余策略可配置
+type PathNormalizationConfig struct {
+	CollapseSlashes bool   `yaml:"collapse_slashes"` // 合并连续斜杠
+	TrailingSlash   string `yaml:"trailing_slash"`   // strict（按字面匹配）、ignore（忽略末尾斜杠）、redirect（308 重定向到可匹配的形式）
+	CaseInsensitive bool   `yaml:"case_insensitive"` // 路由匹配忽略大小写（转发的路径不变）
+	PercentDecoding string `yaml:"percent_decoding"` // decode（解码后匹配）、preserve-slash（%2F 不作为分隔符）、raw（按编码路径匹配）
+}
+
+// 租户解析：访问令牌中的租户声明优先，请求头只能与之一致；解析出的租户用于路由匹配、LLM 预算和用量统计
+type TenancyConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Header   string `yaml:"header"`    // 租户请求头
+	JWTClaim string `yaml:"jwt_claim"` // 访问令牌中的租户声明
+	Required bool   `yaml:"required"`  // 未解析出租户的请求返回 400
+}
+
+// LLM 响应缓存：exact 模式只使用响应存储，semantic 模式额外需要 embedding 接口和向量存储
+type LLMCacheConfig struct {
+	VectorStore string             `yaml:"vector_store"` // memory（默认）或 redis（所有网关实例共享）
+	MaxEntries  int                `yaml:"max_entries"`  // 每个路由保留的最大向量条目数，默认 10000
+	Embedding   LLMEmbeddingConfig `yaml:"embedding"`
+}
+
+// 计算 prompt 向量使用的 OpenAI 兼容 embeddings 接口
+type LLMEmbeddingConfig struct {
+	Target  string `yaml:"target"`   // 基础地址，请求 {target}/v1/embeddings
+	KeyPool string `yaml:"key_pool"` // gateway.llm_key_pools 中的池名称
+	Model   string `yaml:"model"`    // 如 text-embedding-3-small
+}
+
+// LLM 上游 Key 池：轮换使用，按 Key 限流，失败的 Key 冷却一段时间
+type LLMKeyPoolConfig struct {
+	Name       string         `yaml:"name"`
+	Keys       []LLMKeyConfig `yaml:"keys"`
+	Cooldown   int            `yaml:"cooldown"`    // 429/401/403 后的冷却时间（秒），429 优先使用 Retry-After
+	AuthHeader string         `yaml:"auth_header"` // 默认 Authorization（Bearer），也可设为 api-key 等（原样传递 Key）
+}
+
+// 上游 Key
+type LLMKeyConfig struct {
+	Key string `yaml:"key"` // 密钥引用：env:NAME、file:/path、secret:NAME
+	RPM int    `yaml:"rpm"` // 每分钟请求上限（所有网关实例合计），0 表示不限制
+}
+
+// Dify 集成：以 Dify 自定义工具（OpenAPI）和 API 扩展的约定调用路由
+type DifyIntegrationConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	PathPrefix string `yaml:"path_prefix"` // 网关端口上的接口前缀
+}
+
+// 网关实例自注册配置：实例定时把地址、版本和配置版本写入 Redis
+type DiscoveryConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	AdvertiseAddress string `yaml:"advertise_address"` // 对外地址（主机名、IPv4 或 IPv6），为空时使用主机名
+	TTL              int    `yaml:"ttl"`               // 注册有效期（秒），超过未续约视为下线
+}
+
+// OAuth2 client_credentials 配置：机器客户端用凭证换取短期访问令牌
+type OAuthConfig struct {
+	Enabled       bool                `yaml:"enabled"`
+	TokenPath     string              `yaml:"token_path"`     // 令牌端点路径（网关端口）
+	TokenTTL      int                 `yaml:"token_ttl"`      // 令牌有效期（秒）
+	Issuer        string              `yaml:"issuer"`         // 令牌签发者
+	SigningSecret string              `yaml:"signing_secret"` // 令牌签名密钥引用，所有网关实例需一致
+	Clients       []OAuthClientConfig `yaml:"clients"`
+}
+
+// OAuth2 客户端
+type OAuthClientConfig struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"` // 密钥引用：env:NAME、file:/path、secret:NAME
+	Scopes       []string `yaml:"scopes"`
+	Tenant       string   `yaml:"tenant"` // 签发的令牌携带的租户（gateway.tenancy.jwt_claim）
+}
+
+// 客户端请求签名配置：客户端用共享密钥对请求签名，网关校验签名、时间窗口与 nonce 防重放
+type RequestSigningConfig struct {
+	Mode    string             `yaml:"mode"`     // off、optional（携带签名时校验）、required（必须签名）
+	MaxSkew int                `yaml:"max_skew"` // 时间戳允许的最大偏差（秒）
+	Keys    []SigningKeyConfig `yaml:"keys"`
+}
+
+// 客户端签名密钥
+type SigningKeyConfig struct {
+	KeyID      string `yaml:"key_id"`
+	Secret     string `yaml:"secret"` // 密钥引用：env:NAME、file:/path、secret:NAME
+	RouteScope `yaml:",inline"`
+}
+
+// 路由访问范围，全部为空表示不限制
+type RouteScope struct {
+	RouteIDs     []string `yaml:"route_ids"`
+	PathPrefixes []string `yaml:"path_prefixes"`
+	Groups       []string `yaml:"groups"` // 匹配路由 metadata.group
+}
+
+// 消费者 API Key：只能调用访问范围内的路由
+type APIKeyConfig struct {
+	Name       string `yaml:"name"`
+	Key        string `yaml:"key"`        // 明文 Key（兼容旧配置，建议改用 key_hash）
+	KeyPrefix  string `yaml:"key_prefix"` // Key 前缀，用于快速定位
+	KeyHash    string `yaml:"key_hash"`   // hex(HMAC-SHA256(pepper, key))
+	RouteScope `yaml:",inline"`
+
+	MaxConcurrency int `yaml:"max_concurrency"` // 🔧 新增：每个网关实例上进行中请求的上限，超出返回 429；0 表示不限制
+}
+
+// 没有健康沙箱（或都达到并发上限）时，请求最多等待 max_wait 秒，期间健康检查或扩容恢复容量即继续处理；
+// 超时后返回 503 和 Retry-After
+type SandboxWaitConfig struct {
+	MaxWait    int `yaml:"max_wait"`    // 最长等待（秒），0 表示立即返回 503
+	RetryAfter int `yaml:"retry_after"` // 等待超时后 Retry-After 的秒数
+}
+
+// 路由超时后向沙箱发送取消请求（POST，请求体 {"execution_id": "..."}），释放仍在执行的沙箱 worker。
+// 沙箱注册时的 cancel_path 优先，未设置时使用 default_path，都为空时不发送
+type ExecutionCancelConfig struct {
+	DefaultPath string `yaml:"default_path"` // 沙箱取消接口的默认路径，如 /cancel
+	Timeout     int    `yaml:"timeout"`      // 取消请求的超时（秒）
+}
+
+// 自适应并发限制（AIMD）：延迟在无负载延迟的 latency_tolerance 倍以内时逐步提高上限，延迟超出或上游过载时乘以 backoff
+type AdaptiveConcurrencyConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	InitialLimit     int     `yaml:"initial_limit"`
+	MinLimit         int     `yaml:"min_limit"`
+	MaxLimit         int     `yaml:"max_limit"`
+	LatencyTolerance float64 `yaml:"latency_tolerance"`
+	Backoff          float64 `yaml:"backoff"`
+}
+
+// HTTP Basic 认证：密码只保存 bcrypt 哈希（配置了 api_key_pepper 时对 HMAC-SHA256(pepper, password) 的 hex 做 bcrypt）
+type BasicAuthConfig struct {
+	Realm       string                      `yaml:"realm"` // WWW-Authenticate 中的 realm
+	Credentials []BasicAuthCredentialConfig `yaml:"credentials"`
+}
+
+type BasicAuthCredentialConfig struct {
+	Name         string `yaml:"name"` // 凭据名称，路由 auth.credentials 按名称引用
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password_hash"` // bcrypt(password)，配置了 pepper 时为 bcrypt(hex(HMAC-SHA256(pepper, password)))
 }
 
 // Redis配置
@@ -36,19 +435,130 @@ type RedisConfig struct {
 	Addr     string `yaml:"addr"`
 	Password string `yaml:"password"`
 	DB       int    `yaml:"db"`
+
+	// 启动时 Redis 不可用的处理：fail（启动失败）、wait（重试等待）、degraded（内存模式运行）
+	Startup              string `yaml:"startup"`
+	StartupTimeout       int    `yaml:"startup_timeout"`        // wait 模式的最长等待时间（秒），0 表示一直等待
+	StartupRetryInterval int    `yaml:"startup_retry_interval"` // wait 模式的重试间隔（秒）
+}
+
+// 备份配置
+type BackupConfig struct {
+	Enabled     bool           `yaml:"enabled"`
+	Interval    int            `yaml:"interval"`      // 备份间隔（秒）
+	Retention   int            `yaml:"retention"`     // 保留的备份数量，0 表示不限制
+	MaxAgeHours int            `yaml:"max_age_hours"` // 备份最长保留时间（小时），0 表示不限制
+	Storage     string         `yaml:"storage"`       // "local" 或 "s3"
+	LocalDir    string         `yaml:"local_dir"`
+	S3          S3BackupConfig `yaml:"s3"`
+}
+
+// S3 备份存储配置（兼容 S3 协议的对象存储）
+type S3BackupConfig struct {
+	Endpoint  string `yaml:"endpoint"` // 为空时使用 https://s3.<region>.amazonaws.com
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
 }
 
 type DifySandboxGlobalConfigurations struct {
-	App           AppConfig     `yaml:"app"`
-	MaxWorkers    int           `yaml:"max_workers"`
-	MaxRequests   int           `yaml:"max_requests"`
-	WorkerTimeout int           `yaml:"worker_timeout"`
-	EnableNetwork bool          `yaml:"enable_network"`
-	EnablePreload bool          `yaml:"enable_preload"`
-	AllowedSyscalls []string    `yaml:"allowed_syscalls"`
-	Proxy         ProxyConfig   `yaml:"proxy"`
-	Gateway       GatewayConfig `yaml:"gateway"`
-	Redis         RedisConfig   `yaml:"redis"`
+	App             AppConfig           `yaml:"app"`
+	MaxWorkers      int                 `yaml:"max_workers"`
+	MaxRequests     int                 `yaml:"max_requests"`
+	WorkerTimeout   int                 `yaml:"worker_timeout"`
+	EnableNetwork   bool                `yaml:"enable_network"`
+	EnablePreload   bool                `yaml:"enable_preload"`
+	AllowedSyscalls []string            `yaml:"allowed_syscalls"`
+	Proxy           ProxyConfig         `yaml:"proxy"`
+	Gateway         GatewayConfig       `yaml:"gateway"`
+	Redis           RedisConfig         `yaml:"redis"`
+	Backup          BackupConfig        `yaml:"backup"`
+	Admin           AdminConfig         `yaml:"admin"`
+	LogForwarding   LogForwardingConfig `yaml:"log_forwarding"`
+	Telemetry       TelemetryConfig     `yaml:"telemetry"`
+}
+
+// 遥测导出配置
+type TelemetryConfig struct {
+	ServiceName string       `yaml:"service_name"`
+	OTLP        OTLPConfig   `yaml:"otlp"`
+	StatsD      StatsDConfig `yaml:"statsd"`
+}
+
+// StatsD/DogStatsD 导出（UDP）
+type StatsDConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Address       string   `yaml:"address"`        // 如 127.0.0.1:8125
+	Prefix        string   `yaml:"prefix"`         // 指标名前缀，如 dify_router.
+	Tags          []string `yaml:"tags"`           // 全局标签，如 env:prod
+	TagFormat     string   `yaml:"tag_format"`     // dogstatsd（|#k:v）或 none（不发送标签）
+	FlushInterval int      `yaml:"flush_interval"` // 数据包发送间隔（毫秒）
+	MaxPacketSize int      `yaml:"max_packet_size"`
+	GaugeInterval int      `yaml:"gauge_interval"` // 路由数等状态指标上报间隔（秒）
+}
+
+// OTLP/HTTP 导出（JSON 编码），适配 OpenTelemetry Collector
+type OTLPConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Endpoint string            `yaml:"endpoint"` // 如 http://otel-collector:4318
+	Headers  map[string]string `yaml:"headers"`  // 值支持密钥引用
+	Metrics  bool              `yaml:"metrics"`  // 导出指标
+	Logs     bool              `yaml:"logs"`     // 导出访问/审计日志
+	Traces   bool              `yaml:"traces"`   // 🔧 新增：导出网关请求的追踪 span
+	Interval int               `yaml:"interval"` // 指标导出间隔（秒）
+	Timeout  int               `yaml:"timeout"`  // 请求超时（秒）
+	// 🔧 新增：默认追踪采样率（0-1），可通过管理接口按路由调整
+	TraceSampleRate float64 `yaml:"trace_sample_rate"`
+}
+
+// 访问日志与审计日志转发（SIEM 接入）
+type LogForwardingConfig struct {
+	Enabled       bool             `yaml:"enabled"`
+	AccessLog     bool             `yaml:"access_log"`     // 转发网关端口访问日志
+	AuditLog      bool             `yaml:"audit_log"`      // 转发管理端口修改操作审计日志
+	BufferSize    int              `yaml:"buffer_size"`    // 发送队列长度，队列满时丢弃
+	BatchSize     int              `yaml:"batch_size"`     // 单批最大事件数
+	FlushInterval int              `yaml:"flush_interval"` // 批量发送间隔（毫秒）
+	Syslog        SyslogSinkConfig `yaml:"syslog"`
+	HTTP          HTTPSinkConfig   `yaml:"http"`
+}
+
+// Syslog 输出（RFC 5424）
+type SyslogSinkConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	Network            string `yaml:"network"` // udp、tcp、tcp+tls
+	Address            string `yaml:"address"`
+	Facility           int    `yaml:"facility"` // 默认 13（log audit）
+	AppName            string `yaml:"app_name"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// HTTP 日志投递（POST JSON 数组）
+type HTTPSinkConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // 值支持密钥引用：env:NAME、file:/path、secret:NAME
+	Timeout int               `yaml:"timeout"` // 秒
+}
+
+// 管理端口浏览器访问安全配置
+type AdminConfig struct {
+	AllowedOrigins []string   `yaml:"allowed_origins"` // 允许发起修改类管理请求的浏览器来源，同源请求始终允许
+	CSRFEnabled    bool       `yaml:"csrf_enabled"`    // 浏览器发起的修改类请求需携带 CSRF 令牌
+	CSRFTokenTTL   int        `yaml:"csrf_token_ttl"`  // CSRF 令牌有效期（秒）
+	CORS           CORSConfig `yaml:"cors"`            // 管理端口 CORS，与网关端口独立
+}
+
+// CORS 配置
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // "*" 表示任意来源
+	AllowCredentials bool     `yaml:"allow_credentials"` // 仅对显式列出的来源生效
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	MaxAge           int      `yaml:"max_age"` // 预检结果缓存时间（秒）
 }
 
 var (
@@ -70,11 +580,11 @@ func InitConfig(configPath string) error {
 			Debug: true,
 			Key:   "dify-sandbox",
 		},
-		MaxWorkers:     4,
-		MaxRequests:    50,
-		WorkerTimeout:  5,
-		EnableNetwork:  true,
-		EnablePreload:  false,
+		MaxWorkers:      4,
+		MaxRequests:     50,
+		WorkerTimeout:   5,
+		EnableNetwork:   true,
+		EnablePreload:   false,
 		AllowedSyscalls: []string{},
 		Proxy: ProxyConfig{
 			Socks5: "",
@@ -83,15 +593,190 @@ func InitConfig(configPath string) error {
 		},
 		Gateway: GatewayConfig{
 			Port:                 8080,
-			RedisAddr:           "localhost:6379",
+			RedisAddr:            "localhost:6379",
 			LoadBalancerStrategy: "least-connections",
+			CostSpillLoad:        4,
 			HealthCheckInterval:  15,
+			HealthCheckMode:      "leader",
 			CorsEnabled:          true,
+			MaxCodeSize:          1 << 20,
+			MaxCacheMemory:       0,
+			LazyCodeThreshold:    64 << 10,
+			CodeCacheMemory:      64 << 20,
+			MatchCacheSize:       10000,
+			RetryAttempts:        2,
+			CORS: CORSConfig{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-Requested-With", "X-Api-Key", "X-Tenant-ID"},
+				MaxAge:         600,
+			},
+			SandboxWait: SandboxWaitConfig{
+				MaxWait:    0,
+				RetryAfter: 5,
+			},
+			ExecutionCancel: ExecutionCancelConfig{
+				Timeout: 2,
+			},
+			AdaptiveConcurrency: AdaptiveConcurrencyConfig{
+				Enabled:          false,
+				InitialLimit:     20,
+				MinLimit:         1,
+				MaxLimit:         200,
+				LatencyTolerance: 2.0,
+				Backoff:          0.9,
+			},
+			RequestSigning: RequestSigningConfig{
+				Mode:    "off",
+				MaxSkew: 300,
+			},
+			OAuth: OAuthConfig{
+				Enabled:   false,
+				TokenPath: "/oauth/token",
+				TokenTTL:  3600,
+				Issuer:    "dify-router",
+			},
+			BasicAuth: BasicAuthConfig{
+				Realm: "dify-router",
+			},
+			Discovery: DiscoveryConfig{
+				Enabled: true,
+				TTL:     30,
+			},
+			Dify: DifyIntegrationConfig{
+				Enabled:    false,
+				PathPrefix: "/dify",
+			},
+			EventPublish: EventPublishConfig{
+				Mode:          "fire-and-forget",
+				RetryInterval: 5,
+				MaxEvents:     10000,
+			},
+			Sync: SyncConfig{
+				Interval: 60,
+				Jitter:   10,
+				Poll:     "always",
+			},
+			RouteExpiry: RouteExpiryConfig{
+				SweepInterval: 30,
+			},
+			Network: NetworkConfig{
+				PreferredFamily: "auto",
+				FallbackDelay:   300,
+			},
+			DNS: DNSConfig{
+				DefaultTTL:  30,
+				MinTTL:      5,
+				MaxTTL:      300,
+				NegativeTTL: 10,
+				Timeout:     2000,
+			},
+			Tunnel: TunnelConfig{
+				Enabled:        false,
+				Path:           "/_tunnel",
+				MaxConnections: 16,
+				Grace:          30,
+			},
+			TLS: GatewayTLSConfig{
+				ClientAuth: "none",
+				ClientCertHeaders: ClientCertHeadersConfig{
+					Subject:     "X-Client-Cert-Subject",
+					SAN:         "X-Client-Cert-SAN",
+					Fingerprint: "X-Client-Cert-Fingerprint",
+				},
+			},
+			Shutdown: ShutdownConfig{
+				DrainPeriod: 15,
+				Timeout:     30,
+			},
+			MethodOverride: MethodOverrideConfig{
+				Enabled: false,
+				Header:  "X-HTTP-Method-Override",
+				Methods: []string{"PUT", "PATCH", "DELETE"},
+			},
+			PathNormalization: PathNormalizationConfig{
+				CollapseSlashes: true,
+				TrailingSlash:   "strict",
+				PercentDecoding: "decode",
+			},
+			Tenancy: TenancyConfig{
+				Enabled:  false,
+				Header:   "X-Tenant-ID",
+				JWTClaim: "tenant",
+			},
+			Policy: PolicyConfig{
+				TimeoutMs: 200,
+			},
+			RouteEncryption: RouteEncryptionConfig{
+				Enabled: false,
+				Fields:  []string{"code", "metadata"},
+			},
+			OperationalLogs: OperationalLogConfig{
+				DedupWindow: 60,
+				RateLimit:   20,
+			},
 		},
 		Redis: RedisConfig{
 			Addr:     "localhost:6379",
 			Password: "",
 			DB:       0,
+
+			Startup:              "degraded",
+			StartupTimeout:       60,
+			StartupRetryInterval: 2,
+		},
+		Backup: BackupConfig{
+			Enabled:   false,
+			Interval:  3600,
+			Retention: 24,
+			Storage:   "local",
+			LocalDir:  "backups",
+		},
+		LogForwarding: LogForwardingConfig{
+			Enabled:       false,
+			AccessLog:     true,
+			AuditLog:      true,
+			BufferSize:    10000,
+			BatchSize:     100,
+			FlushInterval: 1000,
+			Syslog: SyslogSinkConfig{
+				Network:  "udp",
+				Facility: 13,
+				AppName:  "dify-router",
+			},
+			HTTP: HTTPSinkConfig{
+				Timeout: 10,
+			},
+		},
+		Telemetry: TelemetryConfig{
+			ServiceName: "dify-router",
+			OTLP: OTLPConfig{
+				Endpoint:        "http://localhost:4318",
+				Metrics:         true,
+				Logs:            true,
+				TraceSampleRate: 0.01,
+				Interval:        15,
+				Timeout:         10,
+			},
+			StatsD: StatsDConfig{
+				Address:       "127.0.0.1:8125",
+				Prefix:        "dify_router.",
+				TagFormat:     "dogstatsd",
+				FlushInterval: 100,
+				MaxPacketSize: 1432,
+				GaugeInterval: 10,
+			},
+		},
+		Admin: AdminConfig{
+			AllowedOrigins: []string{},
+			CSRFEnabled:    true,
+			CSRFTokenTTL:   43200,
+			CORS: CORSConfig{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-Requested-With", "X-Api-Key", "X-CSRF-Token"},
+				MaxAge:         600,
+			},
 		},
 	}
 
@@ -104,9 +789,8 @@ func InitConfig(configPath string) error {
 	return nil
 }
 
-
 func GetDifySandboxGlobalConfigurations() *DifySandboxGlobalConfigurations {
 	configMutex.RLock()
 	defer configMutex.RUnlock()
 	return globalConfig
-}
\ No newline at end of file
+}
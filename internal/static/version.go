@@ -0,0 +1,69 @@
+package static
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 构建信息，编译时注入：
+//
+//	go build -ldflags "-X github.com/dify-router/dify-router/internal/static.Version=1.2.0 \
+//	  -X github.com/dify-router/dify-router/internal/static.GitCommit=$(git rev-parse --short HEAD) \
+//	  -X github.com/dify-router/dify-router/internal/static.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" cmd/server/main.go
+//
+// 未注入时 GitCommit 和 BuildTime 取 Go 工具链记录的 VCS 信息
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildTime = ""
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // 构建时工作区有未提交的修改
+}
+
+var buildInfo = loadBuildInfo()
+
+func loadBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.GitCommit == "" {
+					info.GitCommit = setting.Value
+					if len(info.GitCommit) > 12 {
+						info.GitCommit = info.GitCommit[:12]
+					}
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+// 当前二进制的构建信息
+func GetBuildInfo() BuildInfo {
+	return buildInfo
+}
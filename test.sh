@@ -1,3 +1,7 @@
 # xai-router
-go build -o main -ldflags="-s -w" cmd/server/main.go
+# 构建信息（启动日志、/admin/version、健康检查和指标标签），VERSION 未设置时取 git describe
+VERSION_PKG=github.com/dify-router/dify-router/internal/static
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+VERSION_LDFLAGS="-X ${VERSION_PKG}.Version=${VERSION} -X ${VERSION_PKG}.GitCommit=$(git rev-parse --short HEAD 2>/dev/null) -X ${VERSION_PKG}.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+go build -o main -ldflags="-s -w ${VERSION_LDFLAGS}" cmd/server/main.go
 ./main
@@ -0,0 +1,82 @@
+#!/bin/bash
+
+# XAI Router Gateway 路由热路径性能回归检查
+# 运行 internal/gateway 下的 Benchmark，并与 bench_thresholds.txt 中的阈值比较
+
+SCRIPT_DIR=$(cd "$(dirname "$0")" && pwd)
+ROOT_DIR=$(cd "$SCRIPT_DIR/.." && pwd)
+THRESHOLDS="$SCRIPT_DIR/bench_thresholds.txt"
+BENCH_TIME=${BENCH_TIME:-1s}
+BENCH_OUTPUT="$ROOT_DIR/bench_output.txt"
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+print_success() {
+    echo -e "${GREEN}✅ $1${NC}"
+}
+
+print_error() {
+    echo -e "${RED}❌ $1${NC}"
+}
+
+print_info() {
+    echo -e "${YELLOW}ℹ️  $1${NC}"
+}
+
+print_info "运行路由热路径 Benchmark (benchtime=$BENCH_TIME)"
+cd "$ROOT_DIR" || exit 1
+if ! go test -run '^$' -bench . -benchmem -benchtime="$BENCH_TIME" ./internal/gateway/ | tee "$BENCH_OUTPUT"; then
+    print_error "Benchmark 执行失败"
+    exit 1
+fi
+
+failed=0
+while read -r name max_ns; do
+    case "$name" in
+        ''|'#'*) continue ;;
+    esac
+
+    actual=$(awk -v n="$name" '$1 ~ "^"n"(-[0-9]+)?$" {print $3}' "$BENCH_OUTPUT" | head -1)
+    if [ -z "$actual" ]; then
+        print_error "$name: 未找到 Benchmark 结果"
+        failed=1
+        continue
+    fi
+
+    if awk -v a="$actual" -v m="$max_ns" 'BEGIN {exit !(a > m)}'; then
+        print_error "$name: ${actual} ns/op 超过阈值 ${max_ns} ns/op"
+        failed=1
+    else
+        print_success "$name: ${actual} ns/op (阈值 ${max_ns} ns/op)"
+    fi
+done < "$THRESHOLDS"
+
+# 命中匹配缓存不应慢于直接查找路径索引（相同请求，允许 5% 的测量抖动）
+while read -r cached direct; do
+    cached_ns=$(awk -v n="$cached" '$1 ~ "^"n"(-[0-9]+)?$" {print $3}' "$BENCH_OUTPUT" | head -1)
+    direct_ns=$(awk -v n="$direct" '$1 ~ "^"n"(-[0-9]+)?$" {print $3}' "$BENCH_OUTPUT" | head -1)
+    if [ -z "$cached_ns" ] || [ -z "$direct_ns" ]; then
+        print_error "$cached / $direct: 未找到 Benchmark 结果"
+        failed=1
+        continue
+    fi
+
+    if awk -v c="$cached_ns" -v d="$direct_ns" 'BEGIN {exit !(c > d * 1.05)}'; then
+        print_error "$cached: ${cached_ns} ns/op 慢于 $direct ${direct_ns} ns/op"
+        failed=1
+    else
+        print_success "$cached: ${cached_ns} ns/op 不慢于 $direct ${direct_ns} ns/op"
+    fi
+done <<EOF
+BenchmarkMatchRouteCached BenchmarkMatchRouteParam
+BenchmarkMatchRouteCachedParallel BenchmarkMatchRouteParallel
+EOF
+
+if [ $failed -ne 0 ]; then
+    print_error "检测到性能回归"
+    exit 1
+fi
+print_success "路由热路径性能检查通过"
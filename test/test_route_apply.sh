@@ -0,0 +1,101 @@
+#!/bin/bash
+
+# XAI Router Gateway 声明式 apply 测试脚本
+# 文档有错误（重复的路由ID）时整体拒绝，路由表保持不变
+
+echo "🚀 XAI Router Gateway 声明式 apply 测试"
+echo "=========================================="
+
+MANAGEMENT_URL="http://localhost:8195/admin"
+ADMIN_API_KEY="xai-admin-key"
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+print_success() {
+    echo -e "${GREEN}✅ $1${NC}"
+}
+
+print_error() {
+    echo -e "${RED}❌ $1${NC}"
+}
+
+print_info() {
+    echo -e "${YELLOW}ℹ️  $1${NC}"
+}
+
+failed=0
+
+# 当前路由表（按ID排序的 id/path/method，用于比较）
+route_table() {
+    curl -s -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes" \
+        | jq -c '[.routes[] | {id, path, method, handler}] | sort_by(.id)'
+}
+
+cleanup() {
+    for id in test-apply-a test-apply-b test-apply-c; do
+        curl -s -o /dev/null -X DELETE -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/$id"
+    done
+}
+
+# 0. 检查服务
+print_info "0. 检查服务状态"
+http_code=$(curl -s -o /dev/null -w "%{http_code}" -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/health")
+if [ "$http_code" != "200" ]; then
+    print_error "管理服务连接失败 (HTTP $http_code)"
+    exit 1
+fi
+command -v jq >/dev/null || { print_error "需要 jq"; exit 1; }
+
+# 1. 准备路由
+echo ""
+print_info "1. 创建测试路由"
+cleanup
+response=$(curl -s -X POST -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/batch" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [
+        {"op": "create", "route": {"id": "test-apply-a", "path": "/api/test-apply-a", "method": "GET", "handler": "echo"}},
+        {"op": "create", "route": {"id": "test-apply-b", "path": "/api/test-apply-b", "method": "GET", "handler": "echo"}}]}')
+echo "创建响应: $response"
+before=$(route_table)
+
+# 2. 重复的路由ID：整体拒绝。文档中没有 test-apply-b，也不能被删除
+echo ""
+print_info "2. 提交包含重复路由ID的文档"
+response=$(curl -s -w "\n%{http_code}" -X POST -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/apply" \
+  -H "Content-Type: application/json" \
+  -d '{"routes": [
+        {"id": "test-apply-a", "path": "/api/test-apply-a2", "method": "GET", "handler": "echo"},
+        {"id": "test-apply-c", "path": "/api/test-apply-c", "method": "GET", "handler": "echo"},
+        {"id": "test-apply-c", "path": "/api/test-apply-c", "method": "GET", "handler": "echo"}]}')
+http_code=$(echo "$response" | tail -1)
+echo "响应: $(echo "$response" | head -n -1)"
+if [ "$http_code" = "400" ]; then
+    print_success "重复路由ID返回 400"
+else
+    print_error "重复路由ID应返回 400，实际为 HTTP $http_code"
+    failed=1
+fi
+
+after=$(route_table)
+if [ "$before" = "$after" ]; then
+    print_success "路由表未改变"
+else
+    print_error "被拒绝的 apply 修改了路由表"
+    echo "之前: $before"
+    echo "之后: $after"
+    failed=1
+fi
+
+# 3. 清理
+echo ""
+print_info "3. 清理测试路由"
+cleanup
+
+if [ $failed -ne 0 ]; then
+    print_error "声明式 apply 测试失败"
+    exit 1
+fi
+print_success "🎉 声明式 apply 测试通过！"
@@ -0,0 +1,102 @@
+#!/bin/bash
+
+# XAI Router Gateway 路由批量变更测试脚本
+# 批量变更中任何一个操作出错时整批拒绝，路由表保持不变
+
+echo "🚀 XAI Router Gateway 路由批量变更测试"
+echo "=========================================="
+
+MANAGEMENT_URL="http://localhost:8195/admin"
+ADMIN_API_KEY="xai-admin-key"
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+print_success() {
+    echo -e "${GREEN}✅ $1${NC}"
+}
+
+print_error() {
+    echo -e "${RED}❌ $1${NC}"
+}
+
+print_info() {
+    echo -e "${YELLOW}ℹ️  $1${NC}"
+}
+
+failed=0
+
+# 当前路由表（按ID排序的 id/path/method，用于比较）
+route_table() {
+    curl -s -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes" \
+        | jq -c '[.routes[] | {id, path, method, handler}] | sort_by(.id)'
+}
+
+cleanup() {
+    for id in test-batch-a test-batch-b test-batch-c; do
+        curl -s -o /dev/null -X DELETE -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/$id"
+    done
+}
+
+# 0. 检查服务
+print_info "0. 检查服务状态"
+http_code=$(curl -s -o /dev/null -w "%{http_code}" -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/health")
+if [ "$http_code" != "200" ]; then
+    print_error "管理服务连接失败 (HTTP $http_code)"
+    exit 1
+fi
+command -v jq >/dev/null || { print_error "需要 jq"; exit 1; }
+
+# 1. 准备路由
+echo ""
+print_info "1. 创建测试路由"
+cleanup
+response=$(curl -s -X POST -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/batch" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [
+        {"op": "create", "route": {"id": "test-batch-a", "path": "/api/test-batch-a", "method": "GET", "handler": "echo"}},
+        {"op": "create", "route": {"id": "test-batch-b", "path": "/api/test-batch-b", "method": "GET", "handler": "echo"}}]}')
+echo "创建响应: $response"
+before=$(route_table)
+
+# 2. 最后一个操作失败（删除不存在的路由）：前面的新增、更新和删除都不能写入
+echo ""
+print_info "2. 提交包含失败操作的批量变更"
+response=$(curl -s -w "\n%{http_code}" -X POST -H "X-Api-Key: $ADMIN_API_KEY" "$MANAGEMENT_URL/routes/batch" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [
+        {"op": "create", "route": {"id": "test-batch-c", "path": "/api/test-batch-c", "method": "GET", "handler": "echo"}},
+        {"op": "update", "route": {"id": "test-batch-a", "path": "/api/test-batch-a2", "method": "GET", "handler": "echo"}},
+        {"op": "delete", "id": "test-batch-b"},
+        {"op": "delete", "id": "test-batch-missing"}]}')
+http_code=$(echo "$response" | tail -1)
+echo "响应: $(echo "$response" | head -n -1)"
+if [ "$http_code" = "400" ]; then
+    print_success "失败的批量变更返回 400"
+else
+    print_error "失败的批量变更应返回 400，实际为 HTTP $http_code"
+    failed=1
+fi
+
+after=$(route_table)
+if [ "$before" = "$after" ]; then
+    print_success "路由表未改变"
+else
+    print_error "被拒绝的批量变更修改了路由表"
+    echo "之前: $before"
+    echo "之后: $after"
+    failed=1
+fi
+
+# 3. 清理
+echo ""
+print_info "3. 清理测试路由"
+cleanup
+
+if [ $failed -ne 0 ]; then
+    print_error "路由批量变更测试失败"
+    exit 1
+fi
+print_success "🎉 路由批量变更测试通过！"